@@ -1,21 +1,31 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"blog-api/internal/config"
 	"blog-api/internal/database"
+	"blog-api/internal/events"
 	"blog-api/internal/handlers"
 	"blog-api/internal/models"
 
-	"github.com/gorilla/mux"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
@@ -23,10 +33,59 @@ import (
 	"github.com/stretchr/testify/suite"
 )
 
+// testAdminToken authorizes requests to the admin API in tests
+const testAdminToken = "test-admin-token"
+
+// testJWTSecret signs the access tokens issued in tests
+const testJWTSecret = "test-jwt-secret"
+
+// sentEmail is an email sent through recordingNotifier
+type sentEmail struct {
+	to, subject, body string
+}
+
+// recordingNotifier hands the emails sent through it to the test instead of
+// delivering them
+type recordingNotifier struct {
+	sent chan sentEmail
+}
+
+func (n *recordingNotifier) Send(_ context.Context, to, subject, body string) error {
+	n.sent <- sentEmail{to: to, subject: subject, body: body}
+	return nil
+}
+
+// updateRecorder collects the IDs of the posts announced by PostUpdated
+// events
+type updateRecorder struct {
+	mu  sync.Mutex
+	ids []int
+}
+
+func (u *updateRecorder) record(e events.Event) {
+	if e, ok := e.(events.PostUpdated); ok {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		u.ids = append(u.ids, e.Post.ID)
+	}
+}
+
+// take returns the IDs recorded so far and forgets them
+func (u *updateRecorder) take() []int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	ids := u.ids
+	u.ids = nil
+	return ids
+}
+
 type IntegrationTestSuite struct {
 	suite.Suite
-	server *httptest.Server
-	db     *database.DB
+	server   *httptest.Server
+	db       *database.DB
+	cfg      *config.Config
+	notifier *recordingNotifier
+	updates  *updateRecorder
 }
 
 func (suite *IntegrationTestSuite) SetupSuite() {
@@ -41,20 +100,46 @@ func (suite *IntegrationTestSuite) SetupSuite() {
 		DatabaseUser: getEnv("TEST_DB_USER", "postgres"),
 		DatabasePass: getEnv("TEST_DB_PASS", "password"),
 		DatabaseName: getEnv("TEST_DB_NAME", "blog_api_test"),
+		AdminToken:   testAdminToken,
+		JWTSecret:    testJWTSecret,
+
+		JWTExpiryMinutes:        60,
+		RefreshTokenExpiryHours: 24,
+
+		PasswordResetExpiryMinutes: 60,
+
+		MaxRequestBytes: 1 << 20,
+
+		DefaultContentFormat: models.ContentFormatMarkdown,
+		ExportBatchSize:      2,
 	}
 
+	suite.cfg = cfg
+
 	// Initialize test database
 	var err error
 	suite.db, err = database.New(cfg)
-	require.NoError(suite.T(), err)
+	if err != nil {
+		suite.T().Skipf("Test database not available: %v", err)
+	}
 
 	// Initialize handlers
-	userHandler := handlers.NewUserHandler(suite.db)
-	postHandler := handlers.NewPostHandler(suite.db)
-	healthHandler := handlers.NewHealthHandler(suite.db)
+	userHandler := handlers.NewUserHandler(suite.db, events.NewSyncBus(), cfg)
+	suite.updates = &updateRecorder{}
+	postBus := events.NewSyncBus()
+	postBus.Subscribe(suite.updates.record)
+	postHandler := handlers.NewPostHandler(suite.db, postBus, cfg)
+	monitor := handlers.NewHealthMonitor(suite.db, time.Minute)
+	monitor.Check(context.Background())
+	healthHandler := handlers.NewHealthHandler(suite.db, monitor)
+	healthHandler.SetReady(true)
+	webHandler := handlers.NewWebHandler(suite.db, postBus, cfg)
+	versionHandler := handlers.NewVersionHandler(handlers.BuildInfo{Version: "test"})
+	suite.notifier = &recordingNotifier{sent: make(chan sentEmail, 10)}
+	authHandler := handlers.NewAuthHandler(suite.db, cfg, suite.notifier)
 
 	// Setup test router
-	router := setupRouter(userHandler, postHandler, healthHandler)
+	router := setupRouter(cfg, suite.db, userHandler, postHandler, healthHandler, webHandler, versionHandler, authHandler)
 
 	// Create test server
 	suite.server = httptest.NewServer(router)
@@ -87,6 +172,16 @@ func (suite *IntegrationTestSuite) TestHealthEndpoint() {
 	assert.Equal(suite.T(), "healthy", response["status"])
 }
 
+func (suite *IntegrationTestSuite) TestHealthProbes() {
+	for _, path := range []string{"/health/live", "/health/ready"} {
+		resp, err := http.Get(suite.server.URL + path)
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode, path)
+	}
+}
+
 func (suite *IntegrationTestSuite) TestUserCRUDOperations() {
 	// Test Create User
 	userReq := models.UserRequest{
@@ -121,14 +216,263 @@ func (suite *IntegrationTestSuite) TestUserCRUDOperations() {
 
 	// Test Delete User
 	suite.deleteUser(createdUser.ID)
-	
+
+	// Deleting again is a no-op, so retries are safe
+	suite.deleteUser(createdUser.ID)
+
 	// Verify user is deleted
-	resp, err := http.Get(fmt.Sprintf("%s/users/%d", suite.server.URL, createdUser.ID))
+	resp, err := http.Get(fmt.Sprintf("%s/api/users/%d", suite.server.URL, createdUser.ID))
 	require.NoError(suite.T(), err)
 	defer resp.Body.Close()
 	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
 }
 
+func (suite *IntegrationTestSuite) TestCaseVariantDuplicateUser() {
+	suite.createUser(models.UserRequest{Username: "Alice", Email: "alice@example.com", Password: "password123"})
+
+	register := func(req models.UserRequest) (int, models.ErrorResponse) {
+		body, _ := json.Marshal(req)
+		resp, err := http.Post(suite.server.URL+"/api/users", "application/json", bytes.NewReader(body))
+		require.NoError(suite.T(), err)
+		defer resp.Body.Close()
+		var errResp models.ErrorResponse
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return resp.StatusCode, errResp
+	}
+
+	status, errResp := register(models.UserRequest{Username: "alice", Email: "other@example.com", Password: "password123"})
+	assert.Equal(suite.T(), http.StatusConflict, status)
+	assert.Contains(suite.T(), errResp.Message, "Username")
+
+	status, errResp = register(models.UserRequest{Username: "bob", Email: "ALICE@example.com", Password: "password123"})
+	assert.Equal(suite.T(), http.StatusConflict, status)
+	assert.Contains(suite.T(), errResp.Message, "Email")
+}
+
+func (suite *IntegrationTestSuite) TestUserUpdateRejectsProtectedFields() {
+	user := suite.createUser(models.UserRequest{Username: "plain", Email: "plain@example.com", Password: "password123"})
+
+	body := fmt.Sprintf(`{"username":"renamed","id":%d,"role":"admin","verified":true,"banned":true,"created_at":"2000-01-01T00:00:00Z"}`, user.ID+100)
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/users/%d", suite.server.URL, user.ID), bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	suite.authorize(req, user.ID)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(suite.T(), err)
+	resp.Body.Close()
+	require.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+
+	updated := suite.getUser(user.ID)
+	assert.Equal(suite.T(), "plain", updated.Username)
+	assert.Equal(suite.T(), user.ID, updated.ID)
+	assert.False(suite.T(), updated.Banned)
+	assert.True(suite.T(), user.CreatedAt.Equal(updated.CreatedAt))
+}
+
+func (suite *IntegrationTestSuite) TestLogin() {
+	user := suite.createUser(models.UserRequest{Username: "author", Email: "author@example.com", Password: "password123"})
+
+	resp := suite.login("author", "password123")
+	defer resp.Body.Close()
+	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	var login models.TokenResponse
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&login))
+	assert.NotEmpty(suite.T(), login.Token)
+	assert.True(suite.T(), login.ExpiresAt.After(time.Now()))
+
+	var claims struct {
+		UserID   int    `json:"user_id"`
+		Username string `json:"username"`
+		jwt.RegisteredClaims
+	}
+	_, err := jwt.ParseWithClaims(login.Token, &claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(testJWTSecret), nil
+	})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), user.ID, claims.UserID)
+	assert.Equal(suite.T(), "author", claims.Username)
+	assert.Equal(suite.T(), login.ExpiresAt.Unix(), claims.ExpiresAt.Unix())
+
+	for _, creds := range [][2]string{{"author", "wrong-password"}, {"nobody", "password123"}} {
+		resp := suite.login(creds[0], creds[1])
+		resp.Body.Close()
+		assert.Equal(suite.T(), http.StatusUnauthorized, resp.StatusCode, creds[0])
+	}
+}
+
+func (suite *IntegrationTestSuite) TestPasswordReset() {
+	suite.createUser(models.UserRequest{Username: "forgetful", Email: "forgetful@example.com", Password: "password123"})
+
+	resp := suite.login("forgetful", "password123")
+	var login models.TokenResponse
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&login))
+	resp.Body.Close()
+
+	post := func(path string, body interface{}) int {
+		data, _ := json.Marshal(body)
+		resp, err := http.Post(suite.server.URL+path, "application/json", bytes.NewBuffer(data))
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Unknown addresses get the same answer, but no email
+	assert.Equal(suite.T(), http.StatusOK, post("/api/auth/forgot-password", models.ForgotPasswordRequest{Email: "nobody@example.com"}))
+
+	// Outside DEV_MODE the token is only in the email
+	data, _ := json.Marshal(models.ForgotPasswordRequest{Email: "Forgetful@example.com"})
+	resp, err := http.Post(suite.server.URL+"/api/auth/forgot-password", "application/json", bytes.NewBuffer(data))
+	require.NoError(suite.T(), err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.NotContains(suite.T(), string(body), "reset_token")
+	assert.NotContains(suite.T(), string(body), `"data"`)
+
+	var email sentEmail
+	select {
+	case email = <-suite.notifier.sent:
+	case <-time.After(5 * time.Second):
+		suite.T().Fatal("no password reset email was sent")
+	}
+	assert.Equal(suite.T(), "forgetful@example.com", email.to)
+	assert.Equal(suite.T(), "Reset your password", email.subject)
+	assert.Empty(suite.T(), suite.notifier.sent, "only the registered address should get an email")
+
+	token := regexp.MustCompile(`(?m)^[A-Za-z0-9_-]{43}$`).FindString(email.body)
+	require.NotEmpty(suite.T(), token, "email should carry the reset token")
+
+	reset := models.PasswordResetRequest{Token: token, NewPassword: "new-password"}
+	require.Equal(suite.T(), http.StatusNoContent, post("/api/auth/reset-password", reset))
+
+	resp = suite.login("forgetful", "new-password")
+	resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	resp = suite.login("forgetful", "password123")
+	resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusUnauthorized, resp.StatusCode)
+
+	// Sessions from before the reset are logged out
+	assert.Equal(suite.T(), http.StatusUnauthorized, post("/api/auth/refresh", models.RefreshRequest{RefreshToken: login.RefreshToken}))
+
+	// The token only works once
+	reset.NewPassword = "another-password"
+	assert.Equal(suite.T(), http.StatusBadRequest, post("/api/auth/reset-password", reset))
+}
+
+func (suite *IntegrationTestSuite) TestRefreshTokenRotation() {
+	suite.createUser(models.UserRequest{Username: "refresher", Email: "refresher@example.com", Password: "password123"})
+
+	resp := suite.login("refresher", "password123")
+	defer resp.Body.Close()
+	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	var login models.TokenResponse
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&login))
+	require.NotEmpty(suite.T(), login.RefreshToken)
+
+	refresh := func(token string) (int, models.TokenResponse) {
+		body, _ := json.Marshal(models.RefreshRequest{RefreshToken: token})
+		resp, err := http.Post(suite.server.URL+"/api/auth/refresh", "application/json", bytes.NewBuffer(body))
+		require.NoError(suite.T(), err)
+		defer resp.Body.Close()
+
+		var tokens models.TokenResponse
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&tokens))
+		}
+		return resp.StatusCode, tokens
+	}
+
+	// Each refresh hands out a new refresh token
+	status, first := refresh(login.RefreshToken)
+	require.Equal(suite.T(), http.StatusOK, status)
+	assert.NotEmpty(suite.T(), first.Token)
+	assert.NotEqual(suite.T(), login.RefreshToken, first.RefreshToken)
+
+	status, second := refresh(first.RefreshToken)
+	require.Equal(suite.T(), http.StatusOK, status)
+
+	// Replaying a rotated token is rejected and logs out the whole chain
+	status, _ = refresh(login.RefreshToken)
+	assert.Equal(suite.T(), http.StatusUnauthorized, status)
+	status, _ = refresh(second.RefreshToken)
+	assert.Equal(suite.T(), http.StatusUnauthorized, status)
+
+	status, _ = refresh("never-issued")
+	assert.Equal(suite.T(), http.StatusUnauthorized, status)
+}
+
+func (suite *IntegrationTestSuite) TestLogout() {
+	suite.createUser(models.UserRequest{Username: "leaver", Email: "leaver@example.com", Password: "password123"})
+
+	loginAs := func() models.TokenResponse {
+		resp := suite.login("leaver", "password123")
+		defer resp.Body.Close()
+		require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+		var tokens models.TokenResponse
+		require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&tokens))
+		return tokens
+	}
+	logout := func(tokens models.TokenResponse, query string) int {
+		body, _ := json.Marshal(models.RefreshRequest{RefreshToken: tokens.RefreshToken})
+		req, _ := http.NewRequest("POST", suite.server.URL+"/api/auth/logout"+query, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+tokens.Token)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	refresh := func(tokens models.TokenResponse) int {
+		body, _ := json.Marshal(models.RefreshRequest{RefreshToken: tokens.RefreshToken})
+		resp, err := http.Post(suite.server.URL+"/api/auth/refresh", "application/json", bytes.NewBuffer(body))
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Logging out ends only that session
+	phone, laptop := loginAs(), loginAs()
+	require.Equal(suite.T(), http.StatusNoContent, logout(phone, ""))
+	assert.Equal(suite.T(), http.StatusUnauthorized, refresh(phone))
+	assert.Equal(suite.T(), http.StatusOK, refresh(laptop))
+
+	// ?all=true ends every session
+	phone, laptop = loginAs(), loginAs()
+	require.Equal(suite.T(), http.StatusNoContent, logout(phone, "?all=true"))
+	assert.Equal(suite.T(), http.StatusUnauthorized, refresh(phone))
+	assert.Equal(suite.T(), http.StatusUnauthorized, refresh(laptop))
+}
+
+func (suite *IntegrationTestSuite) TestChangePassword() {
+	user := suite.createUser(models.UserRequest{Username: "rotator", Email: "rotator@example.com", Password: "password123"})
+
+	changePassword := func(current, next string) int {
+		body, _ := json.Marshal(models.PasswordChangeRequest{CurrentPassword: current, NewPassword: next})
+		req, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/users/%d/password", suite.server.URL, user.ID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		suite.authorize(req, user.ID)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	loginStatus := func(password string) int {
+		resp := suite.login("rotator", password)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(suite.T(), http.StatusUnauthorized, changePassword("wrong-password", "new-password"))
+	assert.Equal(suite.T(), http.StatusBadRequest, changePassword("password123", "123"))
+	assert.Equal(suite.T(), http.StatusOK, loginStatus("password123"))
+
+	require.Equal(suite.T(), http.StatusNoContent, changePassword("password123", "new-password"))
+	assert.Equal(suite.T(), http.StatusUnauthorized, loginStatus("password123"))
+	assert.Equal(suite.T(), http.StatusOK, loginStatus("new-password"))
+}
+
 func (suite *IntegrationTestSuite) TestPostCRUDOperations() {
 	// First create a user for the posts
 	userReq := models.UserRequest{
@@ -163,7 +507,7 @@ func (suite *IntegrationTestSuite) TestPostCRUDOperations() {
 		Title:   "Updated Post",
 		Content: "Updated content",
 	}
-	updatedPost := suite.updatePost(createdPost.ID, updateReq)
+	updatedPost := suite.updatePost(createdPost.ID, updateReq, user.ID)
 	assert.Equal(suite.T(), updateReq.Title, updatedPost.Title)
 	assert.Equal(suite.T(), updateReq.Content, updatedPost.Content)
 
@@ -172,68 +516,944 @@ func (suite *IntegrationTestSuite) TestPostCRUDOperations() {
 	assert.GreaterOrEqual(suite.T(), len(posts), 1)
 
 	// Test Delete Post
-	suite.deletePost(createdPost.ID)
-	
+	suite.deletePost(createdPost.ID, user.ID)
+
+	// Deleting again is a no-op, so retries are safe
+	suite.deletePost(createdPost.ID, user.ID)
+
 	// Verify post is deleted
-	resp, err := http.Get(fmt.Sprintf("%s/posts/%d", suite.server.URL, createdPost.ID))
+	resp, err := http.Get(fmt.Sprintf("%s/api/posts/%d", suite.server.URL, createdPost.ID))
 	require.NoError(suite.T(), err)
 	defer resp.Body.Close()
 	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
 }
 
+func (suite *IntegrationTestSuite) TestOnlyAuthorChangesPost() {
+	author := suite.createUser(models.UserRequest{Username: "author", Email: "author@example.com", Password: "password123"})
+	other := suite.createUser(models.UserRequest{Username: "other", Email: "other@example.com", Password: "password123"})
+	post := suite.createPost(models.PostRequest{Title: "Mine", Content: "Content", UserID: author.ID})
+	draft := suite.createPost(models.PostRequest{Title: "My Draft", Content: "Content", UserID: author.ID, Status: models.PostStatusDraft})
+
+	send := func(method string, id int, body string, authorize func(*http.Request)) int {
+		req, _ := http.NewRequest(method, fmt.Sprintf("%s/api/posts/%d", suite.server.URL, id), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		authorize(req)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	asOther := func(req *http.Request) { suite.authorize(req, other.ID) }
+	asAdmin := func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+testAdminToken) }
+
+	// Someone else can't update or delete the post
+	assert.Equal(suite.T(), http.StatusForbidden, send(http.MethodPut, post.ID, `{"title":"Theirs"}`, asOther))
+	assert.Equal(suite.T(), http.StatusForbidden, send(http.MethodDelete, post.ID, "", asOther))
+	assert.Equal(suite.T(), "Mine", suite.getPost(post.ID).Title)
+
+	// Nor learn that the draft exists
+	assert.Equal(suite.T(), http.StatusNotFound, send(http.MethodPut, draft.ID, `{"title":"Theirs"}`, asOther))
+	assert.Equal(suite.T(), http.StatusNotFound, send(http.MethodDelete, draft.ID, "", asOther))
+
+	// Admins can
+	assert.Equal(suite.T(), http.StatusOK, send(http.MethodPut, post.ID, `{"title":"Moderated"}`, asAdmin))
+	assert.Equal(suite.T(), "Moderated", suite.getPost(post.ID).Title)
+	assert.Equal(suite.T(), http.StatusNoContent, send(http.MethodDelete, post.ID, "", asAdmin))
+}
+
+func (suite *IntegrationTestSuite) TestCreatePostAuthor() {
+	author := suite.createUser(models.UserRequest{Username: "poster", Email: "poster@example.com", Password: "password123"})
+	other := suite.createUser(models.UserRequest{Username: "impersonated", Email: "impersonated@example.com", Password: "password123"})
+
+	create := func(body string, authorize func(*http.Request)) (int, models.Post) {
+		req, _ := http.NewRequest(http.MethodPost, suite.server.URL+"/api/posts", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		authorize(req)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(suite.T(), err)
+		defer resp.Body.Close()
+
+		var post models.Post
+		if resp.StatusCode == http.StatusCreated {
+			require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&post))
+		}
+		return resp.StatusCode, post
+	}
+	asAuthor := func(req *http.Request) { suite.authorize(req, author.ID) }
+	asAdmin := func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+testAdminToken) }
+
+	// A user's user_id is ignored; they always post as themselves
+	status, post := create(fmt.Sprintf(`{"title":"Mine","content":"Content","user_id":%d}`, other.ID), asAuthor)
+	require.Equal(suite.T(), http.StatusCreated, status)
+	assert.Equal(suite.T(), author.ID, post.UserID)
+
+	// Admins post as the user they name, who must exist
+	status, post = create(fmt.Sprintf(`{"title":"On Behalf","content":"Content","user_id":%d}`, other.ID), asAdmin)
+	require.Equal(suite.T(), http.StatusCreated, status)
+	assert.Equal(suite.T(), other.ID, post.UserID)
+
+	status, _ = create(`{"title":"Nobody's","content":"Content","user_id":999999}`, asAdmin)
+	assert.Equal(suite.T(), http.StatusBadRequest, status)
+	status, _ = create(`{"title":"Nobody's","content":"Content"}`, asAdmin)
+	assert.Equal(suite.T(), http.StatusBadRequest, status)
+}
+
+func (suite *IntegrationTestSuite) TestMovedPostsAnnounced() {
+	leaving := suite.createUser(models.UserRequest{Username: "leaving", Email: "leaving@example.com", Password: "password123"})
+	heir := suite.createUser(models.UserRequest{Username: "heir", Email: "heir@example.com", Password: "password123"})
+	first := suite.createPost(models.PostRequest{Title: "First", Content: "Content", UserID: leaving.ID})
+	second := suite.createPost(models.PostRequest{Title: "Second", Content: "Content", UserID: leaving.ID})
+	third := suite.createPost(models.PostRequest{Title: "Third", Content: "Content", UserID: leaving.ID})
+	suite.updates.take()
+
+	move := func(path string) int {
+		req, err := http.NewRequest("POST", suite.server.URL+path, strings.NewReader(fmt.Sprintf(`{"new_user_id":%d}`, heir.ID)))
+		require.NoError(suite.T(), err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testAdminToken)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Moving one post announces it
+	require.Equal(suite.T(), http.StatusOK, move(fmt.Sprintf("/api/posts/%d/move", first.ID)))
+	assert.Equal(suite.T(), []int{first.ID}, suite.updates.take())
+
+	// Transferring all of a user's posts announces each of them
+	require.Equal(suite.T(), http.StatusOK, move(fmt.Sprintf("/api/admin/users/%d/transfer-posts", leaving.ID)))
+	assert.ElementsMatch(suite.T(), []int{second.ID, third.ID}, suite.updates.take())
+}
+
+func (suite *IntegrationTestSuite) TestPasswordResetDevMode() {
+	suite.createUser(models.UserRequest{Username: "developer", Email: "developer@example.com", Password: "password123"})
+
+	cfg := *suite.cfg
+	cfg.DevMode = true
+	handler := handlers.NewAuthHandler(suite.db, &cfg, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/forgot-password", strings.NewReader(`{"email":"developer@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ForgotPassword(rec, req)
+	require.Equal(suite.T(), http.StatusOK, rec.Code)
+
+	var body struct {
+		Data struct {
+			ResetToken string `json:"reset_token"`
+		} `json:"data"`
+	}
+	require.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &body))
+	require.NotEmpty(suite.T(), body.Data.ResetToken)
+
+	reset, _ := json.Marshal(models.PasswordResetRequest{Token: body.Data.ResetToken, NewPassword: "new-password"})
+	resp, err := http.Post(suite.server.URL+"/api/auth/reset-password", "application/json", bytes.NewBuffer(reset))
+	require.NoError(suite.T(), err)
+	resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusNoContent, resp.StatusCode)
+}
+
+func (suite *IntegrationTestSuite) TestAutosaveDraft() {
+	author := suite.createUser(models.UserRequest{Username: "autosaver", Email: "autosaver@example.com", Password: "password123"})
+	other := suite.createUser(models.UserRequest{Username: "bystander", Email: "bystander@example.com", Password: "password123"})
+	draft := suite.createPost(models.PostRequest{Title: "Draft", Content: "Content", UserID: author.ID, Status: models.PostStatusDraft})
+	published := suite.createPost(models.PostRequest{Title: "Published", Content: "Content", UserID: author.ID})
+
+	autosave := func(id int, body string, userID int) (int, models.Post) {
+		req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/api/posts/%d/autosave", suite.server.URL, id), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		suite.authorize(req, userID)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(suite.T(), err)
+		defer resp.Body.Close()
+
+		var post models.Post
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&post))
+		}
+		return resp.StatusCode, post
+	}
+
+	status, saved := autosave(draft.ID, `{"title":"Half Done","content":""}`, author.ID)
+	require.Equal(suite.T(), http.StatusOK, status)
+	assert.Equal(suite.T(), "Half Done", saved.Title)
+	assert.Equal(suite.T(), "", saved.Content)
+	assert.Equal(suite.T(), models.PostStatusDraft, saved.Status)
+
+	// Someone else's draft stays hidden, and published posts aren't autosaved
+	status, _ = autosave(draft.ID, `{"content":"Mine now"}`, other.ID)
+	assert.Equal(suite.T(), http.StatusNotFound, status)
+	status, _ = autosave(published.ID, `{"content":"Edited"}`, author.ID)
+	assert.Equal(suite.T(), http.StatusConflict, status)
+	assert.Equal(suite.T(), "Content", suite.getPost(published.ID).Content)
+}
+
+func (suite *IntegrationTestSuite) TestScheduledPost() {
+	author := suite.createUser(models.UserRequest{Username: "scheduler", Email: "scheduler@example.com", Password: "password123"})
+	publishAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	post := suite.createPost(models.PostRequest{Title: "Later", Content: "Content", UserID: author.ID, Status: models.PostStatusDraft, PublishAt: &publishAt})
+	require.NotNil(suite.T(), post.PublishAt)
+	assert.True(suite.T(), publishAt.Equal(*post.PublishAt))
+
+	// Not listed for anonymous readers before it is due
+	assert.Empty(suite.T(), suite.getAllPosts())
+
+	send := func(id int, body string) int {
+		req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/api/posts/%d", suite.server.URL, id), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		suite.authorize(req, author.ID)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	assert.Equal(suite.T(), http.StatusBadRequest, send(post.ID, `{"publish_at":"`+past+`"}`))
+
+	published := suite.createPost(models.PostRequest{Title: "Now", Content: "Content", UserID: author.ID})
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	assert.Equal(suite.T(), http.StatusConflict, send(published.ID, `{"publish_at":"`+future+`"}`))
+}
+
+func (suite *IntegrationTestSuite) TestSoftDeleteAndRestore() {
+	user := suite.createUser(models.UserRequest{Username: "eraser", Email: "eraser@example.com", Password: "password123"})
+	post := suite.createPost(models.PostRequest{Title: "Second Thoughts", Content: "Content", UserID: user.ID})
+
+	suite.deletePost(post.ID, user.ID)
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/posts/%d", suite.server.URL, post.ID))
+	require.NoError(suite.T(), err)
+	resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+	assert.Empty(suite.T(), suite.getAllPosts())
+
+	// Only admins may list deleted posts
+	resp, err = http.Get(suite.server.URL + "/api/posts?include_deleted=true")
+	require.NoError(suite.T(), err)
+	resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+
+	req, _ := http.NewRequest(http.MethodGet, suite.server.URL+"/api/posts?include_deleted=true", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(suite.T(), err)
+	var posts []models.Post
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&posts))
+	resp.Body.Close()
+	require.Len(suite.T(), posts, 1)
+	assert.NotNil(suite.T(), posts[0].DeletedAt)
+
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/posts/%d/restore", suite.server.URL, post.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(suite.T(), err)
+	resp.Body.Close()
+	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	assert.Equal(suite.T(), "Second Thoughts", suite.getPost(post.ID).Title)
+}
+
+func (suite *IntegrationTestSuite) TestCreatePostAuthorFromToken() {
+	alice := suite.createUser(models.UserRequest{Username: "alice", Email: "alice@example.com", Password: "password123"})
+	bob := suite.createUser(models.UserRequest{Username: "bob", Email: "bob@example.com", Password: "password123"})
+
+	// Alice can't post as Bob by naming him in the body
+	resp := suite.postPost(models.PostRequest{Title: "Impersonated", Content: "Content", Status: models.PostStatusPublished, UserID: bob.ID}, alice.ID)
+	defer resp.Body.Close()
+	require.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	var post models.Post
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&post))
+	assert.Equal(suite.T(), alice.ID, post.UserID)
+	assert.Equal(suite.T(), alice.ID, suite.getPost(post.ID).UserID)
+}
+
+func (suite *IntegrationTestSuite) TestPostContentFormatDefault() {
+	user := suite.createUser(models.UserRequest{
+		Username: "formatter",
+		Email:    "formatter@example.com",
+		Password: "password123",
+	})
+
+	// Omitting the format falls back to the configured default
+	post := suite.createPost(models.PostRequest{
+		Title:   "Default Format",
+		Content: "# Heading",
+		UserID:  user.ID,
+	})
+	assert.Equal(suite.T(), models.ContentFormatMarkdown, post.ContentFormat)
+
+	post = suite.createPost(models.PostRequest{
+		Title:         "Explicit Format",
+		Content:       "<h1>Heading</h1>",
+		ContentFormat: models.ContentFormatHTML,
+		UserID:        user.ID,
+	})
+	assert.Equal(suite.T(), models.ContentFormatHTML, post.ContentFormat)
+}
+
+func (suite *IntegrationTestSuite) TestPostTags() {
+	user := suite.createUser(models.UserRequest{
+		Username: "tagger",
+		Email:    "tagger@example.com",
+		Password: "password123",
+	})
+
+	post := suite.createPost(models.PostRequest{
+		Title:   "Tagged Post",
+		Content: "Content",
+		UserID:  user.ID,
+		Tags:    []string{"Go", "go", "Web"},
+	})
+	assert.Equal(suite.T(), []string{"go", "web"}, post.Tags)
+	suite.createPost(models.PostRequest{Title: "Other Post", Content: "Content", UserID: user.ID})
+
+	tagged := func(tag string) []models.Post {
+		resp, err := http.Get(suite.server.URL + "/api/posts?tag=" + tag)
+		require.NoError(suite.T(), err)
+		defer resp.Body.Close()
+		require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+		var posts []models.Post
+		require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&posts))
+		return posts
+	}
+
+	posts := tagged("web")
+	require.Len(suite.T(), posts, 1)
+	assert.Equal(suite.T(), post.ID, posts[0].ID)
+
+	// Tags in an update replace the post's tags
+	updated := suite.updatePost(post.ID, models.PostRequest{Tags: []string{"go"}}, user.ID)
+	assert.Equal(suite.T(), []string{"go"}, updated.Tags)
+	assert.Empty(suite.T(), tagged("web"))
+	assert.Len(suite.T(), tagged("go"), 1)
+
+	// Tags are stored and matched in their canonical form
+	updated = suite.updatePost(post.ID, models.PostRequest{Tags: []string{" Golang", "Web  Dev", "web--dev"}}, user.ID)
+	assert.Equal(suite.T(), []string{"golang", "web-dev"}, updated.Tags)
+	assert.Equal(suite.T(), []string{"golang", "web-dev"}, suite.getPost(post.ID).Tags)
+	assert.Len(suite.T(), tagged("Golang"), 1)
+	assert.Len(suite.T(), tagged("golang"), 1)
+	assert.Len(suite.T(), tagged("Web%20Dev"), 1)
+}
+
+func (suite *IntegrationTestSuite) TestPostTagEndpoints() {
+	author := suite.createUser(models.UserRequest{Username: "tagowner", Email: "tagowner@example.com", Password: "password123"})
+	other := suite.createUser(models.UserRequest{Username: "tagstranger", Email: "tagstranger@example.com", Password: "password123"})
+	post := suite.createPost(models.PostRequest{Title: "Tag Me", Content: "Content", UserID: author.ID, Tags: []string{"old"}})
+
+	send := func(method, path, body string, userID int) (int, models.Post) {
+		req, _ := http.NewRequest(method, fmt.Sprintf("%s/api/posts/%d/tags%s", suite.server.URL, post.ID, path), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		suite.authorize(req, userID)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(suite.T(), err)
+		defer resp.Body.Close()
+
+		var updated models.Post
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&updated))
+		}
+		return resp.StatusCode, updated
+	}
+
+	status, updated := send(http.MethodPut, "", `{"tags":["Go","web"]}`, author.ID)
+	require.Equal(suite.T(), http.StatusOK, status)
+	assert.Equal(suite.T(), []string{"go", "web"}, updated.Tags)
+
+	// Adding a tag twice and removing a missing one change nothing
+	status, updated = send(http.MethodPost, "/go", "", author.ID)
+	require.Equal(suite.T(), http.StatusOK, status)
+	assert.Equal(suite.T(), []string{"go", "web"}, updated.Tags)
+	status, updated = send(http.MethodDelete, "/rust", "", author.ID)
+	require.Equal(suite.T(), http.StatusOK, status)
+	assert.Equal(suite.T(), []string{"go", "web"}, updated.Tags)
+
+	status, updated = send(http.MethodDelete, "/web", "", author.ID)
+	require.Equal(suite.T(), http.StatusOK, status)
+	assert.Equal(suite.T(), []string{"go"}, updated.Tags)
+	assert.Equal(suite.T(), []string{"go"}, suite.getPost(post.ID).Tags)
+
+	// Only the author changes the tags
+	status, _ = send(http.MethodPut, "", `{"tags":["spam"]}`, other.ID)
+	assert.Equal(suite.T(), http.StatusForbidden, status)
+	status, _ = send(http.MethodPost, "/spam", "", other.ID)
+	assert.Equal(suite.T(), http.StatusForbidden, status)
+
+	// The tag limit holds for both replacing and adding
+	full := make([]string, models.MaxPostTags)
+	for i := range full {
+		full[i] = fmt.Sprintf("%q", fmt.Sprintf("tag%02d", i))
+	}
+	status, _ = send(http.MethodPut, "", `{"tags":[`+strings.Join(full, ",")+`,"extra"]}`, author.ID)
+	assert.Equal(suite.T(), http.StatusBadRequest, status)
+	status, _ = send(http.MethodPut, "", `{"tags":[`+strings.Join(full, ",")+`]}`, author.ID)
+	require.Equal(suite.T(), http.StatusOK, status)
+	status, _ = send(http.MethodPost, "/extra", "", author.ID)
+	assert.Equal(suite.T(), http.StatusConflict, status)
+}
+
+func (suite *IntegrationTestSuite) TestPostsByUser() {
+	author := suite.createUser(models.UserRequest{Username: "prolific", Email: "prolific@example.com", Password: "password123"})
+	quiet := suite.createUser(models.UserRequest{Username: "quiet", Email: "quiet@example.com", Password: "password123"})
+	other := suite.createUser(models.UserRequest{Username: "other", Email: "other@example.com", Password: "password123"})
+	first := suite.createPost(models.PostRequest{Title: "First", Content: "Content", UserID: author.ID})
+	second := suite.createPost(models.PostRequest{Title: "Second", Content: "Content", UserID: author.ID})
+	suite.createPost(models.PostRequest{Title: "Someone else's", Content: "Content", UserID: other.ID})
+	suite.createPost(models.PostRequest{Title: "Unfinished", Content: "Content", Status: models.PostStatusDraft, UserID: author.ID})
+
+	get := func(userID int) (*http.Response, []byte) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/users/%d/posts", suite.server.URL, userID))
+		require.NoError(suite.T(), err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(suite.T(), err)
+		return resp, body
+	}
+
+	// Only the author's published posts, newest first
+	resp, body := get(author.ID)
+	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	var posts []models.Post
+	require.NoError(suite.T(), json.Unmarshal(body, &posts))
+	require.Len(suite.T(), posts, 2)
+	assert.Equal(suite.T(), second.ID, posts[0].ID)
+	assert.Equal(suite.T(), first.ID, posts[1].ID)
+
+	// An author without posts has an empty list
+	resp, body = get(quiet.ID)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.JSONEq(suite.T(), `[]`, string(body))
+
+	// A user that doesn't exist is a 404
+	resp, _ = get(other.ID + 1000)
+	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *IntegrationTestSuite) TestDraftsAndPublishing() {
+	author := suite.createUser(models.UserRequest{Username: "drafter", Email: "drafter@example.com", Password: "password123"})
+	reader := suite.createUser(models.UserRequest{Username: "reader", Email: "reader@example.com", Password: "password123"})
+
+	// Posts are drafts unless created as published
+	resp := suite.postPost(models.PostRequest{Title: "Work in Progress", Content: "Content"}, author.ID)
+	defer resp.Body.Close()
+	require.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	var draft models.Post
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&draft))
+	assert.Equal(suite.T(), models.PostStatusDraft, draft.Status)
+	assert.Nil(suite.T(), draft.PublishedAt)
+
+	listAs := func(userID int) []models.Post {
+		req, _ := http.NewRequest(http.MethodGet, suite.server.URL+"/api/posts", nil)
+		if userID != 0 {
+			suite.authorize(req, userID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(suite.T(), err)
+		defer resp.Body.Close()
+		require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+		var posts []models.Post
+		require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&posts))
+		return posts
+	}
+	getAs := func(userID int) int {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/posts/%d", suite.server.URL, draft.ID), nil)
+		if userID != 0 {
+			suite.authorize(req, userID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	publish := func(userID int) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/posts/%d/publish", suite.server.URL, draft.ID), nil)
+		suite.authorize(req, userID)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(suite.T(), err)
+		return resp
+	}
+
+	// Only the author sees the draft
+	assert.Empty(suite.T(), listAs(0))
+	assert.Empty(suite.T(), listAs(reader.ID))
+	assert.Len(suite.T(), listAs(author.ID), 1)
+	assert.Equal(suite.T(), http.StatusNotFound, getAs(0))
+	assert.Equal(suite.T(), http.StatusNotFound, getAs(reader.ID))
+	assert.Equal(suite.T(), http.StatusOK, getAs(author.ID))
+
+	// Nobody else can publish it
+	resp = publish(reader.ID)
+	resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+
+	resp = publish(author.ID)
+	defer resp.Body.Close()
+	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	var published models.Post
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&published))
+	assert.Equal(suite.T(), models.PostStatusPublished, published.Status)
+	require.NotNil(suite.T(), published.PublishedAt)
+
+	assert.Len(suite.T(), listAs(0), 1)
+	assert.Equal(suite.T(), http.StatusOK, getAs(reader.ID))
+
+	// Publishing again has no effect and says so
+	resp = publish(author.ID)
+	resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusConflict, resp.StatusCode)
+}
+
+func (suite *IntegrationTestSuite) TestHeadPost() {
+	user := suite.createUser(models.UserRequest{
+		Username: "header",
+		Email:    "header@example.com",
+		Password: "password123",
+	})
+	post := suite.createPost(models.PostRequest{
+		Title:   "Head Check",
+		Content: "Only the headers, please",
+		UserID:  user.ID,
+	})
+
+	resp, err := http.Head(fmt.Sprintf("%s/api/posts/%d", suite.server.URL, post.ID))
+	require.NoError(suite.T(), err)
+	defer resp.Body.Close()
+
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(suite.T(), resp.Header.Get("ETag"))
+	assert.NotEmpty(suite.T(), resp.Header.Get("Content-Length"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), body)
+
+	// Checking existence doesn't count as a view
+	assert.Equal(suite.T(), 1, suite.getPost(post.ID).ViewCount)
+
+	resp, err = http.Head(fmt.Sprintf("%s/api/posts/%d", suite.server.URL, post.ID+1000))
+	require.NoError(suite.T(), err)
+	defer resp.Body.Close()
+
+	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *IntegrationTestSuite) TestDeleteIfMatch() {
+	user := suite.createUser(models.UserRequest{Username: "ifmatch", Email: "ifmatch@example.com", Password: "password123"})
+	post := suite.createPost(models.PostRequest{Title: "Original", Content: "Content", UserID: user.ID})
+
+	etagOf := func(path string) string {
+		resp, err := http.Get(suite.server.URL + path)
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+		require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+		return resp.Header.Get("ETag")
+	}
+	deleteIfMatch := func(path, etag string) int {
+		req, _ := http.NewRequest("DELETE", suite.server.URL+path, nil)
+		req.Header.Set("If-Match", etag)
+		suite.authorize(req, user.ID)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	postPath := fmt.Sprintf("/api/posts/%d", post.ID)
+	userPath := fmt.Sprintf("/api/users/%d", user.ID)
+
+	// A stale ETag leaves the resource in place
+	stale := etagOf(postPath)
+	suite.updatePost(post.ID, models.PostRequest{Title: "Edited"}, user.ID)
+	assert.Equal(suite.T(), http.StatusPreconditionFailed, deleteIfMatch(postPath, stale))
+	assert.Equal(suite.T(), "Edited", suite.getPost(post.ID).Title)
+
+	// The current ETag deletes it
+	assert.Equal(suite.T(), http.StatusNoContent, deleteIfMatch(postPath, etagOf(postPath)))
+
+	staleUser := etagOf(userPath)
+	suite.updateUser(user.ID, models.UserRequest{Username: "ifmatch2"})
+	assert.Equal(suite.T(), http.StatusPreconditionFailed, deleteIfMatch(userPath, staleUser))
+	assert.Equal(suite.T(), http.StatusNoContent, deleteIfMatch(userPath, etagOf(userPath)))
+}
+
+func (suite *IntegrationTestSuite) TestEmptyPostsList() {
+	resp, err := http.Get(suite.server.URL + "/api/posts")
+	require.NoError(suite.T(), err)
+	defer resp.Body.Close()
+
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(suite.T(), err)
+	assert.JSONEq(suite.T(), `[]`, string(body))
+
+	// Operators can opt into 204 for clients that can't handle an empty array
+	noContent := *suite.cfg
+	noContent.EmptyListNoContent = true
+	handler := handlers.NewPostHandler(suite.db, nil, &noContent)
+
+	rec := httptest.NewRecorder()
+	handler.GetAllPosts(rec, httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+
+	assert.Equal(suite.T(), http.StatusNoContent, rec.Code)
+	assert.Empty(suite.T(), rec.Body.String())
+}
+
+func (suite *IntegrationTestSuite) TestPostsListGzip() {
+	user := suite.createUser(models.UserRequest{Username: "verbose", Email: "verbose@example.com", Password: "password123"})
+	for i := 0; i < 10; i++ {
+		suite.createPost(models.PostRequest{Title: fmt.Sprintf("Long post %d", i), Content: strings.Repeat("Lorem ipsum dolor sit amet. ", 20), UserID: user.ID})
+	}
+
+	// The default transport asks for gzip and decompresses on its own, which
+	// would hide the encoding
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	get := func(acceptEncoding string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, suite.server.URL+"/api/posts", nil)
+		require.NoError(suite.T(), err)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		resp, err := client.Do(req)
+		require.NoError(suite.T(), err)
+		return resp
+	}
+
+	resp := get("gzip")
+	defer resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.Equal(suite.T(), "gzip", resp.Header.Get("Content-Encoding"))
+	gz, err := gzip.NewReader(resp.Body)
+	require.NoError(suite.T(), err)
+	var posts []models.Post
+	require.NoError(suite.T(), json.NewDecoder(gz).Decode(&posts))
+	assert.Len(suite.T(), posts, 10)
+
+	plain := get("")
+	defer plain.Body.Close()
+	assert.Empty(suite.T(), plain.Header.Get("Content-Encoding"))
+	posts = nil
+	require.NoError(suite.T(), json.NewDecoder(plain.Body).Decode(&posts))
+	assert.Len(suite.T(), posts, 10)
+}
+
+func (suite *IntegrationTestSuite) TestPostsListTruncated() {
+	user := suite.createUser(models.UserRequest{Username: "busy", Email: "busy@example.com", Password: "password123"})
+	for i := 0; i < 5; i++ {
+		suite.createPost(models.PostRequest{Title: fmt.Sprintf("Post %d", i), Content: "Content", UserID: user.ID})
+	}
+
+	capped := *suite.cfg
+	capped.MaxListRows = 3
+	handler := handlers.NewPostHandler(suite.db, nil, &capped)
+
+	rec := httptest.NewRecorder()
+	handler.GetAllPosts(rec, httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+
+	assert.Equal(suite.T(), http.StatusOK, rec.Code)
+	assert.Equal(suite.T(), "true", rec.Header().Get("X-Result-Truncated"))
+	var posts []models.Post
+	require.NoError(suite.T(), json.Unmarshal(rec.Body.Bytes(), &posts))
+	assert.Len(suite.T(), posts, 3)
+
+	// Under the cap the header is left out
+	capped.MaxListRows = 5
+	rec = httptest.NewRecorder()
+	handler.GetAllPosts(rec, httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+	assert.Empty(suite.T(), rec.Header().Get("X-Result-Truncated"))
+}
+
+func (suite *IntegrationTestSuite) TestPostsListIfModifiedSince() {
+	user := suite.createUser(models.UserRequest{
+		Username: "poller",
+		Email:    "poller@example.com",
+		Password: "password123",
+	})
+	post := suite.createPost(models.PostRequest{
+		Title:   "Polled",
+		Content: "Fetched again and again",
+		UserID:  user.ID,
+	})
+
+	getPosts := func(ifModifiedSince string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, suite.server.URL+"/api/posts", nil)
+		require.NoError(suite.T(), err)
+		if ifModifiedSince != "" {
+			req.Header.Set("If-Modified-Since", ifModifiedSince)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(suite.T(), err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// Last-Modified is only sent once the latest change is a second old
+	time.Sleep(1100 * time.Millisecond)
+	resp := getPosts("")
+	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	lastModified := resp.Header.Get("Last-Modified")
+	require.NotEmpty(suite.T(), lastModified)
+
+	resp = getPosts(lastModified)
+	assert.Equal(suite.T(), http.StatusNotModified, resp.StatusCode)
+
+	// Views alone don't invalidate the list
+	suite.getPost(post.ID)
+	assert.Equal(suite.T(), http.StatusNotModified, getPosts(lastModified).StatusCode)
+
+	suite.updatePost(post.ID, models.PostRequest{Title: "Polled and edited"}, user.ID)
+	time.Sleep(1100 * time.Millisecond)
+
+	resp = getPosts(lastModified)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.NotEqual(suite.T(), lastModified, resp.Header.Get("Last-Modified"))
+}
+
 func (suite *IntegrationTestSuite) TestValidationErrors() {
 	// Test invalid user creation
 	invalidUser := models.UserRequest{
-		Username: "", // Invalid: empty username
+		Username: "",              // Invalid: empty username
 		Email:    "invalid-email", // Invalid: bad email format
-		Password: "123", // Invalid: too short
+		Password: "123",           // Invalid: too short
 	}
 
 	userJSON, _ := json.Marshal(invalidUser)
-	resp, err := http.Post(suite.server.URL+"/users", "application/json", bytes.NewBuffer(userJSON))
+	resp, err := http.Post(suite.server.URL+"/api/users", "application/json", bytes.NewBuffer(userJSON))
 	require.NoError(suite.T(), err)
 	defer resp.Body.Close()
-	
+
 	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
 
 	// Test invalid post creation
 	invalidPost := models.PostRequest{
 		Title:   "", // Invalid: empty title
 		Content: "", // Invalid: empty content
-		UserID:  0,  // Invalid: zero user ID
 	}
 
-	postJSON, _ := json.Marshal(invalidPost)
-	resp, err = http.Post(suite.server.URL+"/posts", "application/json", bytes.NewBuffer(postJSON))
+	resp = suite.postPost(invalidPost, 1)
+	defer resp.Body.Close()
+
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+}
+
+func (suite *IntegrationTestSuite) TestExportPostsNDJSON() {
+	user := suite.createUser(models.UserRequest{
+		Username: "exporter",
+		Email:    "exporter@example.com",
+		Password: "password123",
+	})
+	// More posts than the suite's export batch size, so several batches are read
+	for i := 0; i < 5; i++ {
+		suite.createPost(models.PostRequest{
+			Title:   fmt.Sprintf("Export %d", i),
+			Content: "Exported content",
+			UserID:  user.ID,
+		})
+	}
+
+	req, err := http.NewRequest(http.MethodGet, suite.server.URL+"/api/admin/export?format=ndjson", nil)
+	require.NoError(suite.T(), err)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+
+	resp, err := http.DefaultClient.Do(req)
 	require.NoError(suite.T(), err)
 	defer resp.Body.Close()
-	
+
+	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.Equal(suite.T(), "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lines = append(lines, append([]byte(nil), scanner.Bytes()...))
+	}
+	require.NoError(suite.T(), scanner.Err())
+	require.Len(suite.T(), lines, 6)
+
+	for i, line := range lines[:5] {
+		var post models.Post
+		require.NoError(suite.T(), json.Unmarshal(line, &post))
+		assert.Equal(suite.T(), fmt.Sprintf("Export %d", i), post.Title)
+		assert.Equal(suite.T(), "exporter", post.Username)
+	}
+
+	var trailer struct {
+		Summary struct {
+			Count    int  `json:"count"`
+			Complete bool `json:"complete"`
+		} `json:"summary"`
+	}
+	require.NoError(suite.T(), json.Unmarshal(lines[5], &trailer))
+	assert.Equal(suite.T(), 5, trailer.Summary.Count)
+	assert.True(suite.T(), trailer.Summary.Complete)
+}
+
+func (suite *IntegrationTestSuite) TestPostBlankTitleRejectedByDatabase() {
+	user := suite.createUser(models.UserRequest{
+		Username: "blanktitle",
+		Email:    "blanktitle@example.com",
+		Password: "password123",
+	})
+
+	// Whitespace passes request validation but not the posts_title_check constraint
+	resp := suite.postPost(models.PostRequest{Title: "   ", Content: "Content", UserID: user.ID}, user.ID)
+	defer resp.Body.Close()
+
 	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+
+	var body struct {
+		Details []handlers.ValidationError `json:"details"`
+	}
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(suite.T(), body.Details, 1)
+	assert.Equal(suite.T(), "title", body.Details[0].Field)
 }
 
-// Helper methods for making HTTP requests
+func (suite *IntegrationTestSuite) TestPostUpdateCannotChangeAuthor() {
+	author := suite.createUser(models.UserRequest{Username: "author", Email: "author@example.com", Password: "password123"})
+	target := suite.createUser(models.UserRequest{Username: "target", Email: "target@example.com", Password: "password123"})
+	post := suite.createPost(models.PostRequest{Title: "Staying", Content: "Content", UserID: author.ID})
+
+	postJSON, _ := json.Marshal(models.PostRequest{Title: "Moved", UserID: target.ID})
+	httpReq, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/posts/%d", suite.server.URL, post.ID), bytes.NewBuffer(postJSON))
+	httpReq.Header.Set("Content-Type", "application/json")
+	suite.authorize(httpReq, author.ID)
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	require.NoError(suite.T(), err)
+	resp.Body.Close()
+
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+	fetched := suite.getPost(post.ID)
+	assert.Equal(suite.T(), author.ID, fetched.UserID)
+	assert.Equal(suite.T(), "Staying", fetched.Title)
+}
+
+func (suite *IntegrationTestSuite) TestMovePostTargetUserDeletedConcurrently() {
+	author := suite.createUser(models.UserRequest{Username: "author", Email: "author@example.com", Password: "password123"})
+	target := suite.createUser(models.UserRequest{Username: "target", Email: "target@example.com", Password: "password123"})
+	post := suite.createPost(models.PostRequest{Title: "Moving", Content: "Content", UserID: author.ID})
+
+	// Delete the target user in a transaction that stays open until the
+	// move is waiting on the user's row
+	tx, err := suite.db.Begin()
+	require.NoError(suite.T(), err)
+	defer tx.Rollback()
+	_, err = tx.Exec("DELETE FROM users WHERE id = $1", target.ID)
+	require.NoError(suite.T(), err)
+
+	status := make(chan int, 1)
+	go func() {
+		body := fmt.Sprintf(`{"new_user_id":%d}`, target.ID)
+		httpReq, _ := http.NewRequest("POST", fmt.Sprintf("%s/api/posts/%d/move", suite.server.URL, post.ID), strings.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+testAdminToken)
+
+		resp, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+
+	require.Eventually(suite.T(), func() bool {
+		var waiting int
+		err := suite.db.QueryRow(`
+			SELECT count(*) FROM pg_stat_activity
+			WHERE datname = current_database() AND wait_event_type = 'Lock'`).Scan(&waiting)
+		return err == nil && waiting > 0
+	}, 5*time.Second, 10*time.Millisecond, "move never waited for the target user")
+	require.NoError(suite.T(), tx.Commit())
+
+	assert.Equal(suite.T(), http.StatusBadRequest, <-status)
+	assert.Equal(suite.T(), author.ID, suite.getPost(post.ID).UserID)
+}
+
+func (suite *IntegrationTestSuite) TestAdminPage() {
+	author := suite.createUser(models.UserRequest{Username: "admined", Email: "admined@example.com", Password: "password123"})
+	suite.createPost(models.PostRequest{Title: "Published Post", Content: "Content", UserID: author.ID})
+	draft := suite.createPost(models.PostRequest{Title: "Draft Post", Content: "Content", Status: models.PostStatusDraft, UserID: author.ID})
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(suite.T(), err)
+	client := &http.Client{Jar: jar}
+
+	// Without a session the page is a 401
+	resp, err := client.Get(suite.server.URL + "/admin")
+	require.NoError(suite.T(), err)
+	resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusUnauthorized, resp.StatusCode)
+
+	// The login form carries the CSRF token its post must echo
+	resp, err = client.Get(suite.server.URL + "/admin/login")
+	require.NoError(suite.T(), err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(suite.T(), err)
+	match := regexp.MustCompile(`name="csrf_token" value="([0-9a-f]+)"`).FindStringSubmatch(string(body))
+	require.Len(suite.T(), match, 2)
+	csrfToken := match[1]
+
+	// Logging in redirects to the post list, drafts included
+	resp, err = client.PostForm(suite.server.URL+"/admin/login", url.Values{"token": {testAdminToken}, "csrf_token": {csrfToken}})
+	require.NoError(suite.T(), err)
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.Contains(suite.T(), string(body), "Published Post")
+	assert.Contains(suite.T(), string(body), "Draft Post")
+
+	publishURL := fmt.Sprintf("%s/admin/posts/%d/publish", suite.server.URL, draft.ID)
+	assert.Contains(suite.T(), string(body), fmt.Sprintf(`action="/admin/posts/%d/publish"`, draft.ID))
+
+	// Form posts without the CSRF token are refused
+	resp, err = client.PostForm(publishURL, url.Values{})
+	require.NoError(suite.T(), err)
+	resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+
+	resp, err = client.PostForm(publishURL, url.Values{"csrf_token": {csrfToken}})
+	require.NoError(suite.T(), err)
+	resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.Equal(suite.T(), models.PostStatusPublished, suite.getPost(draft.ID).Status)
+	assert.Contains(suite.T(), suite.updates.take(), draft.ID)
+}
 
 func (suite *IntegrationTestSuite) createUser(req models.UserRequest) models.User {
 	userJSON, _ := json.Marshal(req)
-	resp, err := http.Post(suite.server.URL+"/users", "application/json", bytes.NewBuffer(userJSON))
+	resp, err := http.Post(suite.server.URL+"/api/users", "application/json", bytes.NewBuffer(userJSON))
 	require.NoError(suite.T(), err)
 	defer resp.Body.Close()
-	
+
 	require.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
-	
+
 	var user models.User
 	err = json.NewDecoder(resp.Body).Decode(&user)
 	require.NoError(suite.T(), err)
 	return user
 }
 
+func (suite *IntegrationTestSuite) login(username, password string) *http.Response {
+	body, _ := json.Marshal(models.LoginRequest{Username: username, Password: password})
+	resp, err := http.Post(suite.server.URL+"/api/auth/login", "application/json", bytes.NewBuffer(body))
+	require.NoError(suite.T(), err)
+	return resp
+}
+
 func (suite *IntegrationTestSuite) getUser(id int) models.User {
-	resp, err := http.Get(fmt.Sprintf("%s/users/%d", suite.server.URL, id))
+	resp, err := http.Get(fmt.Sprintf("%s/api/users/%d", suite.server.URL, id))
 	require.NoError(suite.T(), err)
 	defer resp.Body.Close()
-	
+
 	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
-	
+
 	var user models.User
 	err = json.NewDecoder(resp.Body).Decode(&user)
 	require.NoError(suite.T(), err)
@@ -243,15 +1463,16 @@ func (suite *IntegrationTestSuite) getUser(id int) models.User {
 func (suite *IntegrationTestSuite) updateUser(id int, req models.UserRequest) models.User {
 	userJSON, _ := json.Marshal(req)
 	client := &http.Client{}
-	httpReq, _ := http.NewRequest("PUT", fmt.Sprintf("%s/users/%d", suite.server.URL, id), bytes.NewBuffer(userJSON))
+	httpReq, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/users/%d", suite.server.URL, id), bytes.NewBuffer(userJSON))
 	httpReq.Header.Set("Content-Type", "application/json")
-	
+	suite.authorize(httpReq, id)
+
 	resp, err := client.Do(httpReq)
 	require.NoError(suite.T(), err)
 	defer resp.Body.Close()
-	
+
 	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
-	
+
 	var user models.User
 	err = json.NewDecoder(resp.Body).Decode(&user)
 	require.NoError(suite.T(), err)
@@ -260,91 +1481,120 @@ func (suite *IntegrationTestSuite) updateUser(id int, req models.UserRequest) mo
 
 func (suite *IntegrationTestSuite) deleteUser(id int) {
 	client := &http.Client{}
-	httpReq, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/users/%d", suite.server.URL, id), nil)
-	
+	httpReq, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/api/users/%d", suite.server.URL, id), nil)
+	suite.authorize(httpReq, id)
+
 	resp, err := client.Do(httpReq)
 	require.NoError(suite.T(), err)
 	defer resp.Body.Close()
-	
-	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	require.Equal(suite.T(), http.StatusNoContent, resp.StatusCode)
 }
 
 func (suite *IntegrationTestSuite) getAllUsers() []models.User {
-	resp, err := http.Get(suite.server.URL + "/users")
+	resp, err := http.Get(suite.server.URL + "/api/users")
 	require.NoError(suite.T(), err)
 	defer resp.Body.Close()
-	
+
 	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
-	
+
 	var users []models.User
 	err = json.NewDecoder(resp.Body).Decode(&users)
 	require.NoError(suite.T(), err)
 	return users
 }
 
-func (suite *IntegrationTestSuite) createPost(req models.PostRequest) models.Post {
+// authorize adds an access token for userID to req
+func (suite *IntegrationTestSuite) authorize(req *http.Request, userID int) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString([]byte(testJWTSecret))
+	require.NoError(suite.T(), err)
+	req.Header.Set("Authorization", "Bearer "+signed)
+}
+
+// postPost sends POST /api/posts as userID and returns the response as is
+func (suite *IntegrationTestSuite) postPost(req models.PostRequest, userID int) *http.Response {
 	postJSON, _ := json.Marshal(req)
-	resp, err := http.Post(suite.server.URL+"/posts", "application/json", bytes.NewBuffer(postJSON))
+	httpReq, _ := http.NewRequest("POST", suite.server.URL+"/api/posts", bytes.NewBuffer(postJSON))
+	httpReq.Header.Set("Content-Type", "application/json")
+	suite.authorize(httpReq, userID)
+
+	resp, err := http.DefaultClient.Do(httpReq)
 	require.NoError(suite.T(), err)
+	return resp
+}
+
+// createPost creates a post as its author. Unlike the API, it publishes the
+// post unless req.Status says otherwise, so anonymous reads can see it.
+func (suite *IntegrationTestSuite) createPost(req models.PostRequest) models.Post {
+	if req.Status == "" {
+		req.Status = models.PostStatusPublished
+	}
+	resp := suite.postPost(req, req.UserID)
 	defer resp.Body.Close()
-	
+
 	require.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
-	
+
 	var post models.Post
-	err = json.NewDecoder(resp.Body).Decode(&post)
+	err := json.NewDecoder(resp.Body).Decode(&post)
 	require.NoError(suite.T(), err)
 	return post
 }
 
 func (suite *IntegrationTestSuite) getPost(id int) models.Post {
-	resp, err := http.Get(fmt.Sprintf("%s/posts/%d", suite.server.URL, id))
+	resp, err := http.Get(fmt.Sprintf("%s/api/posts/%d", suite.server.URL, id))
 	require.NoError(suite.T(), err)
 	defer resp.Body.Close()
-	
+
 	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
-	
+
 	var post models.Post
 	err = json.NewDecoder(resp.Body).Decode(&post)
 	require.NoError(suite.T(), err)
 	return post
 }
 
-func (suite *IntegrationTestSuite) updatePost(id int, req models.PostRequest) models.Post {
+func (suite *IntegrationTestSuite) updatePost(id int, req models.PostRequest, userID int) models.Post {
 	postJSON, _ := json.Marshal(req)
 	client := &http.Client{}
-	httpReq, _ := http.NewRequest("PUT", fmt.Sprintf("%s/posts/%d", suite.server.URL, id), bytes.NewBuffer(postJSON))
+	httpReq, _ := http.NewRequest("PUT", fmt.Sprintf("%s/api/posts/%d", suite.server.URL, id), bytes.NewBuffer(postJSON))
 	httpReq.Header.Set("Content-Type", "application/json")
-	
+	suite.authorize(httpReq, userID)
+
 	resp, err := client.Do(httpReq)
 	require.NoError(suite.T(), err)
 	defer resp.Body.Close()
-	
+
 	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
-	
+
 	var post models.Post
 	err = json.NewDecoder(resp.Body).Decode(&post)
 	require.NoError(suite.T(), err)
 	return post
 }
 
-func (suite *IntegrationTestSuite) deletePost(id int) {
+func (suite *IntegrationTestSuite) deletePost(id, userID int) {
 	client := &http.Client{}
-	httpReq, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/posts/%d", suite.server.URL, id), nil)
-	
+	httpReq, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/api/posts/%d", suite.server.URL, id), nil)
+	suite.authorize(httpReq, userID)
+
 	resp, err := client.Do(httpReq)
 	require.NoError(suite.T(), err)
 	defer resp.Body.Close()
-	
-	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	require.Equal(suite.T(), http.StatusNoContent, resp.StatusCode)
 }
 
 func (suite *IntegrationTestSuite) getAllPosts() []models.Post {
-	resp, err := http.Get(suite.server.URL + "/posts")
+	resp, err := http.Get(suite.server.URL + "/api/posts")
 	require.NoError(suite.T(), err)
 	defer resp.Body.Close()
-	
+
 	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
-	
+
 	var posts []models.Post
 	err = json.NewDecoder(resp.Body).Decode(&posts)
 	require.NoError(suite.T(), err)
@@ -355,7 +1605,8 @@ func (suite *IntegrationTestSuite) cleanDatabase() {
 	// Clean up posts first (due to foreign key constraint)
 	suite.db.Exec("DELETE FROM posts")
 	suite.db.Exec("DELETE FROM users")
-	
+	suite.db.Exec("DELETE FROM tags")
+
 	// Reset sequences
 	suite.db.Exec("ALTER SEQUENCE posts_id_seq RESTART WITH 1")
 	suite.db.Exec("ALTER SEQUENCE users_id_seq RESTART WITH 1")
@@ -365,7 +1616,7 @@ func TestIntegrationSuite(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration tests in short mode")
 	}
-	
+
 	suite.Run(t, new(IntegrationTestSuite))
 }
 
@@ -2,28 +2,57 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"blog-api/internal/config"
 	"blog-api/internal/database"
+	"blog-api/internal/events"
 	"blog-api/internal/handlers"
+	"blog-api/internal/notify"
+	"blog-api/internal/tracing"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+// Build information, injected at build time with
+//
+//	go build -ldflags "-X main.version=1.0.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/api
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildTime = "unknown"
+)
+
 func main() {
 	// Configure structured logging
 	zerolog.TimeFieldFormat = time.RFC3339
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: "2006-01-02 15:04:05"})
 
-	// Load configuration
+	// Load configuration, reading a local .env file first if there is one and
+	// then the config file named by CONFIG_FILE
+	envFile := os.Getenv("ENV_FILE")
+	if envFile == "" {
+		envFile = ".env"
+	}
+	if err := config.LoadEnvFile(envFile); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load env file")
+	}
+	if err := config.LoadConfigFile(os.Getenv("CONFIG_FILE")); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load config file")
+	}
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("Invalid configuration")
+	}
 
 	// Set log level
 	switch cfg.LogLevel {
@@ -40,8 +69,19 @@ func main() {
 	}
 
 	log.Info().Msg("Starting Blog API server...")
+	log.Info().Interface("config", cfg.Redacted()).Msg("Loaded configuration")
+	if cfg.DevMode {
+		log.Warn().Msg("DEV_MODE is on, password reset tokens are returned to clients; never use it in production")
+	}
+
+	// Export traces when a collector is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.OTLPEndpoint, "blog-api", version)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
+	}
 
-	// Initialize database connection
+	// Initialize database connection, labeled with the build if it's known
+	cfg.DatabaseAppName = applicationName(cfg.DatabaseAppName, version, commit)
 	db, err := database.New(cfg)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to connect to database")
@@ -52,19 +92,55 @@ func main() {
 		}
 	}()
 
+	// Optionally enforce unique post titles per author
+	if cfg.UniqueTitlePerUser {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := db.EnsureUniqueTitleIndex(ctx)
+		cancel()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to enable unique post titles per user")
+		}
+	}
+
+	// Keep track of whether the database is reachable, so health checks
+	// don't wait on it
+	monitor := handlers.NewHealthMonitor(db, time.Duration(cfg.HealthCheckIntervalMS)*time.Millisecond)
+	monitor.Check(context.Background())
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	defer stopMonitor()
+	go monitor.Run(monitorCtx)
+
+	// Side effects of data changes subscribe to this bus
+	bus := events.NewBus()
+
+	// Publish scheduled posts once they are due
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		runPublishScheduler(schedulerCtx, db, bus, time.Duration(cfg.PublishScheduleIntervalMS)*time.Millisecond)
+	}()
+
 	// Initialize handlers
-	userHandler := handlers.NewUserHandler(db)
-	postHandler := handlers.NewPostHandler(db)
-	healthHandler := handlers.NewHealthHandler(db)
-	webHandler := handlers.NewWebHandler()
+	userHandler := handlers.NewUserHandler(db, bus, cfg)
+	postHandler := handlers.NewPostHandler(db, bus, cfg)
+	healthHandler := handlers.NewHealthHandler(db, monitor)
+	webHandler := handlers.NewWebHandler(db, bus, cfg)
+	versionHandler := handlers.NewVersionHandler(handlers.BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+	})
+	authHandler := handlers.NewAuthHandler(db, cfg, notify.New(cfg))
 
 	// Setup router
-	router := setupRouter(userHandler, postHandler, healthHandler, webHandler)
+	router := setupRouter(cfg, db, userHandler, postHandler, healthHandler, webHandler, versionHandler, authHandler)
 
 	// Configure HTTP server
 	server := &http.Server{
 		Addr:         ":" + cfg.Port,
 		Handler:      router,
+		TLSConfig:    newTLSConfig(),
 		ReadTimeout:  time.Duration(cfg.ReadTimeout) * time.Second,
 		WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
 		IdleTimeout:  time.Duration(cfg.IdleTimeout) * time.Second,
@@ -72,12 +148,48 @@ func main() {
 
 	// Start server in a goroutine
 	go func() {
-		log.Info().Str("port", cfg.Port).Msg("Server starting on port " + cfg.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.TLSEnabled() {
+			log.Info().Str("port", cfg.Port).Msg("Server starting with TLS on port " + cfg.Port)
+			err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			log.Info().Str("port", cfg.Port).Msg("Server starting on port " + cfg.Port)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatal().Err(err).Msg("Failed to start server")
 		}
 	}()
 
+	// Optionally send plain HTTP clients over to HTTPS
+	var redirectServer *http.Server
+	if cfg.TLSEnabled() && cfg.HTTPRedirectPort != "" {
+		redirectServer = &http.Server{
+			Addr:         ":" + cfg.HTTPRedirectPort,
+			Handler:      redirectToHTTPS(cfg.Port),
+			ReadTimeout:  time.Duration(cfg.ReadTimeout) * time.Second,
+			WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
+			IdleTimeout:  time.Duration(cfg.IdleTimeout) * time.Second,
+		}
+		go func() {
+			log.Info().Str("port", cfg.HTTPRedirectPort).Msg("Redirecting HTTP on port " + cfg.HTTPRedirectPort + " to HTTPS")
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal().Err(err).Msg("Failed to start HTTP redirect server")
+			}
+		}()
+	}
+
+	// Take traffic once the schema is known to be migrated. Otherwise the
+	// server stays up but never reports ready.
+	schemaCtx, cancelSchema := context.WithTimeout(context.Background(), 10*time.Second)
+	err = db.CheckSchema(schemaCtx)
+	cancelSchema()
+	if err != nil {
+		log.Error().Err(err).Msg("Database schema is not ready, /health/ready will keep failing")
+	} else {
+		healthHandler.SetReady(true)
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -85,28 +197,102 @@ func main() {
 
 	log.Info().Msg("Server shutting down...")
 
+	// Fail readiness first and give load balancers time to notice before
+	// connections are refused
+	healthHandler.SetReady(false)
+	if cfg.ShutdownDelayMS > 0 {
+		time.Sleep(time.Duration(cfg.ShutdownDelayMS) * time.Millisecond)
+	}
+
 	// Create a deadline to wait for
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	// Attempt graceful shutdown
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(ctx); err != nil {
+			log.Error().Err(err).Msg("HTTP redirect server forced to shutdown")
+		}
+	}
 	if err := server.Shutdown(ctx); err != nil {
 		log.Error().Err(err).Msg("Server forced to shutdown")
 	} else {
 		log.Info().Msg("Server gracefully stopped")
 	}
+
+	// Stop publishing scheduled posts, then let subscribers finish handling
+	// events from the last requests
+	stopScheduler()
+	<-schedulerDone
+	bus.Wait()
+
+	// Send the spans of the last requests
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to flush traces")
+	}
+}
+
+// newTLSConfig returns the TLS settings of the server. TLS 1.0 and 1.1 are
+// refused; they are deprecated and no current client needs them.
+func newTLSConfig() *tls.Config {
+	return &tls.Config{MinVersion: tls.VersionTLS12}
+}
+
+// redirectToHTTPS redirects every request to the same URL over HTTPS on
+// httpsPort. 308 keeps the method and body of the request.
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+		if httpsPort == "443" {
+			if strings.Contains(host, ":") {
+				host = "[" + host + "]"
+			}
+		} else {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// applicationName appends the build version and commit to name, skipping the
+// placeholders used when they weren't injected at build time
+func applicationName(name, version, commit string) string {
+	if name == "" {
+		return name
+	}
+	if version != "" && version != "dev" {
+		name += " " + version
+	}
+	if commit != "" && commit != "unknown" {
+		name += " (" + commit + ")"
+	}
+	return name
 }
 
 // setupRouter configures and returns the HTTP router with all routes and middleware
-func setupRouter(userHandler *handlers.UserHandler, postHandler *handlers.PostHandler, healthHandler *handlers.HealthHandler, webHandler *handlers.WebHandler) *mux.Router {
+func setupRouter(cfg *config.Config, db *database.DB, userHandler *handlers.UserHandler, postHandler *handlers.PostHandler, healthHandler *handlers.HealthHandler, webHandler *handlers.WebHandler, versionHandler *handlers.VersionHandler, authHandler *handlers.AuthHandler) *mux.Router {
 	router := mux.NewRouter()
 
 	// Apply global middleware
+	router.Use(handlers.RequestIDMiddleware)
 	router.Use(handlers.LoggingMiddleware)
+	router.Use(handlers.TracingMiddleware)
+	router.Use(handlers.GzipMiddleware)
 	router.Use(handlers.PanicRecoveryMiddleware)
-	router.Use(handlers.CORSMiddleware)
-	router.Use(handlers.SecurityHeadersMiddleware)
-	router.Use(handlers.TimeoutMiddleware(30 * time.Second))
+	router.Use(handlers.ConcurrencyLimitMiddleware(cfg.MaxConcurrentRequests))
+	router.Use(handlers.CORSMiddleware(cfg))
+	router.Use(handlers.SecurityHeadersMiddleware(cfg))
+	if cfg.CSRFProtection {
+		router.Use(handlers.CSRFMiddleware(cfg))
+	}
+	router.Use(handlers.DecompressionMiddleware(cfg.MaxDecompressedBytes))
+	router.Use(handlers.TimeoutMiddleware(30*time.Second, "/api/admin/export"))
 
 	// Serve static files
 	staticDir := http.Dir("web/static/")
@@ -115,43 +301,165 @@ func setupRouter(userHandler *handlers.UserHandler, postHandler *handlers.PostHa
 
 	// Web interface routes
 	router.HandleFunc("/", webHandler.Index).Methods("GET")
+	router.HandleFunc("/", handlers.OptionsHandler("GET")).Methods("OPTIONS")
 
-	// Health check endpoint
+	// Health check endpoints, with separate liveness and readiness probes
 	router.HandleFunc("/health", healthHandler.HealthCheck).Methods("GET")
+	router.HandleFunc("/health", handlers.OptionsHandler("GET")).Methods("OPTIONS")
+	router.HandleFunc("/health/live", healthHandler.Live).Methods("GET")
+	router.HandleFunc("/health/live", handlers.OptionsHandler("GET")).Methods("OPTIONS")
+	router.HandleFunc("/health/ready", healthHandler.Ready).Methods("GET")
+	router.HandleFunc("/health/ready", handlers.OptionsHandler("GET")).Methods("OPTIONS")
 
 	// API routes
 	api := router.PathPrefix("/api").Subrouter()
+	api.Use(handlers.PoolBackpressureMiddleware(db, time.Duration(cfg.DBAcquireTimeoutMS)*time.Millisecond))
+
+	// Guards admin-only routes, both under /api/admin and elsewhere
+	adminOnly := handlers.AdminMiddleware(cfg.AdminToken)
+
+	// Guards routes that change data on behalf of a logged-in user. Sign-up
+	// and login stay public.
+	var denylist handlers.TokenDenylist
+	if cfg.JWTDenylist {
+		denylist = db
+	}
+	authenticated := handlers.AuthMiddleware(cfg.JWTSecret, denylist)
+	authenticatedOrAdmin := handlers.AuthOrAdminMiddleware(cfg.AdminToken, cfg.JWTSecret, denylist)
+	optionalAuth := handlers.OptionalAuthMiddleware(cfg.AdminToken, cfg.JWTSecret, denylist)
+
+	// Guard the API's reads. They are public unless RequireAuthForReads is
+	// set; readsAsViewer also identifies the caller, who sees their drafts.
+	reads := func(next http.Handler) http.Handler { return next }
+	readsAsViewer := optionalAuth
+	if cfg.RequireAuthForReads {
+		reads = authenticatedOrAdmin
+		readsAsViewer = authenticatedOrAdmin
+	}
+
+	// Guards the routes that guess passwords or create accounts against abuse
+	rateLimited := handlers.RateLimitMiddleware(cfg)
+
+	// Authentication routes
+	api.Handle("/auth/login", rateLimited(http.HandlerFunc(authHandler.Login))).Methods("POST")
+	api.HandleFunc("/auth/refresh", authHandler.Refresh).Methods("POST")
+	api.Handle("/auth/forgot-password", rateLimited(http.HandlerFunc(authHandler.ForgotPassword))).Methods("POST")
+	api.Handle("/auth/reset-password", rateLimited(http.HandlerFunc(authHandler.ResetPassword))).Methods("POST")
+	api.Handle("/auth/logout", authenticated(http.HandlerFunc(authHandler.Logout))).Methods("POST")
+	api.Handle("/auth/validate", authenticated(http.HandlerFunc(authHandler.Validate))).Methods("GET")
 
 	// User routes
-	api.HandleFunc("/users", userHandler.CreateUser).Methods("POST")
-	api.HandleFunc("/users", userHandler.GetAllUsers).Methods("GET")
-	api.HandleFunc("/users/{id:[0-9]+}", userHandler.GetUser).Methods("GET")
-	api.HandleFunc("/users/{id:[0-9]+}", userHandler.UpdateUser).Methods("PUT")
-	api.HandleFunc("/users/{id:[0-9]+}", userHandler.DeleteUser).Methods("DELETE")
+	api.Handle("/users", rateLimited(http.HandlerFunc(userHandler.CreateUser))).Methods("POST")
+	api.Handle("/users", reads(http.HandlerFunc(userHandler.GetAllUsers))).Methods("GET")
+	api.Handle("/users/{id:[0-9]+}", reads(http.HandlerFunc(userHandler.GetUser))).Methods("GET", "HEAD")
+	api.Handle("/users/{id:[0-9]+}", authenticatedOrAdmin(http.HandlerFunc(userHandler.UpdateUser))).Methods("PUT")
+	api.Handle("/users/{id:[0-9]+}", authenticatedOrAdmin(http.HandlerFunc(userHandler.DeleteUser))).Methods("DELETE")
+	api.Handle("/users/{id:[0-9]+}/stats", readsAsViewer(http.HandlerFunc(userHandler.GetUserStats))).Methods("GET")
+	api.Handle("/users/{id:[0-9]+}/posts", readsAsViewer(http.HandlerFunc(postHandler.GetPostsByUser))).Methods("GET")
+	api.Handle("/users/{id:[0-9]+}/password", authenticatedOrAdmin(http.HandlerFunc(userHandler.ChangePassword))).Methods("POST")
+	api.Handle("/users/{id:[0-9]+}/ban", adminOnly(http.HandlerFunc(userHandler.BanUser))).Methods("POST")
+	api.Handle("/users/{id:[0-9]+}/unban", adminOnly(http.HandlerFunc(userHandler.UnbanUser))).Methods("POST")
 
 	// Post routes
-	api.HandleFunc("/posts", postHandler.CreatePost).Methods("POST")
-	api.HandleFunc("/posts", postHandler.GetAllPosts).Methods("GET")
-	api.HandleFunc("/posts/{id:[0-9]+}", postHandler.GetPost).Methods("GET")
-	api.HandleFunc("/posts/{id:[0-9]+}", postHandler.UpdatePost).Methods("PUT")
-	api.HandleFunc("/posts/{id:[0-9]+}", postHandler.DeletePost).Methods("DELETE")
+	api.Handle("/posts", authenticatedOrAdmin(http.HandlerFunc(postHandler.CreatePost))).Methods("POST")
+	api.Handle("/posts", readsAsViewer(http.HandlerFunc(postHandler.GetAllPosts))).Methods("GET")
+	api.Handle("/posts/featured", reads(http.HandlerFunc(postHandler.GetFeaturedPosts))).Methods("GET")
+	api.Handle("/posts/archive", reads(http.HandlerFunc(postHandler.GetPostArchive))).Methods("GET")
+	api.Handle("/posts/search", reads(http.HandlerFunc(postHandler.SearchPosts))).Methods("GET")
+	api.Handle("/posts/{id:[0-9]+}", readsAsViewer(http.HandlerFunc(postHandler.GetPost))).Methods("GET", "HEAD")
+	api.Handle("/posts/{id:[0-9]+}", authenticatedOrAdmin(http.HandlerFunc(postHandler.UpdatePost))).Methods("PUT")
+	api.Handle("/posts/{id:[0-9]+}", authenticatedOrAdmin(http.HandlerFunc(postHandler.DeletePost))).Methods("DELETE")
+	api.Handle("/posts/{id:[0-9]+}/tags", authenticatedOrAdmin(http.HandlerFunc(postHandler.SetPostTags))).Methods("PUT")
+	api.Handle("/posts/{id:[0-9]+}/tags/{tag}", authenticatedOrAdmin(http.HandlerFunc(postHandler.AddPostTag))).Methods("POST")
+	api.Handle("/posts/{id:[0-9]+}/tags/{tag}", authenticatedOrAdmin(http.HandlerFunc(postHandler.RemovePostTag))).Methods("DELETE")
+	api.Handle("/posts/{id:[0-9]+}/siblings", reads(http.HandlerFunc(postHandler.GetPostSiblings))).Methods("GET")
+	api.Handle("/posts/{id:[0-9]+}/publish", authenticated(http.HandlerFunc(postHandler.PublishPost))).Methods("POST")
+	api.Handle("/posts/{id:[0-9]+}/autosave", authenticated(http.HandlerFunc(postHandler.AutosaveDraft))).Methods("PUT")
+	api.Handle("/posts/{id:[0-9]+}/move", adminOnly(http.HandlerFunc(postHandler.MovePost))).Methods("POST")
+	api.Handle("/posts/{id:[0-9]+}/restore", adminOnly(http.HandlerFunc(postHandler.RestorePost))).Methods("POST")
+	api.Handle("/posts/{id:[0-9]+}/feature", adminOnly(http.HandlerFunc(postHandler.FeaturePost))).Methods("POST")
+	api.Handle("/posts/{id:[0-9]+}/unfeature", adminOnly(http.HandlerFunc(postHandler.UnfeaturePost))).Methods("POST")
+
+	// Admin routes
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(adminOnly)
+	admin.HandleFunc("/posts/reset-views", postHandler.ResetViewCounts).Methods("POST")
+	admin.HandleFunc("/users/{id:[0-9]+}/transfer-posts", postHandler.TransferUserPosts).Methods("POST")
+	admin.HandleFunc("/export", postHandler.ExportPosts).Methods("GET")
+	admin.HandleFunc("/db", healthHandler.DatabaseStatus).Methods("GET")
 
 	// API Health check
 	api.HandleFunc("/health", healthHandler.HealthCheck).Methods("GET")
+	api.HandleFunc("/health", handlers.OptionsHandler("GET")).Methods("OPTIONS")
 
-	// 404 handler
-	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error":"Not Found","message":"The requested resource was not found","code":404}`))
-	})
+	// Build information
+	api.HandleFunc("/version", versionHandler.Version).Methods("GET")
+
+	// Admin page, used with a session cookie from logging in with the admin
+	// token. Its forms always carry a CSRF token.
+	adminPage := router.PathPrefix("/admin").Subrouter()
+	if !cfg.CSRFProtection {
+		adminPage.Use(handlers.CSRFMiddleware(cfg))
+	}
+	adminPage.HandleFunc("/login", webHandler.AdminLoginPage).Methods("GET")
+	adminPage.Handle("/login", rateLimited(http.HandlerFunc(webHandler.AdminLogin))).Methods("POST")
+	adminPage.HandleFunc("/logout", webHandler.AdminLogout).Methods("POST")
+	adminPage.Handle("", webHandler.AdminSession(http.HandlerFunc(webHandler.AdminPosts))).Methods("GET")
+	adminPage.Handle("/posts/{id:[0-9]+}/edit", webHandler.AdminSession(http.HandlerFunc(webHandler.AdminEditPage))).Methods("GET")
+	adminPage.Handle("/posts/{id:[0-9]+}/edit", webHandler.AdminSession(http.HandlerFunc(webHandler.AdminEditPost))).Methods("POST")
+	adminPage.Handle("/posts/{id:[0-9]+}/publish", webHandler.AdminSession(http.HandlerFunc(webHandler.AdminPublishPost))).Methods("POST")
+	adminPage.Handle("/posts/{id:[0-9]+}/delete", webHandler.AdminSession(http.HandlerFunc(webHandler.AdminDeletePost))).Methods("POST")
 
 	// 405 handler
-	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	methodNotAllowed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowedMethods(router, r), ", "))
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		w.Write([]byte(`{"error":"Method Not Allowed","message":"The request method is not allowed for this resource","code":405}`))
 	})
+	// mux skips middleware for unmatched requests, so they're tagged and logged
+	// here. CORS preflights are OPTIONS requests, which most routes don't
+	// register, so they end up here too and are answered by the CORS middleware.
+	unmatchedMethod := handlers.CORSMiddleware(cfg)(methodNotAllowed)
+	router.MethodNotAllowedHandler = handlers.RequestIDMiddleware(handlers.LoggingMiddleware(unmatchedMethod))
+
+	// 404 handler. mux reports some method mismatches as not found (a later
+	// route on a different path clears the mismatch), so check for those first.
+	router.NotFoundHandler = handlers.RequestIDMiddleware(handlers.LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(allowedMethods(router, r)) > 0 {
+			unmatchedMethod.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"Not Found","message":"The requested resource was not found","code":404}`))
+	})))
 
 	return router
 }
+
+// routeMethods are the methods checked when building a 405 Allow header
+var routeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// allowedMethods lists the methods registered for the path of r
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	var allowed []string
+	for _, method := range routeMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+
+		var match mux.RouteMatch
+		if router.Match(probe, &match) && match.MatchErr == nil {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
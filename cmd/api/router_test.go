@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"blog-api/internal/config"
+	"blog-api/internal/handlers"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRouter builds the full router without a database, for exercising
+// routing behavior that never reaches a handler's queries. With no pool
+// there is nothing to apply backpressure to.
+func newTestRouter(cfg *config.Config) *mux.Router {
+	noPool := *cfg
+	noPool.DBAcquireTimeoutMS = 0
+
+	return setupRouter(
+		&noPool,
+		nil,
+		handlers.NewUserHandler(nil, nil, cfg),
+		handlers.NewPostHandler(nil, nil, cfg),
+		handlers.NewHealthHandler(nil, nil),
+		handlers.NewWebHandler(nil, nil, cfg),
+		handlers.NewVersionHandler(handlers.BuildInfo{Version: "test"}),
+		handlers.NewAuthHandler(nil, cfg, nil),
+	)
+}
+
+func TestOptionsAllowHeader(t *testing.T) {
+	router := newTestRouter(config.Load())
+
+	for _, path := range []string{"/", "/health", "/health/live", "/health/ready", "/api/health"} {
+		t.Run(path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, path, nil))
+
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+			assert.Equal(t, "GET, OPTIONS", rec.Header().Get("Allow"))
+			assert.Empty(t, rec.Body.String())
+		})
+	}
+}
+
+func TestMethodNotAllowedAllowHeader(t *testing.T) {
+	router := newTestRouter(config.Load())
+
+	tests := []struct {
+		method string
+		path   string
+		allow  string
+	}{
+		{http.MethodDelete, "/api/health", "GET, OPTIONS"},
+		{http.MethodPost, "/api/posts/1", "GET, HEAD, PUT, DELETE"},
+		{http.MethodPost, "/api/posts/featured", "GET"},
+		{http.MethodGet, "/api/admin/posts/reset-views", "POST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+			assert.Equal(t, tt.allow, rec.Header().Get("Allow"))
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	cfg := config.Load()
+	cfg.CORSAllowedOrigins = []string{"https://app.example.com"}
+	router := newTestRouter(cfg)
+
+	preflight := func(path, origin, method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Routes without an OPTIONS handler of their own
+	for _, tt := range []struct{ path, method string }{
+		{"/api/posts", http.MethodPost},
+		{"/api/posts/1", http.MethodPut},
+		{"/api/users/1", http.MethodDelete},
+		{"/api/auth/login", http.MethodPost},
+	} {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := preflight(tt.path, "https://app.example.com", tt.method)
+
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+			assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+			assert.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), tt.method)
+			assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+		})
+	}
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		rec := preflight("/api/posts", "https://evil.example.com", http.MethodPost)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("unknown path", func(t *testing.T) {
+		rec := preflight("/api/nope", "https://app.example.com", http.MethodPost)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
+
+func TestNotFoundStillNotFound(t *testing.T) {
+	router := newTestRouter(config.Load())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/nope", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, rec.Header().Get("Allow"))
+}
+
+func TestDatabaseStatusRequiresAdmin(t *testing.T) {
+	cfg := config.Load()
+	cfg.AdminToken = "secret"
+	router := newTestRouter(cfg)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/admin/db", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRestorePostRequiresAdmin(t *testing.T) {
+	cfg := config.Load()
+	cfg.AdminToken = "secret"
+	router := newTestRouter(cfg)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/posts/1/restore", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAdminPageRequiresSession(t *testing.T) {
+	cfg := config.Load()
+	cfg.AdminToken = "secret"
+	router := newTestRouter(cfg)
+
+	// The page uses a session cookie; the API's bearer token doesn't open it
+	for _, bearer := range []string{"", "secret"} {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestMutatingRoutesRequireAuthentication(t *testing.T) {
+	cfg := config.Load()
+	cfg.JWTSecret = "secret"
+	router := newTestRouter(cfg)
+
+	routes := []struct{ method, path string }{
+		{http.MethodPost, "/api/posts"},
+		{http.MethodPut, "/api/posts/1"},
+		{http.MethodDelete, "/api/posts/1"},
+		{http.MethodPut, "/api/users/1"},
+		{http.MethodDelete, "/api/users/1"},
+	}
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(route.method, route.path, nil))
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		})
+	}
+}
+
+func TestRequireAuthForReads(t *testing.T) {
+	cfg := config.Load()
+	cfg.JWTSecret = "secret"
+	cfg.RequireAuthForReads = true
+	router := newTestRouter(cfg)
+
+	for _, path := range []string{"/api/posts", "/api/posts/1", "/api/posts/search?q=go", "/api/users", "/api/users/1/posts"} {
+		t.Run(path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		})
+	}
+
+	// Health checks and the version stay public for smoke tests
+	for _, path := range []string{"/health/live", "/api/version"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+	}
+
+	// Reads are public while the flag is off; this one stops at validation
+	cfg.RequireAuthForReads = false
+	router = newTestRouter(cfg)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/posts/search", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestApplicationName(t *testing.T) {
+	assert.Equal(t, "blog-api", applicationName("blog-api", "dev", "unknown"))
+	assert.Equal(t, "blog-api 1.2.0 (abc123)", applicationName("blog-api", "1.2.0", "abc123"))
+	assert.Equal(t, "blog-api (abc123)", applicationName("blog-api", "dev", "abc123"))
+	assert.Equal(t, "", applicationName("", "1.2.0", "abc123"))
+}
+
+func TestServeOverTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(newTestRouter(config.Load()))
+	server.TLS = newTLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/api/version")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotNil(t, resp.TLS)
+	assert.GreaterOrEqual(t, resp.TLS.Version, uint16(tls.VersionTLS12))
+
+	// Clients stuck on TLS 1.1 are turned away
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.MinVersion = tls.VersionTLS10
+	transport.TLSClientConfig.MaxVersion = tls.VersionTLS11
+	_, err = (&http.Client{Transport: transport}).Get(server.URL + "/api/version")
+	assert.Error(t, err)
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		port   string
+		target string
+		want   string
+	}{
+		{port: "8443", target: "http://blog.example.com:8080/api/posts?tag=go", want: "https://blog.example.com:8443/api/posts?tag=go"},
+		{port: "443", target: "http://blog.example.com/", want: "https://blog.example.com/"},
+		{port: "443", target: "http://[::1]:8080/health", want: "https://[::1]/health"},
+		{port: "8443", target: "http://[::1]/health", want: "https://[::1]:8443/health"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			redirectToHTTPS(tt.port).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, nil))
+
+			assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+			assert.Equal(t, tt.want, rec.Header().Get("Location"))
+		})
+	}
+}
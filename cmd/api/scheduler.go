@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"blog-api/internal/events"
+	"blog-api/internal/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// duePostPublisher publishes the scheduled posts that are due
+type duePostPublisher interface {
+	PublishDuePosts(ctx context.Context) ([]models.Post, error)
+}
+
+// runPublishScheduler publishes due scheduled posts every interval until ctx
+// is done, announcing each one on bus like any other post update
+func runPublishScheduler(ctx context.Context, publisher duePostPublisher, bus *events.Bus, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			publishDuePosts(ctx, publisher, bus)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// publishDuePosts runs one round of the scheduler, logging rather than
+// returning a failure since nobody is waiting on it
+func publishDuePosts(ctx context.Context, publisher duePostPublisher, bus *events.Bus) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	posts, err := publisher.PublishDuePosts(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to publish scheduled posts")
+		return
+	}
+	for _, post := range posts {
+		log.Info().Int("post_id", post.ID).Int("user_id", post.UserID).Msg("Scheduled post published")
+		bus.Publish(events.PostUpdated{Post: post})
+	}
+}
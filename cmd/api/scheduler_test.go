@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"blog-api/internal/events"
+	"blog-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePublisher hands out its due posts once, counting the rounds
+type fakePublisher struct {
+	mu     sync.Mutex
+	due    []models.Post
+	err    error
+	rounds int
+}
+
+func (p *fakePublisher) PublishDuePosts(context.Context) ([]models.Post, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rounds++
+	due := p.due
+	p.due = nil
+	return due, p.err
+}
+
+func (p *fakePublisher) roundCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rounds
+}
+
+func TestPublishDuePostsAnnouncesPosts(t *testing.T) {
+	bus := events.NewSyncBus()
+	var updated []int
+	bus.Subscribe(func(e events.Event) {
+		if e, ok := e.(events.PostUpdated); ok {
+			updated = append(updated, e.Post.ID)
+		}
+	})
+
+	publisher := &fakePublisher{due: []models.Post{{ID: 1}, {ID: 2}}}
+	publishDuePosts(context.Background(), publisher, bus)
+	assert.Equal(t, []int{1, 2}, updated)
+
+	// A failed round announces nothing
+	publisher.err = errors.New("connection refused")
+	publishDuePosts(context.Background(), publisher, bus)
+	assert.Equal(t, []int{1, 2}, updated)
+}
+
+func TestRunPublishScheduler(t *testing.T) {
+	publisher := &fakePublisher{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		runPublishScheduler(ctx, publisher, nil, 5*time.Millisecond)
+	}()
+
+	require.Eventually(t, func() bool { return publisher.roundCount() >= 2 }, time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler didn't stop after its context was canceled")
+	}
+}
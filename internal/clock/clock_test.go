@@ -0,0 +1,27 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+	assert.Equal(t, start, fake.Now())
+
+	fake.Advance(90 * time.Second)
+	assert.Equal(t, start.Add(90*time.Second), fake.Now())
+
+	later := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	fake.Set(later)
+	assert.Equal(t, later, fake.Now())
+}
+
+func TestRealIsCurrent(t *testing.T) {
+	before := time.Now()
+	now := Real{}.Now()
+	assert.False(t, now.Before(before))
+}
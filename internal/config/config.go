@@ -1,8 +1,15 @@
 package config
 
 import (
+	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
+
+	"blog-api/internal/models"
 )
 
 // Config holds all configuration for our application
@@ -19,6 +26,204 @@ type Config struct {
 	WriteTimeout   int
 	IdleTimeout    int
 	MaxConnections int
+
+	// TLSCertFile and TLSKeyFile, when both are set, make the server speak
+	// HTTPS with that certificate and key
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// HTTPRedirectPort, when set together with TLS, is a second port serving
+	// plain HTTP that redirects every request to HTTPS
+	HTTPRedirectPort string
+
+	// DatabaseAppName is reported to Postgres as application_name so the
+	// service's connections are labeled in pg_stat_activity
+	DatabaseAppName string
+
+	// DBAcquireTimeoutMS is how long an API request waits for a free database
+	// connection before getting a 503; 0 waits for the request's own deadline
+	DBAcquireTimeoutMS int
+
+	// MaxConcurrentRequests caps in-flight requests; 0 means unlimited
+	MaxConcurrentRequests int
+
+	// MaxConcurrentRenders caps in-flight web page renders, separately from
+	// the API; 0 means unlimited
+	MaxConcurrentRenders int
+
+	// RateLimitRPS is how many login and sign-up requests per second each
+	// client IP may make on average, after a burst of RateLimitBurst; 0
+	// disables rate limiting
+	RateLimitRPS   float64
+	RateLimitBurst int
+
+	// AutosaveRPS is how many autosaves per second each draft may take on
+	// average, after a burst of AutosaveBurst; 0 disables the limit
+	AutosaveRPS   float64
+	AutosaveBurst int
+
+	// RootRedirect, when set, is a local path that GET / redirects to
+	// instead of serving the landing page
+	RootRedirect string
+
+	// MaxRequestBytes caps the size of JSON request bodies
+	MaxRequestBytes int64
+
+	// MaxDecompressedBytes caps the size of compressed request bodies once inflated
+	MaxDecompressedBytes int64
+
+	// MaxJSONDepth and MaxJSONElements bound how deeply nested and how large
+	// a JSON request body may be; 0 means unlimited
+	MaxJSONDepth    int
+	MaxJSONElements int
+
+	// AllowTrailingJSON accepts request bodies with more content after the
+	// first JSON value, which is then ignored
+	AllowTrailingJSON bool
+
+	// Security headers sent with every response
+	ContentSecurityPolicy string
+	HSTSMaxAge            int
+	ReferrerPolicy        string
+	PermissionsPolicy     string
+	FrameOptions          string
+
+	// CSRFProtection requires a CSRF token on unsafe requests that aren't
+	// authenticated with a bearer token
+	CSRFProtection bool
+
+	// CORSAllowedOrigins lists the origins, such as https://app.example.com,
+	// whose scripts may call the API from a browser; "*" allows any origin.
+	// Cross-origin requests are refused when it is empty.
+	CORSAllowedOrigins []string
+
+	// CORSAllowCredentials lets allowed origins send cookies along. It can't
+	// be combined with the "*" origin.
+	CORSAllowCredentials bool
+
+	// ExportBatchSize is how many posts the admin export reads per query
+	ExportBatchSize int
+
+	// TrustedProxies lists the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-* headers are believed
+	TrustedProxies []string
+
+	// PublicBaseURL, when set, is used for generated links instead of the
+	// scheme and host of the incoming request
+	PublicBaseURL string
+
+	// AdminToken is the bearer token required by the /api/admin endpoints.
+	// The admin API is disabled when it is empty.
+	AdminToken string
+
+	// JWTSecret signs the access tokens issued by POST /api/auth/login.
+	// Login is disabled when it is empty.
+	JWTSecret string
+
+	// JWTExpiryMinutes is how long an access token stays valid
+	JWTExpiryMinutes int
+
+	// RefreshTokenExpiryHours is how long a refresh token can be exchanged
+	// for a new access token
+	RefreshTokenExpiryHours int
+
+	// PasswordResetExpiryMinutes is how long a password reset token sent by
+	// POST /api/auth/forgot-password can be used
+	PasswordResetExpiryMinutes int
+
+	// JWTDenylist makes logout revoke the access token too, at the cost of a
+	// database lookup on every authenticated request
+	JWTDenylist bool
+
+	// RequireAuthForReads makes the API's GET routes require an access token
+	// or the admin token too. The web landing page and health checks stay
+	// public.
+	RequireAuthForReads bool
+
+	// DeleteMissingNotFound makes DELETE of an already-deleted resource return
+	// 404 instead of 204. Off by default so retried deletes succeed.
+	DeleteMissingNotFound bool
+
+	// EmptyListNoContent makes list endpoints answer 204 No Content instead
+	// of 200 with [] when there is nothing to list
+	EmptyListNoContent bool
+
+	// MaxListRows caps how many posts GET /api/posts reads and returns;
+	// 0 means unlimited
+	MaxListRows int
+
+	// SparseFieldsLimit caps how many fields ?fields= may name; 0 means unlimited
+	SparseFieldsLimit int
+
+	// ViewFlushIntervalMS buffers post view counts in memory and writes them
+	// to the database this often; 0 writes every view immediately
+	ViewFlushIntervalMS int
+
+	// PublishScheduleIntervalMS is how often scheduled posts that are due get
+	// published
+	PublishScheduleIntervalMS int
+
+	// ShutdownDelayMS is how long the server keeps serving after failing its
+	// readiness probe on shutdown, so load balancers can drain it
+	ShutdownDelayMS int
+
+	// HealthCheckIntervalMS is how often the database is pinged in the
+	// background; /health reports the last result
+	HealthCheckIntervalMS int
+
+	// CompressPostContent stores post content gzip-compressed in the database.
+	// Existing plain-text posts stay readable either way.
+	CompressPostContent bool
+
+	// DefaultContentFormat is used for new posts that don't specify a format
+	DefaultContentFormat string
+
+	// ModerationDenylist lists words that get a new post rejected
+	ModerationDenylist []string
+
+	// ModerationTimeoutMS bounds how long post moderation may take before the
+	// post is let through; 0 means no limit
+	ModerationTimeoutMS int
+
+	// UniqueTitlePerUser enforces case-insensitive unique post titles per
+	// author, among posts that aren't deleted
+	UniqueTitlePerUser bool
+
+	// OTLPEndpoint is the OTLP/HTTP collector URL traces are exported to;
+	// empty disables tracing
+	OTLPEndpoint string
+
+	// SMTP server for outgoing email. Without SMTPHost no email is sent.
+	SMTPHost string
+	SMTPPort int
+	SMTPUser string
+	SMTPPass string
+	// SMTPFrom is the sender address; it defaults to SMTPUser
+	SMTPFrom string
+
+	// DevMode returns password reset tokens in the response of POST
+	// /api/auth/forgot-password, since no email carries them without SMTP.
+	// It is refused together with SMTP or TLS, so it can't reach production.
+	DevMode bool
+}
+
+// defaultContentSecurityPolicy allows the landing page's own assets plus the
+// font and icon CDNs it links to
+const defaultContentSecurityPolicy = "default-src 'self'; " +
+	"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com https://cdnjs.cloudflare.com; " +
+	"font-src 'self' https://fonts.gstatic.com https://cdnjs.cloudflare.com; " +
+	"img-src 'self' data:; frame-ancestors 'none'"
+
+// validReferrerPolicies lists the values accepted for the Referrer-Policy header
+var validReferrerPolicies = map[string]bool{
+	"no-referrer":                     true,
+	"no-referrer-when-downgrade":      true,
+	"origin":                          true,
+	"origin-when-cross-origin":        true,
+	"same-origin":                     true,
+	"strict-origin":                   true,
+	"strict-origin-when-cross-origin": true,
+	"unsafe-url":                      true,
 }
 
 // Load returns a new config struct
@@ -36,7 +241,403 @@ func Load() *Config {
 		WriteTimeout:   getEnvAsInt("WRITE_TIMEOUT", 10),
 		IdleTimeout:    getEnvAsInt("IDLE_TIMEOUT", 120),
 		MaxConnections: getEnvAsInt("MAX_DB_CONNECTIONS", 25),
+
+		TLSCertFile:      getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:       getEnv("TLS_KEY_FILE", ""),
+		HTTPRedirectPort: getEnv("HTTP_REDIRECT_PORT", ""),
+
+		DatabaseAppName: getEnv("DB_APPLICATION_NAME", "blog-api"),
+
+		DBAcquireTimeoutMS: getEnvAsInt("DB_ACQUIRE_TIMEOUT_MS", 1000),
+
+		MaxConcurrentRequests: getEnvAsInt("MAX_CONCURRENT_REQUESTS", 0),
+
+		MaxConcurrentRenders: getEnvAsInt("MAX_CONCURRENT_RENDERS", 16),
+
+		RateLimitRPS:   getEnvAsFloat("RATE_LIMIT_RPS", 1),
+		RateLimitBurst: getEnvAsInt("RATE_LIMIT_BURST", 5),
+		AutosaveRPS:    getEnvAsFloat("AUTOSAVE_RPS", 1),
+		AutosaveBurst:  getEnvAsInt("AUTOSAVE_BURST", 5),
+
+		RootRedirect: getEnv("ROOT_REDIRECT", ""),
+
+		MaxRequestBytes:      int64(getEnvAsInt("MAX_REQUEST_BYTES", 1<<20)),
+		MaxDecompressedBytes: int64(getEnvAsInt("MAX_DECOMPRESSED_BYTES", 10<<20)),
+
+		MaxJSONDepth:    getEnvAsInt("MAX_JSON_DEPTH", 32),
+		MaxJSONElements: getEnvAsInt("MAX_JSON_ELEMENTS", 10000),
+
+		AllowTrailingJSON: getEnvAsBool("ALLOW_TRAILING_JSON", false),
+
+		ContentSecurityPolicy: getEnv("CONTENT_SECURITY_POLICY", defaultContentSecurityPolicy),
+		HSTSMaxAge:            getEnvAsInt("HSTS_MAX_AGE", 31536000),
+		ReferrerPolicy:        getEnv("REFERRER_POLICY", "strict-origin-when-cross-origin"),
+		PermissionsPolicy:     getEnv("PERMISSIONS_POLICY", "camera=(), microphone=(), geolocation=()"),
+		FrameOptions:          getEnv("FRAME_OPTIONS", "DENY"),
+
+		TrustedProxies: getEnvAsList("TRUSTED_PROXIES"),
+		PublicBaseURL:  getEnv("PUBLIC_BASE_URL", ""),
+
+		AdminToken: getEnv("ADMIN_TOKEN", ""),
+
+		JWTSecret:        getEnv("JWT_SECRET", ""),
+		JWTExpiryMinutes: getEnvAsInt("JWT_EXPIRY_MINUTES", 15),
+
+		RefreshTokenExpiryHours: getEnvAsInt("REFRESH_TOKEN_EXPIRY_HOURS", 720),
+		JWTDenylist:             getEnvAsBool("JWT_DENYLIST", false),
+		RequireAuthForReads:     getEnvAsBool("REQUIRE_AUTH_FOR_READS", false),
+
+		PasswordResetExpiryMinutes: getEnvAsInt("PASSWORD_RESET_EXPIRY_MINUTES", 60),
+
+		ExportBatchSize: getEnvAsInt("EXPORT_BATCH_SIZE", 500),
+
+		CSRFProtection: getEnvAsBool("CSRF_PROTECTION", false),
+
+		CORSAllowedOrigins:   getEnvAsList("CORS_ALLOWED_ORIGINS"),
+		CORSAllowCredentials: getEnvAsBool("CORS_ALLOW_CREDENTIALS", false),
+
+		DefaultContentFormat: getEnv("DEFAULT_CONTENT_FORMAT", "markdown"),
+		CompressPostContent:  getEnvAsBool("COMPRESS_POST_CONTENT", false),
+
+		ViewFlushIntervalMS: getEnvAsInt("VIEW_FLUSH_INTERVAL_MS", 5000),
+
+		HealthCheckIntervalMS: getEnvAsInt("HEALTH_CHECK_INTERVAL_MS", 5000),
+		ShutdownDelayMS:       getEnvAsInt("SHUTDOWN_DELAY_MS", 0),
+
+		PublishScheduleIntervalMS: getEnvAsInt("PUBLISH_SCHEDULE_INTERVAL_MS", 30000),
+
+		SparseFieldsLimit: getEnvAsInt("SPARSE_FIELDS_LIMIT", 10),
+
+		MaxListRows: getEnvAsInt("MAX_LIST_ROWS", 10000),
+
+		EmptyListNoContent: getEnvAsBool("EMPTY_LIST_NO_CONTENT", false),
+
+		DeleteMissingNotFound: getEnvAsBool("DELETE_MISSING_NOT_FOUND", false),
+
+		UniqueTitlePerUser: getEnvAsBool("UNIQUE_TITLE_PER_USER", false),
+
+		ModerationDenylist:  getEnvAsList("MODERATION_DENYLIST"),
+		ModerationTimeoutMS: getEnvAsInt("MODERATION_TIMEOUT_MS", 2000),
+
+		OTLPEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+
+		SMTPHost: getEnv("SMTP_HOST", ""),
+		SMTPPort: getEnvAsInt("SMTP_PORT", 587),
+		SMTPUser: getEnv("SMTP_USER", ""),
+		SMTPPass: getEnv("SMTP_PASS", ""),
+		SMTPFrom: getEnv("SMTP_FROM", getEnv("SMTP_USER", "")),
+
+		DevMode: getEnvAsBool("DEV_MODE", false),
+	}
+}
+
+// Validate checks that the configuration values are usable
+func (c *Config) Validate() error {
+	if c.DatabaseURL == "" {
+		var missing []string
+		for _, setting := range []struct{ name, value string }{
+			{"DB_HOST", c.DatabaseHost},
+			{"DB_USER", c.DatabaseUser},
+			{"DB_NAME", c.DatabaseName},
+		} {
+			if setting.value == "" {
+				missing = append(missing, setting.name)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("DATABASE_URL or DB_HOST, DB_USER and DB_NAME must be set, missing %s", strings.Join(missing, ", "))
+		}
+	}
+
+	if !isPort(c.Port) {
+		return fmt.Errorf("PORT must be a port number between 1 and 65535, got %q", c.Port)
+	}
+
+	for _, timeout := range []struct {
+		name  string
+		value int
+	}{
+		{"READ_TIMEOUT", c.ReadTimeout},
+		{"WRITE_TIMEOUT", c.WriteTimeout},
+		{"IDLE_TIMEOUT", c.IdleTimeout},
+	} {
+		if timeout.value <= 0 {
+			return fmt.Errorf("%s must be a positive number of seconds, got %d", timeout.name, timeout.value)
+		}
+	}
+
+	if c.MaxConnections < 1 {
+		return fmt.Errorf("MAX_DB_CONNECTIONS must be at least 1, got %d", c.MaxConnections)
+	}
+
+	if c.HSTSMaxAge < 0 {
+		return fmt.Errorf("HSTS_MAX_AGE must not be negative, got %d", c.HSTSMaxAge)
+	}
+
+	if c.DBAcquireTimeoutMS < 0 {
+		return fmt.Errorf("DB_ACQUIRE_TIMEOUT_MS must not be negative, got %d", c.DBAcquireTimeoutMS)
+	}
+
+	if c.ViewFlushIntervalMS < 0 {
+		return fmt.Errorf("VIEW_FLUSH_INTERVAL_MS must not be negative, got %d", c.ViewFlushIntervalMS)
+	}
+
+	if c.HealthCheckIntervalMS <= 0 {
+		return fmt.Errorf("HEALTH_CHECK_INTERVAL_MS must be positive, got %d", c.HealthCheckIntervalMS)
+	}
+
+	if c.PublishScheduleIntervalMS <= 0 {
+		return fmt.Errorf("PUBLISH_SCHEDULE_INTERVAL_MS must be positive, got %d", c.PublishScheduleIntervalMS)
+	}
+
+	if c.ShutdownDelayMS < 0 {
+		return fmt.Errorf("SHUTDOWN_DELAY_MS must not be negative, got %d", c.ShutdownDelayMS)
+	}
+
+	if c.MaxConcurrentRequests < 0 {
+		return fmt.Errorf("MAX_CONCURRENT_REQUESTS must not be negative, got %d", c.MaxConcurrentRequests)
+	}
+
+	if c.RateLimitRPS < 0 {
+		return fmt.Errorf("RATE_LIMIT_RPS must not be negative, got %g", c.RateLimitRPS)
+	}
+
+	if c.RateLimitRPS > 0 && c.RateLimitBurst < 1 {
+		return fmt.Errorf("RATE_LIMIT_BURST must be at least 1, got %d", c.RateLimitBurst)
+	}
+
+	if c.AutosaveRPS < 0 {
+		return fmt.Errorf("AUTOSAVE_RPS must not be negative, got %g", c.AutosaveRPS)
+	}
+
+	if c.AutosaveRPS > 0 && c.AutosaveBurst < 1 {
+		return fmt.Errorf("AUTOSAVE_BURST must be at least 1, got %d", c.AutosaveBurst)
+	}
+
+	if c.MaxConcurrentRenders < 0 {
+		return fmt.Errorf("MAX_CONCURRENT_RENDERS must not be negative, got %d", c.MaxConcurrentRenders)
+	}
+
+	if c.MaxRequestBytes <= 0 {
+		return fmt.Errorf("MAX_REQUEST_BYTES must be positive, got %d", c.MaxRequestBytes)
+	}
+
+	if c.MaxJSONDepth < 0 {
+		return fmt.Errorf("MAX_JSON_DEPTH must not be negative, got %d", c.MaxJSONDepth)
+	}
+
+	if c.MaxJSONElements < 0 {
+		return fmt.Errorf("MAX_JSON_ELEMENTS must not be negative, got %d", c.MaxJSONElements)
+	}
+
+	if c.ExportBatchSize <= 0 {
+		return fmt.Errorf("EXPORT_BATCH_SIZE must be positive, got %d", c.ExportBatchSize)
+	}
+
+	if c.JWTExpiryMinutes <= 0 {
+		return fmt.Errorf("JWT_EXPIRY_MINUTES must be positive, got %d", c.JWTExpiryMinutes)
+	}
+
+	if c.RequireAuthForReads && c.JWTSecret == "" && c.AdminToken == "" {
+		return fmt.Errorf("REQUIRE_AUTH_FOR_READS needs JWT_SECRET or ADMIN_TOKEN to be set, or nothing could be read")
+	}
+
+	if c.RefreshTokenExpiryHours <= 0 {
+		return fmt.Errorf("REFRESH_TOKEN_EXPIRY_HOURS must be positive, got %d", c.RefreshTokenExpiryHours)
+	}
+
+	if c.PasswordResetExpiryMinutes <= 0 {
+		return fmt.Errorf("PASSWORD_RESET_EXPIRY_MINUTES must be positive, got %d", c.PasswordResetExpiryMinutes)
+	}
+
+	if c.MaxListRows < 0 {
+		return fmt.Errorf("MAX_LIST_ROWS must not be negative, got %d", c.MaxListRows)
+	}
+
+	if c.SparseFieldsLimit < 0 {
+		return fmt.Errorf("SPARSE_FIELDS_LIMIT must not be negative, got %d", c.SparseFieldsLimit)
+	}
+
+	if c.ModerationTimeoutMS < 0 {
+		return fmt.Errorf("MODERATION_TIMEOUT_MS must not be negative, got %d", c.ModerationTimeoutMS)
+	}
+
+	if c.FrameOptions != "DENY" && c.FrameOptions != "SAMEORIGIN" {
+		return fmt.Errorf("FRAME_OPTIONS must be DENY or SAMEORIGIN, got %q", c.FrameOptions)
+	}
+
+	if c.DefaultContentFormat != models.ContentFormatMarkdown && c.DefaultContentFormat != models.ContentFormatHTML {
+		return fmt.Errorf("DEFAULT_CONTENT_FORMAT must be %s or %s, got %q",
+			models.ContentFormatMarkdown, models.ContentFormatHTML, c.DefaultContentFormat)
+	}
+
+	if !validReferrerPolicies[c.ReferrerPolicy] {
+		return fmt.Errorf("REFERRER_POLICY %q is not a valid referrer policy", c.ReferrerPolicy)
+	}
+
+	for _, origin := range c.CORSAllowedOrigins {
+		if origin == "*" {
+			if c.CORSAllowCredentials {
+				return fmt.Errorf("CORS_ALLOWED_ORIGINS can't contain * while CORS_ALLOW_CREDENTIALS is set")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" {
+			return fmt.Errorf("CORS_ALLOWED_ORIGINS entry %q must be * or an origin such as https://app.example.com", origin)
+		}
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if _, err := parseProxy(proxy); err != nil {
+			return fmt.Errorf("TRUSTED_PROXIES entry %q is not an IP address or CIDR range", proxy)
+		}
+	}
+
+	if c.PublicBaseURL != "" {
+		u, err := url.Parse(c.PublicBaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("PUBLIC_BASE_URL must be an absolute http or https URL, got %q", c.PublicBaseURL)
+		}
+	}
+
+	if c.OTLPEndpoint != "" {
+		u, err := url.Parse(c.OTLPEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT must be an absolute http or https URL, got %q", c.OTLPEndpoint)
+		}
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	if c.HTTPRedirectPort != "" {
+		if !c.TLSEnabled() {
+			return fmt.Errorf("HTTP_REDIRECT_PORT requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		if !isPort(c.HTTPRedirectPort) {
+			return fmt.Errorf("HTTP_REDIRECT_PORT must be a port number between 1 and 65535, got %q", c.HTTPRedirectPort)
+		}
+		if c.HTTPRedirectPort == c.Port {
+			return fmt.Errorf("HTTP_REDIRECT_PORT must differ from PORT, both are %s", c.Port)
+		}
+	}
+
+	if c.SMTPHost != "" {
+		if c.SMTPPort <= 0 || c.SMTPPort > 65535 {
+			return fmt.Errorf("SMTP_PORT must be between 1 and 65535, got %d", c.SMTPPort)
+		}
+		if c.SMTPFrom == "" {
+			return fmt.Errorf("SMTP_FROM or SMTP_USER must be set when SMTP_HOST is")
+		}
+	}
+
+	if c.DevMode && (c.SMTPHost != "" || c.TLSEnabled()) {
+		return fmt.Errorf("DEV_MODE exposes password reset tokens and can't be combined with SMTP_HOST or TLS")
+	}
+
+	if c.RootRedirect != "" && !isLocalPath(c.RootRedirect) {
+		return fmt.Errorf("ROOT_REDIRECT must be a local path such as /docs, got %q", c.RootRedirect)
+	}
+
+	headers := map[string]string{
+		"CONTENT_SECURITY_POLICY": c.ContentSecurityPolicy,
+		"PERMISSIONS_POLICY":      c.PermissionsPolicy,
+	}
+	for name, value := range headers {
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%s must not contain line breaks", name)
+		}
+	}
+
+	return nil
+}
+
+// redactedSecret replaces secret values in Redacted
+const redactedSecret = "****"
+
+// dsnPassword matches the password in a key=value connection string
+var dsnPassword = regexp.MustCompile(`password=('(?:[^'\\]|\\.)*'|\S+)`)
+
+// Redacted returns a copy of the config that is safe to log, with the
+// database password, admin token and JWT secret masked
+func (c *Config) Redacted() Config {
+	redacted := *c
+	if redacted.DatabasePass != "" {
+		redacted.DatabasePass = redactedSecret
+	}
+	if redacted.AdminToken != "" {
+		redacted.AdminToken = redactedSecret
+	}
+	if redacted.JWTSecret != "" {
+		redacted.JWTSecret = redactedSecret
+	}
+	if redacted.SMTPPass != "" {
+		redacted.SMTPPass = redactedSecret
+	}
+	redacted.DatabaseURL = redactDSN(redacted.DatabaseURL)
+	return redacted
+}
+
+// redactDSN masks the password in a URL or key=value connection string
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redactedSecret)
+		}
+		query := u.Query()
+		if query.Has("password") {
+			query.Set("password", redactedSecret)
+			u.RawQuery = query.Encode()
+		}
+		// Query encoding escapes the mask's asterisks
+		return strings.ReplaceAll(u.String(), url.QueryEscape(redactedSecret), redactedSecret)
 	}
+	return dsnPassword.ReplaceAllString(dsn, "password="+redactedSecret)
+}
+
+// TLSEnabled reports whether the server is configured to serve HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// TrustsProxy reports whether addr belongs to a configured trusted proxy
+func (c *Config) TrustsProxy(addr netip.Addr) bool {
+	for _, proxy := range c.TrustedProxies {
+		if prefix, err := parseProxy(proxy); err == nil && prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProxy parses a trusted proxy entry, treating a bare address as a
+// single-address range
+func parseProxy(proxy string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(proxy); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	return netip.ParsePrefix(proxy)
+}
+
+// isPort reports whether s is a TCP port number from 1 to 65535
+func isPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port > 0 && port <= 65535
+}
+
+// isLocalPath reports whether p is an absolute path on this server, rejecting
+// the protocol-relative forms browsers would follow to another host
+func isLocalPath(p string) bool {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.HasPrefix(p, "/\\") {
+		return false
+	}
+	if strings.ContainsAny(p, "\r\n") {
+		return false
+	}
+	u, err := url.Parse(p)
+	return err == nil && u.Scheme == "" && u.Host == ""
 }
 
 // getEnv gets an environment variable or returns a default value
@@ -56,3 +657,35 @@ func getEnvAsInt(key string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+// getEnvAsFloat gets an environment variable as a float or returns a default value
+func getEnvAsFloat(key string, defaultVal float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultVal
+}
+
+// getEnvAsBool gets an environment variable as a boolean or returns a default value
+func getEnvAsBool(key string, defaultVal bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultVal
+}
+
+// getEnvAsList gets a comma-separated environment variable as a list,
+// dropping empty entries
+func getEnvAsList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{name: "defaults are valid", modify: func(c *Config) {}},
+		{name: "non-numeric port", modify: func(c *Config) { c.Port = "http" }, wantErr: "PORT"},
+		{name: "empty port", modify: func(c *Config) { c.Port = "" }, wantErr: "PORT"},
+		{name: "zero port", modify: func(c *Config) { c.Port = "0" }, wantErr: "PORT"},
+		{name: "port out of range", modify: func(c *Config) { c.Port = "65536" }, wantErr: "PORT"},
+		{name: "highest port", modify: func(c *Config) { c.Port = "65535" }},
+		{name: "zero read timeout", modify: func(c *Config) { c.ReadTimeout = 0 }, wantErr: "READ_TIMEOUT"},
+		{name: "negative write timeout", modify: func(c *Config) { c.WriteTimeout = -1 }, wantErr: "WRITE_TIMEOUT"},
+		{name: "zero idle timeout", modify: func(c *Config) { c.IdleTimeout = 0 }, wantErr: "IDLE_TIMEOUT"},
+		{name: "zero database connections", modify: func(c *Config) { c.MaxConnections = 0 }, wantErr: "MAX_DB_CONNECTIONS"},
+		{name: "negative HSTS max-age", modify: func(c *Config) { c.HSTSMaxAge = -1 }, wantErr: "HSTS_MAX_AGE"},
+		{name: "negative render limit", modify: func(c *Config) { c.MaxConcurrentRenders = -1 }, wantErr: "MAX_CONCURRENT_RENDERS"},
+		{name: "negative acquire timeout", modify: func(c *Config) { c.DBAcquireTimeoutMS = -1 }, wantErr: "DB_ACQUIRE_TIMEOUT_MS"},
+		{name: "negative view flush interval", modify: func(c *Config) { c.ViewFlushIntervalMS = -1 }, wantErr: "VIEW_FLUSH_INTERVAL_MS"},
+		{name: "zero health check interval", modify: func(c *Config) { c.HealthCheckIntervalMS = 0 }, wantErr: "HEALTH_CHECK_INTERVAL_MS"},
+		{name: "zero publish schedule interval", modify: func(c *Config) { c.PublishScheduleIntervalMS = 0 }, wantErr: "PUBLISH_SCHEDULE_INTERVAL_MS"},
+		{name: "negative shutdown delay", modify: func(c *Config) { c.ShutdownDelayMS = -1 }, wantErr: "SHUTDOWN_DELAY_MS"},
+		{name: "zero request size limit", modify: func(c *Config) { c.MaxRequestBytes = 0 }, wantErr: "MAX_REQUEST_BYTES"},
+		{name: "negative JSON depth", modify: func(c *Config) { c.MaxJSONDepth = -1 }, wantErr: "MAX_JSON_DEPTH"},
+		{name: "negative JSON elements", modify: func(c *Config) { c.MaxJSONElements = -1 }, wantErr: "MAX_JSON_ELEMENTS"},
+		{name: "zero export batch size", modify: func(c *Config) { c.ExportBatchSize = 0 }, wantErr: "EXPORT_BATCH_SIZE"},
+		{name: "zero JWT expiry", modify: func(c *Config) { c.JWTExpiryMinutes = 0 }, wantErr: "JWT_EXPIRY_MINUTES"},
+		{name: "auth for reads without secrets", modify: func(c *Config) { c.RequireAuthForReads = true; c.JWTSecret = ""; c.AdminToken = "" }, wantErr: "REQUIRE_AUTH_FOR_READS"},
+		{name: "auth for reads", modify: func(c *Config) { c.RequireAuthForReads = true; c.JWTSecret = "secret" }},
+		{name: "zero refresh token expiry", modify: func(c *Config) { c.RefreshTokenExpiryHours = 0 }, wantErr: "REFRESH_TOKEN_EXPIRY_HOURS"},
+		{name: "negative rate limit", modify: func(c *Config) { c.RateLimitRPS = -1 }, wantErr: "RATE_LIMIT_RPS"},
+		{name: "zero rate limit burst", modify: func(c *Config) { c.RateLimitBurst = 0 }, wantErr: "RATE_LIMIT_BURST"},
+		{name: "negative autosave rate", modify: func(c *Config) { c.AutosaveRPS = -1 }, wantErr: "AUTOSAVE_RPS"},
+		{name: "zero autosave burst", modify: func(c *Config) { c.AutosaveBurst = 0 }, wantErr: "AUTOSAVE_BURST"},
+		{name: "zero burst without rate limit", modify: func(c *Config) { c.RateLimitRPS = 0; c.RateLimitBurst = 0 }},
+		{name: "CORS origin", modify: func(c *Config) { c.CORSAllowedOrigins = []string{"https://app.example.com", "http://localhost:3000/"} }},
+		{name: "CORS origin with path", modify: func(c *Config) { c.CORSAllowedOrigins = []string{"https://app.example.com/blog"} }, wantErr: "CORS_ALLOWED_ORIGINS"},
+		{name: "CORS origin without scheme", modify: func(c *Config) { c.CORSAllowedOrigins = []string{"app.example.com"} }, wantErr: "CORS_ALLOWED_ORIGINS"},
+		{name: "CORS wildcard", modify: func(c *Config) { c.CORSAllowedOrigins = []string{"*"} }},
+		{name: "CORS wildcard with credentials", modify: func(c *Config) { c.CORSAllowedOrigins = []string{"*"}; c.CORSAllowCredentials = true }, wantErr: "CORS_ALLOW_CREDENTIALS"},
+		{name: "TLS", modify: func(c *Config) { c.TLSCertFile = "cert.pem"; c.TLSKeyFile = "key.pem"; c.HTTPRedirectPort = "8081" }},
+		{name: "TLS certificate without key", modify: func(c *Config) { c.TLSCertFile = "cert.pem" }, wantErr: "TLS_KEY_FILE"},
+		{name: "redirect port without TLS", modify: func(c *Config) { c.HTTPRedirectPort = "8081" }, wantErr: "HTTP_REDIRECT_PORT"},
+		{name: "invalid redirect port", modify: func(c *Config) { c.TLSCertFile = "cert.pem"; c.TLSKeyFile = "key.pem"; c.HTTPRedirectPort = "http" }, wantErr: "HTTP_REDIRECT_PORT"},
+		{name: "redirect port same as port", modify: func(c *Config) { c.TLSCertFile = "cert.pem"; c.TLSKeyFile = "key.pem"; c.HTTPRedirectPort = c.Port }, wantErr: "HTTP_REDIRECT_PORT"},
+		{name: "no database target", modify: func(c *Config) { c.DatabaseHost = ""; c.DatabaseName = "" }, wantErr: "missing DB_HOST, DB_NAME"},
+		{name: "database URL without host", modify: func(c *Config) { c.DatabaseURL = "postgres://db.internal/blog"; c.DatabaseHost = "" }},
+		{name: "zero password reset expiry", modify: func(c *Config) { c.PasswordResetExpiryMinutes = 0 }, wantErr: "PASSWORD_RESET_EXPIRY_MINUTES"},
+		{name: "negative list row cap", modify: func(c *Config) { c.MaxListRows = -1 }, wantErr: "MAX_LIST_ROWS"},
+		{name: "negative sparse fields limit", modify: func(c *Config) { c.SparseFieldsLimit = -1 }, wantErr: "SPARSE_FIELDS_LIMIT"},
+		{name: "negative moderation timeout", modify: func(c *Config) { c.ModerationTimeoutMS = -1 }, wantErr: "MODERATION_TIMEOUT_MS"},
+		{name: "unknown frame options", modify: func(c *Config) { c.FrameOptions = "ALLOW-FROM x" }, wantErr: "FRAME_OPTIONS"},
+		{name: "unknown referrer policy", modify: func(c *Config) { c.ReferrerPolicy = "everything" }, wantErr: "REFERRER_POLICY"},
+		{name: "unknown content format", modify: func(c *Config) { c.DefaultContentFormat = "rtf" }, wantErr: "DEFAULT_CONTENT_FORMAT"},
+		{name: "invalid trusted proxy", modify: func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/8", "proxy.local"} }, wantErr: "TRUSTED_PROXIES"},
+		{name: "relative public base URL", modify: func(c *Config) { c.PublicBaseURL = "/blog" }, wantErr: "PUBLIC_BASE_URL"},
+		{name: "SMTP port out of range", modify: func(c *Config) { c.SMTPHost = "smtp.example.com"; c.SMTPFrom = "blog@example.com"; c.SMTPPort = 0 }, wantErr: "SMTP_PORT"},
+		{name: "SMTP without sender", modify: func(c *Config) { c.SMTPHost = "smtp.example.com"; c.SMTPFrom = "" }, wantErr: "SMTP_FROM"},
+		{name: "dev mode", modify: func(c *Config) { c.DevMode = true }},
+		{name: "dev mode with SMTP", modify: func(c *Config) { c.DevMode = true; c.SMTPHost = "smtp.example.com"; c.SMTPFrom = "blog@example.com" }, wantErr: "DEV_MODE"},
+		{name: "dev mode with TLS", modify: func(c *Config) { c.DevMode = true; c.TLSCertFile = "cert.pem"; c.TLSKeyFile = "key.pem" }, wantErr: "DEV_MODE"},
+		{name: "OTLP endpoint without scheme", modify: func(c *Config) { c.OTLPEndpoint = "collector:4318" }, wantErr: "OTEL_EXPORTER_OTLP_ENDPOINT"},
+		{name: "root redirect to another host", modify: func(c *Config) { c.RootRedirect = "https://example.com/docs" }, wantErr: "ROOT_REDIRECT"},
+		{name: "protocol-relative root redirect", modify: func(c *Config) { c.RootRedirect = "//example.com" }, wantErr: "ROOT_REDIRECT"},
+		{name: "relative root redirect", modify: func(c *Config) { c.RootRedirect = "docs" }, wantErr: "ROOT_REDIRECT"},
+		{name: "local root redirect", modify: func(c *Config) { c.RootRedirect = "/api/posts" }},
+		{name: "header injection in CSP", modify: func(c *Config) { c.ContentSecurityPolicy = "default-src 'self'\r\nX-Evil: 1" }, wantErr: "CONTENT_SECURITY_POLICY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Load()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTrustsProxy(t *testing.T) {
+	cfg := &Config{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.5"}}
+
+	assert.True(t, cfg.TrustsProxy(netip.MustParseAddr("10.1.2.3")))
+	assert.True(t, cfg.TrustsProxy(netip.MustParseAddr("192.168.1.5")))
+	assert.True(t, cfg.TrustsProxy(netip.MustParseAddr("::ffff:10.1.2.3")))
+	assert.False(t, cfg.TrustsProxy(netip.MustParseAddr("192.168.1.6")))
+	assert.False(t, (&Config{}).TrustsProxy(netip.MustParseAddr("10.1.2.3")))
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{DatabasePass: "s3cret-pass", AdminToken: "s3cret-token", JWTSecret: "s3cret-jwt", SMTPPass: "s3cret-smtp", DatabaseUser: "blog"}
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{name: "no URL", url: "", want: ""},
+		{name: "URL password", url: "postgres://blog:s3cret-pass@db:5432/blog?sslmode=disable", want: "postgres://blog:****@db:5432/blog?sslmode=disable"},
+		{name: "URL query password", url: "postgres://db/blog?password=s3cret-pass", want: "postgres://db/blog?password=****"},
+		{name: "key/value password", url: "host=db password=s3cret-pass dbname=blog", want: "host=db password=**** dbname=blog"},
+		{name: "quoted key/value password", url: `host=db password='s3cret pass\'s' dbname=blog`, want: "host=db password=**** dbname=blog"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.DatabaseURL = tt.url
+			redacted := cfg.Redacted()
+
+			assert.Equal(t, tt.want, redacted.DatabaseURL)
+			assert.Equal(t, "****", redacted.DatabasePass)
+			assert.Equal(t, "****", redacted.AdminToken)
+			assert.Equal(t, "****", redacted.JWTSecret)
+			assert.Equal(t, "****", redacted.SMTPPass)
+			assert.Equal(t, "blog", redacted.DatabaseUser)
+		})
+	}
+
+	// The original is left alone
+	assert.Equal(t, "s3cret-pass", cfg.DatabasePass)
+}
+
+func TestRedactedLogOutput(t *testing.T) {
+	cfg := Load()
+	cfg.DatabasePass = "s3cret-pass"
+	cfg.DatabaseURL = "postgres://blog:s3cret-pass@db/blog"
+	cfg.AdminToken = "s3cret-token"
+	cfg.JWTSecret = "s3cret-jwt"
+	cfg.SMTPPass = "s3cret-smtp"
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	logger.Info().Interface("config", cfg.Redacted()).Msg("Loaded configuration")
+
+	assert.NotContains(t, buf.String(), "s3cret")
+	assert.Contains(t, buf.String(), "****")
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unsetEnv clears key for the duration of the test
+func unsetEnv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	for _, key := range []string{"PORT", "DB_NAME", "LOG_LEVEL", "ADMIN_TOKEN"} {
+		unsetEnv(t, key)
+	}
+	// Already-set process variables win over the file
+	t.Setenv("DB_HOST", "db.internal")
+
+	path := filepath.Join(t.TempDir(), ".env")
+	contents := `# local development
+PORT=9090
+export DB_NAME="blog_dev"
+DB_HOST=localhost
+LOG_LEVEL='debug'
+
+ADMIN_TOKEN=s3cret=with=equals
+`
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+
+	require.NoError(t, LoadEnvFile(path))
+	cfg := Load()
+
+	assert.Equal(t, "9090", cfg.Port)
+	assert.Equal(t, "blog_dev", cfg.DatabaseName)
+	assert.Equal(t, "db.internal", cfg.DatabaseHost)
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, "s3cret=with=equals", cfg.AdminToken)
+}
+
+func TestLoadEnvFileMissing(t *testing.T) {
+	assert.NoError(t, LoadEnvFile(filepath.Join(t.TempDir(), "missing.env")))
+}
+
+func TestLoadEnvFileMalformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte("PORT=9090\nnot a pair\n"), 0o600))
+
+	assert.ErrorContains(t, LoadEnvFile(path), ":2:")
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfigFile reads settings from a YAML (.yaml or .yml) or JSON (.json)
+// file into the process environment so Load picks them up, like
+// LoadEnvFile. The file holds one flat object whose keys are the environment
+// variable names, matched ignoring case, and whose values are strings,
+// numbers, booleans or lists, which become comma-separated. Variables that are
+// already set are left alone, so the environment overrides the file. An empty
+// path loads nothing; a missing file is an error, since it was asked for.
+func LoadConfigFile(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var settings map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &settings)
+	case ".json":
+		err = json.Unmarshal(data, &settings)
+	default:
+		return fmt.Errorf("config file %s must be .yaml, .yml or .json, got %q", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	for key, raw := range settings {
+		key = strings.ToUpper(key)
+		value, err := settingString(raw)
+		if err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, key, err)
+		}
+
+		if _, set := os.LookupEnv(key); set {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, key, err)
+		}
+	}
+
+	return nil
+}
+
+// settingString formats a config file value the way it would be written in
+// an environment variable
+func settingString(raw interface{}) (string, error) {
+	switch v := raw.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64, uint64:
+		return fmt.Sprint(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := settingString(item)
+			if err != nil {
+				return "", err
+			}
+			if _, nested := item.([]interface{}); nested {
+				return "", fmt.Errorf("lists can't be nested")
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("values must be strings, numbers, booleans or lists, got %T", raw)
+	}
+}
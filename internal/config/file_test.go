@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	files := map[string]string{
+		"blog.yaml": `# production
+port: 9090
+DB_NAME: blog_prod
+db_host: db.file
+jwt_denylist: true
+rate_limit_rps: 2.5
+cors_allowed_origins:
+  - https://app.example.com
+  - https://admin.example.com
+`,
+		"blog.json": `{
+  "port": 9090,
+  "DB_NAME": "blog_prod",
+  "db_host": "db.file",
+  "jwt_denylist": true,
+  "rate_limit_rps": 2.5,
+  "cors_allowed_origins": ["https://app.example.com", "https://admin.example.com"]
+}`,
+	}
+
+	for name, contents := range files {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"PORT", "DB_NAME", "JWT_DENYLIST", "RATE_LIMIT_RPS", "CORS_ALLOWED_ORIGINS"} {
+				unsetEnv(t, key)
+			}
+			// The environment overrides the file
+			t.Setenv("DB_HOST", "db.env")
+
+			path := filepath.Join(t.TempDir(), name)
+			require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+
+			require.NoError(t, LoadConfigFile(path))
+			cfg := Load()
+
+			assert.Equal(t, "9090", cfg.Port)
+			assert.Equal(t, "blog_prod", cfg.DatabaseName)
+			assert.Equal(t, "db.env", cfg.DatabaseHost)
+			assert.True(t, cfg.JWTDenylist)
+			assert.Equal(t, 2.5, cfg.RateLimitRPS)
+			assert.Equal(t, []string{"https://app.example.com", "https://admin.example.com"}, cfg.CORSAllowedOrigins)
+		})
+	}
+}
+
+func TestLoadConfigFileWithoutPath(t *testing.T) {
+	assert.NoError(t, LoadConfigFile(""))
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		contents string
+		wantErr  string
+	}{
+		{name: "unknown extension", file: "blog.toml", contents: `port = 9090`, wantErr: ".toml"},
+		{name: "malformed YAML", file: "blog.yaml", contents: "port: [9090", wantErr: "failed to parse"},
+		{name: "malformed JSON", file: "blog.json", contents: `{"port": }`, wantErr: "failed to parse"},
+		{name: "not an object", file: "blog.json", contents: `["port"]`, wantErr: "failed to parse"},
+		{name: "nested object", file: "blog.yaml", contents: "database:\n  host: db\n", wantErr: "DATABASE"},
+		{name: "nested list", file: "blog.json", contents: `{"trusted_proxies": [["10.0.0.1"]]}`, wantErr: "TRUSTED_PROXIES"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			require.NoError(t, os.WriteFile(path, []byte(tt.contents), 0o600))
+
+			assert.ErrorContains(t, LoadConfigFile(path), tt.wantErr)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		assert.Error(t, LoadConfigFile(filepath.Join(t.TempDir(), "missing.yaml")))
+	})
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// Values of posts.content_encoding
+const (
+	contentEncodingPlain = "plain"
+	contentEncodingGzip  = "gzip"
+)
+
+// encodeContent prepares post content for storage. Compressed content goes in
+// content_compressed and leaves content empty; plain content is stored as is.
+func encodeContent(content string, compress bool) (plain string, compressed []byte, encoding string, err error) {
+	if !compress {
+		return content, nil, contentEncodingPlain, nil
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(content)); err != nil {
+		return "", nil, "", fmt.Errorf("failed to compress content: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", nil, "", fmt.Errorf("failed to compress content: %w", err)
+	}
+	return "", buf.Bytes(), contentEncodingGzip, nil
+}
+
+// decodeContent returns the text of post content read back from storage
+func decodeContent(plain string, compressed []byte, encoding string) (string, error) {
+	switch encoding {
+	case contentEncodingPlain:
+		return plain, nil
+	case contentEncodingGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return "", fmt.Errorf("failed to decompress content: %w", err)
+		}
+		defer gz.Close()
+
+		content, err := io.ReadAll(gz)
+		if err != nil {
+			return "", fmt.Errorf("failed to decompress content: %w", err)
+		}
+		return string(content), nil
+	default:
+		return "", fmt.Errorf("unknown content encoding %q", encoding)
+	}
+}
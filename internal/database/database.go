@@ -3,10 +3,16 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
+	"blog-api/internal/clock"
 	"blog-api/internal/config"
+	"blog-api/internal/models"
+	"blog-api/migrations"
 
 	_ "github.com/lib/pq"
 	"github.com/rs/zerolog/log"
@@ -15,26 +21,30 @@ import (
 // DB wraps the sql.DB connection pool
 type DB struct {
 	*sql.DB
+
+	// Clock stamps created_at on new rows. A nil Clock uses the system time.
+	Clock clock.Clock
+
+	// CompressContent stores new and updated post content gzip-compressed
+	CompressContent bool
+
+	// views buffers view count increments; nil writes each view immediately
+	views *viewBuffer
+
+	// driver is driverPostgres or driverSQLite; empty means Postgres
+	driver string
 }
 
-// New creates a new database connection
-func New(cfg *config.Config) (*DB, error) {
-	var dsn string
-	
-	// Use DATABASE_URL if provided, otherwise construct from individual components
-	if cfg.DatabaseURL != "" {
-		dsn = cfg.DatabaseURL
-	} else {
-		dsn = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-			cfg.DatabaseHost,
-			cfg.DatabasePort,
-			cfg.DatabaseUser,
-			cfg.DatabasePass,
-			cfg.DatabaseName,
-		)
-	}
+// Drivers a DB can run on. Only Postgres can serve the API; SQLite covers
+// users so far and is only opened by tests.
+const (
+	driverPostgres = "postgres"
+	driverSQLite   = "sqlite"
+)
 
-	db, err := sql.Open("postgres", dsn)
+// New creates a new Postgres database connection
+func New(cfg *config.Config) (*DB, error) {
+	db, err := sql.Open("postgres", buildDSN(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -54,16 +64,258 @@ func New(cfg *config.Config) (*DB, error) {
 
 	log.Info().Msg("Successfully connected to database")
 
-	return &DB{db}, nil
+	d := &DB{DB: db, Clock: clock.Real{}, CompressContent: cfg.CompressPostContent, driver: driverPostgres}
+	if cfg.ViewFlushIntervalMS > 0 {
+		d.startViewFlusher(time.Duration(cfg.ViewFlushIntervalMS) * time.Millisecond)
+	}
+
+	return d, nil
+}
+
+// buildDSN returns the connection string for cfg, labeled with
+// cfg.DatabaseAppName unless DATABASE_URL already sets an application_name
+func buildDSN(cfg *config.Config) string {
+	// Use DATABASE_URL if provided, otherwise construct from individual components
+	if cfg.DatabaseURL == "" {
+		dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+			cfg.DatabaseHost,
+			cfg.DatabasePort,
+			cfg.DatabaseUser,
+			cfg.DatabasePass,
+			cfg.DatabaseName,
+		)
+		if cfg.DatabaseAppName != "" {
+			dsn += " application_name=" + quoteDSNValue(cfg.DatabaseAppName)
+		}
+		return dsn
+	}
+
+	dsn := cfg.DatabaseURL
+	if cfg.DatabaseAppName == "" {
+		return dsn
+	}
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			// Leave it for sql.Open to report
+			return dsn
+		}
+		query := u.Query()
+		if query.Get("application_name") == "" {
+			query.Set("application_name", cfg.DatabaseAppName)
+			u.RawQuery = query.Encode()
+		}
+		return u.String()
+	}
+
+	if strings.Contains(dsn, "application_name=") {
+		return dsn
+	}
+	return dsn + " application_name=" + quoteDSNValue(cfg.DatabaseAppName)
+}
+
+// quoteDSNValue quotes a value for a key=value connection string
+func quoteDSNValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// now returns the current time from the DB's clock
+func (db *DB) now() time.Time {
+	if db.Clock == nil {
+		return time.Now()
+	}
+	return db.Clock.Now()
 }
 
-// Close closes the database connection
+// Close writes any buffered post views and closes the database connection
 func (db *DB) Close() error {
+	db.stopViewFlusher()
+
 	log.Info().Msg("Closing database connection")
 	return db.DB.Close()
 }
 
+// WithTx runs fn in a transaction, committing it if fn returns nil and
+// rolling it back if fn fails or panics; a panic is re-raised once the
+// transaction is rolled back. Canceling ctx aborts the transaction, so a
+// transaction abandoned by its caller never commits.
+func (db *DB) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", classifyPGError(err))
+	}
+	return nil
+}
+
+// WaitForConnection reports ErrPoolExhausted if every pooled connection stays
+// busy for longer than timeout. While the pool has spare capacity it returns
+// immediately without taking a connection.
+func (db *DB) WaitForConnection(ctx context.Context, timeout time.Duration) error {
+	stats := db.Stats()
+	if stats.Idle > 0 || stats.MaxOpenConnections == 0 || stats.InUse < stats.MaxOpenConnections {
+		return nil
+	}
+
+	acquireCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := db.Conn(acquireCtx)
+	if err != nil {
+		// Only our own deadline means the pool is saturated; the request's
+		// context ending is reported as is
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return ErrPoolExhausted
+		}
+		return err
+	}
+	return conn.Close()
+}
+
+// Status reports the estimated row count of each table in the current schema,
+// the applied migrations and the pool's statistics. Estimates come from
+// pg_class rather than COUNT(*), so they are cheap however large the tables are.
+func (db *DB) Status(ctx context.Context) (*models.DatabaseStatus, error) {
+	query := `
+		SELECT c.relname, c.reltuples::bigint
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p') AND n.nspname = current_schema()
+		ORDER BY c.relname`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query table estimates: %w", err)
+	}
+	defer rows.Close()
+
+	status := &models.DatabaseStatus{Tables: []models.TableEstimate{}}
+	for rows.Next() {
+		var table models.TableEstimate
+		var estimate int64
+		if err := rows.Scan(&table.Name, &estimate); err != nil {
+			return nil, fmt.Errorf("failed to scan table estimate: %w", err)
+		}
+		// Postgres reports -1 for tables that were never vacuumed or analyzed
+		if estimate >= 0 {
+			table.EstimatedRows = &estimate
+		}
+		status.Tables = append(status.Tables, table)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status.Migrations = applied
+	if status.Migrations == nil {
+		status.Migrations = []string{}
+	}
+
+	stats := db.Stats()
+	status.Pool = models.PoolStats{
+		MaxOpen:        stats.MaxOpenConnections,
+		Open:           stats.OpenConnections,
+		InUse:          stats.InUse,
+		Idle:           stats.Idle,
+		WaitCount:      stats.WaitCount,
+		WaitDurationMS: stats.WaitDuration.Milliseconds(),
+	}
+
+	return status, nil
+}
+
 // Ping tests the database connection
 func (db *DB) Ping(ctx context.Context) error {
 	return db.PingContext(ctx)
 }
+
+// migrationVersions are the versions of the files in migrations/, all of
+// which CheckSchema expects to find recorded in schema_migrations
+var migrationVersions = migrations.Versions()
+
+// CheckSchema returns an error unless every migration has been applied.
+// SQLite databases create their tables on first use and are always current.
+func (db *DB) CheckSchema(ctx context.Context) error {
+	if db.driver == driverSQLite {
+		return nil
+	}
+
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if applied == nil {
+		return errors.New("schema is out of date, schema_migrations is missing; apply the files in migrations/")
+	}
+
+	recorded := make(map[string]bool, len(applied))
+	for _, version := range applied {
+		recorded[version] = true
+	}
+	var missing []string
+	for _, version := range migrationVersions {
+		if !recorded[version] {
+			missing = append(missing, version)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("schema is out of date, migrations %s have not been applied; apply them from migrations/", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// appliedMigrations returns the versions recorded in schema_migrations,
+// oldest first, or nil when the table doesn't exist yet
+func (db *DB) appliedMigrations(ctx context.Context) ([]string, error) {
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check schema: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query migrations: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []string{}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan migration: %w", err)
+		}
+		versions = append(versions, version)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return versions, nil
+}
@@ -2,114 +2,413 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"blog-api/internal/clock"
+	"blog-api/internal/config"
 	"blog-api/internal/models"
 
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-// TestUserOperations tests all user CRUD operations
+// TestUserOperations tests all user CRUD operations against every driver
 func TestUserOperations(t *testing.T) {
+	for _, driver := range []string{driverPostgres, driverSQLite} {
+		t.Run(driver, func(t *testing.T) {
+			db := setupTestDBFor(t, driver)
+			defer teardownTestDB(t, db)
+
+			ctx := context.Background()
+
+			t.Run("CreateUser", func(t *testing.T) {
+				req := &models.UserRequest{
+					Username: "testuser",
+					Email:    "test@example.com",
+					Password: "password123",
+				}
+
+				user, err := db.CreateUser(ctx, req)
+				require.NoError(t, err)
+				assert.Equal(t, "testuser", user.Username)
+				assert.Equal(t, "test@example.com", user.Email)
+				assert.NotZero(t, user.ID)
+				assert.False(t, user.CreatedAt.IsZero())
+			})
+
+			t.Run("GetUserByID", func(t *testing.T) {
+				// First create a user
+				req := &models.UserRequest{
+					Username: "getuser",
+					Email:    "get@example.com",
+					Password: "password123",
+				}
+				createdUser, err := db.CreateUser(ctx, req)
+				require.NoError(t, err)
+
+				// Then get the user
+				user, err := db.GetUserByID(ctx, createdUser.ID)
+				require.NoError(t, err)
+				assert.Equal(t, createdUser.ID, user.ID)
+				assert.Equal(t, createdUser.Username, user.Username)
+				assert.Equal(t, createdUser.Email, user.Email)
+			})
+
+			t.Run("GetAllUsers", func(t *testing.T) {
+				// Create multiple users
+				users := []models.UserRequest{
+					{Username: "user1", Email: "user1@example.com", Password: "password123"},
+					{Username: "user2", Email: "user2@example.com", Password: "password123"},
+				}
+
+				for _, userReq := range users {
+					_, err := db.CreateUser(ctx, &userReq)
+					require.NoError(t, err)
+				}
+
+				// Get all users
+				allUsers, err := db.GetAllUsers(ctx)
+				require.NoError(t, err)
+				assert.GreaterOrEqual(t, len(allUsers), 2)
+			})
+
+			t.Run("UpdateUser", func(t *testing.T) {
+				// Create a user
+				req := &models.UserRequest{
+					Username: "updateuser",
+					Email:    "update@example.com",
+					Password: "password123",
+				}
+				createdUser, err := db.CreateUser(ctx, req)
+				require.NoError(t, err)
+
+				// Update the user
+				updateReq := &models.UserRequest{
+					Username: "updateduser",
+					Email:    "updated@example.com",
+				}
+				updatedUser, err := db.UpdateUser(ctx, createdUser.ID, updateReq)
+				require.NoError(t, err)
+				assert.Equal(t, "updateduser", updatedUser.Username)
+				assert.Equal(t, "updated@example.com", updatedUser.Email)
+			})
+
+			t.Run("UpdatePassword", func(t *testing.T) {
+				createdUser, err := db.CreateUser(ctx, &models.UserRequest{
+					Username: "passworduser",
+					Email:    "password@example.com",
+					Password: "password123",
+				})
+				require.NoError(t, err)
+
+				hash, err := HashPassword("new-password")
+				require.NoError(t, err)
+				require.NoError(t, db.UpdatePassword(ctx, createdUser.ID, hash))
+
+				_, err = db.VerifyPassword(ctx, "passworduser", "password123")
+				assert.ErrorIs(t, err, ErrInvalidPassword)
+				_, err = db.VerifyPassword(ctx, "passworduser", "new-password")
+				assert.NoError(t, err)
+
+				assert.ErrorIs(t, db.UpdatePassword(ctx, createdUser.ID+1000, hash), ErrUserNotFound)
+			})
+
+			t.Run("DeleteUser", func(t *testing.T) {
+				// Create a user
+				req := &models.UserRequest{
+					Username: "deleteuser",
+					Email:    "delete@example.com",
+					Password: "password123",
+				}
+				createdUser, err := db.CreateUser(ctx, req)
+				require.NoError(t, err)
+
+				// Delete the user
+				err = db.DeleteUser(ctx, createdUser.ID)
+				require.NoError(t, err)
+
+				// Try to get the deleted user
+				_, err = db.GetUserByID(ctx, createdUser.ID)
+				assert.Error(t, err)
+			})
+
+			t.Run("CaseVariantDuplicates", func(t *testing.T) {
+				_, err := db.CreateUser(ctx, &models.UserRequest{Username: "Alice", Email: "Alice@Example.com", Password: "password123"})
+				require.NoError(t, err)
+
+				_, err = db.CreateUser(ctx, &models.UserRequest{Username: "alice", Email: "someone@example.com", Password: "password123"})
+				assert.ErrorIs(t, err, ErrDuplicateUsername)
+				assert.ErrorIs(t, err, ErrDuplicate)
+
+				_, err = db.CreateUser(ctx, &models.UserRequest{Username: "bob", Email: "alice@example.COM", Password: "password123"})
+				assert.ErrorIs(t, err, ErrDuplicateEmail)
+
+				bob, err := db.CreateUser(ctx, &models.UserRequest{Username: "bob", Email: "bob@example.com", Password: "password123"})
+				require.NoError(t, err)
+				_, err = db.UpdateUser(ctx, bob.ID, &models.UserRequest{Username: "ALICE"})
+				assert.ErrorIs(t, err, ErrDuplicateUsername)
+
+				// Logging in ignores case too
+				verified, err := db.VerifyPassword(ctx, "ALICE", "password123")
+				require.NoError(t, err)
+				assert.Equal(t, "Alice", verified.Username)
+			})
+
+		})
+	}
+}
+
+// TestBannedUsers tests banning, the login check and hiding posts of banned users
+func TestBannedUsers(t *testing.T) {
 	db := setupTestDB(t)
 	defer teardownTestDB(t, db)
 
 	ctx := context.Background()
 
-	t.Run("CreateUser", func(t *testing.T) {
-		req := &models.UserRequest{
-			Username: "testuser",
-			Email:    "test@example.com",
-			Password: "password123",
-		}
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "spammer", Email: "spam@example.com", Password: "password123"})
+	require.NoError(t, err)
+	assert.False(t, user.Banned)
+
+	_, err = db.CreatePost(ctx, &models.PostRequest{Title: "Buy now", Content: "Spam", Status: models.PostStatusPublished, UserID: user.ID})
+	require.NoError(t, err)
+
+	banned, err := db.SetUserBanned(ctx, user.ID, true)
+	require.NoError(t, err)
+	assert.True(t, banned.Banned)
 
-		user, err := db.CreateUser(ctx, req)
+	t.Run("LoginWhileBanned", func(t *testing.T) {
+		_, err := db.VerifyPassword(ctx, "spammer", "password123")
+		assert.ErrorIs(t, err, ErrUserBanned)
+
+		// A wrong password doesn't reveal the ban
+		_, err = db.VerifyPassword(ctx, "spammer", "wrong-password")
+		assert.NotErrorIs(t, err, ErrUserBanned)
+		assert.ErrorIs(t, err, ErrInvalidPassword)
+	})
+
+	t.Run("PostsHiddenFromListings", func(t *testing.T) {
+		posts, err := db.GetAllPosts(ctx)
 		require.NoError(t, err)
-		assert.Equal(t, "testuser", user.Username)
-		assert.Equal(t, "test@example.com", user.Email)
-		assert.NotZero(t, user.ID)
-		assert.False(t, user.CreatedAt.IsZero())
+		assert.Empty(t, posts)
 	})
 
-	t.Run("GetUserByID", func(t *testing.T) {
-		// First create a user
-		req := &models.UserRequest{
-			Username: "getuser",
-			Email:    "get@example.com",
-			Password: "password123",
-		}
-		createdUser, err := db.CreateUser(ctx, req)
+	t.Run("Unban", func(t *testing.T) {
+		_, err := db.SetUserBanned(ctx, user.ID, false)
 		require.NoError(t, err)
 
-		// Then get the user
-		user, err := db.GetUserByID(ctx, createdUser.ID)
+		verified, err := db.VerifyPassword(ctx, "spammer", "password123")
 		require.NoError(t, err)
-		assert.Equal(t, createdUser.ID, user.ID)
-		assert.Equal(t, createdUser.Username, user.Username)
-		assert.Equal(t, createdUser.Email, user.Email)
+		assert.Equal(t, user.ID, verified.ID)
+
+		posts, err := db.GetAllPosts(ctx)
+		require.NoError(t, err)
+		assert.Len(t, posts, 1)
 	})
 
-	t.Run("GetAllUsers", func(t *testing.T) {
-		// Create multiple users
-		users := []models.UserRequest{
-			{Username: "user1", Email: "user1@example.com", Password: "password123"},
-			{Username: "user2", Email: "user2@example.com", Password: "password123"},
-		}
+	t.Run("MissingUser", func(t *testing.T) {
+		_, err := db.SetUserBanned(ctx, user.ID+1000, true)
+		assert.ErrorContains(t, err, "not found")
+	})
+}
 
-		for _, userReq := range users {
-			_, err := db.CreateUser(ctx, &userReq)
-			require.NoError(t, err)
-		}
+// TestRefreshTokens tests storing, rotating and revoking refresh tokens
+func TestRefreshTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "refresher", Email: "refresher@example.com", Password: "password123"})
+	require.NoError(t, err)
 
-		// Get all users
-		allUsers, err := db.GetAllUsers(ctx)
+	expiresAt := time.Now().Add(time.Hour)
+	created, err := db.CreateRefreshToken(ctx, user.ID, "first", expiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, created.UserID)
+	assert.False(t, created.Revoked)
+
+	t.Run("Rotate", func(t *testing.T) {
+		rotated, err := db.RotateRefreshToken(ctx, "first", "second", expiresAt)
+		require.NoError(t, err)
+		assert.Equal(t, created.Family, rotated.Family)
+
+		old, err := db.GetRefreshToken(ctx, "first")
 		require.NoError(t, err)
-		assert.GreaterOrEqual(t, len(allUsers), 2)
+		assert.True(t, old.Revoked)
 	})
 
-	t.Run("UpdateUser", func(t *testing.T) {
-		// Create a user
-		req := &models.UserRequest{
-			Username: "updateuser",
-			Email:    "update@example.com",
-			Password: "password123",
-		}
-		createdUser, err := db.CreateUser(ctx, req)
+	t.Run("ReuseRevokesFamily", func(t *testing.T) {
+		_, err := db.RotateRefreshToken(ctx, "first", "third", expiresAt)
+		assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+		latest, err := db.GetRefreshToken(ctx, "second")
 		require.NoError(t, err)
+		assert.True(t, latest.Revoked)
 
-		// Update the user
-		updateReq := &models.UserRequest{
-			Username: "updateduser",
-			Email:    "updated@example.com",
-		}
-		updatedUser, err := db.UpdateUser(ctx, createdUser.ID, updateReq)
+		_, err = db.GetRefreshToken(ctx, "third")
+		assert.ErrorIs(t, err, ErrRefreshTokenNotFound)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		_, err := db.CreateRefreshToken(ctx, user.ID, "stale", time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+
+		_, err = db.RotateRefreshToken(ctx, "stale", "fresh", expiresAt)
+		assert.ErrorIs(t, err, ErrRefreshTokenExpired)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		_, err := db.CreateRefreshToken(ctx, user.ID, "revoked", expiresAt)
 		require.NoError(t, err)
-		assert.Equal(t, "updateduser", updatedUser.Username)
-		assert.Equal(t, "updated@example.com", updatedUser.Email)
+		require.NoError(t, db.RevokeRefreshToken(ctx, "revoked"))
+
+		_, err = db.RotateRefreshToken(ctx, "revoked", "next", expiresAt)
+		assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+		assert.ErrorIs(t, db.RevokeRefreshToken(ctx, "unknown"), ErrRefreshTokenNotFound)
 	})
 
-	t.Run("DeleteUser", func(t *testing.T) {
-		// Create a user
-		req := &models.UserRequest{
-			Username: "deleteuser",
-			Email:    "delete@example.com",
-			Password: "password123",
+	t.Run("RevokeUser", func(t *testing.T) {
+		_, err := db.CreateRefreshToken(ctx, user.ID, "phone", expiresAt)
+		require.NoError(t, err)
+		_, err = db.CreateRefreshToken(ctx, user.ID, "laptop", expiresAt)
+		require.NoError(t, err)
+
+		require.NoError(t, db.RevokeUserRefreshTokens(ctx, user.ID))
+
+		for _, token := range []string{"phone", "laptop"} {
+			stored, err := db.GetRefreshToken(ctx, token)
+			require.NoError(t, err)
+			assert.True(t, stored.Revoked, token)
 		}
-		createdUser, err := db.CreateUser(ctx, req)
+	})
+}
+
+// TestPasswordResets tests issuing and redeeming password reset tokens
+func TestPasswordResets(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "forgetful", Email: "Forgetful@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	found, err := db.GetUserByEmail(ctx, "forgetful@EXAMPLE.com")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+	_, err = db.GetUserByEmail(ctx, "nobody@example.com")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	expiresAt := time.Now().Add(time.Hour)
+	hash, err := HashPassword("new-password")
+	require.NoError(t, err)
+
+	t.Run("Reset", func(t *testing.T) {
+		_, err := db.CreateRefreshToken(ctx, user.ID, "session", expiresAt)
 		require.NoError(t, err)
+		require.NoError(t, db.CreatePasswordReset(ctx, user.ID, "reset", expiresAt))
+		require.NoError(t, db.CreatePasswordReset(ctx, user.ID, "older", expiresAt))
 
-		// Delete the user
-		err = db.DeleteUser(ctx, createdUser.ID)
+		userID, err := db.ResetPassword(ctx, "reset", hash)
 		require.NoError(t, err)
+		assert.Equal(t, user.ID, userID)
 
-		// Try to get the deleted user
-		_, err = db.GetUserByID(ctx, createdUser.ID)
-		assert.Error(t, err)
+		_, err = db.VerifyPassword(ctx, "forgetful", "new-password")
+		assert.NoError(t, err)
+
+		session, err := db.GetRefreshToken(ctx, "session")
+		require.NoError(t, err)
+		assert.True(t, session.Revoked, "a reset should log out every session")
+
+		// Other outstanding resets of the user are used up as well
+		_, err = db.ResetPassword(ctx, "older", hash)
+		assert.ErrorIs(t, err, ErrPasswordResetUsed)
+	})
+
+	t.Run("Reuse", func(t *testing.T) {
+		_, err := db.ResetPassword(ctx, "reset", hash)
+		assert.ErrorIs(t, err, ErrPasswordResetUsed)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		require.NoError(t, db.CreatePasswordReset(ctx, user.ID, "stale", time.Now().Add(-time.Minute)))
+
+		_, err := db.ResetPassword(ctx, "stale", hash)
+		assert.ErrorIs(t, err, ErrPasswordResetExpired)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := db.ResetPassword(ctx, "unknown", hash)
+		assert.ErrorIs(t, err, ErrPasswordResetNotFound)
 	})
 }
 
+// TestRevokedAccessTokens tests the deny list of logged out access tokens
+func TestRevokedAccessTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	_, err := db.Exec("TRUNCATE revoked_access_tokens")
+	require.NoError(t, err)
+
+	revoked, err := db.IsAccessTokenRevoked(ctx, "abc")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, db.RevokeAccessToken(ctx, "abc", time.Now().Add(time.Hour)))
+	// Revoking twice is harmless
+	require.NoError(t, db.RevokeAccessToken(ctx, "abc", time.Now().Add(time.Hour)))
+
+	revoked, err = db.IsAccessTokenRevoked(ctx, "abc")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+// TestAuthorRenameReflectedInPosts tests that posts show an author's current
+// username. It is joined from users on every read rather than stored on posts,
+// so there is nothing to re-sync after a rename.
+func TestAuthorRenameReflectedInPosts(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "oldname", Email: "rename@example.com", Password: "password123"})
+	require.NoError(t, err)
+	post, err := db.CreatePost(ctx, &models.PostRequest{Title: "Renamed", Content: "Content", Status: models.PostStatusPublished, UserID: user.ID})
+	require.NoError(t, err)
+	_, err = db.SetFeatured(ctx, post.ID, true, nil)
+	require.NoError(t, err)
+
+	_, err = db.UpdateUser(ctx, user.ID, &models.UserRequest{Username: "newname"})
+	require.NoError(t, err)
+
+	posts, err := db.GetAllPosts(ctx)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, "newname", posts[0].Username)
+
+	got, err := db.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "newname", got.Username)
+
+	featured, err := db.GetFeaturedPosts(ctx)
+	require.NoError(t, err)
+	require.Len(t, featured, 1)
+	assert.Equal(t, "newname", featured[0].Username)
+
+	byUser, err := db.GetPostsByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, byUser, 1)
+	assert.Equal(t, "newname", byUser[0].Username)
+}
+
 // TestPostOperations tests all post CRUD operations
 func TestPostOperations(t *testing.T) {
 	db := setupTestDB(t)
@@ -138,6 +437,7 @@ func TestPostOperations(t *testing.T) {
 		assert.Equal(t, "Test Post", post.Title)
 		assert.Equal(t, "This is a test post content", post.Content)
 		assert.Equal(t, user.ID, post.UserID)
+		assert.Equal(t, models.ContentFormatHTML, post.ContentFormat)
 		assert.NotZero(t, post.ID)
 		assert.False(t, post.CreatedAt.IsZero())
 	})
@@ -164,8 +464,8 @@ func TestPostOperations(t *testing.T) {
 	t.Run("GetAllPosts", func(t *testing.T) {
 		// Create multiple posts
 		posts := []models.PostRequest{
-			{Title: "Post 1", Content: "Content 1", UserID: user.ID},
-			{Title: "Post 2", Content: "Content 2", UserID: user.ID},
+			{Title: "Post 1", Content: "Content 1", Status: models.PostStatusPublished, UserID: user.ID},
+			{Title: "Post 2", Content: "Content 2", Status: models.PostStatusPublished, UserID: user.ID},
 		}
 
 		for _, postReq := range posts {
@@ -220,21 +520,1564 @@ func TestPostOperations(t *testing.T) {
 	})
 }
 
-// setupTestDB creates a test database connection
-func setupTestDB(t *testing.T) *DB {
-	// Note: This is a simplified setup for unit tests
-	// In a real scenario, you would use a test database or an in-memory database
-	// For now, this is a placeholder that assumes a test database is available
-	
-	// You might want to use environment variables or a separate test config
-	// to connect to a test database
-	t.Skip("Test database setup required - please configure test database connection")
-	return nil
+// TestWithTx tests that transactions commit, roll back and re-panic as they should
+func TestWithTx(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "atomic", Email: "atomic@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	insert := func(tx *sql.Tx, ctx context.Context, title string) {
+		t.Helper()
+		_, err := tx.ExecContext(ctx, `INSERT INTO posts (title, content, user_id) VALUES ($1, 'Content', $2)`, title, user.ID)
+		require.NoError(t, err)
+	}
+	countPosts := func() int {
+		t.Helper()
+		var n int
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM posts`).Scan(&n))
+		return n
+	}
+
+	t.Run("commits on success", func(t *testing.T) {
+		err := db.WithTx(ctx, func(tx *sql.Tx) error {
+			insert(tx, ctx, "Kept")
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, countPosts())
+	})
+
+	t.Run("rolls back on error", func(t *testing.T) {
+		failure := fmt.Errorf("second step failed")
+		err := db.WithTx(ctx, func(tx *sql.Tx) error {
+			insert(tx, ctx, "First")
+			insert(tx, ctx, "Second")
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+		assert.Equal(t, 1, countPosts())
+	})
+
+	t.Run("rolls back and re-panics on panic", func(t *testing.T) {
+		assert.PanicsWithValue(t, "boom", func() {
+			db.WithTx(ctx, func(tx *sql.Tx) error {
+				insert(tx, ctx, "Panicked")
+				panic("boom")
+			})
+		})
+		assert.Equal(t, 1, countPosts())
+
+		// The connection went back to the pool in a usable state
+		require.NoError(t, db.Ping(ctx))
+	})
+
+	t.Run("aborts when the context is canceled", func(t *testing.T) {
+		txCtx, cancel := context.WithCancel(ctx)
+		err := db.WithTx(txCtx, func(tx *sql.Tx) error {
+			insert(tx, txCtx, "Abandoned")
+			cancel()
+			return nil
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, countPosts())
+	})
+
+	t.Run("create post leaves nothing behind when tags fail", func(t *testing.T) {
+		_, err := db.CreatePost(ctx, &models.PostRequest{
+			Title:   "Orphan",
+			Content: "Content",
+			UserID:  user.ID,
+			Tags:    []string{strings.Repeat("x", 51)},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, countPosts())
+	})
 }
 
-// teardownTestDB cleans up the test database
-func teardownTestDB(t *testing.T, db *DB) {
-	if db != nil {
-		db.Close()
+// TestSoftDeletePost tests that deleted posts are hidden until restored
+func TestSoftDeletePost(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "eraser", Email: "eraser@example.com", Password: "password123"})
+	require.NoError(t, err)
+	post, err := db.CreatePost(ctx, &models.PostRequest{Title: "Regrettable", Content: "Content", Status: models.PostStatusPublished, UserID: user.ID})
+	require.NoError(t, err)
+
+	require.NoError(t, db.DeletePost(ctx, post.ID))
+	assert.ErrorIs(t, db.DeletePost(ctx, post.ID), ErrPostNotFound, "a deleted post can't be deleted again")
+
+	// Normal reads don't see it
+	_, err = db.GetPostByID(ctx, post.ID)
+	assert.ErrorIs(t, err, ErrPostNotFound)
+	posts, err := db.GetAllPosts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, posts)
+	posts, err = db.GetPostsByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, posts)
+	_, err = db.UpdatePost(ctx, post.ID, &models.PostRequest{Title: "Edited"})
+	assert.ErrorIs(t, err, ErrPostNotFound)
+
+	// The row is still there for admins
+	posts, _, err = db.GetRecentPosts(ctx, models.PostListOptions{IncludeDeleted: true}, 0)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.NotNil(t, posts[0].DeletedAt)
+
+	restored, err := db.RestorePost(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+
+	fetched, err := db.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Regrettable", fetched.Title)
+
+	_, err = db.RestorePost(ctx, 999999)
+	assert.ErrorIs(t, err, ErrPostNotFound)
+}
+
+// TestUniqueTitlePerUser tests the optional per-author unique title index
+func TestUniqueTitlePerUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+
+	require.NoError(t, db.EnsureUniqueTitleIndex(ctx))
+	defer db.Exec("DROP INDEX IF EXISTS " + uniqueTitleIndex)
+
+	author, err := db.CreateUser(ctx, &models.UserRequest{Username: "titleauthor", Email: "title@example.com", Password: "password123"})
+	require.NoError(t, err)
+	other, err := db.CreateUser(ctx, &models.UserRequest{Username: "otherauthor", Email: "other@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	_, err = db.CreatePost(ctx, &models.PostRequest{Title: "Same Title", Content: "First", UserID: author.ID})
+	require.NoError(t, err)
+
+	// Same author, same title (ignoring case) is rejected
+	_, err = db.CreatePost(ctx, &models.PostRequest{Title: "same title", Content: "Second", UserID: author.ID})
+	assert.ErrorIs(t, err, ErrDuplicateTitle)
+
+	// A different author may reuse the title
+	_, err = db.CreatePost(ctx, &models.PostRequest{Title: "Same Title", Content: "Third", UserID: other.ID})
+	assert.NoError(t, err)
+
+	t.Run("deleted posts free their title", func(t *testing.T) {
+		deleted, err := db.CreatePost(ctx, &models.PostRequest{Title: "Retired", Content: "Old", UserID: author.ID})
+		require.NoError(t, err)
+		require.NoError(t, db.DeletePost(ctx, deleted.ID))
+
+		_, err = db.CreatePost(ctx, &models.PostRequest{Title: "retired", Content: "New", UserID: author.ID})
+		require.NoError(t, err)
+
+		// Restoring the old post would duplicate the title
+		_, err = db.RestorePost(ctx, deleted.ID)
+		assert.ErrorIs(t, err, ErrDuplicateTitle)
+	})
+
+	t.Run("moving a post onto a taken title", func(t *testing.T) {
+		giver, err := db.CreateUser(ctx, &models.UserRequest{Username: "giver", Email: "giver@example.com", Password: "password123"})
+		require.NoError(t, err)
+		clash, err := db.CreatePost(ctx, &models.PostRequest{Title: "SAME TITLE", Content: "Moved", UserID: giver.ID})
+		require.NoError(t, err)
+
+		_, err = db.TransferPostOwnership(ctx, clash.ID, author.ID)
+		assert.ErrorIs(t, err, ErrDuplicateTitle)
+
+		_, err = db.TransferUserPosts(ctx, giver.ID, author.ID)
+		assert.ErrorIs(t, err, ErrDuplicateTitle)
+
+		// Nothing moved
+		got, err := db.GetPostByID(ctx, clash.ID)
+		require.NoError(t, err)
+		assert.Equal(t, giver.ID, got.UserID)
+	})
+
+	t.Run("an index covering deleted posts is replaced", func(t *testing.T) {
+		_, err := db.Exec("DROP INDEX " + uniqueTitleIndex)
+		require.NoError(t, err)
+		_, err = db.Exec("CREATE UNIQUE INDEX " + uniqueTitleIndex + " ON posts (user_id, lower(title))")
+		require.Error(t, err, "the deleted duplicate should block a full index")
+
+		_, err = db.Exec("TRUNCATE posts RESTART IDENTITY CASCADE")
+		require.NoError(t, err)
+		_, err = db.Exec("CREATE UNIQUE INDEX " + uniqueTitleIndex + " ON posts (user_id, lower(title))")
+		require.NoError(t, err)
+
+		require.NoError(t, db.EnsureUniqueTitleIndex(ctx))
+
+		var definition string
+		require.NoError(t, db.QueryRow(`SELECT indexdef FROM pg_indexes WHERE indexname = $1`, uniqueTitleIndex).Scan(&definition))
+		assert.Contains(t, definition, "WHERE (deleted_at IS NULL)")
+
+		// And applying it again keeps it
+		require.NoError(t, db.EnsureUniqueTitleIndex(ctx))
+	})
+}
+
+// TestResetViewCounts tests bulk resetting of post view counters
+func TestResetViewCounts(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+
+	alice, err := db.CreateUser(ctx, &models.UserRequest{Username: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+	bob, err := db.CreateUser(ctx, &models.UserRequest{Username: "bob", Email: "bob@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	// Seed two posts for alice and one for bob, each viewed a few times
+	var postIDs []int
+	for _, userID := range []int{alice.ID, alice.ID, bob.ID} {
+		post, err := db.CreatePost(ctx, &models.PostRequest{Title: "Viewed", Content: "Content", UserID: userID})
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, db.IncrementPostViews(ctx, post.ID))
+		}
+		postIDs = append(postIDs, post.ID)
 	}
+
+	t.Run("FilteredByAuthor", func(t *testing.T) {
+		affected, err := db.ResetViewCounts(ctx, models.PostFilter{UserID: alice.ID})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), affected)
+
+		post, err := db.GetPostByID(ctx, postIDs[0])
+		require.NoError(t, err)
+		assert.Zero(t, post.ViewCount)
+
+		post, err = db.GetPostByID(ctx, postIDs[2])
+		require.NoError(t, err)
+		assert.Equal(t, 3, post.ViewCount)
+	})
+
+	t.Run("FilteredByDate", func(t *testing.T) {
+		future := time.Now().Add(time.Hour)
+		affected, err := db.ResetViewCounts(ctx, models.PostFilter{CreatedAfter: &future})
+		require.NoError(t, err)
+		assert.Zero(t, affected)
+	})
+
+	t.Run("AllPosts", func(t *testing.T) {
+		affected, err := db.ResetViewCounts(ctx, models.PostFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), affected)
+
+		post, err := db.GetPostByID(ctx, postIDs[2])
+		require.NoError(t, err)
+		assert.Zero(t, post.ViewCount)
+	})
+}
+
+// TestTransferPostOwnership tests moving posts between users
+func TestTransferPostOwnership(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+
+	leaving, err := db.CreateUser(ctx, &models.UserRequest{Username: "leaving", Email: "leaving@example.com", Password: "password123"})
+	require.NoError(t, err)
+	heir, err := db.CreateUser(ctx, &models.UserRequest{Username: "heir", Email: "heir@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	first, err := db.CreatePost(ctx, &models.PostRequest{Title: "First", Content: "Content", UserID: leaving.ID})
+	require.NoError(t, err)
+	_, err = db.CreatePost(ctx, &models.PostRequest{Title: "Second", Content: "Content", UserID: leaving.ID})
+	require.NoError(t, err)
+	_, err = db.CreatePost(ctx, &models.PostRequest{Title: "Third", Content: "Content", UserID: leaving.ID})
+	require.NoError(t, err)
+
+	t.Run("SinglePost", func(t *testing.T) {
+		post, err := db.TransferPostOwnership(ctx, first.ID, heir.ID)
+		require.NoError(t, err)
+		assert.Equal(t, heir.ID, post.UserID)
+		assert.Equal(t, "heir", post.Username)
+
+		fetched, err := db.GetPostByID(ctx, first.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "heir", fetched.Username)
+	})
+
+	t.Run("MissingTargetUser", func(t *testing.T) {
+		_, err := db.TransferPostOwnership(ctx, first.ID, heir.ID+1000)
+		assert.ErrorIs(t, err, ErrTargetUserNotFound)
+	})
+
+	t.Run("MissingPost", func(t *testing.T) {
+		_, err := db.TransferPostOwnership(ctx, first.ID+1000, heir.ID)
+		assert.ErrorContains(t, err, "not found")
+	})
+
+	t.Run("AllUserPosts", func(t *testing.T) {
+		moved, err := db.TransferUserPosts(ctx, leaving.ID, heir.ID)
+		require.NoError(t, err)
+		require.Len(t, moved, 2)
+		for _, post := range moved {
+			assert.Equal(t, heir.ID, post.UserID)
+			assert.Equal(t, "heir", post.Username)
+		}
+
+		remaining, err := db.GetPostsByUserID(ctx, leaving.ID)
+		require.NoError(t, err)
+		assert.Empty(t, remaining)
+
+		owned, err := db.GetPostsByUserID(ctx, heir.ID)
+		require.NoError(t, err)
+		assert.Len(t, owned, 3)
+	})
+}
+
+// TestFeaturedPosts tests featuring posts and listing them
+func TestFeaturedPosts(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "editor", Email: "editor@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	var ids []int
+	for _, title := range []string{"Oldest", "Middle", "Newest", "Plain"} {
+		post, err := db.CreatePost(ctx, &models.PostRequest{Title: title, Content: "Content", Status: models.PostStatusPublished, UserID: user.ID})
+		require.NoError(t, err)
+		ids = append(ids, post.ID)
+	}
+
+	first := 1
+	post, err := db.SetFeatured(ctx, ids[0], true, &first)
+	require.NoError(t, err)
+	assert.True(t, post.Featured)
+	require.NotNil(t, post.FeaturedOrder)
+	assert.Equal(t, 1, *post.FeaturedOrder)
+
+	_, err = db.SetFeatured(ctx, ids[1], true, nil)
+	require.NoError(t, err)
+	_, err = db.SetFeatured(ctx, ids[2], true, nil)
+	require.NoError(t, err)
+
+	t.Run("OrderedThenByRecency", func(t *testing.T) {
+		featured, err := db.GetFeaturedPosts(ctx)
+		require.NoError(t, err)
+		require.Len(t, featured, 3)
+		assert.Equal(t, "Oldest", featured[0].Title)
+		assert.Equal(t, "Newest", featured[1].Title)
+		assert.Equal(t, "Middle", featured[2].Title)
+		assert.Equal(t, "editor", featured[0].Username)
+	})
+
+	t.Run("Unfeature", func(t *testing.T) {
+		post, err := db.SetFeatured(ctx, ids[0], false, &first)
+		require.NoError(t, err)
+		assert.False(t, post.Featured)
+		assert.Nil(t, post.FeaturedOrder)
+
+		featured, err := db.GetFeaturedPosts(ctx)
+		require.NoError(t, err)
+		assert.Len(t, featured, 2)
+	})
+
+	t.Run("MissingPost", func(t *testing.T) {
+		_, err := db.SetFeatured(ctx, ids[3]+1000, true, nil)
+		assert.ErrorContains(t, err, "not found")
+	})
+}
+
+// TestGetUserStats tests per-user post aggregates
+func TestGetUserStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+
+	author, err := db.CreateUser(ctx, &models.UserRequest{Username: "prolific", Email: "prolific@example.com", Password: "password123"})
+	require.NoError(t, err)
+	newcomer, err := db.CreateUser(ctx, &models.UserRequest{Username: "newcomer", Email: "newcomer@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	for i, views := range []int{2, 0, 5} {
+		post, err := db.CreatePost(ctx, &models.PostRequest{Title: fmt.Sprintf("Post %d", i), Content: "Content", Status: models.PostStatusPublished, UserID: author.ID})
+		require.NoError(t, err)
+		for v := 0; v < views; v++ {
+			require.NoError(t, db.IncrementPostViews(ctx, post.ID))
+		}
+	}
+
+	// Drafts count as posts but not as published, and their views don't count
+	for i := 0; i < 2; i++ {
+		draft, err := db.CreatePost(ctx, &models.PostRequest{Title: fmt.Sprintf("Unfinished %d", i), Content: "Content", UserID: author.ID})
+		require.NoError(t, err)
+		require.NoError(t, db.IncrementPostViews(ctx, draft.ID))
+	}
+
+	// Deleted posts don't count at all
+	deleted, err := db.CreatePost(ctx, &models.PostRequest{Title: "Regretted", Content: "Content", Status: models.PostStatusPublished, UserID: author.ID})
+	require.NoError(t, err)
+	require.NoError(t, db.DeletePost(ctx, deleted.ID))
+
+	stats, err := db.GetUserStats(ctx, author.ID)
+	require.NoError(t, err)
+	assert.Equal(t, author.ID, stats.UserID)
+	require.NotNil(t, stats.PostCount)
+	assert.Equal(t, 5, *stats.PostCount)
+	assert.Equal(t, 3, stats.PublishedCount)
+	assert.Equal(t, int64(7), stats.TotalViews)
+	assert.WithinDuration(t, author.CreatedAt, stats.JoinedAt, time.Second)
+
+	// A user without posts has zero totals rather than NULLs
+	stats, err = db.GetUserStats(ctx, newcomer.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stats.PostCount)
+	assert.Equal(t, 0, *stats.PostCount)
+	assert.Equal(t, 0, stats.PublishedCount)
+	assert.Equal(t, int64(0), stats.TotalViews)
+
+	_, err = db.GetUserStats(ctx, 99999)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+// TestStreamPosts tests that batched streaming visits every post exactly once
+func TestStreamPosts(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "streamer", Email: "streamer@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	var want []int
+	for i := 0; i < 6; i++ {
+		post, err := db.CreatePost(ctx, &models.PostRequest{Title: fmt.Sprintf("Stream %d", i), Content: "Content", UserID: user.ID})
+		require.NoError(t, err)
+		want = append(want, post.ID)
+	}
+
+	// Batch sizes that divide the row count evenly, unevenly, and exceed it
+	for _, batchSize := range []int{2, 4, 10} {
+		var got []int
+		err := db.StreamPosts(ctx, batchSize, func(post *models.Post) error {
+			got = append(got, post.ID)
+			assert.Equal(t, "streamer", post.Username)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "batch size %d", batchSize)
+	}
+
+	assert.Error(t, db.StreamPosts(ctx, 0, func(*models.Post) error { return nil }))
+}
+
+// TestAsConstraintViolation tests recognizing check and not-null violations
+func TestAsConstraintViolation(t *testing.T) {
+	t.Run("CheckViolation", func(t *testing.T) {
+		err := fmt.Errorf("failed to create post: %w", &pq.Error{Code: "23514", Table: "posts", Constraint: "posts_title_check"})
+
+		violation, ok := AsConstraintViolation(err)
+		require.True(t, ok)
+		assert.Equal(t, "posts_title_check", violation.Constraint)
+		assert.Equal(t, "title", violation.Column)
+	})
+
+	t.Run("NotNullViolation", func(t *testing.T) {
+		violation, ok := AsConstraintViolation(&pq.Error{Code: "23502", Table: "posts", Column: "content"})
+		require.True(t, ok)
+		assert.Empty(t, violation.Constraint)
+		assert.Equal(t, "content", violation.Column)
+	})
+
+	t.Run("OtherErrors", func(t *testing.T) {
+		_, ok := AsConstraintViolation(&pq.Error{Code: "23505"})
+		assert.False(t, ok)
+
+		_, ok = AsConstraintViolation(ErrPostNotFound)
+		assert.False(t, ok)
+	})
+
+	t.Run("BlankTitle", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer teardownTestDB(t, db)
+
+		ctx := context.Background()
+		user, err := db.CreateUser(ctx, &models.UserRequest{Username: "blank", Email: "blank@example.com", Password: "password123"})
+		require.NoError(t, err)
+
+		_, err = db.CreatePost(ctx, &models.PostRequest{Title: "   ", Content: "Content", UserID: user.ID})
+		violation, ok := AsConstraintViolation(err)
+		require.True(t, ok, "expected a constraint violation, got %v", err)
+		assert.Equal(t, "title", violation.Column)
+	})
+}
+
+// TestCreatedAtUsesClock tests that new rows are stamped from the DB's clock
+func TestCreatedAtUsesClock(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	stamp := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	db.Clock = clock.NewFake(stamp)
+
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "clocked", Email: "clocked@example.com", Password: "password123"})
+	require.NoError(t, err)
+	assert.True(t, stamp.Equal(user.CreatedAt), "user created_at %s", user.CreatedAt)
+
+	post, err := db.CreatePost(ctx, &models.PostRequest{Title: "Clocked", Content: "Content", UserID: user.ID})
+	require.NoError(t, err)
+	assert.True(t, stamp.Equal(post.CreatedAt), "post created_at %s", post.CreatedAt)
+}
+
+// TestGetPostArchive tests monthly post counts
+func TestGetPostArchive(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC))
+	db.Clock = fake
+
+	alice, err := db.CreateUser(ctx, &models.UserRequest{Username: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+	bob, err := db.CreateUser(ctx, &models.UserRequest{Username: "bob", Email: "bob@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	createAt := func(at time.Time, title string, userID int) {
+		fake.Set(at)
+		_, err := db.CreatePost(ctx, &models.PostRequest{Title: title, Content: "Content", Status: models.PostStatusPublished, UserID: userID})
+		require.NoError(t, err)
+	}
+	createAt(time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC), "January one", alice.ID)
+	createAt(time.Date(2024, 1, 31, 23, 30, 0, 0, time.UTC), "January two", bob.ID)
+	createAt(time.Date(2024, 3, 5, 8, 0, 0, 0, time.UTC), "March", alice.ID)
+
+	archive, err := db.GetPostArchive(ctx, models.PostFilter{}, time.UTC)
+	require.NoError(t, err)
+	assert.Equal(t, []models.ArchiveMonth{
+		{Year: 2024, Month: 3, Count: 1},
+		{Year: 2024, Month: 1, Count: 2},
+	}, archive)
+
+	t.Run("author filter", func(t *testing.T) {
+		archive, err := db.GetPostArchive(ctx, models.PostFilter{UserID: bob.ID}, time.UTC)
+		require.NoError(t, err)
+		assert.Equal(t, []models.ArchiveMonth{{Year: 2024, Month: 1, Count: 1}}, archive)
+	})
+
+	t.Run("months follow the time zone", func(t *testing.T) {
+		// 23:30 UTC on January 31st is already February in Tokyo
+		tokyo, err := time.LoadLocation("Asia/Tokyo")
+		require.NoError(t, err)
+
+		archive, err := db.GetPostArchive(ctx, models.PostFilter{}, tokyo)
+		require.NoError(t, err)
+		assert.Equal(t, []models.ArchiveMonth{
+			{Year: 2024, Month: 3, Count: 1},
+			{Year: 2024, Month: 2, Count: 1},
+			{Year: 2024, Month: 1, Count: 1},
+		}, archive)
+	})
+}
+
+func TestSearchPosts(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	create := func(title, content string) int {
+		post, err := db.CreatePost(ctx, &models.PostRequest{Title: title, Content: content, Status: models.PostStatusPublished, UserID: user.ID})
+		require.NoError(t, err)
+		return post.ID
+	}
+	inBody := create("Weekend notes", "A few gardening tips for the spring")
+	inTitle := create("Gardening tips", "What to plant before the spring")
+	create("Gardening", "Nothing but pictures this week")
+	create("Cooking tips", "Soup for the cold months")
+
+	ids := func(posts []models.Post) []int {
+		ids := make([]int, len(posts))
+		for i, post := range posts {
+			ids[i] = post.ID
+		}
+		return ids
+	}
+
+	posts, err := db.SearchPosts(ctx, "gardening tips", 10, 0)
+	require.NoError(t, err)
+	// Only posts with both words match, and title hits rank first
+	assert.Equal(t, []int{inTitle, inBody}, ids(posts))
+	assert.Equal(t, "alice", posts[0].Username)
+
+	t.Run("stemming", func(t *testing.T) {
+		posts, err := db.SearchPosts(ctx, "planting", 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []int{inTitle}, ids(posts))
+	})
+
+	t.Run("paging", func(t *testing.T) {
+		posts, err := db.SearchPosts(ctx, "gardening tips", 1, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []int{inBody}, ids(posts))
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		posts, err := db.SearchPosts(ctx, "astronomy", 10, 0)
+		require.NoError(t, err)
+		assert.Empty(t, posts)
+	})
+}
+
+func TestAutosaveDraft(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	author, err := db.CreateUser(ctx, &models.UserRequest{Username: "author", Email: "author@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	draft, err := db.CreatePost(ctx, &models.PostRequest{Title: "Draft", Content: "Content", UserID: author.ID, Tags: []string{"go"}})
+	require.NoError(t, err)
+	published, err := db.CreatePost(ctx, &models.PostRequest{Title: "Published", Content: "Content", UserID: author.ID, Status: models.PostStatusPublished})
+	require.NoError(t, err)
+
+	title, empty := "Work in progress", ""
+	saved, err := db.AutosaveDraft(ctx, draft.ID, &title, &empty)
+	require.NoError(t, err)
+	assert.Equal(t, "Work in progress", saved.Title)
+	assert.Equal(t, "", saved.Content)
+	assert.Equal(t, models.PostStatusDraft, saved.Status)
+	assert.Nil(t, saved.PublishedAt)
+	assert.Equal(t, []string{"go"}, saved.Tags)
+
+	// A field left out keeps its saved value
+	content := "Second paragraph"
+	saved, err = db.AutosaveDraft(ctx, draft.ID, nil, &content)
+	require.NoError(t, err)
+	assert.Equal(t, "Work in progress", saved.Title)
+	assert.Equal(t, "Second paragraph", saved.Content)
+
+	_, err = db.AutosaveDraft(ctx, published.ID, &title, nil)
+	assert.ErrorIs(t, err, ErrPostAlreadyPublished)
+	post, err := db.GetPostByID(ctx, published.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Published", post.Title)
+
+	_, err = db.AutosaveDraft(ctx, 999999, &title, nil)
+	assert.ErrorIs(t, err, ErrPostNotFound)
+}
+
+func TestScheduledPublishing(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	db.Clock = fake
+
+	ctx := context.Background()
+	author, err := db.CreateUser(ctx, &models.UserRequest{Username: "author", Email: "author@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	publishAt := fake.Now().Add(time.Hour)
+	scheduled, err := db.CreatePost(ctx, &models.PostRequest{Title: "Scheduled", Content: "Content", UserID: author.ID, PublishAt: &publishAt})
+	require.NoError(t, err)
+	assert.Equal(t, models.PostStatusDraft, scheduled.Status)
+	require.NotNil(t, scheduled.PublishAt)
+	assert.True(t, publishAt.Equal(*scheduled.PublishAt))
+
+	_, err = db.CreatePost(ctx, &models.PostRequest{Title: "Unscheduled", Content: "Content", UserID: author.ID})
+	require.NoError(t, err)
+
+	anonymousTitles := func() []string {
+		posts, _, err := db.GetRecentPosts(ctx, models.PostListOptions{}, 0)
+		require.NoError(t, err)
+		var titles []string
+		for _, post := range posts {
+			titles = append(titles, post.Title)
+		}
+		return titles
+	}
+
+	// Not due yet
+	fake.Advance(59 * time.Minute)
+	published, err := db.PublishDuePosts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, published)
+	assert.Empty(t, anonymousTitles())
+
+	// Due
+	fake.Advance(2 * time.Minute)
+	published, err = db.PublishDuePosts(ctx)
+	require.NoError(t, err)
+	require.Len(t, published, 1)
+	assert.Equal(t, scheduled.ID, published[0].ID)
+	assert.Equal(t, "author", published[0].Username)
+	assert.Equal(t, models.PostStatusPublished, published[0].Status)
+	require.NotNil(t, published[0].PublishedAt)
+	assert.True(t, publishAt.Equal(*published[0].PublishedAt))
+	assert.Nil(t, published[0].PublishAt)
+	assert.Equal(t, []string{"Scheduled"}, anonymousTitles())
+
+	// Published only once
+	published, err = db.PublishDuePosts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, published)
+
+	t.Run("publishing cancels the schedule", func(t *testing.T) {
+		later := fake.Now().Add(time.Hour)
+		post, err := db.CreatePost(ctx, &models.PostRequest{Title: "Rushed", Content: "Content", UserID: author.ID, PublishAt: &later})
+		require.NoError(t, err)
+
+		post, err = db.PublishPost(ctx, post.ID)
+		require.NoError(t, err)
+		assert.Nil(t, post.PublishAt)
+	})
+
+	t.Run("rescheduling", func(t *testing.T) {
+		later := fake.Now().Add(time.Hour)
+		post, err := db.CreatePost(ctx, &models.PostRequest{Title: "Postponed", Content: "Content", UserID: author.ID, PublishAt: &later})
+		require.NoError(t, err)
+
+		muchLater := later.Add(24 * time.Hour)
+		post, err = db.UpdatePost(ctx, post.ID, &models.PostRequest{PublishAt: &muchLater})
+		require.NoError(t, err)
+		require.NotNil(t, post.PublishAt)
+		assert.True(t, muchLater.Equal(*post.PublishAt))
+
+		fake.Advance(2 * time.Hour)
+		published, err := db.PublishDuePosts(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, published)
+	})
+}
+
+func TestPostStatus(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	author, err := db.CreateUser(ctx, &models.UserRequest{Username: "author", Email: "author@example.com", Password: "password123"})
+	require.NoError(t, err)
+	reader, err := db.CreateUser(ctx, &models.UserRequest{Username: "reader", Email: "reader@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	draft, err := db.CreatePost(ctx, &models.PostRequest{Title: "Draft", Content: "Content", UserID: author.ID})
+	require.NoError(t, err)
+	assert.Equal(t, models.PostStatusDraft, draft.Status)
+	assert.Nil(t, draft.PublishedAt)
+
+	published, err := db.CreatePost(ctx, &models.PostRequest{Title: "Published", Content: "Content", Status: models.PostStatusPublished, UserID: author.ID})
+	require.NoError(t, err)
+	assert.NotNil(t, published.PublishedAt)
+
+	titles := func(viewerID int) []string {
+		posts, _, err := db.GetRecentPosts(ctx, models.PostListOptions{ViewerID: viewerID}, 0)
+		require.NoError(t, err)
+		var titles []string
+		for _, post := range posts {
+			titles = append(titles, post.Title)
+		}
+		return titles
+	}
+
+	t.Run("visibility", func(t *testing.T) {
+		assert.Equal(t, []string{"Published"}, titles(0))
+		assert.Equal(t, []string{"Published"}, titles(reader.ID))
+		assert.ElementsMatch(t, []string{"Draft", "Published"}, titles(author.ID))
+
+		posts, _, err := db.GetRecentPosts(ctx, models.PostListOptions{IncludeDrafts: true}, 0)
+		require.NoError(t, err)
+		assert.Len(t, posts, 2)
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		post, err := db.PublishPost(ctx, draft.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.PostStatusPublished, post.Status)
+		require.NotNil(t, post.PublishedAt)
+		assert.ElementsMatch(t, []string{"Draft", "Published"}, titles(0))
+
+		_, err = db.PublishPost(ctx, draft.ID)
+		assert.ErrorIs(t, err, ErrPostAlreadyPublished)
+
+		_, err = db.PublishPost(ctx, 999999)
+		assert.ErrorIs(t, err, ErrPostNotFound)
+	})
+
+	t.Run("unpublish", func(t *testing.T) {
+		post, err := db.UpdatePost(ctx, published.ID, &models.PostRequest{Status: models.PostStatusDraft})
+		require.NoError(t, err)
+		assert.Equal(t, models.PostStatusDraft, post.Status)
+		assert.Nil(t, post.PublishedAt)
+		assert.Equal(t, []string{"Draft"}, titles(0))
+	})
+}
+
+func TestNormalizeTag(t *testing.T) {
+	tests := map[string]string{
+		"Golang":        "golang",
+		"  news ":       "news",
+		"Web Dev":       "web-dev",
+		"web \t\n  dev": "web-dev",
+		"web--dev":      "web-dev",
+		"web - dev":     "web-dev",
+		"---":           "-",
+		"   ":           "",
+	}
+	for tag, want := range tests {
+		assert.Equal(t, want, NormalizeTag(tag), "NormalizeTag(%q)", tag)
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	assert.Equal(t, []string{"go", "news"}, normalizeTags([]string{" News", "Go", "go", "", "NEWS "}))
+	assert.Equal(t, []string{"web-dev"}, normalizeTags([]string{"Web Dev", "web--dev"}))
+	assert.Equal(t, []string{}, normalizeTags(nil))
+}
+
+func TestPostTags(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	tagged, err := db.CreatePost(ctx, &models.PostRequest{Title: "Tagged", Content: "Content", Status: models.PostStatusPublished, UserID: user.ID, Tags: []string{"Go", "news", "GO "}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go", "news"}, tagged.Tags)
+
+	untagged, err := db.CreatePost(ctx, &models.PostRequest{Title: "Untagged", Content: "Content", UserID: user.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, untagged.Tags)
+
+	tags, err := db.GetPostTags(ctx, tagged.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go", "news"}, tags)
+
+	post, err := db.GetPostByID(ctx, tagged.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go", "news"}, post.Tags)
+
+	t.Run("filter by tag", func(t *testing.T) {
+		posts, truncated, err := db.GetPostsByTag(ctx, "Go", 0, 0)
+		require.NoError(t, err)
+		assert.False(t, truncated)
+		require.Len(t, posts, 1)
+		assert.Equal(t, tagged.ID, posts[0].ID)
+
+		posts, _, err = db.GetPostsByTag(ctx, "rust", 0, 0)
+		require.NoError(t, err)
+		assert.Empty(t, posts)
+	})
+
+	t.Run("update without tags keeps them", func(t *testing.T) {
+		post, err := db.UpdatePost(ctx, tagged.ID, &models.PostRequest{Title: "Retitled"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"go", "news"}, post.Tags)
+	})
+
+	t.Run("removing a tag deletes its join row", func(t *testing.T) {
+		post, err := db.UpdatePost(ctx, tagged.ID, &models.PostRequest{Tags: []string{"go"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"go"}, post.Tags)
+
+		var joins int
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM post_tags WHERE post_id = $1`, tagged.ID).Scan(&joins))
+		assert.Equal(t, 1, joins)
+
+		posts, _, err := db.GetPostsByTag(ctx, "news", 0, 0)
+		require.NoError(t, err)
+		assert.Empty(t, posts)
+	})
+
+	t.Run("set tags", func(t *testing.T) {
+		tags, err := db.SetPostTags(ctx, untagged.ID, []string{"News"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"news"}, tags)
+
+		tags, err = db.SetPostTags(ctx, untagged.ID, []string{})
+		require.NoError(t, err)
+		assert.Empty(t, tags)
+
+		_, err = db.SetPostTags(ctx, 999999, []string{"go"})
+		assert.ErrorIs(t, err, ErrPostNotFound)
+	})
+
+	t.Run("set tags replaces them", func(t *testing.T) {
+		_, err := db.SetPostTags(ctx, untagged.ID, []string{"go", "news"})
+		require.NoError(t, err)
+
+		tags, err := db.SetPostTags(ctx, untagged.ID, []string{"web"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web"}, tags)
+
+		tags, err = db.GetPostTags(ctx, untagged.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web"}, tags)
+	})
+
+	t.Run("add tag", func(t *testing.T) {
+		_, err := db.SetPostTags(ctx, untagged.ID, []string{"web"})
+		require.NoError(t, err)
+
+		tags, err := db.AddPostTag(ctx, untagged.ID, "Go")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"go", "web"}, tags)
+
+		// Adding it again is a no-op
+		tags, err = db.AddPostTag(ctx, untagged.ID, "go")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"go", "web"}, tags)
+
+		var joins int
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM post_tags WHERE post_id = $1`, untagged.ID).Scan(&joins))
+		assert.Equal(t, 2, joins)
+
+		_, err = db.AddPostTag(ctx, 999999, "go")
+		assert.ErrorIs(t, err, ErrPostNotFound)
+	})
+
+	t.Run("add tag beyond the limit", func(t *testing.T) {
+		full := make([]string, models.MaxPostTags)
+		for i := range full {
+			full[i] = fmt.Sprintf("tag%02d", i)
+		}
+		_, err := db.SetPostTags(ctx, untagged.ID, full)
+		require.NoError(t, err)
+
+		_, err = db.AddPostTag(ctx, untagged.ID, "extra")
+		assert.ErrorIs(t, err, ErrTooManyTags)
+
+		// A tag the post already has is still fine
+		tags, err := db.AddPostTag(ctx, untagged.ID, "tag00")
+		require.NoError(t, err)
+		assert.Equal(t, full, tags)
+	})
+
+	t.Run("remove tag", func(t *testing.T) {
+		_, err := db.SetPostTags(ctx, untagged.ID, []string{"go", "web"})
+		require.NoError(t, err)
+
+		tags, err := db.RemovePostTag(ctx, untagged.ID, "Web")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"go"}, tags)
+
+		// Removing a tag the post doesn't have is a no-op
+		tags, err = db.RemovePostTag(ctx, untagged.ID, "rust")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"go"}, tags)
+
+		_, err = db.RemovePostTag(ctx, 999999, "go")
+		assert.ErrorIs(t, err, ErrPostNotFound)
+	})
+}
+
+// TestPostsLastModified tests which changes move the posts lists' Last-Modified
+func TestPostsLastModified(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+
+	lastModified := func(authorID int) time.Time {
+		changedAt, _, err := db.PostsLastModified(ctx, authorID)
+		require.NoError(t, err)
+		return changedAt
+	}
+
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "poller", Email: "poller@example.com", Password: "password123"})
+	require.NoError(t, err)
+	other, err := db.CreateUser(ctx, &models.UserRequest{Username: "bystander", Email: "bystander@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	before := lastModified(0)
+	post, err := db.CreatePost(ctx, &models.PostRequest{Title: "Fresh", Content: "Content", UserID: user.ID})
+	require.NoError(t, err)
+	created := lastModified(0)
+	assert.True(t, created.After(before), "creating a post changes the list")
+	assert.Equal(t, created, lastModified(user.ID), "the author's list changes too")
+
+	_, settled, err := db.PostsLastModified(ctx, 0)
+	require.NoError(t, err)
+	assert.False(t, settled, "a change this second isn't settled yet")
+
+	require.NoError(t, db.IncrementPostViews(ctx, post.ID))
+	assert.Equal(t, created, lastModified(0), "views don't change the list")
+
+	_, err = db.SetPostTags(ctx, post.ID, []string{"go"})
+	require.NoError(t, err)
+	tagged := lastModified(user.ID)
+	assert.True(t, tagged.After(created), "tagging a post changes the list")
+
+	// Another author's post only changes the full list
+	_, err = db.CreatePost(ctx, &models.PostRequest{Title: "Elsewhere", Content: "Content", UserID: other.ID})
+	require.NoError(t, err)
+	assert.True(t, lastModified(0).After(tagged))
+	assert.Equal(t, tagged, lastModified(user.ID), "other authors don't change an author's list")
+
+	_, err = db.SetUserBanned(ctx, user.ID, true)
+	require.NoError(t, err)
+	banned := lastModified(user.ID)
+	assert.True(t, banned.After(tagged), "banning the author hides their posts")
+
+	// A post moved away leaves the old author's list changed
+	_, err = db.TransferPostOwnership(ctx, post.ID, other.ID)
+	require.NoError(t, err)
+	assert.True(t, lastModified(user.ID).After(banned), "moving a post away changes the list")
+
+	moved := lastModified(other.ID)
+	require.NoError(t, db.DeletePost(ctx, post.ID))
+	assert.True(t, lastModified(other.ID).After(moved), "deleting a post changes the list")
+}
+
+// TestGetPostSiblings tests prev/next lookups in the middle and at both ends
+func TestGetPostSiblings(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	db.Clock = fake
+
+	alice, err := db.CreateUser(ctx, &models.UserRequest{Username: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+	bob, err := db.CreateUser(ctx, &models.UserRequest{Username: "bob", Email: "bob@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	// Oldest to newest: alice, bob, alice
+	var posts []*models.Post
+	for i, author := range []int{alice.ID, bob.ID, alice.ID} {
+		fake.Advance(time.Hour)
+		post, err := db.CreatePost(ctx, &models.PostRequest{Title: fmt.Sprintf("Post %d", i), Content: "Content", Status: models.PostStatusPublished, UserID: author})
+		require.NoError(t, err)
+		posts = append(posts, post)
+	}
+
+	t.Run("Middle", func(t *testing.T) {
+		siblings, err := db.GetPostSiblings(ctx, posts[1].ID, models.SiblingScopeAll)
+		require.NoError(t, err)
+		require.NotNil(t, siblings.Prev)
+		require.NotNil(t, siblings.Next)
+		assert.Equal(t, posts[0].ID, siblings.Prev.ID)
+		assert.Equal(t, posts[2].ID, siblings.Next.ID)
+		assert.Equal(t, "alice", siblings.Prev.Username)
+	})
+
+	t.Run("Oldest", func(t *testing.T) {
+		siblings, err := db.GetPostSiblings(ctx, posts[0].ID, models.SiblingScopeAll)
+		require.NoError(t, err)
+		assert.Nil(t, siblings.Prev)
+		require.NotNil(t, siblings.Next)
+		assert.Equal(t, posts[1].ID, siblings.Next.ID)
+	})
+
+	t.Run("Newest", func(t *testing.T) {
+		siblings, err := db.GetPostSiblings(ctx, posts[2].ID, models.SiblingScopeAll)
+		require.NoError(t, err)
+		require.NotNil(t, siblings.Prev)
+		assert.Equal(t, posts[1].ID, siblings.Prev.ID)
+		assert.Nil(t, siblings.Next)
+	})
+
+	t.Run("SameAuthor", func(t *testing.T) {
+		siblings, err := db.GetPostSiblings(ctx, posts[0].ID, models.SiblingScopeAuthor)
+		require.NoError(t, err)
+		assert.Nil(t, siblings.Prev)
+		require.NotNil(t, siblings.Next)
+		assert.Equal(t, posts[2].ID, siblings.Next.ID)
+	})
+
+	t.Run("SameTimestamp", func(t *testing.T) {
+		// Posts created in the same instant are ordered by id
+		tied, err := db.CreatePost(ctx, &models.PostRequest{Title: "Tied", Content: "Content", Status: models.PostStatusPublished, UserID: bob.ID})
+		require.NoError(t, err)
+
+		siblings, err := db.GetPostSiblings(ctx, posts[2].ID, models.SiblingScopeAll)
+		require.NoError(t, err)
+		require.NotNil(t, siblings.Next)
+		assert.Equal(t, tied.ID, siblings.Next.ID)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := db.GetPostSiblings(ctx, 99999, models.SiblingScopeAll)
+		assert.ErrorIs(t, err, ErrPostNotFound)
+	})
+}
+
+// TestGetRecentPostsLimit tests that a capped list reports being cut short
+func TestGetRecentPostsLimit(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "prolific", Email: "prolific@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO posts (title, content, status, user_id, created_at)
+		SELECT 'Post ' || n, 'Content', 'published', $1, now() - n * interval '1 minute'
+		FROM generate_series(1, 500) AS n`, user.ID)
+	require.NoError(t, err)
+
+	posts, truncated, err := db.GetRecentPosts(ctx, models.PostListOptions{}, 100)
+	require.NoError(t, err)
+	assert.True(t, truncated)
+	require.Len(t, posts, 100)
+	assert.Equal(t, "Post 1", posts[0].Title, "the newest posts are kept")
+
+	posts, truncated, err = db.GetRecentPosts(ctx, models.PostListOptions{}, 500)
+	require.NoError(t, err)
+	assert.False(t, truncated, "exactly the cap isn't truncated")
+	assert.Len(t, posts, 500)
+
+	posts, truncated, err = db.GetRecentPosts(ctx, models.PostListOptions{}, 0)
+	require.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Len(t, posts, 500)
+}
+
+// TestContentEncodingRoundTrip tests that content survives both storage encodings
+func TestContentEncodingRoundTrip(t *testing.T) {
+	content := strings.Repeat("A long post about Go, with unicode: ünïcödé ✓\n", 500)
+
+	for _, compress := range []bool{false, true} {
+		plain, compressed, encoding, err := encodeContent(content, compress)
+		require.NoError(t, err)
+
+		if compress {
+			assert.Equal(t, contentEncodingGzip, encoding)
+			assert.Empty(t, plain)
+			assert.Less(t, len(compressed), len(content))
+		} else {
+			assert.Equal(t, contentEncodingPlain, encoding)
+			assert.Nil(t, compressed)
+		}
+
+		decoded, err := decodeContent(plain, compressed, encoding)
+		require.NoError(t, err)
+		assert.Equal(t, content, decoded)
+	}
+
+	_, err := decodeContent("", []byte("not gzip"), contentEncodingGzip)
+	assert.Error(t, err)
+
+	_, err = decodeContent("text", nil, "brotli")
+	assert.Error(t, err)
+}
+
+// TestCompressedPostContent tests posts written with compression enabled
+func TestCompressedPostContent(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "longform", Email: "longform@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	// A post written before compression was turned on stays readable
+	plainPost, err := db.CreatePost(ctx, &models.PostRequest{Title: "Plain", Content: "Stored as text", Status: models.PostStatusPublished, UserID: user.ID})
+	require.NoError(t, err)
+
+	db.CompressContent = true
+	content := strings.Repeat("Compressible paragraph. ", 1000)
+
+	post, err := db.CreatePost(ctx, &models.PostRequest{Title: "Compressed", Content: content, Status: models.PostStatusPublished, UserID: user.ID})
+	require.NoError(t, err)
+	assert.Equal(t, content, post.Content)
+
+	fetched, err := db.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, content, fetched.Content)
+
+	var stored string
+	var encoding string
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT content, content_encoding FROM posts WHERE id = $1`, post.ID).Scan(&stored, &encoding))
+	assert.Empty(t, stored)
+	assert.Equal(t, contentEncodingGzip, encoding)
+
+	updated, err := db.UpdatePost(ctx, plainPost.ID, &models.PostRequest{Content: "Now compressed"})
+	require.NoError(t, err)
+	assert.Equal(t, "Now compressed", updated.Content)
+
+	posts, err := db.GetAllPosts(ctx)
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	for _, p := range posts {
+		assert.NotEmpty(t, p.Content)
+	}
+}
+
+// TestBufferedViews tests that buffered view increments are served right away
+// and written to the database when the connection is closed
+func TestBufferedViews(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "viewed", Email: "viewed@example.com", Password: "password123"})
+	require.NoError(t, err)
+	post, err := db.CreatePost(ctx, &models.PostRequest{Title: "Viewed", Content: "Content", UserID: user.ID})
+	require.NoError(t, err)
+
+	// Long enough that only Close flushes
+	cfg := testConfig()
+	cfg.ViewFlushIntervalMS = int(time.Hour / time.Millisecond)
+	buffered, err := New(cfg)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, buffered.IncrementPostViews(ctx, post.ID))
+	}
+
+	var stored int
+	require.NoError(t, db.QueryRow("SELECT view_count FROM posts WHERE id = $1", post.ID).Scan(&stored))
+	assert.Equal(t, 0, stored, "views were written before a flush")
+
+	served, err := buffered.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, served.ViewCount)
+
+	require.NoError(t, buffered.Close())
+
+	require.NoError(t, db.QueryRow("SELECT view_count FROM posts WHERE id = $1", post.ID).Scan(&stored))
+	assert.Equal(t, 3, stored)
+}
+
+// TestBuildDSNApplicationName tests labeling connections with application_name
+func TestBuildDSNApplicationName(t *testing.T) {
+	t.Run("Components", func(t *testing.T) {
+		dsn := buildDSN(&config.Config{DatabaseHost: "db", DatabaseName: "blog", DatabaseAppName: "blog-api 1.2.0 (abc123)"})
+		assert.Contains(t, dsn, "application_name='blog-api 1.2.0 (abc123)'")
+	})
+
+	t.Run("QuotesValue", func(t *testing.T) {
+		dsn := buildDSN(&config.Config{DatabaseAppName: `it's\here`})
+		assert.Contains(t, dsn, `application_name='it\'s\\here'`)
+	})
+
+	t.Run("URL", func(t *testing.T) {
+		dsn := buildDSN(&config.Config{DatabaseURL: "postgres://u:p@db/blog?sslmode=disable", DatabaseAppName: "blog-api"})
+		assert.Equal(t, "postgres://u:p@db/blog?application_name=blog-api&sslmode=disable", dsn)
+	})
+
+	t.Run("KeyValueURL", func(t *testing.T) {
+		dsn := buildDSN(&config.Config{DatabaseURL: "host=db dbname=blog", DatabaseAppName: "blog-api"})
+		assert.Equal(t, "host=db dbname=blog application_name='blog-api'", dsn)
+	})
+
+	t.Run("ExplicitNameKept", func(t *testing.T) {
+		cfg := &config.Config{DatabaseURL: "postgres://db/blog?application_name=reports", DatabaseAppName: "blog-api"}
+		assert.Equal(t, "postgres://db/blog?application_name=reports", buildDSN(cfg))
+
+		cfg.DatabaseURL = "host=db application_name=reports"
+		assert.Equal(t, "host=db application_name=reports", buildDSN(cfg))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		assert.NotContains(t, buildDSN(&config.Config{DatabaseHost: "db"}), "application_name")
+	})
+}
+
+// fakeRow scans fixed values into a row's destinations, standing in for a
+// database row in scan tests. A nil value is SQL NULL.
+type fakeRow []interface{}
+
+func (r fakeRow) Scan(dest ...interface{}) error {
+	if len(dest) != len(r) {
+		return fmt.Errorf("expected %d destinations, got %d", len(r), len(dest))
+	}
+	for i, d := range dest {
+		if scanner, ok := d.(sql.Scanner); ok {
+			if err := scanner.Scan(r[i]); err != nil {
+				return err
+			}
+			continue
+		}
+		target := reflect.ValueOf(d).Elem()
+		if r[i] == nil {
+			if target.Kind() != reflect.Ptr && target.Kind() != reflect.Slice {
+				return fmt.Errorf("column %d: converting NULL to %s is unsupported", i, target.Type())
+			}
+			target.Set(reflect.Zero(target.Type()))
+			continue
+		}
+		target.Set(reflect.ValueOf(r[i]))
+	}
+	return nil
+}
+
+// TestScanNullColumns tests that NULLs in nullable columns scan cleanly
+func TestScanNullColumns(t *testing.T) {
+	t.Run("Post", func(t *testing.T) {
+		row := fakeRow{1, "Title", "Content", nil, contentEncodingPlain, "markdown", 2, 0, false, nil, models.PostStatusDraft, nil, nil, nil, nil, []byte("{go,news}"), "author"}
+
+		var post models.Post
+		require.NoError(t, scanPost(row, &post, &post.Username))
+		assert.Equal(t, "Content", post.Content)
+		assert.Equal(t, []string{"go", "news"}, post.Tags)
+		assert.Nil(t, post.FeaturedOrder)
+		assert.Nil(t, post.PublishedAt)
+		assert.Nil(t, post.PublishAt)
+		assert.True(t, post.CreatedAt.IsZero())
+		assert.Equal(t, "author", post.Username)
+	})
+
+	t.Run("User", func(t *testing.T) {
+		row := fakeRow{1, "anon", "anon@example.com", false, nil}
+
+		var user models.User
+		require.NoError(t, scanUser(row, &user))
+		assert.Equal(t, "anon", user.Username)
+		assert.True(t, user.CreatedAt.IsZero())
+	})
+
+	t.Run("Database", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer teardownTestDB(t, db)
+
+		ctx := context.Background()
+		user, err := db.CreateUser(ctx, &models.UserRequest{Username: "undated", Email: "undated@example.com", Password: "password123"})
+		require.NoError(t, err)
+		post, err := db.CreatePost(ctx, &models.PostRequest{Title: "Undated", Content: "Content", UserID: user.ID})
+		require.NoError(t, err)
+
+		_, err = db.Exec("UPDATE users SET created_at = NULL")
+		require.NoError(t, err)
+		_, err = db.Exec("UPDATE posts SET created_at = NULL")
+		require.NoError(t, err)
+
+		got, err := db.GetPostByID(ctx, post.ID)
+		require.NoError(t, err)
+		assert.True(t, got.CreatedAt.IsZero())
+
+		gotUser, err := db.GetUserByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.True(t, gotUser.CreatedAt.IsZero())
+
+		stats, err := db.GetUserStats(ctx, user.ID)
+		require.NoError(t, err)
+		assert.True(t, stats.JoinedAt.IsZero())
+	})
+}
+
+// TestStatus tests the table estimates and pool statistics
+func TestStatus(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	_, err := db.CreateUser(ctx, &models.UserRequest{Username: "counted", Email: "counted@example.com", Password: "password123"})
+	require.NoError(t, err)
+	_, err = db.Exec("ANALYZE users")
+	require.NoError(t, err)
+
+	status, err := db.Status(ctx)
+	require.NoError(t, err)
+
+	tables := make(map[string]*int64)
+	for _, table := range status.Tables {
+		tables[table.Name] = table.EstimatedRows
+	}
+	require.Contains(t, tables, "posts")
+	require.Contains(t, tables, "users")
+	require.NotNil(t, tables["users"])
+	assert.Equal(t, int64(1), *tables["users"])
+
+	assert.Equal(t, 5, status.Pool.MaxOpen)
+	assert.GreaterOrEqual(t, status.Pool.Open, 1)
+
+	// schema.sql records every migration as applied
+	assert.Equal(t, migrationVersions, status.Migrations)
+}
+
+// TestSchemaRecordsMigrations tests that schema.sql records every file in
+// migrations/, so fresh databases pass CheckSchema
+func TestSchemaRecordsMigrations(t *testing.T) {
+	schema, err := os.ReadFile("../../schema.sql")
+	require.NoError(t, err)
+
+	require.NotEmpty(t, migrationVersions)
+	assert.Equal(t, "001", migrationVersions[0])
+	for _, version := range migrationVersions {
+		assert.Contains(t, string(schema), "('"+version+"')")
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		db := setupTestDBFor(t, driverSQLite)
+		defer teardownTestDB(t, db)
+
+		assert.NoError(t, db.CheckSchema(context.Background()))
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer teardownTestDB(t, db)
+
+		assert.NoError(t, db.CheckSchema(context.Background()))
+
+		// A migration that hasn't been applied yet
+		saved := migrationVersions
+		defer func() { migrationVersions = saved }()
+		migrationVersions = append(append([]string{}, saved...), "999")
+
+		assert.ErrorContains(t, db.CheckSchema(context.Background()), "migrations 999 have not been applied")
+	})
+}
+
+// TestClassifyPGError tests mapping Postgres error codes to sentinels
+func TestClassifyPGError(t *testing.T) {
+	tests := []struct {
+		code pq.ErrorCode
+		want error
+	}{
+		{code: "23505", want: ErrDuplicate},
+		{code: "23503", want: ErrForeignKey},
+		{code: "23514", want: ErrCheckViolation},
+		{code: "23502", want: ErrNotNullViolation},
+		{code: "40001", want: ErrSerializationFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			pqErr := &pq.Error{Code: tt.code, Constraint: "some_constraint"}
+			err := fmt.Errorf("failed to create post: %w", classifyPGError(pqErr))
+
+			assert.ErrorIs(t, err, tt.want)
+
+			// The original error keeps its details
+			var original *pq.Error
+			require.ErrorAs(t, err, &original)
+			assert.Equal(t, "some_constraint", original.Constraint)
+		})
+	}
+
+	t.Run("OtherCode", func(t *testing.T) {
+		pqErr := &pq.Error{Code: "42P01"}
+		assert.Same(t, pqErr, classifyPGError(pqErr))
+	})
+
+	t.Run("NotPostgres", func(t *testing.T) {
+		assert.Equal(t, ErrPostNotFound, classifyPGError(ErrPostNotFound))
+		assert.NoError(t, classifyPGError(nil))
+	})
+
+	t.Run("ConstraintViolationStillRecognized", func(t *testing.T) {
+		err := classifyPGError(&pq.Error{Code: "23514", Table: "posts", Constraint: "posts_title_check"})
+		violation, ok := AsConstraintViolation(err)
+		require.True(t, ok)
+		assert.Equal(t, "title", violation.Column)
+	})
+}
+
+// setupTestDB creates a test database connection
+func setupTestDB(t *testing.T) *DB {
+	// Tests run against a real PostgreSQL database configured through the
+	// TEST_DB_* environment variables, with schema.sql already applied.
+	// They are skipped when no such database is reachable.
+	db, err := New(testConfig())
+	if err != nil {
+		t.Skipf("Test database not available: %v", err)
+	}
+
+	// Start every test from empty tables
+	_, err = db.Exec("TRUNCATE posts, users, tags RESTART IDENTITY CASCADE")
+	require.NoError(t, err)
+
+	return db
+}
+
+// setupTestDBFor creates an empty test database for driver. SQLite runs in
+// memory, so unlike Postgres it is always available.
+func setupTestDBFor(t *testing.T, driver string) *DB {
+	if driver != driverSQLite {
+		return setupTestDB(t)
+	}
+
+	db, err := newSQLite(":memory:")
+	require.NoError(t, err)
+	return db
+}
+
+// testConfig points at the test database
+func testConfig() *config.Config {
+	return &config.Config{
+		DatabaseHost:   getTestEnv("TEST_DB_HOST", "localhost"),
+		DatabasePort:   getTestEnv("TEST_DB_PORT", "5432"),
+		DatabaseUser:   getTestEnv("TEST_DB_USER", "postgres"),
+		DatabasePass:   getTestEnv("TEST_DB_PASS", "password"),
+		DatabaseName:   getTestEnv("TEST_DB_NAME", "blog_api_test"),
+		MaxConnections: 5,
+	}
+}
+
+// teardownTestDB cleans up the test database
+func teardownTestDB(t *testing.T, db *DB) {
+	if db != nil {
+		db.Close()
+	}
+}
+
+// getTestEnv gets an environment variable or returns a default value
+func getTestEnv(key, defaultVal string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultVal
+}
+
+func TestSQLOperation(t *testing.T) {
+	tests := map[string]string{
+		"SELECT id FROM posts":                        "SELECT",
+		"\n\t\tinsert into posts (title) VALUES ($1)": "INSERT",
+		"WITH moved AS (UPDATE posts SET x = 1)":      "WITH",
+		"   ":                                         "QUERY",
+	}
+	for query, want := range tests {
+		assert.Equal(t, want, sqlOperation(query), query)
+	}
+}
+
+// TestSQLiteUsers tests the driver differences SQLite papers over for users
+func TestSQLiteUsers(t *testing.T) {
+	db := setupTestDBFor(t, driverSQLite)
+	defer teardownTestDB(t, db)
+
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, &models.UserRequest{Username: "lite", Email: "lite@example.com", Password: "password123"})
+	require.NoError(t, err)
+	assert.False(t, user.CreatedAt.IsZero())
+
+	_, err = db.CreateUser(ctx, &models.UserRequest{Username: "lite", Email: "other@example.com", Password: "password123"})
+	assert.ErrorIs(t, err, ErrDuplicate)
+
+	banned, err := db.SetUserBanned(ctx, user.ID, true)
+	require.NoError(t, err)
+	assert.True(t, banned.Banned)
+
+	assert.ErrorIs(t, db.DeleteUserIf(ctx, user.ID, func(*models.User) bool { return false }), ErrPreconditionFailed)
+	require.NoError(t, db.DeleteUserIf(ctx, user.ID, func(*models.User) bool { return true }))
+	_, err = db.GetUserByID(ctx, user.ID)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestRebind(t *testing.T) {
+	query := `UPDATE users SET email = $2 WHERE id = $1 AND $1 > 0`
+
+	postgres := &DB{driver: driverPostgres}
+	assert.Equal(t, query, postgres.rebind(query))
+
+	sqlite := &DB{driver: driverSQLite}
+	assert.Equal(t, `UPDATE users SET email = ?2 WHERE id = ?1 AND ?1 > 0`, sqlite.rebind(query))
 }
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var (
+	// ErrPostNotFound is returned when no post has the requested id
+	ErrPostNotFound = errors.New("post not found")
+
+	// ErrUserNotFound is returned when no user has the requested id or username
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrDuplicateTitle is returned when an author already has a post with the same title
+	ErrDuplicateTitle = errors.New("duplicate post title for author")
+
+	// ErrDuplicateUsername is returned when another user has the same
+	// username, ignoring case. It is also an ErrDuplicate.
+	ErrDuplicateUsername = fmt.Errorf("username already taken: %w", ErrDuplicate)
+
+	// ErrDuplicateEmail is returned when another user has the same email,
+	// ignoring case. It is also an ErrDuplicate.
+	ErrDuplicateEmail = fmt.Errorf("email already registered: %w", ErrDuplicate)
+
+	// ErrInvalidPassword is returned when a password doesn't match the user's
+	ErrInvalidPassword = errors.New("invalid password")
+
+	// ErrUserBanned is returned when a banned user tries to authenticate
+	ErrUserBanned = errors.New("user is banned")
+
+	// ErrRefreshTokenNotFound is returned for a refresh token that was never issued
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+	// ErrRefreshTokenExpired is returned for a refresh token past its expiry
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+
+	// ErrRefreshTokenReused is returned when a refresh token that was already
+	// rotated or revoked is presented again. Its whole family is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+
+	// ErrPasswordResetNotFound is returned for a password reset token that was
+	// never issued
+	ErrPasswordResetNotFound = errors.New("password reset token not found")
+
+	// ErrPasswordResetExpired is returned for a password reset token past its expiry
+	ErrPasswordResetExpired = errors.New("password reset token expired")
+
+	// ErrPasswordResetUsed is returned for a password reset token that was
+	// already used, or replaced by a later reset
+	ErrPasswordResetUsed = errors.New("password reset token already used")
+
+	// ErrPostAlreadyPublished is returned when publishing a post that is already published
+	ErrPostAlreadyPublished = errors.New("post already published")
+
+	// ErrTooManyTags is returned when a tag is added to a post that already
+	// has models.MaxPostTags tags
+	ErrTooManyTags = errors.New("too many tags")
+
+	// ErrTargetUserNotFound is returned when posts are moved to a user that doesn't exist
+	ErrTargetUserNotFound = errors.New("target user does not exist")
+
+	// ErrPreconditionFailed is returned when a conditional change finds the
+	// row no longer in the state the caller expected
+	ErrPreconditionFailed = errors.New("precondition failed")
+
+	// ErrPoolExhausted is returned when no connection frees up within the acquire timeout
+	ErrPoolExhausted = errors.New("database connection pool exhausted")
+)
+
+// Errors for Postgres error codes, as returned by classifyPGError. The
+// original *pq.Error stays in the chain for callers that need its details.
+var (
+	// ErrDuplicate is a unique violation (23505)
+	ErrDuplicate = errors.New("duplicate value")
+
+	// ErrForeignKey is a foreign key violation (23503)
+	ErrForeignKey = errors.New("foreign key violation")
+
+	// ErrCheckViolation is a check constraint violation (23514)
+	ErrCheckViolation = errors.New("check constraint violation")
+
+	// ErrNotNullViolation is a not-null constraint violation (23502)
+	ErrNotNullViolation = errors.New("not-null constraint violation")
+
+	// ErrSerializationFailure is a transaction that lost a serialization
+	// conflict (40001) and may succeed if retried
+	ErrSerializationFailure = errors.New("serialization failure")
+)
+
+// pgErrorClasses maps the Postgres error codes the API reacts to onto their sentinels
+var pgErrorClasses = map[pq.ErrorCode]error{
+	"23505": ErrDuplicate,
+	"23503": ErrForeignKey,
+	"23514": ErrCheckViolation,
+	"23502": ErrNotNullViolation,
+	"40001": ErrSerializationFailure,
+}
+
+// sqliteErrorClasses maps SQLite's extended result codes onto the same sentinels
+var sqliteErrorClasses = map[int]error{
+	sqlite3.SQLITE_CONSTRAINT_UNIQUE:     ErrDuplicate,
+	sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY: ErrDuplicate,
+	sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY: ErrForeignKey,
+	sqlite3.SQLITE_CONSTRAINT_CHECK:      ErrCheckViolation,
+	sqlite3.SQLITE_CONSTRAINT_NOTNULL:    ErrNotNullViolation,
+}
+
+// classifyPGError wraps a Postgres error in the sentinel for its code, so
+// callers can use errors.Is instead of inspecting codes. SQLite errors are
+// classified the same way. Other errors, including nil, are returned
+// unchanged.
+func classifyPGError(err error) error {
+	var sentinel error
+	var pqErr *pq.Error
+	var sqliteErr *sqlite.Error
+	switch {
+	case errors.As(err, &pqErr):
+		sentinel = pgErrorClasses[pqErr.Code]
+	case errors.As(err, &sqliteErr):
+		sentinel = sqliteErrorClasses[sqliteErr.Code()]
+	}
+	if sentinel == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
+
+// uniqueTitleIndex is the name of the optional per-author unique title index
+const uniqueTitleIndex = "idx_posts_user_title_unique"
+
+// isUniqueViolation reports whether err is a unique violation on the given constraint
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+// userUniqueIndexes maps the unique indexes on users, current and from
+// before migration 014, to the error for a clash
+var userUniqueIndexes = map[string]error{
+	"idx_users_username_lower": ErrDuplicateUsername,
+	"users_username_key":       ErrDuplicateUsername,
+	"idx_users_email_lower":    ErrDuplicateEmail,
+	"users_email_key":          ErrDuplicateEmail,
+}
+
+// duplicateUserError returns ErrDuplicateUsername or ErrDuplicateEmail if
+// err is a unique violation on that column of users, and nil otherwise
+func duplicateUserError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if pqErr.Code != "23505" {
+			return nil
+		}
+		return userUniqueIndexes[pqErr.Constraint]
+	}
+
+	// SQLite names the column in the message, e.g. "UNIQUE constraint failed: users.email"
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		switch {
+		case strings.Contains(sqliteErr.Error(), "users.username"):
+			return ErrDuplicateUsername
+		case strings.Contains(sqliteErr.Error(), "users.email"):
+			return ErrDuplicateEmail
+		}
+	}
+	return nil
+}
+
+// ConstraintViolation is a row rejected by a check or not-null constraint
+type ConstraintViolation struct {
+	// Constraint is the violated constraint's name; empty for not-null violations
+	Constraint string
+	// Column is the offending column, when it can be determined
+	Column string
+}
+
+func (e *ConstraintViolation) Error() string {
+	if e.Constraint == "" {
+		return fmt.Sprintf("null value in column %q violates not-null constraint", e.Column)
+	}
+	return fmt.Sprintf("value violates check constraint %q", e.Constraint)
+}
+
+// AsConstraintViolation reports whether err is a check (23514) or not-null
+// (23502) violation and describes it
+func AsConstraintViolation(err error) (*ConstraintViolation, bool) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil, false
+	}
+
+	switch pqErr.Code {
+	case "23502":
+		return &ConstraintViolation{Column: pqErr.Column}, true
+	case "23514":
+		column := pqErr.Column
+		if column == "" {
+			// Postgres names unnamed check constraints <table>_<column>_check
+			column = strings.TrimSuffix(strings.TrimPrefix(pqErr.Constraint, pqErr.Table+"_"), "_check")
+			if column == pqErr.Constraint {
+				column = ""
+			}
+		}
+		return &ConstraintViolation{Constraint: pqErr.Constraint, Column: column}, true
+	}
+
+	return nil, false
+}
@@ -4,101 +4,261 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"blog-api/internal/models"
+
+	"github.com/lib/pq"
 )
 
-// CreatePost creates a new post in the database
-func (db *DB) CreatePost(ctx context.Context, req *models.PostRequest) (*models.Post, error) {
-	query := `
-		INSERT INTO posts (title, content, user_id, created_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, title, content, user_id, created_at`
+// postColumns lists the columns read for a post, with the posts table aliased
+// as p. The post's tags are gathered into an array, sorted by name.
+const postColumns = `p.id, p.title, p.content, p.content_compressed, p.content_encoding, p.content_format, p.user_id, p.view_count, p.featured, p.featured_order, p.status, p.published_at, p.publish_at, p.created_at, p.deleted_at, ` + postTagsColumn
 
-	var post models.Post
-	err := db.QueryRowContext(ctx, query, req.Title, req.Content, req.UserID, time.Now()).Scan(
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPost scans a row selected with postColumns, followed by any extra
+// columns, decompressing the content if it was stored compressed. The
+// nullable columns are featured_order, content_compressed, published_at,
+// publish_at, created_at and deleted_at; a NULL created_at leaves CreatedAt
+// zero.
+func scanPost(row rowScanner, post *models.Post, extra ...interface{}) error {
+	var compressed []byte
+	var encoding string
+	var publishedAt, publishAt, createdAt, deletedAt sql.NullTime
+	dest := []interface{}{
 		&post.ID,
 		&post.Title,
 		&post.Content,
+		&compressed,
+		&encoding,
+		&post.ContentFormat,
 		&post.UserID,
-		&post.CreatedAt,
-	)
+		&post.ViewCount,
+		&post.Featured,
+		&post.FeaturedOrder,
+		&post.Status,
+		&publishedAt,
+		&publishAt,
+		&createdAt,
+		&deletedAt,
+		pq.Array(&post.Tags),
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	post.CreatedAt = createdAt.Time
+	if publishedAt.Valid {
+		post.PublishedAt = &publishedAt.Time
+	}
+	if publishAt.Valid {
+		post.PublishAt = &publishAt.Time
+	}
+	if deletedAt.Valid {
+		post.DeletedAt = &deletedAt.Time
+	}
+
+	content, err := decodeContent(post.Content, compressed, encoding)
+	if err != nil {
+		return err
+	}
+	post.Content = content
+	return nil
+}
+
+// scanPostsWithUsername collects rows selected with postColumns followed by u.username
+func scanPostsWithUsername(rows *sql.Rows) ([]models.Post, error) {
+	var posts []models.Post
+	for rows.Next() {
+		var post models.Post
+		if err := scanPost(rows, &post, &post.Username); err != nil {
+			return nil, fmt.Errorf("failed to scan post: %w", err)
+		}
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return posts, nil
+}
+
+// CreatePost creates a new post in the database, together with its tags.
+// Posts without a content format are stored as html and posts without a
+// status as drafts, matching the column defaults. A draft with req.PublishAt
+// is scheduled.
+func (db *DB) CreatePost(ctx context.Context, req *models.PostRequest) (*models.Post, error) {
+	query := `
+		INSERT INTO posts AS p (title, content, content_compressed, content_encoding, content_format, status, published_at, publish_at, user_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING ` + postColumns
+
+	format := req.ContentFormat
+	if format == "" {
+		format = models.ContentFormatHTML
+	}
+
+	now := db.now()
+	status := req.Status
+	var publishedAt *time.Time
+	switch status {
+	case "":
+		status = models.PostStatusDraft
+	case models.PostStatusPublished:
+		publishedAt = &now
+	}
+
+	content, compressed, encoding, err := encodeContent(req.Content, db.CompressContent)
+	if err != nil {
+		return nil, err
+	}
+
+	// The post and its tags are stored together or not at all
+	var post models.Post
+	err = db.WithTx(ctx, func(tx *sql.Tx) error {
+		err := scanPost(tx.QueryRowContext(ctx, query, req.Title, content, compressed, encoding, format, status, publishedAt, req.PublishAt, req.UserID, now), &post)
+		if err != nil {
+			if isUniqueViolation(err, uniqueTitleIndex) {
+				return ErrDuplicateTitle
+			}
+			return fmt.Errorf("failed to create post: %w", classifyPGError(err))
+		}
 
+		post.Tags, err = setPostTags(ctx, tx, post.ID, req.Tags)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create post: %w", err)
+		return nil, err
 	}
 
 	return &post, nil
 }
 
-// GetAllPosts retrieves all posts from the database with user information
+// GetAllPosts retrieves all published posts from the database with user
+// information. Deleted posts and posts by banned users are left out.
 func (db *DB) GetAllPosts(ctx context.Context) ([]models.Post, error) {
+	posts, _, err := db.GetRecentPosts(ctx, models.PostListOptions{}, 0)
+	return posts, err
+}
+
+// GetRecentPosts is GetAllPosts reading the posts selected by opts, at most
+// limit of them, newest first; 0 means no limit. truncated reports whether
+// there were more posts.
+func (db *DB) GetRecentPosts(ctx context.Context, opts models.PostListOptions, limit int) (posts []models.Post, truncated bool, err error) {
+	tag := ""
+	if opts.Tag != "" {
+		tags := normalizeTags([]string{opts.Tag})
+		if len(tags) == 0 {
+			return nil, false, nil
+		}
+		tag = tags[0]
+	}
+
 	query := `
-		SELECT p.id, p.title, p.content, p.user_id, p.created_at, u.username
+		SELECT ` + postColumns + `, u.username
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
-		ORDER BY p.created_at DESC`
+		WHERE NOT u.banned
+			AND (p.deleted_at IS NULL OR $4)
+			AND (p.status = 'published' OR p.user_id = $3 OR $6)
+			AND ($5 = 0 OR p.user_id = $5)
+			AND ($2 = '' OR EXISTS (
+			SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.post_id = p.id AND t.name = $2
+		))
+		ORDER BY p.created_at DESC
+		LIMIT $1`
+
+	// LIMIT NULL reads every row; otherwise one extra row tells whether
+	// the list was cut short
+	var rowLimit interface{}
+	if limit > 0 {
+		rowLimit = limit + 1
+	}
 
-	rows, err := db.QueryContext(ctx, query)
+	rows, err := db.QueryContext(ctx, query, rowLimit, tag, opts.ViewerID, opts.IncludeDeleted, opts.AuthorID, opts.IncludeDrafts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query posts: %w", err)
+		return nil, false, fmt.Errorf("failed to query posts: %w", err)
 	}
 	defer rows.Close()
 
-	var posts []models.Post
-	for rows.Next() {
-		var post models.Post
-		err := rows.Scan(
-			&post.ID,
-			&post.Title,
-			&post.Content,
-			&post.UserID,
-			&post.CreatedAt,
-			&post.Username,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan post: %w", err)
-		}
-		posts = append(posts, post)
+	posts, err = scanPostsWithUsername(rows)
+	if err != nil {
+		return nil, false, err
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("row iteration error: %w", err)
+	if limit > 0 && len(posts) > limit {
+		return posts[:limit], true, nil
 	}
+	return posts, false, nil
+}
 
-	return posts, nil
+// GetPostsByTag is GetRecentPosts for the posts tagged with tag, as seen by
+// viewerID
+func (db *DB) GetPostsByTag(ctx context.Context, tag string, viewerID, limit int) (posts []models.Post, truncated bool, err error) {
+	return db.GetRecentPosts(ctx, models.PostListOptions{Tag: tag, ViewerID: viewerID}, limit)
 }
 
-// GetPostByID retrieves a post by its ID with user information
+// PostsLastModified returns when the list returned by GetRecentPosts last
+// changed: the newest updated_at among the posts it could show, or the last
+// time a post was removed or moved to another author if that is newer. A
+// non-zero authorID scopes it to that author's list. Drafts, deleted posts
+// and other tags are in scope too, so posts that leave a list still count.
+// View count increments don't count as a change. settled reports whether a
+// full second has passed since, by the database's clock: until then a
+// whole-second Last-Modified could hide another change in the same second.
+func (db *DB) PostsLastModified(ctx context.Context, authorID int) (changedAt time.Time, settled bool, err error) {
+	latest := `SELECT max(updated_at) FROM posts`
+	args := []interface{}{}
+	if authorID != 0 {
+		latest += ` WHERE user_id = $1`
+		args = append(args, authorID)
+	}
+
+	query := `
+		SELECT changed_at, clock_timestamp() - changed_at >= interval '1 second'
+		FROM (
+			SELECT GREATEST(s.changed_at, (` + latest + `)) AS changed_at
+			FROM posts_list_state s
+		) list`
+
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&changedAt, &settled); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get posts list state: %w", err)
+	}
+	return changedAt, settled, nil
+}
+
+// GetPostByID retrieves a post by its ID with user information. A deleted
+// post is reported as ErrPostNotFound.
 func (db *DB) GetPostByID(ctx context.Context, id int) (*models.Post, error) {
 	query := `
-		SELECT p.id, p.title, p.content, p.user_id, p.created_at, u.username
+		SELECT ` + postColumns + `, u.username
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
-		WHERE p.id = $1`
+		WHERE p.id = $1 AND p.deleted_at IS NULL`
 
 	var post models.Post
-	err := db.QueryRowContext(ctx, query, id).Scan(
-		&post.ID,
-		&post.Title,
-		&post.Content,
-		&post.UserID,
-		&post.CreatedAt,
-		&post.Username,
-	)
+	err := scanPost(db.QueryRowContext(ctx, query, id), &post, &post.Username)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("post not found")
+			return nil, ErrPostNotFound
 		}
 		return nil, fmt.Errorf("failed to get post: %w", err)
 	}
 
+	post.ViewCount += db.views.count(id)
 	return &post, nil
 }
 
-// UpdatePost updates an existing post
+// UpdatePost updates an existing post; a non-nil req.Tags replaces its tags.
+// req.UserID is ignored: posts change author only through
+// TransferPostOwnership and TransferUserPosts.
 func (db *DB) UpdatePost(ctx context.Context, id int, req *models.PostRequest) (*models.Post, error) {
 	// Start building the query dynamically based on what fields are provided
 	setParts := []string{}
@@ -112,18 +272,41 @@ func (db *DB) UpdatePost(ctx context.Context, id int, req *models.PostRequest) (
 	}
 
 	if req.Content != "" {
-		setParts = append(setParts, fmt.Sprintf("content = $%d", argIndex))
-		args = append(args, req.Content)
+		content, compressed, encoding, err := encodeContent(req.Content, db.CompressContent)
+		if err != nil {
+			return nil, err
+		}
+		setParts = append(setParts, fmt.Sprintf("content = $%d, content_compressed = $%d, content_encoding = $%d", argIndex, argIndex+1, argIndex+2))
+		args = append(args, content, compressed, encoding)
+		argIndex += 3
+	}
+
+	if req.ContentFormat != "" {
+		setParts = append(setParts, fmt.Sprintf("content_format = $%d", argIndex))
+		args = append(args, req.ContentFormat)
 		argIndex++
 	}
 
-	if req.UserID != 0 {
-		setParts = append(setParts, fmt.Sprintf("user_id = $%d", argIndex))
-		args = append(args, req.UserID)
+	// Publishing keeps the original publication time of a post that was
+	// already published; unpublishing clears it
+	if req.Status != "" {
+		setParts = append(setParts, fmt.Sprintf(
+			"status = $%d, published_at = CASE WHEN $%d = '%s' THEN COALESCE(published_at, $%d) END",
+			argIndex, argIndex, models.PostStatusPublished, argIndex+1))
+		args = append(args, req.Status, db.now())
+		argIndex += 2
+	}
+
+	// A new schedule replaces the old one, and publishing cancels it
+	if req.PublishAt != nil {
+		setParts = append(setParts, fmt.Sprintf("publish_at = $%d", argIndex))
+		args = append(args, *req.PublishAt)
 		argIndex++
+	} else if req.Status == models.PostStatusPublished {
+		setParts = append(setParts, "publish_at = NULL")
 	}
 
-	if len(setParts) == 0 {
+	if len(setParts) == 0 && req.Tags == nil {
 		return nil, fmt.Errorf("no fields to update")
 	}
 
@@ -131,40 +314,163 @@ func (db *DB) UpdatePost(ctx context.Context, id int, req *models.PostRequest) (
 	args = append(args, id)
 
 	query := fmt.Sprintf(`
-		UPDATE posts 
-		SET %s 
-		WHERE id = $%d
-		RETURNING id, title, content, user_id, created_at`,
+		UPDATE posts AS p
+		SET %s
+		WHERE id = $%d AND deleted_at IS NULL
+		RETURNING %s`,
 		joinStrings(setParts, ", "),
 		argIndex,
+		postColumns,
 	)
+	if len(setParts) == 0 {
+		// Only the tags change; lock the post as the update would
+		query = `SELECT ` + postColumns + ` FROM posts p WHERE p.id = $1 AND p.deleted_at IS NULL FOR UPDATE`
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
 	var post models.Post
-	err := db.QueryRowContext(ctx, query, args...).Scan(
-		&post.ID,
-		&post.Title,
-		&post.Content,
-		&post.UserID,
-		&post.CreatedAt,
-	)
+	err = scanPost(tx.QueryRowContext(ctx, query, args...), &post)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("post not found")
+			return nil, ErrPostNotFound
+		}
+		if isUniqueViolation(err, uniqueTitleIndex) {
+			return nil, ErrDuplicateTitle
+		}
+		return nil, fmt.Errorf("failed to update post: %w", classifyPGError(err))
+	}
+
+	if req.Tags != nil {
+		if post.Tags, err = setPostTags(ctx, tx, id, req.Tags); err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to update post: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit post update: %w", classifyPGError(err))
 	}
 
 	return &post, nil
 }
 
-// DeletePost deletes a post by its ID
+// PublishPost publishes a draft, recording when it was published and
+// dropping any schedule. A post that is already published is left alone and
+// ErrPostAlreadyPublished is returned.
+func (db *DB) PublishPost(ctx context.Context, id int) (*models.Post, error) {
+	query := `
+		UPDATE posts AS p
+		SET status = 'published', published_at = $2, publish_at = NULL
+		WHERE id = $1 AND status = 'draft' AND deleted_at IS NULL
+		RETURNING ` + postColumns
+
+	var post models.Post
+	err := scanPost(db.QueryRowContext(ctx, query, id, db.now()), &post)
+	if err == nil {
+		return &post, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to publish post: %w", classifyPGError(err))
+	}
+
+	// Nothing was updated: the post is either missing or already published
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+	if !exists {
+		return nil, ErrPostNotFound
+	}
+	return nil, ErrPostAlreadyPublished
+}
+
+// AutosaveDraft saves the title and content of a draft while it is being
+// written, leaving a nil field as it is. Content may be empty, and nothing
+// else about the draft changes. A published post is ErrPostAlreadyPublished.
+func (db *DB) AutosaveDraft(ctx context.Context, id int, title, content *string) (*models.Post, error) {
+	setParts := []string{}
+	args := []interface{}{id}
+
+	if title != nil {
+		args = append(args, *title)
+		setParts = append(setParts, fmt.Sprintf("title = $%d", len(args)))
+	}
+
+	if content != nil {
+		plain, compressed, encoding, err := encodeContent(*content, db.CompressContent)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, plain, compressed, encoding)
+		setParts = append(setParts, fmt.Sprintf("content = $%d, content_compressed = $%d, content_encoding = $%d", len(args)-2, len(args)-1, len(args)))
+	}
+
+	if len(setParts) == 0 {
+		return nil, fmt.Errorf("no fields to autosave")
+	}
+
+	query := `
+		UPDATE posts AS p
+		SET ` + joinStrings(setParts, ", ") + `
+		WHERE id = $1 AND status = 'draft' AND deleted_at IS NULL
+		RETURNING ` + postColumns
+
+	var post models.Post
+	err := scanPost(db.QueryRowContext(ctx, query, args...), &post)
+	if err == nil {
+		return &post, nil
+	}
+	if err != sql.ErrNoRows {
+		if isUniqueViolation(err, uniqueTitleIndex) {
+			return nil, ErrDuplicateTitle
+		}
+		return nil, fmt.Errorf("failed to autosave draft: %w", classifyPGError(err))
+	}
+
+	// Nothing was updated: the post is either missing or already published
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+	if !exists {
+		return nil, ErrPostNotFound
+	}
+	return nil, ErrPostAlreadyPublished
+}
+
+// PublishDuePosts publishes the scheduled drafts whose publish_at has passed,
+// recording publish_at as their publication time, and returns them with
+// their author's username. Deleted drafts wait until they are restored.
+func (db *DB) PublishDuePosts(ctx context.Context) ([]models.Post, error) {
+	query := `
+		UPDATE posts AS p
+		SET status = 'published', published_at = p.publish_at, publish_at = NULL
+		FROM users u
+		WHERE u.id = p.user_id AND p.status = 'draft' AND p.publish_at <= $1 AND p.deleted_at IS NULL
+		RETURNING ` + postColumns + `, u.username`
+
+	rows, err := db.QueryContext(ctx, query, db.now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish scheduled posts: %w", classifyPGError(err))
+	}
+	defer rows.Close()
+
+	return scanPostsWithUsername(rows)
+}
+
+// DeletePost soft-deletes a post by its ID, hiding it from every read until
+// RestorePost. Deleting a deleted post reports ErrPostNotFound.
 func (db *DB) DeletePost(ctx context.Context, id int) error {
-	query := `DELETE FROM posts WHERE id = $1`
+	query := `UPDATE posts SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL`
 
-	result, err := db.ExecContext(ctx, query, id)
+	result, err := db.ExecContext(ctx, query, id, db.now())
 	if err != nil {
-		return fmt.Errorf("failed to delete post: %w", err)
+		return fmt.Errorf("failed to delete post: %w", classifyPGError(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -173,7 +479,73 @@ func (db *DB) DeletePost(ctx context.Context, id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("post not found")
+		return ErrPostNotFound
+	}
+
+	return nil
+}
+
+// RestorePost undoes DeletePost. Restoring a post that isn't deleted leaves
+// it as it is; restoring one whose title its author has since reused is
+// ErrDuplicateTitle while titles are unique.
+func (db *DB) RestorePost(ctx context.Context, id int) (*models.Post, error) {
+	query := `
+		UPDATE posts AS p
+		SET deleted_at = NULL
+		WHERE id = $1
+		RETURNING ` + postColumns
+
+	var post models.Post
+	if err := scanPost(db.QueryRowContext(ctx, query, id), &post); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPostNotFound
+		}
+		// The author has since reused the title
+		if isUniqueViolation(err, uniqueTitleIndex) {
+			return nil, ErrDuplicateTitle
+		}
+		return nil, fmt.Errorf("failed to restore post: %w", classifyPGError(err))
+	}
+
+	return &post, nil
+}
+
+// DeletePostIf soft-deletes a post only if match accepts its current state,
+// and returns ErrPreconditionFailed otherwise. The post is locked while match
+// runs, so it can't change between the check and the delete.
+func (db *DB) DeletePostIf(ctx context.Context, id int, match func(*models.Post) bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT ` + postColumns + `, u.username
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.id = $1 AND p.deleted_at IS NULL
+		FOR UPDATE OF p`
+
+	var post models.Post
+	if err := scanPost(tx.QueryRowContext(ctx, query, id), &post, &post.Username); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPostNotFound
+		}
+		return fmt.Errorf("failed to lock post: %w", err)
+	}
+	post.ViewCount += db.views.count(id)
+
+	if !match(&post) {
+		return ErrPreconditionFailed
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE posts SET deleted_at = $2 WHERE id = $1`, id, db.now()); err != nil {
+		return fmt.Errorf("failed to delete post: %w", classifyPGError(err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit post delete: %w", classifyPGError(err))
 	}
 
 	return nil
@@ -182,10 +554,10 @@ func (db *DB) DeletePost(ctx context.Context, id int) error {
 // GetPostsByUserID retrieves all posts by a specific user
 func (db *DB) GetPostsByUserID(ctx context.Context, userID int) ([]models.Post, error) {
 	query := `
-		SELECT p.id, p.title, p.content, p.user_id, p.created_at, u.username
+		SELECT ` + postColumns + `, u.username
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
-		WHERE p.user_id = $1
+		WHERE p.user_id = $1 AND p.deleted_at IS NULL
 		ORDER BY p.created_at DESC`
 
 	rows, err := db.QueryContext(ctx, query, userID)
@@ -194,26 +566,413 @@ func (db *DB) GetPostsByUserID(ctx context.Context, userID int) ([]models.Post,
 	}
 	defer rows.Close()
 
-	var posts []models.Post
+	return scanPostsWithUsername(rows)
+}
+
+// uniqueTitlePredicate limits the unique title index to posts that aren't
+// deleted, so a deleted post's title can be reused
+const uniqueTitlePredicate = "deleted_at IS NULL"
+
+// EnsureUniqueTitleIndex creates the optional index that makes post titles
+// unique per author, ignoring case, among posts that aren't deleted. It is
+// only applied when enabled in config, since existing data may already
+// contain duplicates. An index created before deleted posts were left out is
+// replaced, which CREATE INDEX IF NOT EXISTS alone would keep.
+func (db *DB) EnsureUniqueTitleIndex(ctx context.Context) error {
+	return db.WithTx(ctx, func(tx *sql.Tx) error {
+		var definition string
+		err := tx.QueryRowContext(ctx, `SELECT indexdef FROM pg_indexes WHERE indexname = $1`, uniqueTitleIndex).Scan(&definition)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to look up unique title index: %w", err)
+		}
+		if err == nil && !strings.Contains(definition, "WHERE") {
+			if _, err := tx.ExecContext(ctx, `DROP INDEX `+uniqueTitleIndex); err != nil {
+				return fmt.Errorf("failed to drop unique title index: %w", classifyPGError(err))
+			}
+		}
+
+		query := fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS %s ON posts (user_id, lower(title)) WHERE %s`, uniqueTitleIndex, uniqueTitlePredicate)
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to create unique title index: %w", classifyPGError(err))
+		}
+		return nil
+	})
+}
+
+// IncrementPostViews increments the view counter of a post. With view
+// buffering enabled the increment is only written on the next flush.
+func (db *DB) IncrementPostViews(ctx context.Context, id int) error {
+	if db.views != nil {
+		db.views.add(id, 1)
+		return nil
+	}
+
+	query := `UPDATE posts SET view_count = view_count + 1 WHERE id = $1`
+
+	if _, err := db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to increment post views: %w", classifyPGError(err))
+	}
+
+	return nil
+}
+
+// ResetViewCounts sets the view counter of every post matching the filter to zero
+// and returns the number of posts affected
+func (db *DB) ResetViewCounts(ctx context.Context, filter models.PostFilter) (int64, error) {
+	// Write buffered views first so they are reset along with the rest
+	if err := db.FlushViews(ctx); err != nil {
+		return 0, err
+	}
+
+	conditions := []string{}
+	args := []interface{}{}
+
+	if filter.UserID != 0 {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+
+	if filter.CreatedAfter != nil {
+		args = append(args, *filter.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+
+	if filter.CreatedBefore != nil {
+		args = append(args, *filter.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	query := `UPDATE posts SET view_count = 0`
+	if len(conditions) > 0 {
+		query += " WHERE " + joinStrings(conditions, " AND ")
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset view counts: %w", classifyPGError(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+// TransferPostOwnership moves a post to another user. The target user is
+// locked for the duration of the transaction so it can't be deleted mid-move.
+// A title the target user already has is ErrDuplicateTitle while titles are
+// unique.
+func (db *DB) TransferPostOwnership(ctx context.Context, postID, newUserID int) (*models.Post, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	username, err := lockUserForShare(ctx, tx, newUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE posts AS p
+		SET user_id = $1
+		WHERE id = $2 AND deleted_at IS NULL
+		RETURNING ` + postColumns
+
+	var post models.Post
+	err = scanPost(tx.QueryRowContext(ctx, query, newUserID, postID), &post)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPostNotFound
+		}
+		if isUniqueViolation(err, uniqueTitleIndex) {
+			return nil, ErrDuplicateTitle
+		}
+		return nil, fmt.Errorf("failed to transfer post: %w", classifyPGError(err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit post transfer: %w", classifyPGError(err))
+	}
+
+	post.Username = username
+	return &post, nil
+}
+
+// TransferUserPosts moves every post of one user to another user and returns
+// the moved posts. While titles are unique, a title both users have is
+// ErrDuplicateTitle and nothing is moved.
+func (db *DB) TransferUserPosts(ctx context.Context, fromUserID, toUserID int) ([]models.Post, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	username, err := lockUserForShare(ctx, tx, toUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE posts AS p
+		SET user_id = $1
+		WHERE user_id = $2
+		RETURNING ` + postColumns
+
+	rows, err := tx.QueryContext(ctx, query, toUserID, fromUserID)
+	if err != nil {
+		if isUniqueViolation(err, uniqueTitleIndex) {
+			return nil, ErrDuplicateTitle
+		}
+		return nil, fmt.Errorf("failed to transfer posts: %w", classifyPGError(err))
+	}
+	defer rows.Close()
+
+	posts := []models.Post{}
 	for rows.Next() {
 		var post models.Post
-		err := rows.Scan(
-			&post.ID,
-			&post.Title,
-			&post.Content,
-			&post.UserID,
-			&post.CreatedAt,
-			&post.Username,
-		)
-		if err != nil {
+		if err := scanPost(rows, &post); err != nil {
 			return nil, fmt.Errorf("failed to scan post: %w", err)
 		}
+		post.Username = username
 		posts = append(posts, post)
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("row iteration error: %w", err)
+	if err := rows.Err(); err != nil {
+		if isUniqueViolation(err, uniqueTitleIndex) {
+			return nil, ErrDuplicateTitle
+		}
+		return nil, fmt.Errorf("failed to transfer posts: %w", classifyPGError(err))
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit post transfer: %w", classifyPGError(err))
 	}
 
 	return posts, nil
 }
+
+// lockUserForShare verifies a user exists and holds a share lock on the row
+// until the transaction ends, returning the username
+func lockUserForShare(ctx context.Context, tx *sql.Tx, userID int) (string, error) {
+	var username string
+	err := tx.QueryRowContext(ctx, `SELECT username FROM users WHERE id = $1 FOR SHARE`, userID).Scan(&username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrTargetUserNotFound
+		}
+		return "", fmt.Errorf("failed to lock user: %w", err)
+	}
+	return username, nil
+}
+
+// SetFeatured marks or unmarks a post as featured. Featured posts are listed
+// by ascending order first, then newest first when no order is given.
+func (db *DB) SetFeatured(ctx context.Context, id int, featured bool, order *int) (*models.Post, error) {
+	if !featured {
+		order = nil
+	}
+
+	query := `
+		UPDATE posts AS p
+		SET featured = $1, featured_order = $2
+		WHERE id = $3 AND deleted_at IS NULL
+		RETURNING ` + postColumns
+
+	var post models.Post
+	err := scanPost(db.QueryRowContext(ctx, query, featured, order, id), &post)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPostNotFound
+		}
+		return nil, fmt.Errorf("failed to set featured: %w", classifyPGError(err))
+	}
+
+	return &post, nil
+}
+
+// GetFeaturedPosts retrieves all featured published posts with user
+// information. Posts by banned users are left out.
+func (db *DB) GetFeaturedPosts(ctx context.Context) ([]models.Post, error) {
+	query := `
+		SELECT ` + postColumns + `, u.username
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.featured AND p.status = 'published' AND p.deleted_at IS NULL AND NOT u.banned
+		ORDER BY p.featured_order ASC NULLS LAST, p.created_at DESC`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query featured posts: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPostsWithUsername(rows)
+}
+
+// GetPostArchive counts posts per calendar month in loc, newest month first.
+// Drafts and posts by banned users are left out, as in GetAllPosts. filter.UserID
+// limits the count to one author; the time bounds are ignored.
+func (db *DB) GetPostArchive(ctx context.Context, filter models.PostFilter, loc *time.Location) ([]models.ArchiveMonth, error) {
+	query := `
+		SELECT EXTRACT(YEAR FROM month)::int, EXTRACT(MONTH FROM month)::int, count
+		FROM (
+			SELECT date_trunc('month', p.created_at AT TIME ZONE $1) AS month, COUNT(*) AS count
+			FROM posts p
+			JOIN users u ON p.user_id = u.id
+			WHERE p.status = 'published' AND p.deleted_at IS NULL AND NOT u.banned
+				AND ($2 = 0 OR p.user_id = $2)
+			GROUP BY month
+		) AS months
+		ORDER BY month DESC`
+
+	rows, err := db.QueryContext(ctx, query, loc.String(), filter.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query post archive: %w", err)
+	}
+	defer rows.Close()
+
+	var archive []models.ArchiveMonth
+	for rows.Next() {
+		var month models.ArchiveMonth
+		if err := rows.Scan(&month.Year, &month.Month, &month.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan archive month: %w", err)
+		}
+		archive = append(archive, month)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return archive, nil
+}
+
+// SearchPosts finds posts matching every word of query, best matches first,
+// skipping offset matches and returning at most limit. Words in the title
+// count for more than words in the content. Drafts and posts by banned users
+// are left out, as in GetAllPosts. The content of compressed posts isn't
+// indexed, so they are only found by their title.
+func (db *DB) SearchPosts(ctx context.Context, query string, limit, offset int) ([]models.Post, error) {
+	sqlQuery := `
+		SELECT ` + postColumns + `, u.username
+		FROM posts p
+		JOIN users u ON p.user_id = u.id,
+			plainto_tsquery('english', $1) AS q
+		WHERE p.search_vector @@ q AND p.status = 'published' AND p.deleted_at IS NULL AND NOT u.banned
+		ORDER BY ts_rank(p.search_vector, q) DESC, p.created_at DESC, p.id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := db.QueryContext(ctx, sqlQuery, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search posts: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPostsWithUsername(rows)
+}
+
+// GetPostSiblings returns the posts created just before and after the given
+// one, ordered by created_at and then id. Drafts and posts by banned users
+// are skipped, as in GetAllPosts. SiblingScopeAuthor only considers the same
+// author's posts.
+func (db *DB) GetPostSiblings(ctx context.Context, id int, scope models.SiblingScope) (*models.PostSiblings, error) {
+	var userID int
+	err := db.QueryRowContext(ctx, `SELECT user_id FROM posts WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPostNotFound
+		}
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+
+	authorID := 0
+	if scope == models.SiblingScopeAuthor {
+		authorID = userID
+	}
+
+	var siblings models.PostSiblings
+	if siblings.Prev, err = db.adjacentPost(ctx, id, authorID, false); err != nil {
+		return nil, err
+	}
+	if siblings.Next, err = db.adjacentPost(ctx, id, authorID, true); err != nil {
+		return nil, err
+	}
+	return &siblings, nil
+}
+
+// adjacentPost returns the closest newer or older post to the given one,
+// limited to authorID's posts unless it is 0, or nil if there is none
+func (db *DB) adjacentPost(ctx context.Context, id, authorID int, newer bool) (*models.Post, error) {
+	cmp, order := "<", "DESC"
+	if newer {
+		cmp, order = ">", "ASC"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT `+postColumns+`, u.username
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE (p.created_at, p.id) %s (SELECT created_at, id FROM posts WHERE id = $1)
+			AND p.status = 'published' AND p.deleted_at IS NULL AND NOT u.banned
+			AND ($2 = 0 OR p.user_id = $2)
+		ORDER BY p.created_at %s, p.id %s
+		LIMIT 1`, cmp, order, order)
+
+	var post models.Post
+	err := scanPost(db.QueryRowContext(ctx, query, id, authorID), &post, &post.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get sibling post: %w", err)
+	}
+	return &post, nil
+}
+
+// StreamPosts calls fn for every post in id order. Posts are fetched in
+// keyset batches of batchSize, and each batch is read in full before fn runs,
+// so neither memory use nor the time a pooled connection is held grows with
+// the size of the table.
+func (db *DB) StreamPosts(ctx context.Context, batchSize int, fn func(*models.Post) error) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+
+	query := `
+		SELECT ` + postColumns + `, u.username
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.id > $1 AND p.deleted_at IS NULL
+		ORDER BY p.id
+		LIMIT $2`
+
+	lastID := 0
+	for {
+		rows, err := db.QueryContext(ctx, query, lastID, batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to query posts: %w", err)
+		}
+		batch, err := scanPostsWithUsername(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < batchSize {
+			return nil
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
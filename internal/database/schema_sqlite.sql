@@ -0,0 +1,10 @@
+-- SQLite schema for local development and tests. Only the tables that work
+-- without Postgres are here; posts, tags and tokens need schema.sql.
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(50) UNIQUE NOT NULL COLLATE NOCASE,
+    email VARCHAR(255) UNIQUE NOT NULL COLLATE NOCASE,
+    password_hash VARCHAR(255) NOT NULL,
+    banned BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"blog-api/internal/clock"
+
+	"github.com/rs/zerolog/log"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the tables SQLite supports, if they don't exist yet
+//
+//go:embed schema_sqlite.sql
+var sqliteSchema string
+
+// newSQLite opens the SQLite database at path, or ":memory:", creating its
+// tables on first use. Only users are supported so far; everything else
+// still needs Postgres.
+func newSQLite(path string) (*DB, error) {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	db, err := sql.Open("sqlite", path+separator+"_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite takes one writer at a time, and every connection to :memory:
+	// would get a database of its own
+	db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create SQLite schema: %w", err)
+	}
+
+	log.Info().Str("path", path).Msg("Opened SQLite database")
+
+	return &DB{DB: db, Clock: clock.Real{}, driver: driverSQLite}, nil
+}
+
+// placeholderPattern matches the $N placeholders queries are written with
+var placeholderPattern = regexp.MustCompile(`\$(\d+)`)
+
+// rebind rewrites query's $N placeholders for the DB's driver. SQLite reads
+// $1 as a named parameter, so it gets the numbered ?1 form instead.
+func (db *DB) rebind(query string) string {
+	if db.driver != driverSQLite {
+		return query
+	}
+	return placeholderPattern.ReplaceAllString(query, "?${1}")
+}
+
+// forUpdate returns the clause locking selected rows until the transaction
+// ends. SQLite has none; a write transaction locks the whole database.
+func (db *DB) forUpdate() string {
+	if db.driver == driverSQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"blog-api/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// postTagsColumn selects the tags of post p as an array, sorted by name
+const postTagsColumn = `ARRAY(
+	SELECT t.name FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+	WHERE pt.post_id = p.id ORDER BY t.name)`
+
+// repeatedHyphens matches the runs of hyphens NormalizeTag collapses
+var repeatedHyphens = regexp.MustCompile(`-{2,}`)
+
+// NormalizeTag returns the canonical form of a tag, which is how it is stored
+// and matched: lowercase and trimmed, with each run of internal whitespace
+// turned into a hyphen and repeated hyphens collapsed into one. "Web  Dev"
+// and "web--dev" are both "web-dev".
+func NormalizeTag(tag string) string {
+	tag = strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+	return repeatedHyphens.ReplaceAllString(tag, "-")
+}
+
+// normalizeTags normalizes tags, dropping blanks and duplicates, and returns
+// them sorted by name as they are read back
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// SetPostTags replaces the tags of a post, creating tags that don't exist yet
+// and returning the tags as stored
+func (db *DB) SetPostTags(ctx context.Context, postID int, tags []string) ([]string, error) {
+	var stored []string
+	err := db.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := lockPostForTags(ctx, tx, postID); err != nil {
+			return err
+		}
+		var err error
+		stored, err = setPostTags(ctx, tx, postID, tags)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// AddPostTag tags a post with tag, returning its tags as stored. Adding a tag
+// the post already has changes nothing; adding one to a post that already
+// has models.MaxPostTags tags is ErrTooManyTags.
+func (db *DB) AddPostTag(ctx context.Context, postID int, tag string) ([]string, error) {
+	normalized := normalizeTags([]string{tag})
+	if len(normalized) == 0 {
+		return db.GetPostTags(ctx, postID)
+	}
+	tag = normalized[0]
+
+	var stored []string
+	err := db.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := lockPostForTags(ctx, tx, postID); err != nil {
+			return err
+		}
+
+		current, err := postTagsInTx(ctx, tx, postID)
+		if err != nil {
+			return err
+		}
+		for _, existing := range current {
+			if existing == tag {
+				stored = current
+				return nil
+			}
+		}
+		if len(current) >= models.MaxPostTags {
+			return ErrTooManyTags
+		}
+
+		stored, err = setPostTags(ctx, tx, postID, append(current, tag))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// RemovePostTag removes tag from a post, returning its tags as stored.
+// Removing a tag the post doesn't have changes nothing.
+func (db *DB) RemovePostTag(ctx context.Context, postID int, tag string) ([]string, error) {
+	normalized := normalizeTags([]string{tag})
+
+	var stored []string
+	err := db.WithTx(ctx, func(tx *sql.Tx) error {
+		if err := lockPostForTags(ctx, tx, postID); err != nil {
+			return err
+		}
+
+		if len(normalized) > 0 {
+			deleteTag := `DELETE FROM post_tags WHERE post_id = $1 AND tag_id IN (SELECT id FROM tags WHERE name = $2)`
+			if _, err := tx.ExecContext(ctx, deleteTag, postID, normalized[0]); err != nil {
+				return fmt.Errorf("failed to untag post: %w", classifyPGError(err))
+			}
+		}
+
+		var err error
+		stored, err = postTagsInTx(ctx, tx, postID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// lockPostForTags locks a post that isn't deleted against concurrent tag
+// changes for the rest of tx
+func lockPostForTags(ctx context.Context, tx *sql.Tx, postID int) error {
+	var id int
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM posts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, postID).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPostNotFound
+		}
+		return fmt.Errorf("failed to lock post: %w", err)
+	}
+	return nil
+}
+
+// postTagsInTx returns the tags of a post within tx, sorted by name
+func postTagsInTx(ctx context.Context, tx *sql.Tx, postID int) ([]string, error) {
+	tags := []string{}
+	if err := tx.QueryRowContext(ctx, `SELECT `+postTagsColumn+` FROM posts p WHERE p.id = $1`, postID).Scan(pq.Array(&tags)); err != nil {
+		return nil, fmt.Errorf("failed to query post tags: %w", err)
+	}
+	return tags, nil
+}
+
+// setPostTags replaces the tags of a post within tx. Tags the post no longer
+// has lose their join row; the tags themselves are kept.
+func setPostTags(ctx context.Context, tx *sql.Tx, postID int, tags []string) ([]string, error) {
+	tags = normalizeTags(tags)
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_tags WHERE post_id = $1`, postID); err != nil {
+		return nil, fmt.Errorf("failed to clear post tags: %w", classifyPGError(err))
+	}
+
+	if len(tags) == 0 {
+		return tags, nil
+	}
+
+	insertTags := `INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, insertTags, pq.Array(tags)); err != nil {
+		return nil, fmt.Errorf("failed to create tags: %w", classifyPGError(err))
+	}
+
+	insertPostTags := `INSERT INTO post_tags (post_id, tag_id) SELECT $1, id FROM tags WHERE name = ANY($2)`
+	if _, err := tx.ExecContext(ctx, insertPostTags, postID, pq.Array(tags)); err != nil {
+		return nil, fmt.Errorf("failed to tag post: %w", classifyPGError(err))
+	}
+
+	return tags, nil
+}
+
+// GetPostTags returns the tags of a post sorted by name, which is empty for
+// an untagged or missing post
+func (db *DB) GetPostTags(ctx context.Context, postID int) ([]string, error) {
+	query := `
+		SELECT t.name
+		FROM post_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.post_id = $1
+		ORDER BY t.name`
+
+	rows, err := db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query post tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return tags, nil
+}
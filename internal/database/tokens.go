@@ -0,0 +1,291 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"blog-api/internal/models"
+)
+
+// refreshTokenColumns lists the columns read for a refresh token
+const refreshTokenColumns = `id, user_id, family, expires_at, revoked, created_at`
+
+// scanRefreshToken scans a row selected with refreshTokenColumns, followed by
+// any extra columns
+func scanRefreshToken(row rowScanner, token *models.RefreshToken, extra ...interface{}) error {
+	var createdAt sql.NullTime
+	dest := []interface{}{
+		&token.ID,
+		&token.UserID,
+		&token.Family,
+		&token.ExpiresAt,
+		&token.Revoked,
+		&createdAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	token.CreatedAt = createdAt.Time
+	return nil
+}
+
+// hashToken returns the hash a refresh or password reset token is stored and
+// looked up by, so a leaked table doesn't hand out working tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newTokenFamily returns a random identifier for the tokens of a new login
+func newTokenFamily() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CreateRefreshToken stores the refresh token issued at a login, starting a
+// new family
+func (db *DB) CreateRefreshToken(ctx context.Context, userID int, token string, expiresAt time.Time) (*models.RefreshToken, error) {
+	family, err := newTokenFamily()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token family: %w", err)
+	}
+
+	query := `
+		INSERT INTO refresh_tokens (token_hash, user_id, family, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + refreshTokenColumns
+
+	var stored models.RefreshToken
+	err = scanRefreshToken(db.QueryRowContext(ctx, query, hashToken(token), userID, family, expiresAt, db.now()), &stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", classifyPGError(err))
+	}
+
+	return &stored, nil
+}
+
+// GetRefreshToken looks up a refresh token, whether or not it is still usable
+func (db *DB) GetRefreshToken(ctx context.Context, token string) (*models.RefreshToken, error) {
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE token_hash = $1`
+
+	var stored models.RefreshToken
+	if err := scanRefreshToken(db.QueryRowContext(ctx, query, hashToken(token)), &stored); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return &stored, nil
+}
+
+// RevokeRefreshToken revokes a refresh token together with every token
+// rotated from the same login, ending that session even if the token was
+// already replaced by a thief. Revoking a revoked token succeeds.
+func (db *DB) RevokeRefreshToken(ctx context.Context, token string) error {
+	query := `
+		UPDATE refresh_tokens SET revoked = TRUE
+		WHERE family = (SELECT family FROM refresh_tokens WHERE token_hash = $1)`
+
+	result, err := db.ExecContext(ctx, query, hashToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", classifyPGError(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrRefreshTokenNotFound
+	}
+
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every refresh token of a user, ending all
+// of their sessions
+func (db *DB) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	query := `UPDATE refresh_tokens SET revoked = TRUE WHERE user_id = $1 AND NOT revoked`
+
+	if _, err := db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", classifyPGError(err))
+	}
+
+	return nil
+}
+
+// RevokeAccessToken denylists an access token by its ID until it expires.
+// Entries for tokens that have expired since are dropped on the way.
+func (db *DB) RevokeAccessToken(ctx context.Context, id string, expiresAt time.Time) error {
+	query := `
+		WITH expired AS (
+			DELETE FROM revoked_access_tokens WHERE expires_at < $3
+		)
+		INSERT INTO revoked_access_tokens (token_id, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (token_id) DO NOTHING`
+
+	if _, err := db.ExecContext(ctx, query, id, expiresAt, db.now()); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", classifyPGError(err))
+	}
+
+	return nil
+}
+
+// IsAccessTokenRevoked reports whether an access token was denylisted by
+// RevokeAccessToken
+func (db *DB) IsAccessTokenRevoked(ctx context.Context, id string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE token_id = $1)`
+
+	var revoked bool
+	if err := db.QueryRowContext(ctx, query, id).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("failed to check access token: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// RotateRefreshToken exchanges a usable refresh token for next, which joins
+// its family and expires at expiresAt. The old token is revoked.
+//
+// A token that was already rotated or revoked means it was copied: either
+// the thief or the user is replaying it. Every token of its family is then
+// revoked, logging both out, and ErrRefreshTokenReused is returned.
+func (db *DB) RotateRefreshToken(ctx context.Context, token, next string, expiresAt time.Time) (*models.RefreshToken, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the token makes concurrent refreshes with it take turns, so
+	// only the first one succeeds
+	query := `
+		SELECT ` + refreshTokenColumns + `, (SELECT banned FROM users WHERE id = user_id)
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE`
+
+	var old models.RefreshToken
+	var banned bool
+	if err := scanRefreshToken(tx.QueryRowContext(ctx, query, hashToken(token)), &old, &banned); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to lock refresh token: %w", err)
+	}
+
+	if old.Revoked {
+		if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked = TRUE WHERE family = $1`, old.Family); err != nil {
+			return nil, fmt.Errorf("failed to revoke refresh token family: %w", classifyPGError(err))
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit refresh token family revocation: %w", classifyPGError(err))
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if !old.ExpiresAt.After(db.now()) {
+		return nil, ErrRefreshTokenExpired
+	}
+
+	if banned {
+		return nil, ErrUserBanned
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked = TRUE WHERE id = $1`, old.ID); err != nil {
+		return nil, fmt.Errorf("failed to revoke refresh token: %w", classifyPGError(err))
+	}
+
+	insert := `
+		INSERT INTO refresh_tokens (token_hash, user_id, family, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + refreshTokenColumns
+
+	var rotated models.RefreshToken
+	err = scanRefreshToken(tx.QueryRowContext(ctx, insert, hashToken(next), old.UserID, old.Family, expiresAt, db.now()), &rotated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", classifyPGError(err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit refresh token rotation: %w", classifyPGError(err))
+	}
+
+	return &rotated, nil
+}
+
+// CreatePasswordReset stores a password reset token for a user, valid until
+// expiresAt
+func (db *DB) CreatePasswordReset(ctx context.Context, userID int, token string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO password_resets (token_hash, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)`
+
+	if _, err := db.ExecContext(ctx, query, hashToken(token), userID, expiresAt, db.now()); err != nil {
+		return fmt.Errorf("failed to create password reset: %w", classifyPGError(err))
+	}
+
+	return nil
+}
+
+// ResetPassword sets the password hash of the user a reset token was issued
+// to and returns the user's ID. The token and every other outstanding reset
+// of the user are used up, and all of the user's refresh tokens are revoked,
+// so whoever knew the old password is logged out.
+func (db *DB) ResetPassword(ctx context.Context, token, hash string) (int, error) {
+	var userID int
+	err := db.WithTx(ctx, func(tx *sql.Tx) error {
+		// Locking the token makes concurrent resets with it take turns, so
+		// only the first one succeeds
+		query := `SELECT user_id, expires_at, used_at FROM password_resets WHERE token_hash = $1` + db.forUpdate()
+
+		var expiresAt time.Time
+		var usedAt sql.NullTime
+		if err := tx.QueryRowContext(ctx, db.rebind(query), hashToken(token)).Scan(&userID, &expiresAt, &usedAt); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrPasswordResetNotFound
+			}
+			return fmt.Errorf("failed to lock password reset: %w", err)
+		}
+
+		if usedAt.Valid {
+			return ErrPasswordResetUsed
+		}
+		now := db.now()
+		if !expiresAt.After(now) {
+			return ErrPasswordResetExpired
+		}
+
+		if _, err := tx.ExecContext(ctx, db.rebind(`UPDATE users SET password_hash = $1 WHERE id = $2`), hash, userID); err != nil {
+			return fmt.Errorf("failed to update password: %w", classifyPGError(err))
+		}
+
+		query = `UPDATE password_resets SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`
+		if _, err := tx.ExecContext(ctx, db.rebind(query), now, userID); err != nil {
+			return fmt.Errorf("failed to use up password resets: %w", classifyPGError(err))
+		}
+
+		query = `UPDATE refresh_tokens SET revoked = TRUE WHERE user_id = $1 AND NOT revoked`
+		if _, err := tx.ExecContext(ctx, db.rebind(query), userID); err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", classifyPGError(err))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
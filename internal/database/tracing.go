@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of database calls
+var tracer = otel.Tracer("blog-api/internal/database")
+
+// QueryContext runs a query that returns rows in a span named after its SQL
+// operation. The span ends once the query returns, before the rows are read.
+// Like the other wrappers it adapts query's placeholders to the driver.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := db.startQuerySpan(ctx, query)
+	rows, err := db.DB.QueryContext(ctx, db.rebind(query), args...)
+	endQuerySpan(span, err)
+	return rows, err
+}
+
+// QueryRowContext runs a query that returns at most one row in a span named
+// after its SQL operation
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := db.startQuerySpan(ctx, query)
+	row := db.DB.QueryRowContext(ctx, db.rebind(query), args...)
+	endQuerySpan(span, row.Err())
+	return row
+}
+
+// ExecContext runs a statement that returns no rows in a span named after its
+// SQL operation
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := db.startQuerySpan(ctx, query)
+	result, err := db.DB.ExecContext(ctx, db.rebind(query), args...)
+	endQuerySpan(span, err)
+	return result, err
+}
+
+// startQuerySpan starts a client span for query as a child of ctx's span.
+// Only the query text is recorded, never its arguments.
+func (db *DB) startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	system := "postgresql"
+	if db.driver == driverSQLite {
+		system = "sqlite"
+	}
+	operation := sqlOperation(query)
+	return tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", system),
+			attribute.String("db.operation", operation),
+			attribute.String("db.statement", strings.TrimSpace(query)),
+		),
+	)
+}
+
+// endQuerySpan ends span, marking it failed when err is a real failure.
+// sql.ErrNoRows is an ordinary outcome rather than an error.
+func endQuerySpan(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// sqlOperation returns the leading keyword of query, such as SELECT or WITH
+func sqlOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
@@ -4,36 +4,54 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"time"
 
 	"blog-api/internal/models"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// userColumns lists the columns read for a user
+const userColumns = `id, username, email, banned, created_at`
+
+// scanUser scans a row selected with userColumns, followed by any extra
+// columns. created_at is the only nullable column; NULL leaves CreatedAt zero.
+func scanUser(row rowScanner, user *models.User, extra ...interface{}) error {
+	var createdAt sql.NullTime
+	dest := []interface{}{
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.Banned,
+		&createdAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	user.CreatedAt = createdAt.Time
+	return nil
+}
+
 // CreateUser creates a new user in the database
 func (db *DB) CreateUser(ctx context.Context, req *models.UserRequest) (*models.User, error) {
 	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := HashPassword(req.Password)
 	if err != nil {
-		return nil, fmt.Errorf("failed to hash password: %w", err)
+		return nil, err
 	}
 
 	query := `
 		INSERT INTO users (username, email, password_hash, created_at)
 		VALUES ($1, $2, $3, $4)
-		RETURNING id, username, email, created_at`
+		RETURNING ` + userColumns
 
 	var user models.User
-	err = db.QueryRowContext(ctx, query, req.Username, req.Email, string(hashedPassword), time.Now()).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.CreatedAt,
-	)
+	err = scanUser(db.QueryRowContext(ctx, query, req.Username, req.Email, hashedPassword, db.now()), &user)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		if duplicate := duplicateUserError(err); duplicate != nil {
+			return nil, duplicate
+		}
+		return nil, fmt.Errorf("failed to create user: %w", classifyPGError(err))
 	}
 
 	return &user, nil
@@ -41,7 +59,7 @@ func (db *DB) CreateUser(ctx context.Context, req *models.UserRequest) (*models.
 
 // GetAllUsers retrieves all users from the database
 func (db *DB) GetAllUsers(ctx context.Context) ([]models.User, error) {
-	query := `SELECT id, username, email, created_at FROM users ORDER BY created_at DESC`
+	query := `SELECT ` + userColumns + ` FROM users ORDER BY created_at DESC`
 
 	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
@@ -52,12 +70,7 @@ func (db *DB) GetAllUsers(ctx context.Context) ([]models.User, error) {
 	var users []models.User
 	for rows.Next() {
 		var user models.User
-		err := rows.Scan(
-			&user.ID,
-			&user.Username,
-			&user.Email,
-			&user.CreatedAt,
-		)
+		err := scanUser(rows, &user)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
@@ -73,19 +86,14 @@ func (db *DB) GetAllUsers(ctx context.Context) ([]models.User, error) {
 
 // GetUserByID retrieves a user by their ID
 func (db *DB) GetUserByID(ctx context.Context, id int) (*models.User, error) {
-	query := `SELECT id, username, email, created_at FROM users WHERE id = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
 	var user models.User
-	err := db.QueryRowContext(ctx, query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.CreatedAt,
-	)
+	err := scanUser(db.QueryRowContext(ctx, query, id), &user)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -93,7 +101,60 @@ func (db *DB) GetUserByID(ctx context.Context, id int) (*models.User, error) {
 	return &user, nil
 }
 
-// UpdateUser updates an existing user
+// GetUserByEmail retrieves the user with an email address, matched ignoring case
+func (db *DB) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE lower(email) = lower($1)`
+
+	var user models.User
+	err := scanUser(db.QueryRowContext(ctx, query, email), &user)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// GetUserStats computes post aggregates for a user. Deleted posts don't
+// count, and drafts only count towards PostCount.
+func (db *DB) GetUserStats(ctx context.Context, userID int) (*models.UserStats, error) {
+	query := `
+		SELECT u.id, u.created_at, COUNT(p.id),
+			COUNT(p.id) FILTER (WHERE p.status = 'published'),
+			COALESCE(SUM(p.view_count) FILTER (WHERE p.status = 'published'), 0)
+		FROM users u
+		LEFT JOIN posts p ON p.user_id = u.id AND p.deleted_at IS NULL
+		WHERE u.id = $1
+		GROUP BY u.id`
+
+	var stats models.UserStats
+	var joinedAt sql.NullTime
+	var postCount int
+	err := db.QueryRowContext(ctx, query, userID).Scan(
+		&stats.UserID,
+		&joinedAt,
+		&postCount,
+		&stats.PublishedCount,
+		&stats.TotalViews,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user stats: %w", err)
+	}
+	stats.JoinedAt = joinedAt.Time
+	stats.PostCount = &postCount
+
+	return &stats, nil
+}
+
+// UpdateUser updates an existing user. Only username and email can change
+// here; the password is set by UpdatePassword and banned by SetUserBanned.
 func (db *DB) UpdateUser(ctx context.Context, id int, req *models.UserRequest) (*models.User, error) {
 	// Start building the query dynamically based on what fields are provided
 	setParts := []string{}
@@ -112,16 +173,6 @@ func (db *DB) UpdateUser(ctx context.Context, id int, req *models.UserRequest) (
 		argIndex++
 	}
 
-	if req.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return nil, fmt.Errorf("failed to hash password: %w", err)
-		}
-		setParts = append(setParts, fmt.Sprintf("password_hash = $%d", argIndex))
-		args = append(args, string(hashedPassword))
-		argIndex++
-	}
-
 	if len(setParts) == 0 {
 		return nil, fmt.Errorf("no fields to update")
 	}
@@ -130,39 +181,26 @@ func (db *DB) UpdateUser(ctx context.Context, id int, req *models.UserRequest) (
 	args = append(args, id)
 
 	query := fmt.Sprintf(`
-		UPDATE users 
-		SET %s 
+		UPDATE users
+		SET %s
 		WHERE id = $%d
-		RETURNING id, username, email, created_at`,
-		fmt.Sprintf("%s", setParts[0]),
+		RETURNING %s`,
+		joinStrings(setParts, ", "),
 		argIndex,
+		userColumns,
 	)
 
-	// Handle multiple SET clauses
-	if len(setParts) > 1 {
-		query = fmt.Sprintf(`
-			UPDATE users 
-			SET %s 
-			WHERE id = $%d
-			RETURNING id, username, email, created_at`,
-			fmt.Sprintf("%s", joinStrings(setParts, ", ")),
-			argIndex,
-		)
-	}
-
 	var user models.User
-	err := db.QueryRowContext(ctx, query, args...).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.CreatedAt,
-	)
+	err := scanUser(db.QueryRowContext(ctx, query, args...), &user)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
+		}
+		if duplicate := duplicateUserError(err); duplicate != nil {
+			return nil, duplicate
 		}
-		return nil, fmt.Errorf("failed to update user: %w", err)
+		return nil, fmt.Errorf("failed to update user: %w", classifyPGError(err))
 	}
 
 	return &user, nil
@@ -174,7 +212,72 @@ func (db *DB) DeleteUser(ctx context.Context, id int) error {
 
 	result, err := db.ExecContext(ctx, query, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+		return fmt.Errorf("failed to delete user: %w", classifyPGError(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// DeleteUserIf deletes a user only if match accepts its current state, and
+// returns ErrPreconditionFailed otherwise. The user is locked while match
+// runs, so it can't change between the check and the delete.
+func (db *DB) DeleteUserIf(ctx context.Context, id int, match func(*models.User) bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1` + db.forUpdate()
+
+	var user models.User
+	if err := scanUser(tx.QueryRowContext(ctx, db.rebind(query), id), &user); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	if !match(&user) {
+		return ErrPreconditionFailed
+	}
+
+	if _, err := tx.ExecContext(ctx, db.rebind(`DELETE FROM users WHERE id = $1`), id); err != nil {
+		return fmt.Errorf("failed to delete user: %w", classifyPGError(err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit user delete: %w", classifyPGError(err))
+	}
+
+	return nil
+}
+
+// HashPassword returns the bcrypt hash of password, as stored in password_hash
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// UpdatePassword replaces a user's password hash, as made by HashPassword
+func (db *DB) UpdatePassword(ctx context.Context, id int, hash string) error {
+	query := `UPDATE users SET password_hash = $1 WHERE id = $2`
+
+	result, err := db.ExecContext(ctx, query, hash, id)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", classifyPGError(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -183,28 +286,23 @@ func (db *DB) DeleteUser(ctx context.Context, id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not found")
+		return ErrUserNotFound
 	}
 
 	return nil
 }
 
-// VerifyPassword verifies a user's password
+// VerifyPassword verifies a user's password. The username is matched
+// ignoring case.
 func (db *DB) VerifyPassword(ctx context.Context, username, password string) (*models.User, error) {
-	query := `SELECT id, username, email, password_hash, created_at FROM users WHERE username = $1`
+	query := `SELECT ` + userColumns + `, password_hash FROM users WHERE lower(username) = lower($1)`
 
 	var user models.User
-	err := db.QueryRowContext(ctx, query, username).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.CreatedAt,
-	)
+	err := scanUser(db.QueryRowContext(ctx, query, username), &user, &user.PasswordHash)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -212,7 +310,12 @@ func (db *DB) VerifyPassword(ctx context.Context, username, password string) (*m
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
 	if err != nil {
-		return nil, fmt.Errorf("invalid password")
+		return nil, ErrInvalidPassword
+	}
+
+	// Only report the ban once the password checked out, so it can't be probed
+	if user.Banned {
+		return nil, ErrUserBanned
 	}
 
 	// Clear password hash before returning
@@ -220,6 +323,22 @@ func (db *DB) VerifyPassword(ctx context.Context, username, password string) (*m
 	return &user, nil
 }
 
+// SetUserBanned bans or unbans a user
+func (db *DB) SetUserBanned(ctx context.Context, id int, banned bool) (*models.User, error) {
+	query := `UPDATE users SET banned = $1 WHERE id = $2 RETURNING ` + userColumns
+
+	var user models.User
+	err := scanUser(db.QueryRowContext(ctx, query, banned, id), &user)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to update ban status: %w", classifyPGError(err))
+	}
+
+	return &user, nil
+}
+
 // Helper function to join strings
 func joinStrings(strs []string, sep string) string {
 	if len(strs) == 0 {
@@ -228,7 +347,7 @@ func joinStrings(strs []string, sep string) string {
 	if len(strs) == 1 {
 		return strs[0]
 	}
-	
+
 	result := strs[0]
 	for i := 1; i < len(strs); i++ {
 		result += sep + strs[i]
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// viewBuffer collects post view increments in memory so a popular post
+// doesn't cost a row update per view. A background loop writes them out
+// periodically, and Close writes whatever is left.
+type viewBuffer struct {
+	mu      sync.Mutex
+	pending map[int]int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newViewBuffer() *viewBuffer {
+	return &viewBuffer{
+		pending: make(map[int]int),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// add records views of a post
+func (b *viewBuffer) add(id, views int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending[id] += views
+}
+
+// count returns the views of a post not yet written to the database
+func (b *viewBuffer) count(id int) int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.pending[id]
+}
+
+// take empties the buffer and returns what it held
+func (b *viewBuffer) take() map[int]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	pending := b.pending
+	b.pending = make(map[int]int)
+	return pending
+}
+
+// startViewFlusher buffers view increments and writes them every interval
+// until Close
+func (db *DB) startViewFlusher(interval time.Duration) {
+	db.views = newViewBuffer()
+
+	go func() {
+		defer close(db.views.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				db.flushViewsWithTimeout()
+			case <-db.views.stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopViewFlusher stops the background loop and writes the remaining views
+func (db *DB) stopViewFlusher() {
+	if db.views == nil {
+		return
+	}
+	close(db.views.stop)
+	<-db.views.done
+	db.flushViewsWithTimeout()
+}
+
+// flushViewsWithTimeout flushes buffered views, logging rather than
+// returning a failure since nobody is waiting on it
+func (db *DB) flushViewsWithTimeout() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := db.FlushViews(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to flush buffered post views")
+	}
+}
+
+// FlushViews writes buffered view increments to the database in a single
+// UPDATE. Increments that fail to write are kept for the next flush.
+// Without buffering it does nothing.
+func (db *DB) FlushViews(ctx context.Context) error {
+	if db.views == nil {
+		return nil
+	}
+
+	pending := db.views.take()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(pending))
+	views := make([]int64, 0, len(pending))
+	for id, n := range pending {
+		ids = append(ids, int64(id))
+		views = append(views, int64(n))
+	}
+
+	query := `
+		UPDATE posts AS p
+		SET view_count = p.view_count + v.views
+		FROM unnest($1::int[], $2::int[]) AS v(id, views)
+		WHERE p.id = v.id`
+
+	if _, err := db.ExecContext(ctx, query, pq.Array(ids), pq.Array(views)); err != nil {
+		for id, n := range pending {
+			db.views.add(id, n)
+		}
+		return fmt.Errorf("failed to flush post views: %w", classifyPGError(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package events
+
+import (
+	"sync"
+
+	"blog-api/internal/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Event is something that happened to the blog's data
+type Event interface {
+	// Name identifies the event type, e.g. "post.created"
+	Name() string
+}
+
+// PostCreated is published after a post is stored
+type PostCreated struct {
+	Post models.Post
+}
+
+// PostUpdated is published after a post is changed
+type PostUpdated struct {
+	Post models.Post
+}
+
+// PostDeleted is published after a post is removed
+type PostDeleted struct {
+	PostID int
+}
+
+// UserCreated is published after a user registers
+type UserCreated struct {
+	User models.User
+}
+
+// UserDeleted is published after a user and their posts are removed
+type UserDeleted struct {
+	UserID int
+}
+
+func (PostCreated) Name() string { return "post.created" }
+func (PostUpdated) Name() string { return "post.updated" }
+func (PostDeleted) Name() string { return "post.deleted" }
+func (UserCreated) Name() string { return "user.created" }
+func (UserDeleted) Name() string { return "user.deleted" }
+
+// Handler reacts to a published event
+type Handler func(Event)
+
+// Bus delivers published events to every subscriber. A nil *Bus is valid and
+// drops all events, so callers never need to check whether one is configured.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+	sync     bool
+	wg       sync.WaitGroup
+}
+
+// NewBus creates a bus that runs each subscriber in its own goroutine, so a
+// slow side effect never holds up the request that triggered it
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// NewSyncBus creates a bus that runs subscribers before Publish returns,
+// which keeps tests deterministic
+func NewSyncBus() *Bus {
+	return &Bus{sync: true}
+}
+
+// Subscribe registers h to receive every event published after this call
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish sends event to all subscribers
+func (b *Bus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		if b.sync {
+			dispatch(h, event)
+			continue
+		}
+		b.wg.Add(1)
+		go func(h Handler) {
+			defer b.wg.Done()
+			dispatch(h, event)
+		}(h)
+	}
+}
+
+// Wait blocks until all asynchronously dispatched events have been handled.
+// Call it during shutdown so pending side effects are not lost.
+func (b *Bus) Wait() {
+	if b == nil {
+		return
+	}
+	b.wg.Wait()
+}
+
+// dispatch runs a single subscriber, keeping a panicking subscriber from
+// taking down the process or other subscribers
+func dispatch(h Handler, event Event) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error().Interface("panic", err).Str("event", event.Name()).Msg("Event subscriber panicked")
+		}
+	}()
+	h(event)
+}
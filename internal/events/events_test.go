@@ -0,0 +1,66 @@
+package events
+
+import (
+	"sync"
+	"testing"
+
+	"blog-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncBusDeliversEvent(t *testing.T) {
+	bus := NewSyncBus()
+
+	var received []Event
+	bus.Subscribe(func(e Event) {
+		received = append(received, e)
+	})
+
+	bus.Publish(PostCreated{Post: models.Post{ID: 7, Title: "Hello"}})
+
+	require.Len(t, received, 1)
+	created, ok := received[0].(PostCreated)
+	require.True(t, ok)
+	assert.Equal(t, 7, created.Post.ID)
+	assert.Equal(t, "post.created", created.Name())
+}
+
+func TestAsyncBusDeliversToAllSubscribers(t *testing.T) {
+	bus := NewBus()
+
+	var mu sync.Mutex
+	var names []string
+	for i := 0; i < 3; i++ {
+		bus.Subscribe(func(e Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			names = append(names, e.Name())
+		})
+	}
+
+	bus.Publish(UserDeleted{UserID: 1})
+	bus.Wait()
+
+	assert.Equal(t, []string{"user.deleted", "user.deleted", "user.deleted"}, names)
+}
+
+func TestBusRecoversFromPanickingSubscriber(t *testing.T) {
+	bus := NewSyncBus()
+
+	delivered := false
+	bus.Subscribe(func(Event) { panic("boom") })
+	bus.Subscribe(func(Event) { delivered = true })
+
+	assert.NotPanics(t, func() { bus.Publish(PostDeleted{PostID: 1}) })
+	assert.True(t, delivered)
+}
+
+func TestNilBusDropsEvents(t *testing.T) {
+	var bus *Bus
+	assert.NotPanics(t, func() {
+		bus.Publish(PostDeleted{PostID: 1})
+		bus.Wait()
+	})
+}
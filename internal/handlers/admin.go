@@ -0,0 +1,464 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"blog-api/internal/database"
+	"blog-api/internal/events"
+	"blog-api/internal/models"
+)
+
+const (
+	// adminSessionCookie holds the signed session the admin page is used with
+	adminSessionCookie = "admin_session"
+
+	// adminSessionTTL is how long an admin page login lasts
+	adminSessionTTL = 8 * time.Hour
+)
+
+// adminSessionMAC signs a session expiry with the admin token, so changing
+// ADMIN_TOKEN ends every session
+func adminSessionMAC(adminToken, expires string) string {
+	mac := hmac.New(sha256.New, []byte(adminToken))
+	mac.Write([]byte("admin-session:" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newAdminSession returns a session cookie value that is valid until expires
+func newAdminSession(adminToken string, expires time.Time) string {
+	unix := strconv.FormatInt(expires.Unix(), 10)
+	return unix + "." + adminSessionMAC(adminToken, unix)
+}
+
+// validAdminSession reports whether value is an unexpired session signed
+// with adminToken. Sessions are never valid without an admin token.
+func validAdminSession(adminToken, value string, now time.Time) bool {
+	if adminToken == "" {
+		return false
+	}
+	unix, mac, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(mac), []byte(adminSessionMAC(adminToken, unix))) {
+		return false
+	}
+	expires, err := strconv.ParseInt(unix, 10, 64)
+	return err == nil && now.Before(time.Unix(expires, 0))
+}
+
+// hasAdminSession reports whether r carries a valid admin session cookie
+func (h *WebHandler) hasAdminSession(r *http.Request) bool {
+	cookie, err := r.Cookie(adminSessionCookie)
+	return err == nil && validAdminSession(h.cfg.AdminToken, cookie.Value, h.clock.Now())
+}
+
+// setAdminSession sets the session cookie; a zero expires clears it
+func (h *WebHandler) setAdminSession(w http.ResponseWriter, r *http.Request, value string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     adminSessionCookie,
+		Value:    value,
+		Path:     "/admin",
+		HttpOnly: true,
+		Secure:   requestScheme(r, h.cfg) == "https",
+		SameSite: http.SameSiteStrictMode,
+	}
+	if expires.IsZero() {
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = expires
+	}
+	http.SetCookie(w, cookie)
+}
+
+// AdminSession guards the admin page, answering requests without a valid
+// session with a 401 page linking to the login form
+func (h *WebHandler) AdminSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.hasAdminSession(r) {
+			h.renderAdmin(w, r, http.StatusUnauthorized, "message", adminMessageData{
+				Message:   "Please log in to use the admin page.",
+				LoginLink: true,
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminPostsData is passed to the admin post list template
+type adminPostsData struct {
+	Posts     []models.Post
+	Truncated bool
+	CSRFToken string
+}
+
+// adminLoginData is passed to the admin login template
+type adminLoginData struct {
+	Error     string
+	CSRFToken string
+}
+
+// adminEditData is passed to the admin post edit template
+type adminEditData struct {
+	Post      *models.Post
+	Errors    []string
+	CSRFToken string
+}
+
+// adminMessageData is passed to the admin message template
+type adminMessageData struct {
+	Message   string
+	LoginLink bool
+}
+
+// AdminLoginPage handles GET /admin/login
+func (h *WebHandler) AdminLoginPage(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.AdminToken == "" {
+		h.renderAdmin(w, r, http.StatusForbidden, "message", adminMessageData{Message: "The admin page is disabled."})
+		return
+	}
+	if h.hasAdminSession(r) {
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+		return
+	}
+	h.renderAdmin(w, r, http.StatusOK, "login", adminLoginData{CSRFToken: csrfTokenFromContext(r)})
+}
+
+// AdminLogin handles POST /admin/login, starting a session for a caller who
+// knows the admin token
+func (h *WebHandler) AdminLogin(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.AdminToken == "" {
+		h.renderAdmin(w, r, http.StatusForbidden, "message", adminMessageData{Message: "The admin page is disabled."})
+		return
+	}
+
+	token := h.formValue(w, r, "token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.AdminToken)) != 1 {
+		loggerFromContext(r).Warn().Msg("Failed admin page login")
+		h.renderAdmin(w, r, http.StatusUnauthorized, "login", adminLoginData{
+			Error:     "Invalid admin token",
+			CSRFToken: csrfTokenFromContext(r),
+		})
+		return
+	}
+
+	expires := h.clock.Now().Add(adminSessionTTL)
+	h.setAdminSession(w, r, newAdminSession(h.cfg.AdminToken, expires), expires)
+	loggerFromContext(r).Info().Msg("Admin page login")
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// AdminLogout handles POST /admin/logout
+func (h *WebHandler) AdminLogout(w http.ResponseWriter, r *http.Request) {
+	h.setAdminSession(w, r, "", time.Time{})
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
+// AdminPosts handles GET /admin, listing every post, drafts included
+func (h *WebHandler) AdminPosts(w http.ResponseWriter, r *http.Request) {
+	if !h.acquireRender() {
+		writeBusyPage(w)
+		return
+	}
+	defer h.releaseRender()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	posts, truncated, err := h.db.GetRecentPosts(ctx, models.PostListOptions{IncludeDrafts: true}, h.cfg.MaxListRows)
+	if err != nil {
+		h.adminDatabaseError(w, r, err, "list posts")
+		return
+	}
+
+	h.renderAdmin(w, r, http.StatusOK, "posts", adminPostsData{
+		Posts:     posts,
+		Truncated: truncated,
+		CSRFToken: csrfTokenFromContext(r),
+	})
+}
+
+// AdminEditPage handles GET /admin/posts/{id}/edit
+func (h *WebHandler) AdminEditPage(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		h.renderAdmin(w, r, http.StatusBadRequest, "message", adminMessageData{Message: "Invalid post ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	post, err := h.db.GetPostByID(ctx, id)
+	if err != nil {
+		h.adminDatabaseError(w, r, err, "get post")
+		return
+	}
+
+	h.renderAdmin(w, r, http.StatusOK, "edit", adminEditData{Post: post, CSRFToken: csrfTokenFromContext(r)})
+}
+
+// AdminEditPost handles POST /admin/posts/{id}/edit, saving a post's title
+// and content. Invalid input re-renders the form with the errors.
+func (h *WebHandler) AdminEditPost(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		h.renderAdmin(w, r, http.StatusBadRequest, "message", adminMessageData{Message: "Invalid post ID"})
+		return
+	}
+
+	req := models.PostRequest{
+		Title:   strings.TrimSpace(h.formValue(w, r, "title")),
+		Content: h.formValue(w, r, "content"),
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	existing, err := h.db.GetPostByID(ctx, id)
+	if err != nil {
+		h.adminDatabaseError(w, r, err, "get post")
+		return
+	}
+
+	// Show the form as submitted, not as stored
+	submitted := *existing
+	submitted.Title, submitted.Content = req.Title, req.Content
+	rerender := func(status int, messages ...string) {
+		h.renderAdmin(w, r, status, "edit", adminEditData{
+			Post:      &submitted,
+			Errors:    messages,
+			CSRFToken: csrfTokenFromContext(r),
+		})
+	}
+
+	var messages []string
+	if req.Title == "" {
+		messages = append(messages, "title is required")
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		messages = append(messages, "content is required")
+	}
+	var validationErrs ValidationErrors
+	if errors.As(ValidatePostUpdateRequest(&req), &validationErrs) {
+		for _, e := range validationErrs.Errors {
+			messages = append(messages, e.Message)
+		}
+	}
+	if len(messages) > 0 {
+		rerender(http.StatusBadRequest, messages...)
+		return
+	}
+
+	post, err := h.db.UpdatePost(ctx, id, &req)
+	if err != nil {
+		if errors.Is(err, database.ErrDuplicateTitle) {
+			rerender(http.StatusConflict, duplicateTitleMessage(req.Title))
+			return
+		}
+		h.adminDatabaseError(w, r, err, "update post")
+		return
+	}
+
+	loggerFromContext(r).Info().Int("post_id", post.ID).Str("title", post.Title).Msg("Post updated from the admin page")
+	h.events.Publish(events.PostUpdated{Post: *post})
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// AdminPublishPost handles POST /admin/posts/{id}/publish. Publishing a
+// post that is already published changes nothing.
+func (h *WebHandler) AdminPublishPost(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		h.renderAdmin(w, r, http.StatusBadRequest, "message", adminMessageData{Message: "Invalid post ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	post, err := h.db.PublishPost(ctx, id)
+	if err != nil && !errors.Is(err, database.ErrPostAlreadyPublished) {
+		h.adminDatabaseError(w, r, err, "publish post")
+		return
+	}
+
+	if post != nil {
+		loggerFromContext(r).Info().Int("post_id", post.ID).Msg("Post published from the admin page")
+		h.events.Publish(events.PostUpdated{Post: *post})
+	}
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// AdminDeletePost handles POST /admin/posts/{id}/delete. Deleting a post
+// that is already gone changes nothing.
+func (h *WebHandler) AdminDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		h.renderAdmin(w, r, http.StatusBadRequest, "message", adminMessageData{Message: "Invalid post ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	err = h.db.DeletePost(ctx, id)
+	if err != nil && !errors.Is(err, database.ErrPostNotFound) {
+		h.adminDatabaseError(w, r, err, "delete post")
+		return
+	}
+
+	if err == nil {
+		loggerFromContext(r).Info().Int("post_id", id).Msg("Post deleted from the admin page")
+		h.events.Publish(events.PostDeleted{PostID: id})
+	}
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// formValue reads a field of a form post, with the body capped at
+// cfg.MaxRequestBytes
+func (h *WebHandler) formValue(w http.ResponseWriter, r *http.Request, field string) string {
+	if r.PostForm == nil {
+		r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxRequestBytes)
+	}
+	return r.PostFormValue(field)
+}
+
+// adminDatabaseError is handleDatabaseError for the admin page's HTML responses
+func (h *WebHandler) adminDatabaseError(w http.ResponseWriter, r *http.Request, err error, operation string) {
+	if errors.Is(err, database.ErrPostNotFound) {
+		h.renderAdmin(w, r, http.StatusNotFound, "message", adminMessageData{Message: "Post not found"})
+		return
+	}
+	loggerFromContext(r).Error().Err(err).Str("operation", operation).Msg("Database operation failed")
+	h.renderAdmin(w, r, http.StatusInternalServerError, "message", adminMessageData{Message: "Something went wrong. Please try again."})
+}
+
+// renderAdmin renders one of adminTemplates. Admin pages are never cached.
+func (h *WebHandler) renderAdmin(w http.ResponseWriter, r *http.Request, status int, name string, data interface{}) {
+	// Render into a buffer so a failing template can't send a partial page
+	var buf bytes.Buffer
+	if err := adminTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		loggerFromContext(r).Error().Err(err).Str("template", name).Msg("Failed to execute template")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// adminTemplates are the admin page's templates. They are built in, like
+// fallbackTemplate, so the admin page works wherever the server runs from.
+var adminTemplates = template.Must(template.New("admin").Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>BlogWriter Admin</title>
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; margin: 2rem; color: #1f2937; }
+        table { border-collapse: collapse; width: 100%; }
+        th, td { text-align: left; padding: 0.5rem; border-bottom: 1px solid #e5e7eb; }
+        form.inline { display: inline; }
+        input[type=text], input[type=password], textarea { width: 100%; padding: 0.5rem; box-sizing: border-box; }
+        textarea { min-height: 16rem; }
+        .errors { color: #b91c1c; }
+        .status-draft { color: #92400e; }
+    </style>
+</head>
+<body>
+{{end}}
+
+{{define "foot"}}</body>
+</html>
+{{end}}
+
+{{define "message"}}{{template "head"}}
+    <p>{{.Message}}</p>
+    {{if .LoginLink}}<p><a href="/admin/login">Log in</a></p>{{end}}
+{{template "foot"}}{{end}}
+
+{{define "login"}}{{template "head"}}
+    <h1>Admin login</h1>
+    {{if .Error}}<p class="errors" role="alert">{{.Error}}</p>{{end}}
+    <form method="post" action="/admin/login">
+        <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+        <label for="token">Admin token</label>
+        <input type="password" id="token" name="token" autocomplete="current-password" required>
+        <button type="submit">Log in</button>
+    </form>
+{{template "foot"}}{{end}}
+
+{{define "posts"}}{{template "head"}}
+    <h1>Posts</h1>
+    <form class="inline" method="post" action="/admin/logout">
+        <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+        <button type="submit">Log out</button>
+    </form>
+    {{if .Truncated}}<p>Only the most recent {{len .Posts}} posts are listed.</p>{{end}}
+    <table>
+        <thead><tr><th>Title</th><th>Author</th><th>Status</th><th>Created</th><th></th></tr></thead>
+        <tbody>
+        {{range .Posts}}
+            <tr>
+                <td>{{.Title}}</td>
+                <td>{{.Username}}</td>
+                <td class="status-{{.Status}}">{{.Status}}</td>
+                <td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td>
+                <td>
+                    <a href="/admin/posts/{{.ID}}/edit">Edit</a>
+                    {{if eq .Status "draft"}}
+                    <form class="inline" method="post" action="/admin/posts/{{.ID}}/publish">
+                        <input type="hidden" name="csrf_token" value="{{$.CSRFToken}}">
+                        <button type="submit">Publish</button>
+                    </form>
+                    {{end}}
+                    <form class="inline" method="post" action="/admin/posts/{{.ID}}/delete">
+                        <input type="hidden" name="csrf_token" value="{{$.CSRFToken}}">
+                        <button type="submit">Delete</button>
+                    </form>
+                </td>
+            </tr>
+        {{else}}
+            <tr><td colspan="5">No posts yet.</td></tr>
+        {{end}}
+        </tbody>
+    </table>
+{{template "foot"}}{{end}}
+
+{{define "edit"}}{{template "head"}}
+    <h1>Edit post</h1>
+    <p><a href="/admin">Back to posts</a></p>
+    {{if .Errors}}
+    <ul class="errors" role="alert">
+        {{range .Errors}}<li>{{.}}</li>{{end}}
+    </ul>
+    {{end}}
+    <form method="post" action="/admin/posts/{{.Post.ID}}/edit">
+        <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+        <p>
+            <label for="title">Title</label>
+            <input type="text" id="title" name="title" value="{{.Post.Title}}" required>
+        </p>
+        <p>
+            <label for="content">Content</label>
+            <textarea id="content" name="content" required>{{.Post.Content}}</textarea>
+        </p>
+        <button type="submit">Save</button>
+    </form>
+{{template "foot"}}{{end}}
+`))
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"blog-api/internal/clock"
+	"blog-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAdminTestHandler returns a web handler without a database whose admin
+// token is "secret"
+func newAdminTestHandler(now time.Time) (*WebHandler, *clock.Fake) {
+	cfg := newTestConfig()
+	cfg.AdminToken = "secret"
+	fake := clock.NewFake(now)
+	handler := NewWebHandler(nil, nil, cfg)
+	handler.clock = fake
+	return handler, fake
+}
+
+func TestAdminSession(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handler, fake := newAdminTestHandler(now)
+	guarded := handler.AdminSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	request := func(session string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: adminSessionCookie, Value: session})
+		}
+		rec := httptest.NewRecorder()
+		guarded.ServeHTTP(rec, req)
+		return rec
+	}
+
+	valid := newAdminSession("secret", now.Add(time.Hour))
+
+	t.Run("no session", func(t *testing.T) {
+		rec := request("")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, rec.Body.String(), `href="/admin/login"`)
+	})
+
+	t.Run("valid session", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, request(valid).Code)
+	})
+
+	t.Run("tampered expiry", func(t *testing.T) {
+		_, mac, _ := strings.Cut(valid, ".")
+		later := strconv.FormatInt(now.Add(48*time.Hour).Unix(), 10)
+		assert.Equal(t, http.StatusUnauthorized, request(later+"."+mac).Code)
+		assert.Equal(t, http.StatusUnauthorized, request("garbage").Code)
+	})
+
+	t.Run("signed with another token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request(newAdminSession("other", now.Add(time.Hour))).Code)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		fake.Advance(2 * time.Hour)
+		assert.Equal(t, http.StatusUnauthorized, request(valid).Code)
+	})
+
+	t.Run("no admin token", func(t *testing.T) {
+		assert.False(t, validAdminSession("", newAdminSession("", now.Add(time.Hour)), now))
+	})
+}
+
+func TestAdminLogin(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handler, _ := newAdminTestHandler(now)
+
+	login := func(token string) *httptest.ResponseRecorder {
+		form := url.Values{"token": {token}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.AdminLogin(rec, req)
+		return rec
+	}
+
+	t.Run("wrong token", func(t *testing.T) {
+		rec := login("guess")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Invalid admin token")
+		assert.Empty(t, rec.Result().Cookies())
+	})
+
+	t.Run("admin token", func(t *testing.T) {
+		rec := login("secret")
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+		assert.Equal(t, "/admin", rec.Header().Get("Location"))
+
+		cookies := rec.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, adminSessionCookie, cookies[0].Name)
+		assert.Equal(t, "/admin", cookies[0].Path)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+		assert.True(t, validAdminSession("secret", cookies[0].Value, now))
+		assert.False(t, validAdminSession("secret", cookies[0].Value, now.Add(adminSessionTTL)))
+	})
+
+	t.Run("disabled without an admin token", func(t *testing.T) {
+		handler.cfg.AdminToken = ""
+		assert.Equal(t, http.StatusForbidden, login("").Code)
+	})
+}
+
+func TestAdminLoginPageCSRFToken(t *testing.T) {
+	handler, _ := newAdminTestHandler(time.Now())
+	page := CSRFMiddleware(handler.cfg)(http.HandlerFunc(handler.AdminLoginPage))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/login", nil)
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "form-token"})
+	rec := httptest.NewRecorder()
+	page.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Contains(t, rec.Body.String(), `name="csrf_token" value="form-token"`)
+}
+
+func TestAdminPostsTemplate(t *testing.T) {
+	handler, _ := newAdminTestHandler(time.Now())
+	posts := []models.Post{
+		{ID: 1, Title: "<script>alert(1)</script>", Username: "alice", Status: models.PostStatusDraft},
+		{ID: 2, Title: "Published", Username: "bob", Status: models.PostStatusPublished},
+	}
+
+	rec := httptest.NewRecorder()
+	handler.renderAdmin(rec, httptest.NewRequest(http.MethodGet, "/admin", nil), http.StatusOK, "posts", adminPostsData{Posts: posts, CSRFToken: "form-token"})
+
+	body := rec.Body.String()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, body, "<script>alert(1)</script>")
+	assert.Contains(t, body, "&lt;script&gt;alert(1)&lt;/script&gt;")
+	assert.Contains(t, body, `action="/admin/posts/1/publish"`)
+	assert.NotContains(t, body, `action="/admin/posts/2/publish"`)
+	assert.Contains(t, body, `action="/admin/posts/2/delete"`)
+	assert.Contains(t, body, `value="form-token"`)
+}
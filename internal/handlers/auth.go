@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"blog-api/internal/clock"
+	"blog-api/internal/config"
+	"blog-api/internal/database"
+	"blog-api/internal/models"
+	"blog-api/internal/notify"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// tokenClaims are the claims carried by the access tokens issued on login
+type tokenClaims struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	jwt.RegisteredClaims
+}
+
+// AuthHandler handles authentication requests
+type AuthHandler struct {
+	db       *database.DB
+	cfg      *config.Config
+	clock    clock.Clock
+	notifier notify.Notifier
+}
+
+// NewAuthHandler creates a new authentication handler that emails users
+// through notifier; a nil notifier sends nothing
+func NewAuthHandler(db *database.DB, cfg *config.Config, notifier notify.Notifier) *AuthHandler {
+	if notifier == nil {
+		notifier = notify.Noop{}
+	}
+	return &AuthHandler{db: db, cfg: cfg, clock: clock.Real{}, notifier: notifier}
+}
+
+// Login handles POST /auth/login, exchanging a username and password for a
+// signed access token and a refresh token
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.JWTSecret == "" {
+		writeError(w, http.StatusForbidden, "Login is disabled")
+		return
+	}
+
+	var req models.LoginRequest
+	if err := parseJSON(r, &req, h.cfg); err != nil {
+		writeInvalidJSON(w, err)
+		return
+	}
+
+	if req.Username == "" || req.Password == "" {
+		writeError(w, http.StatusBadRequest, "Username and password are required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.db.VerifyPassword(ctx, req.Username, req.Password)
+	if err != nil {
+		switch {
+		// An unknown user and a wrong password look the same, so usernames
+		// can't be probed
+		case errors.Is(err, database.ErrUserNotFound), errors.Is(err, database.ErrInvalidPassword):
+			writeError(w, http.StatusUnauthorized, "Invalid username or password")
+		case errors.Is(err, database.ErrUserBanned):
+			writeError(w, http.StatusForbidden, "User is banned")
+		default:
+			handleDatabaseError(w, r, err, "verify password")
+		}
+		return
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		loggerFromContext(r).Error().Err(err).Msg("Failed to generate refresh token")
+		writeError(w, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+
+	refreshExpiresAt := h.refreshExpiry()
+	if _, err := h.db.CreateRefreshToken(ctx, user.ID, refreshToken, refreshExpiresAt); err != nil {
+		handleDatabaseError(w, r, err, "create refresh token")
+		return
+	}
+
+	loggerFromContext(r).Info().Int("user_id", user.ID).Str("username", user.Username).Msg("User logged in")
+
+	h.writeTokens(w, user, refreshToken, refreshExpiresAt)
+}
+
+// Refresh handles POST /auth/refresh, exchanging a refresh token for a new
+// access token and a new refresh token. The presented refresh token can't be
+// used again; presenting it anyway revokes every token rotated from the same
+// login.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.JWTSecret == "" {
+		writeError(w, http.StatusForbidden, "Login is disabled")
+		return
+	}
+
+	var req models.RefreshRequest
+	if err := parseJSON(r, &req, h.cfg); err != nil {
+		writeInvalidJSON(w, err)
+		return
+	}
+
+	if req.RefreshToken == "" {
+		writeError(w, http.StatusBadRequest, "Refresh token is required")
+		return
+	}
+
+	next, err := newRefreshToken()
+	if err != nil {
+		loggerFromContext(r).Error().Err(err).Msg("Failed to generate refresh token")
+		writeError(w, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	nextExpiresAt := h.refreshExpiry()
+	rotated, err := h.db.RotateRefreshToken(ctx, req.RefreshToken, next, nextExpiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRefreshTokenReused):
+			loggerFromContext(r).Warn().Msg("Revoked refresh token reused, revoked every token of its login")
+			writeError(w, http.StatusUnauthorized, "Invalid refresh token")
+		case errors.Is(err, database.ErrRefreshTokenNotFound), errors.Is(err, database.ErrRefreshTokenExpired):
+			writeError(w, http.StatusUnauthorized, "Invalid refresh token")
+		case errors.Is(err, database.ErrUserBanned):
+			writeError(w, http.StatusForbidden, "User is banned")
+		default:
+			handleDatabaseError(w, r, err, "rotate refresh token")
+		}
+		return
+	}
+
+	user, err := h.db.GetUserByID(ctx, rotated.UserID)
+	if err != nil {
+		handleDatabaseError(w, r, err, "get user")
+		return
+	}
+
+	h.writeTokens(w, user, next, nextExpiresAt)
+}
+
+// Logout handles POST /auth/logout, revoking the session of the refresh token
+// in the body, or with ?all=true every session of the user. Access tokens
+// stay valid until they expire unless JWTDenylist is set, in which case the
+// one the request was made with is revoked as well.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := tokenClaimsFromContext(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	query := newQueryParams(r)
+	all := query.boolean("all")
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	var req models.RefreshRequest
+	if !all {
+		if err := parseJSON(r, &req, h.cfg); err != nil {
+			writeInvalidJSON(w, err)
+			return
+		}
+		if req.RefreshToken == "" {
+			writeError(w, http.StatusBadRequest, "Refresh token is required")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if all {
+		if err := h.db.RevokeUserRefreshTokens(ctx, claims.UserID); err != nil {
+			handleDatabaseError(w, r, err, "revoke refresh tokens")
+			return
+		}
+	} else {
+		// Only the owner may end a session
+		stored, err := h.db.GetRefreshToken(ctx, req.RefreshToken)
+		if err != nil {
+			if errors.Is(err, database.ErrRefreshTokenNotFound) {
+				writeError(w, http.StatusUnauthorized, "Invalid refresh token")
+				return
+			}
+			handleDatabaseError(w, r, err, "get refresh token")
+			return
+		}
+		if stored.UserID != claims.UserID {
+			writeError(w, http.StatusUnauthorized, "Invalid refresh token")
+			return
+		}
+
+		if err := h.db.RevokeRefreshToken(ctx, req.RefreshToken); err != nil {
+			handleDatabaseError(w, r, err, "revoke refresh token")
+			return
+		}
+	}
+
+	if h.cfg.JWTDenylist {
+		if err := h.db.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			handleDatabaseError(w, r, err, "revoke access token")
+			return
+		}
+	}
+
+	loggerFromContext(r).Info().Int("user_id", claims.UserID).Bool("all", all).Msg("User logged out")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Validate handles GET /auth/validate, describing the access token the
+// request was made with. AuthMiddleware has already rejected a missing,
+// malformed, expired or revoked token with 401.
+func (h *AuthHandler) Validate(w http.ResponseWriter, r *http.Request) {
+	claims, ok := tokenClaimsFromContext(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	expiresAt := claims.ExpiresAt.Time
+	writeJSON(w, http.StatusOK, models.TokenInfo{
+		UserID:    claims.UserID,
+		Username:  claims.Username,
+		Role:      models.RoleUser,
+		ExpiresAt: expiresAt,
+		ExpiresIn: int(expiresAt.Sub(h.clock.Now()).Seconds()),
+	})
+}
+
+// passwordResetSendTimeout bounds the delivery of a password reset email,
+// which carries on after the response is sent
+const passwordResetSendTimeout = 30 * time.Second
+
+// ForgotPassword handles POST /auth/forgot-password, emailing a single-use
+// password reset token to the user registered with the email in the body.
+// The response is the same whether or not the email is registered, and the
+// email is sent after responding, so addresses can't be probed.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if err := parseJSON(r, &req, h.cfg); err != nil {
+		writeInvalidJSON(w, err)
+		return
+	}
+
+	if strings.TrimSpace(req.Email) == "" {
+		writeError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.db.GetUserByEmail(ctx, strings.TrimSpace(req.Email))
+	if err != nil && !errors.Is(err, database.ErrUserNotFound) {
+		handleDatabaseError(w, r, err, "get user")
+		return
+	}
+
+	// Banned users couldn't log in with a new password anyway
+	var data interface{}
+	if user != nil && !user.Banned {
+		token, err := newRefreshToken()
+		if err != nil {
+			loggerFromContext(r).Error().Err(err).Msg("Failed to generate password reset token")
+			writeError(w, http.StatusInternalServerError, "Failed to start password reset")
+			return
+		}
+
+		expiresAt := h.clock.Now().Add(time.Duration(h.cfg.PasswordResetExpiryMinutes) * time.Minute)
+		if err := h.db.CreatePasswordReset(ctx, user.ID, token, expiresAt); err != nil {
+			handleDatabaseError(w, r, err, "create password reset")
+			return
+		}
+
+		logger := loggerFromContext(r)
+		logger.Info().Int("user_id", user.ID).Msg("Password reset requested")
+
+		if h.exposeResetTokens() {
+			logger.Warn().Int("user_id", user.ID).Msg("DEV_MODE is on, returning the password reset token")
+			data = devResetToken{ResetToken: token}
+		}
+
+		sendCtx := context.WithoutCancel(r.Context())
+		go func() {
+			ctx, cancel := context.WithTimeout(sendCtx, passwordResetSendTimeout)
+			defer cancel()
+
+			if err := h.notifier.Send(ctx, user.Email, "Reset your password", h.passwordResetEmail(user, token)); err != nil {
+				logger.Error().Err(err).Int("user_id", user.ID).Msg("Failed to send password reset email")
+			}
+		}()
+	}
+
+	writeSuccess(w, "If the email is registered, a password reset token has been sent to it", data)
+}
+
+// devResetToken carries a password reset token in the response when
+// exposeResetTokens allows it
+type devResetToken struct {
+	ResetToken string `json:"reset_token"`
+}
+
+// exposeResetTokens reports whether password reset tokens are returned to the
+// client instead of only emailed: in DevMode, and only while no email is
+// actually sent
+func (h *AuthHandler) exposeResetTokens() bool {
+	_, noop := h.notifier.(notify.Noop)
+	return h.cfg.DevMode && noop
+}
+
+// passwordResetEmail returns the body of the email carrying a password reset token
+func (h *AuthHandler) passwordResetEmail(user *models.User, token string) string {
+	return fmt.Sprintf(`Hello %s,
+
+someone asked to reset the password of your account. To choose a new password,
+send this token to POST /api/auth/reset-password within %d minutes:
+
+%s
+
+If it wasn't you, ignore this email; your password stays the same.
+`, user.Username, h.cfg.PasswordResetExpiryMinutes, token)
+}
+
+// ResetPassword handles POST /auth/reset-password, setting a new password with
+// a token from ForgotPassword. The token can't be used again, and every
+// session of the user is logged out.
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.PasswordResetRequest
+	if err := parseJSON(r, &req, h.cfg); err != nil {
+		writeInvalidJSON(w, err)
+		return
+	}
+
+	if err := ValidatePasswordResetRequest(&req); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	hash, err := database.HashPassword(req.NewPassword)
+	if err != nil {
+		loggerFromContext(r).Error().Err(err).Msg("Failed to hash password")
+		writeError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, err := h.db.ResetPassword(ctx, req.Token, hash)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrPasswordResetNotFound),
+			errors.Is(err, database.ErrPasswordResetExpired),
+			errors.Is(err, database.ErrPasswordResetUsed):
+			writeError(w, http.StatusBadRequest, "Invalid or expired password reset token")
+		default:
+			handleDatabaseError(w, r, err, "reset password")
+		}
+		return
+	}
+
+	loggerFromContext(r).Info().Int("user_id", userID).Msg("User password reset")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// refreshExpiry returns when a refresh token issued now expires
+func (h *AuthHandler) refreshExpiry() time.Time {
+	return h.clock.Now().Add(time.Duration(h.cfg.RefreshTokenExpiryHours) * time.Hour).Truncate(time.Second)
+}
+
+// writeTokens signs an access token for user and sends it with refreshToken
+func (h *AuthHandler) writeTokens(w http.ResponseWriter, user *models.User, refreshToken string, refreshExpiresAt time.Time) {
+	token, expiresAt, err := issueToken(h.cfg, user, h.clock.Now())
+	if err != nil {
+		log.Error().Err(err).Int("user_id", user.ID).Msg("Failed to sign access token")
+		writeError(w, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.TokenResponse{
+		Token:            token,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+	})
+}
+
+// newRefreshToken returns a random opaque refresh token
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// issueToken signs an access token for user that expires
+// cfg.JWTExpiryMinutes after now
+func issueToken(cfg *config.Config, user *models.User, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(time.Duration(cfg.JWTExpiryMinutes) * time.Minute).Truncate(time.Second)
+
+	// The ID lets logout denylist this one token
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims := tokenClaims{
+		UserID:   user.ID,
+		Username: user.Username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(id),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.JWTSecret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// parseToken verifies an access token's signature and expiry and returns its claims
+func parseToken(secret, token string) (*tokenClaims, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"blog-api/internal/clock"
+	"blog-api/internal/models"
+	"blog-api/internal/notify"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueToken(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JWTSecret = "test-secret"
+	cfg.JWTExpiryMinutes = 30
+
+	now := time.Now()
+	token, expiresAt, err := issueToken(cfg, &models.User{ID: 42, Username: "alice"}, now)
+	require.NoError(t, err)
+	assert.WithinDuration(t, now.Add(30*time.Minute), expiresAt, time.Second)
+
+	var claims tokenClaims
+	parsed, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("test-secret"), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	require.NoError(t, err)
+	assert.True(t, parsed.Valid)
+
+	assert.Equal(t, 42, claims.UserID)
+	assert.NotEmpty(t, claims.ID)
+	assert.Equal(t, "alice", claims.Username)
+	assert.Equal(t, expiresAt.Unix(), claims.ExpiresAt.Unix())
+
+	// Another secret doesn't verify it
+	_, err = jwt.ParseWithClaims(token, &tokenClaims{}, func(*jwt.Token) (interface{}, error) {
+		return []byte("other-secret"), nil
+	})
+	assert.ErrorIs(t, err, jwt.ErrSignatureInvalid)
+}
+
+func TestValidateToken(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JWTSecret = "test-secret"
+	cfg.JWTExpiryMinutes = 30
+
+	handler := NewAuthHandler(newUnreachableDB(t), cfg, nil)
+	now := time.Now()
+	handler.clock = clock.NewFake(now.Add(10 * time.Minute))
+	validate := AuthMiddleware(cfg.JWTSecret, nil)(http.HandlerFunc(handler.Validate))
+
+	valid, expiresAt, err := issueToken(cfg, &models.User{ID: 42, Username: "alice"}, now)
+	require.NoError(t, err)
+	expired, _, err := issueToken(cfg, &models.User{ID: 42, Username: "alice"}, now.Add(-time.Hour))
+	require.NoError(t, err)
+
+	send := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/validate", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		validate.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		rec := send(valid)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var info models.TokenInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+		assert.Equal(t, 42, info.UserID)
+		assert.Equal(t, "alice", info.Username)
+		assert.Equal(t, models.RoleUser, info.Role)
+		assert.True(t, expiresAt.Equal(info.ExpiresAt))
+		assert.InDelta(t, 20*60, info.ExpiresIn, 1)
+	})
+
+	for name, token := range map[string]string{
+		"expired":   expired,
+		"malformed": "not-a-token",
+		"tampered":  valid[:len(valid)-4] + "AAAA",
+		"missing":   "",
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, http.StatusUnauthorized, send(token).Code)
+		})
+	}
+}
+
+func TestLoginRejectedBeforeDatabase(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		want   int
+	}{
+		{name: "login disabled", secret: "", body: `{"username":"alice","password":"secret"}`, want: http.StatusForbidden},
+		{name: "invalid JSON", secret: "test-secret", body: `{"username":`, want: http.StatusBadRequest},
+		{name: "missing password", secret: "test-secret", body: `{"username":"alice"}`, want: http.StatusBadRequest},
+		{name: "missing username", secret: "test-secret", body: `{"password":"secret"}`, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.JWTSecret = tt.secret
+			handler := NewAuthHandler(newUnreachableDB(t), cfg, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.Login(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestRefreshRejectedBeforeDatabase(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		want   int
+	}{
+		{name: "login disabled", secret: "", body: `{"refresh_token":"abc"}`, want: http.StatusForbidden},
+		{name: "invalid JSON", secret: "test-secret", body: `{"refresh_token":`, want: http.StatusBadRequest},
+		{name: "missing token", secret: "test-secret", body: `{}`, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.JWTSecret = tt.secret
+			handler := NewAuthHandler(newUnreachableDB(t), cfg, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/refresh", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.Refresh(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestNewRefreshToken(t *testing.T) {
+	a, err := newRefreshToken()
+	require.NoError(t, err)
+	b, err := newRefreshToken()
+	require.NoError(t, err)
+
+	assert.Len(t, a, 43)
+	assert.NotEqual(t, a, b)
+}
+
+func TestLogoutRejectedBeforeDatabase(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JWTSecret = "test-secret"
+	token, _, err := issueToken(cfg, &models.User{ID: 7, Username: "alice"}, time.Now())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		target        string
+		body          string
+		authorization string
+		want          int
+	}{
+		{name: "not logged in", target: "/api/auth/logout", body: `{"refresh_token":"abc"}`, want: http.StatusUnauthorized},
+		{name: "invalid all", target: "/api/auth/logout?all=maybe", authorization: "Bearer " + token, want: http.StatusBadRequest},
+		{name: "missing refresh token", target: "/api/auth/logout", body: `{}`, authorization: "Bearer " + token, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAuthHandler(newUnreachableDB(t), cfg, nil)
+
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			AuthMiddleware(cfg.JWTSecret, nil)(http.HandlerFunc(handler.Logout)).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestExposeResetTokens(t *testing.T) {
+	smtp := notify.NewSMTP("smtp.example.com", 587, "", "", "blog@example.com")
+	tests := []struct {
+		name     string
+		devMode  bool
+		notifier notify.Notifier
+		want     bool
+	}{
+		{name: "production", devMode: false, notifier: nil, want: false},
+		{name: "production with SMTP", devMode: false, notifier: smtp, want: false},
+		{name: "dev mode without SMTP", devMode: true, notifier: nil, want: true},
+		{name: "dev mode with SMTP", devMode: true, notifier: smtp, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.DevMode = tt.devMode
+			handler := NewAuthHandler(nil, cfg, tt.notifier)
+
+			assert.Equal(t, tt.want, handler.exposeResetTokens())
+		})
+	}
+}
+
+func TestPasswordResetRejectedBeforeDatabase(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(*AuthHandler) http.HandlerFunc
+		body    string
+		want    int
+	}{
+		{name: "forgot invalid JSON", handler: func(h *AuthHandler) http.HandlerFunc { return h.ForgotPassword }, body: `{"email":`, want: http.StatusBadRequest},
+		{name: "forgot missing email", handler: func(h *AuthHandler) http.HandlerFunc { return h.ForgotPassword }, body: `{"email":"  "}`, want: http.StatusBadRequest},
+		{name: "reset invalid JSON", handler: func(h *AuthHandler) http.HandlerFunc { return h.ResetPassword }, body: `{"token":`, want: http.StatusBadRequest},
+		{name: "reset missing token", handler: func(h *AuthHandler) http.HandlerFunc { return h.ResetPassword }, body: `{"new_password":"new-secret"}`, want: http.StatusBadRequest},
+		{name: "reset short password", handler: func(h *AuthHandler) http.HandlerFunc { return h.ResetPassword }, body: `{"token":"abc","new_password":"123"}`, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAuthHandler(newUnreachableDB(t), newTestConfig(), nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			tt.handler(handler)(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
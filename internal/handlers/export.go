@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"blog-api/internal/models"
+)
+
+// exportFlushInterval is how many rows are written between flushes
+const exportFlushInterval = 100
+
+// exportSummary is the last line of an export stream. Complete is false when
+// the export was cut short, since the status code has already been sent.
+type exportSummary struct {
+	Summary struct {
+		Count    int    `json:"count"`
+		Complete bool   `json:"complete"`
+		Error    string `json:"error,omitempty"`
+	} `json:"summary"`
+}
+
+// ExportPosts handles GET /api/admin/export?format=ndjson
+func (h *PostHandler) ExportPosts(w http.ResponseWriter, r *http.Request) {
+	query := newQueryParams(r)
+	if format := r.URL.Query().Get("format"); format != "" && format != "ndjson" {
+		query.add("format", "unsupported export format "+strconv.Quote(format)+" (supported: ndjson)")
+	}
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	// Exports outlive the server's write timeout, so lift it for this response
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		loggerFromContext(r).Warn().Err(err).Msg("Failed to clear write deadline for export")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Hour)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="posts.ndjson"`)
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	var summary exportSummary
+
+	err := h.db.StreamPosts(ctx, h.cfg.ExportBatchSize, func(post *models.Post) error {
+		if err := encoder.Encode(post); err != nil {
+			return err
+		}
+		summary.Summary.Count++
+		if summary.Summary.Count%exportFlushInterval == 0 {
+			rc.Flush()
+		}
+		return nil
+	})
+
+	if err != nil {
+		if isClientCanceled(r, err) {
+			loggerFromContext(r).Debug().Err(err).Int("count", summary.Summary.Count).Msg("Export canceled by client")
+			return
+		}
+		loggerFromContext(r).Error().Err(err).Int("count", summary.Summary.Count).Msg("Export interrupted")
+		summary.Summary.Error = "export interrupted"
+	} else {
+		summary.Summary.Complete = true
+		loggerFromContext(r).Info().Int("count", summary.Summary.Count).Msg("Posts exported")
+	}
+
+	encoder.Encode(summary)
+	rc.Flush()
+}
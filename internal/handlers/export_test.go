@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportPostsUnsupportedFormat(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/export?format=csv", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ExportPosts(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestExportPostsInterruptedTrailer(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/export?format=ndjson", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ExportPosts(rec, req)
+
+	// The status is sent before the first row, so a failure shows up in the trailer
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+	scanner := bufio.NewScanner(rec.Body)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, lines, 1)
+
+	var trailer exportSummary
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &trailer))
+	assert.False(t, trailer.Summary.Complete)
+	assert.Equal(t, 0, trailer.Summary.Count)
+	assert.NotEmpty(t, trailer.Summary.Error)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// postFields and userFields list the JSON fields a client may request with ?fields=
+var (
+	postFields = map[string]bool{
+		"id": true, "title": true, "content": true, "content_format": true,
+		"user_id": true, "view_count": true, "featured": true,
+		"featured_order": true, "status": true, "published_at": true,
+		"publish_at": true, "created_at": true, "deleted_at": true, "tags": true,
+		"username": true,
+	}
+	userFields = map[string]bool{
+		"id": true, "username": true, "email": true, "banned": true, "created_at": true,
+	}
+)
+
+// parseFields reads the optional ?fields= sparse-fieldset parameter. It returns
+// nil when the parameter is absent, meaning every field is returned. The id
+// field is always included so clients can still tell resources apart.
+func parseFields(r *http.Request, allowed map[string]bool, limit int) ([]string, error) {
+	raw := r.URL.Query().Get("fields")
+	if raw == "" {
+		return nil, nil
+	}
+
+	names := strings.Split(raw, ",")
+	if limit > 0 && len(names) > limit {
+		return nil, fmt.Errorf("too many fields requested: at most %d allowed", limit)
+	}
+
+	fields := []string{"id"}
+	seen := map[string]bool{"id": true}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if !allowed[name] {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		seen[name] = true
+		fields = append(fields, name)
+	}
+
+	return fields, nil
+}
+
+// writeFields writes data as JSON, keeping only the given fields of each
+// object. A nil fields slice writes data unchanged.
+func writeFields(w http.ResponseWriter, status int, data interface{}, fields []string) {
+	if fields == nil {
+		writeJSON(w, status, data)
+		return
+	}
+
+	projected, err := selectFields(data, fields)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+
+	writeJSON(w, status, projected)
+}
+
+// writeList writes the items of a list endpoint, projected onto fields. An
+// empty list is sent as [] rather than null, or as 204 No Content when
+// emptyNoContent is set.
+func writeList(w http.ResponseWriter, items interface{}, count int, fields []string, emptyNoContent bool) {
+	if count == 0 {
+		if emptyNoContent {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, []struct{}{})
+		return
+	}
+
+	writeFields(w, http.StatusOK, items, fields)
+}
+
+// selectFields projects an object, or a slice of objects, onto the given fields
+func selectFields(data interface{}, fields []string) (interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encoded) > 0 && encoded[0] == '[' {
+		var items []map[string]json.RawMessage
+		if err := json.Unmarshal(encoded, &items); err != nil {
+			return nil, err
+		}
+		for i := range items {
+			items[i] = pickFields(items[i], fields)
+		}
+		return items, nil
+	}
+
+	var item map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &item); err != nil {
+		return nil, err
+	}
+	return pickFields(item, fields), nil
+}
+
+// pickFields keeps only the named keys of item. Fields omitted from the
+// original encoding stay omitted.
+func pickFields(item map[string]json.RawMessage, fields []string) map[string]json.RawMessage {
+	picked := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := item[field]; ok {
+			picked[field] = value
+		}
+	}
+	return picked
+}
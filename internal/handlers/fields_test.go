@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"blog-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFields(t *testing.T) {
+	t.Run("absent", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+		fields, err := parseFields(req, postFields, 10)
+		require.NoError(t, err)
+		assert.Nil(t, fields)
+	})
+
+	t.Run("valid subset always includes id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/posts?fields=title,%20username,title", nil)
+		fields, err := parseFields(req, postFields, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"id", "title", "username"}, fields)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/users?fields=username,password_hash", nil)
+		_, err := parseFields(req, userFields, 10)
+		assert.ErrorContains(t, err, "password_hash")
+	})
+
+	t.Run("too many fields", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/posts?fields=title,content,username", nil)
+		_, err := parseFields(req, postFields, 2)
+		assert.ErrorContains(t, err, "at most 2")
+	})
+}
+
+func TestWriteFieldsProjectsSlice(t *testing.T) {
+	posts := []models.Post{
+		{ID: 1, Title: "First", Content: "Long body", Username: "alice", CreatedAt: time.Now()},
+		{ID: 2, Title: "Second", Content: "Long body", Username: "bob", CreatedAt: time.Now()},
+	}
+
+	rec := httptest.NewRecorder()
+	writeFields(rec, http.StatusOK, posts, []string{"id", "title", "username"})
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Len(t, got, 2)
+	assert.Equal(t, map[string]interface{}{"id": 1.0, "title": "First", "username": "alice"}, got[0])
+	assert.Equal(t, map[string]interface{}{"id": 2.0, "title": "Second", "username": "bob"}, got[1])
+}
+
+func TestGetAllPostsUnknownField(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/posts?fields=title,secret", nil)
+	rec := httptest.NewRecorder()
+
+	handler.GetAllPosts(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "secret")
+}
+
+func TestWriteListEmpty(t *testing.T) {
+	var posts []models.Post
+
+	t.Run("empty array by default", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		writeList(rec, posts, len(posts), nil, false)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[]`, rec.Body.String())
+	})
+
+	t.Run("no content when configured", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		writeList(rec, posts, len(posts), []string{"id"}, true)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+}
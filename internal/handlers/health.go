@@ -3,35 +3,120 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"blog-api/internal/clock"
 	"blog-api/internal/database"
+
+	"github.com/rs/zerolog/log"
 )
 
+// healthPingTimeout bounds one database ping of the health monitor
+const healthPingTimeout = 2 * time.Second
+
+// Pinger checks that a dependency such as the database is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthMonitor pings the database in the background and remembers the
+// result, so health checks answer instantly even when the database is slow.
+// database/sql reconnects on its own, so the pings also bring the pool back
+// once the database is reachable again.
+type HealthMonitor struct {
+	pinger   Pinger
+	interval time.Duration
+	clock    clock.Clock
+
+	mu        sync.RWMutex
+	checked   bool
+	healthy   bool
+	checkedAt time.Time
+}
+
+// NewHealthMonitor creates a monitor pinging pinger every interval once Run
+// is started
+func NewHealthMonitor(pinger Pinger, interval time.Duration) *HealthMonitor {
+	return &HealthMonitor{pinger: pinger, interval: interval, clock: clock.Real{}}
+}
+
+// Run checks the database every interval until ctx is done. Call Check first
+// so the status is known before the first tick.
+func (m *HealthMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.Check(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check pings the database once, records the result and logs when the
+// database goes down or comes back
+func (m *HealthMonitor) Check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+	err := m.pinger.Ping(ctx)
+	cancel()
+
+	m.mu.Lock()
+	wasChecked, wasHealthy := m.checked, m.healthy
+	m.checked = true
+	m.healthy = err == nil
+	m.checkedAt = m.clock.Now()
+	m.mu.Unlock()
+
+	switch {
+	case err != nil && (wasHealthy || !wasChecked):
+		log.Error().Err(err).Msg("Database is unreachable")
+	case err == nil && wasChecked && !wasHealthy:
+		log.Info().Msg("Database is reachable again")
+	}
+}
+
+// Status returns whether the last check succeeded and when it ran. Before the
+// first check the database is reported unhealthy.
+func (m *HealthMonitor) Status() (healthy bool, checkedAt time.Time) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.healthy, m.checkedAt
+}
+
 // HealthHandler handles health check requests
 type HealthHandler struct {
-	db *database.DB
+	db      *database.DB
+	monitor *HealthMonitor
+	clock   clock.Clock
+
+	// ready is set once startup completes and cleared on shutdown
+	ready atomic.Bool
 }
 
-// NewHealthHandler creates a new health handler
-func NewHealthHandler(db *database.DB) *HealthHandler {
-	return &HealthHandler{db: db}
+// NewHealthHandler creates a new health handler reporting the database
+// status last seen by monitor
+func NewHealthHandler(db *database.DB, monitor *HealthMonitor) *HealthHandler {
+	return &HealthHandler{db: db, monitor: monitor, clock: clock.Real{}}
 }
 
 // HealthCheck handles GET /health
 func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
-
 	// Check database connection
-	if err := h.db.Ping(ctx); err != nil {
+	healthy, checkedAt := h.monitor.Status()
+	if !healthy {
 		writeError(w, http.StatusServiceUnavailable, "Database connection failed")
 		return
 	}
 
 	response := map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC(),
+		"status":     "healthy",
+		"timestamp":  h.clock.Now().UTC(),
+		"checked_at": checkedAt.UTC(),
 		"services": map[string]string{
 			"database": "healthy",
 		},
@@ -39,3 +124,52 @@ func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, response)
 }
+
+// SetReady marks the server ready to take traffic after startup, or not ready
+// when it starts shutting down
+func (h *HealthHandler) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// Live handles GET /health/live. It always succeeds: answering at all shows
+// the process is up.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":    "alive",
+		"timestamp": h.clock.Now().UTC(),
+	})
+}
+
+// Ready handles GET /health/ready. It succeeds only between startup and
+// shutdown while the database is reachable, so load balancers stop sending
+// traffic to a server that is starting, draining or cut off.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	if !h.ready.Load() {
+		writeError(w, http.StatusServiceUnavailable, "Server is not ready")
+		return
+	}
+	if healthy, _ := h.monitor.Status(); !healthy {
+		writeError(w, http.StatusServiceUnavailable, "Database connection failed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":    "ready",
+		"timestamp": h.clock.Now().UTC(),
+	})
+}
+
+// DatabaseStatus handles GET /api/admin/db, reporting table size estimates
+// and connection pool statistics
+func (h *HealthHandler) DatabaseStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	status, err := h.db.Status(ctx)
+	if err != nil {
+		handleDatabaseError(w, r, err, "get database status")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"blog-api/internal/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePinger fails with err until it is changed, counting its pings
+type fakePinger struct {
+	mu    sync.Mutex
+	err   error
+	pings int
+	block chan struct{}
+}
+
+func (p *fakePinger) Ping(ctx context.Context) error {
+	p.mu.Lock()
+	p.pings++
+	err, block := p.err, p.block
+	p.mu.Unlock()
+
+	if block != nil {
+		select {
+		case <-block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+func (p *fakePinger) set(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func (p *fakePinger) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pings
+}
+
+func TestHealthMonitor(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	pinger := &fakePinger{}
+	monitor := NewHealthMonitor(pinger, time.Minute)
+	monitor.clock = clk
+	handler := NewHealthHandler(nil, monitor)
+
+	check := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.HealthCheck(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		return rec
+	}
+
+	// Nothing is known before the first check
+	healthy, checkedAt := monitor.Status()
+	assert.False(t, healthy)
+	assert.True(t, checkedAt.IsZero())
+	assert.Equal(t, http.StatusServiceUnavailable, check().Code)
+
+	monitor.Check(context.Background())
+	healthy, checkedAt = monitor.Status()
+	assert.True(t, healthy)
+	assert.Equal(t, clk.Now(), checkedAt)
+	rec := check()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"checked_at":"2024-01-01T12:00:00Z"`)
+
+	// The database goes down
+	pinger.set(errors.New("connection refused"))
+	clk.Advance(time.Minute)
+	monitor.Check(context.Background())
+	healthy, checkedAt = monitor.Status()
+	assert.False(t, healthy)
+	assert.Equal(t, clk.Now(), checkedAt)
+	assert.Equal(t, http.StatusServiceUnavailable, check().Code)
+
+	// And comes back
+	pinger.set(nil)
+	clk.Advance(time.Minute)
+	monitor.Check(context.Background())
+	healthy, _ = monitor.Status()
+	assert.True(t, healthy)
+	assert.Equal(t, http.StatusOK, check().Code)
+
+	// The handler reports the cached status without pinging
+	assert.Equal(t, 3, pinger.count())
+}
+
+func TestHealthMonitorSlowPing(t *testing.T) {
+	pinger := &fakePinger{}
+	monitor := NewHealthMonitor(pinger, time.Minute)
+	monitor.Check(context.Background())
+
+	pinger.mu.Lock()
+	pinger.block = make(chan struct{})
+	pinger.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		monitor.Check(context.Background())
+	}()
+	require.Eventually(t, func() bool { return pinger.count() == 2 }, time.Second, time.Millisecond)
+
+	// A ping in progress doesn't hold up the health check
+	rec := httptest.NewRecorder()
+	NewHealthHandler(nil, monitor).HealthCheck(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	close(pinger.block)
+	<-done
+}
+
+func TestHealthMonitorRun(t *testing.T) {
+	pinger := &fakePinger{}
+	monitor := NewHealthMonitor(pinger, 5*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		monitor.Run(ctx)
+	}()
+
+	// The database is pinged repeatedly
+	require.Eventually(t, func() bool { return pinger.count() >= 3 }, time.Second, time.Millisecond)
+	healthy, _ := monitor.Status()
+	assert.True(t, healthy)
+
+	pinger.set(errors.New("connection refused"))
+	require.Eventually(t, func() bool {
+		healthy, _ := monitor.Status()
+		return !healthy
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Run didn't return after its context was canceled")
+	}
+}
+
+func TestHealthProbes(t *testing.T) {
+	pinger := &fakePinger{}
+	monitor := NewHealthMonitor(pinger, time.Minute)
+	monitor.Check(context.Background())
+	handler := NewHealthHandler(nil, monitor)
+
+	probe := func(h http.HandlerFunc) int {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		return rec.Code
+	}
+
+	// Starting up
+	assert.Equal(t, http.StatusOK, probe(handler.Live))
+	assert.Equal(t, http.StatusServiceUnavailable, probe(handler.Ready))
+
+	// Started
+	handler.SetReady(true)
+	assert.Equal(t, http.StatusOK, probe(handler.Live))
+	assert.Equal(t, http.StatusOK, probe(handler.Ready))
+
+	// Database down
+	pinger.set(errors.New("connection refused"))
+	monitor.Check(context.Background())
+	assert.Equal(t, http.StatusOK, probe(handler.Live))
+	assert.Equal(t, http.StatusServiceUnavailable, probe(handler.Ready))
+
+	pinger.set(nil)
+	monitor.Check(context.Background())
+	assert.Equal(t, http.StatusOK, probe(handler.Ready))
+
+	// Shutting down
+	handler.SetReady(false)
+	assert.Equal(t, http.StatusOK, probe(handler.Live))
+	assert.Equal(t, http.StatusServiceUnavailable, probe(handler.Ready))
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"blog-api/internal/config"
+	"blog-api/internal/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newUnreachableDB returns a DB whose pool points at a closed port, so any
+// query that actually reaches the driver fails with a connection error
+func newUnreachableDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	sqlDB, err := sql.Open("postgres", "host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1")
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return &database.DB{DB: sqlDB}
+}
+
+// newTestConfig returns the default configuration, as loaded from a clean environment
+func newTestConfig() *config.Config {
+	return config.Load()
+}
+
+// idleDriver hands out connections that never run anything. It lets tests
+// fill a pool without a real database.
+type idleDriver struct{}
+
+func (idleDriver) Open(string) (driver.Conn, error) { return idleConn{}, nil }
+
+type idleConn struct{}
+
+func (idleConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("idle connection") }
+func (idleConn) Close() error                        { return nil }
+func (idleConn) Begin() (driver.Tx, error)           { return nil, errors.New("idle connection") }
+
+func init() {
+	sql.Register("idle", idleDriver{})
+}
+
+// newPooledDB returns a DB backed by idleDriver with at most maxOpen connections
+func newPooledDB(t *testing.T, maxOpen int) *database.DB {
+	t.Helper()
+
+	sqlDB, err := sql.Open("idle", "")
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(maxOpen)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return &database.DB{DB: sqlDB}
+}
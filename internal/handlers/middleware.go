@@ -1,19 +1,123 @@
 package handlers
 
 import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
+	"blog-api/internal/config"
+	"blog-api/internal/database"
+	"blog-api/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// LoggingMiddleware logs details of every incoming HTTP request
+// requestIDHeader carries the ID correlating a request with its log lines
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps incoming request IDs so clients can't bloat logs
+const maxRequestIDLength = 128
+
+// requestIDKey is the request context key holding the request's ID
+type requestIDKey struct{}
+
+// loggerKey is the request context key holding the logger bound to the
+// request's ID
+type loggerKey struct{}
+
+// RequestIDMiddleware gives every request an ID, taken from its X-Request-ID
+// header or generated when that is missing or unusable. The ID is echoed in
+// the response's X-Request-ID header and added as request_id to every line
+// logged through loggerFromContext.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !isValidRequestID(id) {
+			generated, err := newRequestID()
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to generate request ID")
+				next.ServeHTTP(w, r)
+				return
+			}
+			id = generated
+		}
+
+		w.Header().Set(requestIDHeader, id)
+
+		logger := log.With().Str("request_id", id).Logger()
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = context.WithValue(ctx, loggerKey{}, &logger)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// isValidRequestID reports whether a client-supplied request ID is short and
+// printable enough to be logged and echoed as is
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random version 4 UUID
+func newRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// requestIDFromContext returns the ID RequestIDMiddleware gave r, if any
+func requestIDFromContext(r *http.Request) (string, bool) {
+	id, ok := r.Context().Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// loggerFromContext returns the logger for r, which tags every line with the
+// request's ID, or the global logger outside RequestIDMiddleware
+func loggerFromContext(r *http.Request) *zerolog.Logger {
+	if logger, ok := r.Context().Value(loggerKey{}).(*zerolog.Logger); ok {
+		return logger
+	}
+	return &log.Logger
+}
+
+// LoggingMiddleware writes one access log line per request with its method,
+// path, status, response size, duration and the client's address, plus the
+// template of the route it matched so traffic can be grouped by endpoint.
+// Server errors are logged at error level so they stand out.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		// Create a response writer wrapper to capture status code
+		// Create a response writer wrapper to capture status code and size
 		wrapped := &responseWriter{
 			ResponseWriter: w,
 			statusCode:     http.StatusOK,
@@ -24,32 +128,107 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 
 		// Log the request details
 		duration := time.Since(start)
-		log.Info().
+		logger := loggerFromContext(r)
+		event := logger.Info()
+		if wrapped.statusCode >= http.StatusInternalServerError {
+			event = logger.Error()
+		}
+		event.
 			Str("method", r.Method).
-			Str("url", r.URL.String()).
-			Str("remote_addr", r.RemoteAddr).
+			Str("path", r.URL.Path).
+			Str("route", routeTemplate(r)).
+			Int("status", wrapped.statusCode).
+			Int64("bytes", wrapped.bytes).
+			Float64("duration_ms", float64(duration.Microseconds())/1000).
+			Str("remote_ip", remoteIP(r)).
 			Str("user_agent", r.UserAgent()).
-			Int("status_code", wrapped.statusCode).
-			Dur("duration", duration).
 			Msg("HTTP request")
 	})
 }
 
-// PanicRecoveryMiddleware recovers from panics and returns a 500 error
+// remoteIP returns the address of the peer that sent r, without the port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// routeTemplate returns the path template of the route r matched, such as
+// /api/posts/{id:[0-9]+}, or "unmatched" for 404s and 405s
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "unmatched"
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return "unmatched"
+	}
+	return template
+}
+
+// tracer creates the spans of HTTP requests
+var tracer = otel.Tracer("blog-api/internal/handlers")
+
+// TracingMiddleware starts a server span for every request, continuing the
+// trace of an incoming traceparent header. Spans started from the request's
+// context, such as those of database calls, become its children.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		route := routeTemplate(r)
+		ctx, span := tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		wrapped := &responseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+		next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", wrapped.statusCode))
+		if wrapped.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(wrapped.statusCode))
+		}
+	})
+}
+
+// PanicRecoveryMiddleware recovers from panics and returns a 500 error with
+// the request's ID, so it can be matched to the logs. The panic value and
+// stack are logged but never sent to the client.
 func PanicRecoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
+				// net/http uses this panic to abort a response on purpose
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+
 				// Log the panic with stack trace
-				log.Error().
+				loggerFromContext(r).Error().
 					Interface("panic", err).
 					Str("stack", string(debug.Stack())).
 					Str("method", r.Method).
 					Str("url", r.URL.String()).
 					Msg("Panic recovered")
 
-				// Return 500 Internal Server Error
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				requestID, _ := requestIDFromContext(r)
+				writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{
+					Error:     http.StatusText(http.StatusInternalServerError),
+					Message:   "An unexpected error occurred",
+					Code:      http.StatusInternalServerError,
+					RequestID: requestID,
+				})
 			}
 		}()
 
@@ -57,53 +236,660 @@ func PanicRecoveryMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// CORSMiddleware adds CORS headers
-func CORSMiddleware(next http.Handler) http.Handler {
+// corsPreflightMaxAge is how many seconds browsers may cache a preflight answer
+const corsPreflightMaxAge = "600"
+
+// CORSMiddleware lets browsers call the API from the origins in
+// cfg.CORSAllowedOrigins. Requests from other origins get no CORS headers,
+// so browsers keep their responses from the calling page, and their
+// preflight requests are left to the route like any OPTIONS request. Routes
+// without an OPTIONS handler must still send preflights through it, which
+// setupRouter does for requests no route's method matches.
+func CORSMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(cfg.CORSAllowedOrigins))
+	anyOrigin := false
+	for _, origin := range cfg.CORSAllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		allowed[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if !anyOrigin {
+				// The response depends on the origin, so caches must tell them apart
+				w.Header().Add("Vary", "Origin")
+			}
+			if origin == "" || !(anyOrigin || allowed[strings.ToLower(origin)]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.CORSAllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			// Plain OPTIONS requests reach their route
+			if isPreflight(r) {
+				w.Header().Add("Vary", "Access-Control-Request-Method, Access-Control-Request-Headers")
+				w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-CSRF-Token, X-Request-ID, If-Match, If-Modified-Since")
+				w.Header().Set("Access-Control-Max-Age", corsPreflightMaxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			w.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified, Location, Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-Request-ID, X-Result-Truncated")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isPreflight reports whether r is a CORS preflight request
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// SecurityHeadersMiddleware adds the configured security headers.
+// HSTS is only sent over HTTPS, where browsers honor it, including HTTPS
+// terminated at a trusted proxy.
+func SecurityHeadersMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	hsts := fmt.Sprintf("max-age=%d; includeSubDomains", cfg.HSTSMaxAge)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.Header().Set("X-Frame-Options", cfg.FrameOptions)
+			w.Header().Set("X-XSS-Protection", "1; mode=block")
+			w.Header().Set("Referrer-Policy", cfg.ReferrerPolicy)
+
+			if cfg.ContentSecurityPolicy != "" {
+				w.Header().Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+			}
+			if cfg.PermissionsPolicy != "" {
+				w.Header().Set("Permissions-Policy", cfg.PermissionsPolicy)
+			}
+			if cfg.HSTSMaxAge > 0 && requestScheme(r, cfg) == "https" {
+				w.Header().Set("Strict-Transport-Security", hsts)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// DecompressionMiddleware transparently inflates gzip-encoded request bodies.
+// The inflated body is capped at maxBytes so a small compressed payload can't
+// expand into an unbounded amount of memory.
+func DecompressionMiddleware(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+
+			switch encoding {
+			case "", "identity":
+				next.ServeHTTP(w, r)
+				return
+			case "gzip", "x-gzip":
+				gz, err := gzip.NewReader(r.Body)
+				if err != nil {
+					writeError(w, http.StatusBadRequest, "Malformed gzip request body")
+					return
+				}
+				defer gz.Close()
+
+				r.Body = http.MaxBytesReader(w, gz, maxBytes)
+				r.ContentLength = -1
+				r.Header.Del("Content-Encoding")
+				r.Header.Del("Content-Length")
+
+				next.ServeHTTP(w, r)
+			default:
+				writeError(w, http.StatusUnsupportedMediaType, "Unsupported Content-Encoding: "+encoding)
+			}
+		})
+	}
+}
+
+// gzipMinSize is the smallest response body GzipMiddleware compresses; below
+// it the gzip framing costs about as much as compression saves
+const gzipMinSize = 1024
+
+// GzipMiddleware compresses response bodies for clients that accept gzip.
+// Bodies under gzipMinSize, formats that are compressed already and
+// responses that carry their own Content-Encoding are sent as they are.
+func GzipMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Add("Vary", "Accept-Encoding")
 
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
 	})
 }
 
-// SecurityHeadersMiddleware adds security headers
-func SecurityHeadersMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "DENY")
-		w.Header().Set("X-XSS-Protection", "1; mode=block")
-		w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
-		
-		next.ServeHTTP(w, r)
-	})
+// acceptsGzip reports whether r's Accept-Encoding allows a gzip response
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "x-gzip" {
+			continue
+		}
+		// "gzip;q=0" refuses gzip explicitly
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code
-type responseWriter struct {
+// compressedContentTypes are media type prefixes whose bodies gain nothing
+// from another round of compression
+var compressedContentTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/gzip", "application/x-gzip", "application/zip",
+	"application/x-bzip2", "application/x-7z-compressed", "application/zstd",
+	"application/pdf",
+}
+
+// gzipResponseWriter holds back the status and the first gzipMinSize bytes
+// of the body until it knows whether the response is worth compressing
+type gzipResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	buf        []byte
+	started    bool
+	gz         *gzip.Writer
+}
+
+func (gw *gzipResponseWriter) WriteHeader(code int) {
+	// Informational responses go out immediately and may repeat
+	if code < http.StatusOK {
+		gw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if gw.started || gw.statusCode != 0 {
+		return
+	}
+	gw.statusCode = code
+
+	// Bodiless and partial responses, and ones encoded by the handler, are
+	// passed through untouched
+	if code == http.StatusNoContent || code == http.StatusNotModified ||
+		code == http.StatusPartialContent || gw.Header().Get("Content-Encoding") != "" {
+		gw.start(false)
+	}
+}
+
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+	if gw.started {
+		if gw.gz != nil {
+			return gw.gz.Write(b)
+		}
+		return gw.ResponseWriter.Write(b)
+	}
+
+	gw.buf = append(gw.buf, b...)
+	if len(gw.buf) >= gzipMinSize {
+		if err := gw.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// start sends the held-back status and body, gzip-compressed when compress
+// is set and the content type isn't compressed already
+func (gw *gzipResponseWriter) start(compress bool) error {
+	gw.started = true
+
+	header := gw.Header()
+	// Sniff the type from the plain body; net/http would see gzip bytes
+	if header.Get("Content-Type") == "" && len(gw.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(gw.buf))
+	}
+
+	if compress && header.Get("Content-Encoding") == "" && !isCompressedContentType(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		gw.gz = gzip.NewWriter(gw.ResponseWriter)
+	}
+
+	if gw.statusCode != 0 {
+		gw.ResponseWriter.WriteHeader(gw.statusCode)
+	}
+
+	buf := gw.buf
+	gw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if gw.gz != nil {
+		_, err = gw.gz.Write(buf)
+	} else {
+		_, err = gw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Close sends a response still held back as is and finishes the gzip stream
+func (gw *gzipResponseWriter) Close() error {
+	if !gw.started {
+		if err := gw.start(false); err != nil {
+			return err
+		}
+	}
+	if gw.gz != nil {
+		return gw.gz.Close()
+	}
+	return nil
+}
+
+// Flush lets streaming handlers flush through the wrapper. A body still held
+// back is compressed from then on, since more of it is probably coming.
+func (gw *gzipResponseWriter) Flush() {
+	if !gw.started {
+		if err := gw.start(true); err != nil {
+			return
+		}
+	}
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	if flusher, ok := gw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (gw *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+// isCompressedContentType reports whether contentType is a compressed format
+func isCompressedContentType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range compressedContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConcurrencyLimitMiddleware rejects requests with 503 once max requests are
+// already in flight, instead of letting them queue up. Health checks bypass
+// the limit so an overloaded instance still reports its state.
+func ConcurrencyLimitMiddleware(max int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if max <= 0 {
+			return next
+		}
+
+		slots := make(chan struct{}, max)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isHealthCheck(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			select {
+			case slots <- struct{}{}:
+				// Deferred so the slot is freed even if the handler panics
+				defer func() { <-slots }()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", "1")
+				writeError(w, http.StatusServiceUnavailable, "Server is busy, please retry shortly")
+			}
+		})
+	}
+}
+
+// PoolBackpressureMiddleware answers 503 when the database pool stays
+// exhausted for longer than timeout, rather than letting the request queue
+// for a connection until it times out with a 504
+func PoolBackpressureMiddleware(db *database.DB, timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isHealthCheck(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := db.WaitForConnection(r.Context(), timeout); errors.Is(err, database.ErrPoolExhausted) {
+				loggerFromContext(r).Warn().Str("path", r.URL.Path).Dur("timeout", timeout).Msg("Database connection pool exhausted")
+				w.Header().Set("Retry-After", "1")
+				writeError(w, http.StatusServiceUnavailable, "Server is busy, please retry shortly")
+				return
+			}
+
+			// Other errors are left for the handler's own queries to report
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isHealthCheck reports whether the request targets a health endpoint
+func isHealthCheck(r *http.Request) bool {
+	path := r.URL.Path
+	return path == "/health" || path == "/api/health" || strings.HasPrefix(path, "/health/")
+}
+
+// responseWriter wraps http.ResponseWriter to capture the status code and
+// the number of body bytes written
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	bytes       int64
+	wroteHeader bool
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
+	// Only the first final status reaches the client
+	if !rw.wroteHeader && code >= http.StatusOK {
+		rw.statusCode = code
+		rw.wroteHeader = true
+	}
 	rw.ResponseWriter.WriteHeader(code)
 }
 
 func (rw *responseWriter) Write(b []byte) (int, error) {
-	return rw.ResponseWriter.Write(b)
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the wrapper
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
 }
 
-// TimeoutMiddleware adds a timeout to requests
-func TimeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+// TimeoutMiddleware adds a timeout to requests. http.TimeoutHandler buffers the
+// whole response, so streaming endpoints under streamingPaths are exempt.
+func TimeoutMiddleware(timeout time.Duration, streamingPaths ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return http.TimeoutHandler(next, timeout, "Request timeout")
+		timeoutHandler := http.TimeoutHandler(next, timeout, "Request timeout")
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, path := range streamingPaths {
+				if strings.HasPrefix(r.URL.Path, path) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			timeoutHandler.ServeHTTP(w, r)
+		})
 	}
 }
+
+// AdminMiddleware restricts access to requests carrying the configured admin
+// bearer token. When no token is configured every request is rejected.
+func AdminMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeError(w, http.StatusForbidden, "Admin API is disabled")
+				return
+			}
+
+			if !hasAdminToken(r, token) {
+				writeError(w, http.StatusUnauthorized, "Admin authorization required")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hasAdminToken reports whether r carries the admin bearer token
+func hasAdminToken(r *http.Request, token string) bool {
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// adminKey is the request context key marking requests that
+// AuthOrAdminMiddleware let through with the admin token
+type adminKey struct{}
+
+// AuthOrAdminMiddleware accepts requests carrying either the admin token or
+// an access token, and otherwise responds as AuthMiddleware does. Handlers
+// tell the two apart with isAdmin.
+func AuthOrAdminMiddleware(adminToken, secret string, denylist TokenDenylist) func(http.Handler) http.Handler {
+	authenticated := AuthMiddleware(secret, denylist)
+	return func(next http.Handler) http.Handler {
+		user := authenticated(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if adminToken != "" && hasAdminToken(r, adminToken) {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminKey{}, true)))
+				return
+			}
+			user.ServeHTTP(w, r)
+		})
+	}
+}
+
+// OptionalAuthMiddleware is AuthOrAdminMiddleware for routes anyone may
+// call: requests without an Authorization header, or any request when no
+// secret is configured, pass through anonymously. A token that is present
+// but invalid is still rejected, so clients learn it needs refreshing.
+func OptionalAuthMiddleware(adminToken, secret string, denylist TokenDenylist) func(http.Handler) http.Handler {
+	authenticatedOrAdmin := AuthOrAdminMiddleware(adminToken, secret, denylist)
+	return func(next http.Handler) http.Handler {
+		identified := authenticatedOrAdmin(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if (secret == "" && adminToken == "") || r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			identified.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isAdmin reports whether AuthOrAdminMiddleware let r through with the admin token
+func isAdmin(r *http.Request) bool {
+	admin, _ := r.Context().Value(adminKey{}).(bool)
+	return admin
+}
+
+// tokenClaimsKey is the request context key holding the claims of the
+// access token verified by AuthMiddleware
+type tokenClaimsKey struct{}
+
+// TokenDenylist reports whether an access token was revoked before it expired
+type TokenDenylist interface {
+	IsAccessTokenRevoked(ctx context.Context, id string) (bool, error)
+}
+
+// AuthMiddleware requires an access token issued by POST /api/auth/login in
+// the Authorization header, and makes the token's user available to handlers
+// through userIDFromContext. Tokens on denylist are rejected; a nil denylist
+// accepts every token until it expires. When no secret is configured every
+// request is rejected.
+func AuthMiddleware(secret string, denylist TokenDenylist) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if secret == "" {
+				writeError(w, http.StatusForbidden, "Authentication is disabled")
+				return
+			}
+
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" {
+				writeError(w, http.StatusUnauthorized, "Authentication required")
+				return
+			}
+
+			claims, err := parseToken(secret, token)
+			if err != nil {
+				if errors.Is(err, jwt.ErrTokenExpired) {
+					writeError(w, http.StatusUnauthorized, "Token has expired")
+				} else {
+					writeError(w, http.StatusUnauthorized, "Invalid token")
+				}
+				return
+			}
+
+			if denylist != nil {
+				revoked, err := denylist.IsAccessTokenRevoked(r.Context(), claims.ID)
+				if err != nil {
+					handleDatabaseError(w, r, err, "check access token")
+					return
+				}
+				if revoked {
+					writeError(w, http.StatusUnauthorized, "Token has been revoked")
+					return
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), tokenClaimsKey{}, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// tokenClaimsFromContext returns the claims of the access token verified by
+// AuthMiddleware, if any
+func tokenClaimsFromContext(r *http.Request) (*tokenClaims, bool) {
+	claims, ok := r.Context().Value(tokenClaimsKey{}).(*tokenClaims)
+	return claims, ok
+}
+
+// userIDFromContext returns the ID of the user authenticated by
+// AuthMiddleware, if any
+func userIDFromContext(r *http.Request) (int, bool) {
+	claims, ok := tokenClaimsFromContext(r)
+	if !ok {
+		return 0, false
+	}
+	return claims.UserID, true
+}
+
+// CSRF token cookie, header and form field names used by CSRFMiddleware
+const (
+	csrfCookieName = "csrf_token"
+	csrfHeaderName = "X-CSRF-Token"
+	csrfFormField  = "csrf_token"
+)
+
+// csrfTokenKey is the request context key holding the CSRF token that
+// server-rendered forms must echo
+type csrfTokenKey struct{}
+
+// CSRFMiddleware implements double-submit-cookie CSRF protection. Every
+// response without a token cookie gets a fresh one, and unsafe requests must
+// echo the cookie's value in the X-CSRF-Token header or a csrf_token form
+// field. Requests authenticated with a bearer token are exempt, since
+// browsers never attach those automatically. The cookie is Secure when the
+// request came over HTTPS, including through a trusted proxy. Handlers
+// rendering forms read the token with csrfTokenFromContext.
+func CSRFMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var token, formToken string
+			if cookie, err := r.Cookie(csrfCookieName); err == nil && cookie.Value != "" {
+				token = cookie.Value
+				formToken = token
+			} else {
+				issued, err := newCSRFToken()
+				if err != nil {
+					loggerFromContext(r).Error().Err(err).Msg("Failed to generate CSRF token")
+					writeError(w, http.StatusInternalServerError, "Internal server error")
+					return
+				}
+				http.SetCookie(w, &http.Cookie{
+					Name:     csrfCookieName,
+					Value:    issued,
+					Path:     "/",
+					Secure:   requestScheme(r, cfg) == "https",
+					SameSite: http.SameSiteLaxMode,
+				})
+				formToken = issued
+			}
+			r = r.WithContext(context.WithValue(r.Context(), csrfTokenKey{}, formToken))
+
+			if isSafeMethod(r.Method) || strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			provided := r.Header.Get(csrfHeaderName)
+			if provided == "" && isFormRequest(r) {
+				provided = r.PostFormValue(csrfFormField)
+			}
+
+			if token == "" || provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				writeError(w, http.StatusForbidden, "Missing or invalid CSRF token")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// csrfTokenFromContext returns the CSRF token CSRFMiddleware stored for r,
+// or "" when the middleware didn't run
+func csrfTokenFromContext(r *http.Request) string {
+	token, _ := r.Context().Value(csrfTokenKey{}).(string)
+	return token
+}
+
+// newCSRFToken returns a random hex-encoded CSRF token
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// isSafeMethod reports whether method is read-only per RFC 9110
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// isFormRequest reports whether r carries an HTML form body
+func isFormRequest(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return strings.HasPrefix(contentType, "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(contentType, "multipart/form-data")
+}
@@ -0,0 +1,935 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"blog-api/internal/config"
+	"blog-api/internal/models"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// okHandler is a trivial handler used to observe whether middleware let a request through
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestAdminMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "valid token", token: "secret", authorization: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "wrong token", token: "secret", authorization: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "missing token", token: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "admin disabled", token: "", authorization: "Bearer ", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/posts/reset-views", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+
+			AdminMiddleware(tt.token)(okHandler).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JWTSecret = "secret"
+	user := &models.User{ID: 7, Username: "alice"}
+
+	valid, _, err := issueToken(cfg, user, time.Now())
+	require.NoError(t, err)
+	expired, _, err := issueToken(cfg, user, time.Now().Add(-2*time.Hour))
+	require.NoError(t, err)
+	otherCfg := *cfg
+	otherCfg.JWTSecret = "other"
+	forged, _, err := issueToken(&otherCfg, user, time.Now())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		secret        string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "valid token", secret: "secret", authorization: "Bearer " + valid, wantStatus: http.StatusOK},
+		{name: "expired token", secret: "secret", authorization: "Bearer " + expired, wantStatus: http.StatusUnauthorized},
+		{name: "wrong signature", secret: "secret", authorization: "Bearer " + forged, wantStatus: http.StatusUnauthorized},
+		{name: "malformed token", secret: "secret", authorization: "Bearer not.a.jwt", wantStatus: http.StatusUnauthorized},
+		{name: "not a bearer token", secret: "secret", authorization: "Basic " + valid, wantStatus: http.StatusUnauthorized},
+		{name: "absent token", secret: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "authentication disabled", secret: "", authorization: "Bearer " + valid, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotID int
+			var gotOK bool
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotID, gotOK = userIDFromContext(r)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+
+			AuthMiddleware(tt.secret, nil)(next).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.True(t, gotOK)
+				assert.Equal(t, 7, gotID)
+			}
+		})
+	}
+}
+
+// fakeDenylist answers denylist lookups with revoked and err
+type fakeDenylist struct {
+	revoked bool
+	err     error
+}
+
+func (d fakeDenylist) IsAccessTokenRevoked(context.Context, string) (bool, error) {
+	return d.revoked, d.err
+}
+
+func TestAuthMiddlewareDenylist(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JWTSecret = "secret"
+	token, _, err := issueToken(cfg, &models.User{ID: 7, Username: "alice"}, time.Now())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		denylist   TokenDenylist
+		wantStatus int
+	}{
+		{name: "no denylist", denylist: nil, wantStatus: http.StatusOK},
+		{name: "not revoked", denylist: fakeDenylist{}, wantStatus: http.StatusOK},
+		{name: "revoked", denylist: fakeDenylist{revoked: true}, wantStatus: http.StatusUnauthorized},
+		{name: "lookup fails", denylist: fakeDenylist{err: sql.ErrConnDone}, wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+
+			AuthMiddleware(cfg.JWTSecret, tt.denylist)(okHandler).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestAuthOrAdminMiddleware(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JWTSecret = "secret"
+	token, _, err := issueToken(cfg, &models.User{ID: 7, Username: "alice"}, time.Now())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		adminToken    string
+		authorization string
+		wantStatus    int
+		wantAdmin     bool
+	}{
+		{name: "admin token", adminToken: "admin", authorization: "Bearer admin", wantStatus: http.StatusOK, wantAdmin: true},
+		{name: "access token", adminToken: "admin", authorization: "Bearer " + token, wantStatus: http.StatusOK},
+		{name: "neither", adminToken: "admin", authorization: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "admin disabled", adminToken: "", authorization: "Bearer ", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotAdmin bool
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotAdmin = isAdmin(r)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/users/7/password", nil)
+			req.Header.Set("Authorization", tt.authorization)
+			rec := httptest.NewRecorder()
+
+			AuthOrAdminMiddleware(tt.adminToken, cfg.JWTSecret, nil)(next).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantAdmin, gotAdmin)
+		})
+	}
+}
+
+func TestOptionalAuthMiddleware(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JWTSecret = "secret"
+	token, _, err := issueToken(cfg, &models.User{ID: 7, Username: "alice"}, time.Now())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantUserID    int
+		wantAdmin     bool
+	}{
+		{name: "anonymous", wantStatus: http.StatusOK},
+		{name: "access token", authorization: "Bearer " + token, wantStatus: http.StatusOK, wantUserID: 7},
+		{name: "admin token", authorization: "Bearer admin", wantStatus: http.StatusOK, wantAdmin: true},
+		{name: "invalid token", authorization: "Bearer nope", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUserID int
+			var gotAdmin bool
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserID, _ = userIDFromContext(r)
+				gotAdmin = isAdmin(r)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+
+			OptionalAuthMiddleware("admin", cfg.JWTSecret, nil)(next).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantUserID, gotUserID)
+			assert.Equal(t, tt.wantAdmin, gotAdmin)
+		})
+	}
+}
+
+func TestUserIDFromContextUnauthenticated(t *testing.T) {
+	_, ok := userIDFromContext(httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+	assert.False(t, ok)
+}
+
+func TestDecompressionMiddleware(t *testing.T) {
+	// echoTitle decodes a post request and writes back its title
+	echoTitle := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.PostRequest
+		if err := parseJSON(r, &req, newTestConfig()); err != nil {
+			writeInvalidJSON(w, err)
+			return
+		}
+		w.Write([]byte(req.Title))
+	})
+
+	gzipBody := func(t *testing.T, data []byte) *bytes.Buffer {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, err := gz.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+		return &buf
+	}
+
+	t.Run("gzip body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", gzipBody(t, []byte(`{"title":"Compressed"}`)))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+
+		DecompressionMiddleware(1<<20)(echoTitle).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Compressed", rec.Body.String())
+	})
+
+	t.Run("plain body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(`{"title":"Plain"}`))
+		rec := httptest.NewRecorder()
+
+		DecompressionMiddleware(1<<20)(echoTitle).ServeHTTP(rec, req)
+
+		assert.Equal(t, "Plain", rec.Body.String())
+	})
+
+	t.Run("malformed gzip", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader("not gzip"))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+
+		DecompressionMiddleware(1<<20)(echoTitle).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("unsupported encoding", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader("data"))
+		req.Header.Set("Content-Encoding", "br")
+		rec := httptest.NewRecorder()
+
+		DecompressionMiddleware(1<<20)(echoTitle).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	})
+
+	t.Run("exceeds decompressed limit", func(t *testing.T) {
+		payload := `{"title":"` + strings.Repeat("a", 4096) + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", gzipBody(t, []byte(payload)))
+		req.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+
+		DecompressionMiddleware(1024)(echoTitle).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
+
+func TestGzipMiddleware(t *testing.T) {
+	large := `[` + strings.Repeat(`{"title":"A post about compression"},`, 100) + `{}]`
+
+	respond := func(contentType, body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write([]byte(body))
+		})
+	}
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		wantGzip       bool
+	}{
+		{name: "large JSON", acceptEncoding: "gzip, deflate, br", contentType: "application/json", body: large, wantGzip: true},
+		{name: "not accepted", contentType: "application/json", body: large},
+		{name: "refused with q=0", acceptEncoding: "gzip;q=0", contentType: "application/json", body: large},
+		{name: "small body", acceptEncoding: "gzip", contentType: "application/json", body: `{"ok":true}`},
+		{name: "already compressed", acceptEncoding: "gzip", contentType: "image/png", body: large},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+
+			GzipMiddleware(respond(tt.contentType, tt.body)).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+			if !tt.wantGzip {
+				assert.Empty(t, rec.Header().Get("Content-Encoding"))
+				assert.Equal(t, strconv.Itoa(len(tt.body)), rec.Header().Get("Content-Length"))
+				assert.Equal(t, tt.body, rec.Body.String())
+				return
+			}
+
+			assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+			assert.Empty(t, rec.Header().Get("Content-Length"))
+			assert.Less(t, rec.Body.Len(), len(tt.body))
+			gz, err := gzip.NewReader(rec.Body)
+			require.NoError(t, err)
+			plain, err := io.ReadAll(gz)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(plain))
+		})
+	}
+
+	t.Run("no content", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/api/posts/1", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+
+		GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Empty(t, rec.Body.Bytes())
+	})
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	newRequest := func(method, origin string, preflight bool) *http.Request {
+		req := httptest.NewRequest(method, "/api/posts", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+		}
+		return req
+	}
+	serve := func(cfg *config.Config, req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		CORSMiddleware(cfg)(next).ServeHTTP(rec, req)
+		return rec
+	}
+
+	cfg := &config.Config{CORSAllowedOrigins: []string{"https://app.example.com", "http://localhost:3000/"}}
+
+	t.Run("allowed origin", func(t *testing.T) {
+		rec := serve(cfg, newRequest(http.MethodGet, "https://app.example.com", false))
+		assert.Equal(t, http.StatusTeapot, rec.Code)
+		assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, rec.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID")
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "Origin", rec.Header().Get("Vary"))
+
+		rec = serve(cfg, newRequest(http.MethodGet, "http://localhost:3000", false))
+		assert.Equal(t, "http://localhost:3000", rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		for _, origin := range []string{"https://evil.example.com", "https://app.example.com.evil.net", "null", ""} {
+			rec := serve(cfg, newRequest(http.MethodGet, origin, false))
+			assert.Equal(t, http.StatusTeapot, rec.Code, origin)
+			for name := range rec.Header() {
+				assert.NotContains(t, name, "Access-Control-", origin)
+			}
+		}
+
+		// Nor is a preflight answered for it
+		rec := serve(cfg, newRequest(http.MethodOptions, "https://evil.example.com", true))
+		assert.Equal(t, http.StatusTeapot, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		rec := serve(cfg, newRequest(http.MethodOptions, "https://app.example.com", true))
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), http.MethodPost)
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+		assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "Content-Type")
+		assert.Equal(t, corsPreflightMaxAge, rec.Header().Get("Access-Control-Max-Age"))
+
+		// A plain OPTIONS request isn't a preflight and reaches the route
+		rec = serve(cfg, newRequest(http.MethodOptions, "https://app.example.com", false))
+		assert.Equal(t, http.StatusTeapot, rec.Code)
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		withCredentials := &config.Config{CORSAllowedOrigins: cfg.CORSAllowedOrigins, CORSAllowCredentials: true}
+		rec := serve(withCredentials, newRequest(http.MethodGet, "https://app.example.com", false))
+		assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("any origin", func(t *testing.T) {
+		rec := serve(&config.Config{CORSAllowedOrigins: []string{"*"}}, newRequest(http.MethodGet, "https://anywhere.example.com", false))
+		assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, rec.Header().Get("Vary"))
+	})
+
+	t.Run("no origins configured", func(t *testing.T) {
+		rec := serve(&config.Config{}, newRequest(http.MethodGet, "https://app.example.com", false))
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	cfg := &config.Config{
+		ContentSecurityPolicy: "default-src 'none'",
+		HSTSMaxAge:            600,
+		ReferrerPolicy:        "no-referrer",
+		PermissionsPolicy:     "geolocation=()",
+		FrameOptions:          "SAMEORIGIN",
+	}
+	handler := SecurityHeadersMiddleware(cfg)(okHandler)
+
+	t.Run("reflects config overrides", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, "default-src 'none'", rec.Header().Get("Content-Security-Policy"))
+		assert.Equal(t, "no-referrer", rec.Header().Get("Referrer-Policy"))
+		assert.Equal(t, "geolocation=()", rec.Header().Get("Permissions-Policy"))
+		assert.Equal(t, "SAMEORIGIN", rec.Header().Get("X-Frame-Options"))
+		assert.Empty(t, rec.Header().Get("Strict-Transport-Security"), "HSTS must not be sent over plain HTTP")
+	})
+
+	t.Run("HSTS over HTTPS", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, "max-age=600; includeSubDomains", rec.Header().Get("Strict-Transport-Security"))
+	})
+
+	t.Run("HSTS behind trusted TLS proxy", func(t *testing.T) {
+		proxied := *cfg
+		proxied.TrustedProxies = []string{"192.0.2.1"}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		rec := httptest.NewRecorder()
+		SecurityHeadersMiddleware(&proxied)(okHandler).ServeHTTP(rec, req)
+
+		assert.Equal(t, "max-age=600; includeSubDomains", rec.Header().Get("Strict-Transport-Security"))
+	})
+}
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	handler := ConcurrencyLimitMiddleware(2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		if r.URL.Path == "/block" {
+			entered <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// A panicking handler must still give its slot back
+	assert.Panics(t, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	})
+
+	// Saturate the limiter with two blocked requests
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/block", nil))
+		}()
+		<-entered
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	// Health checks bypass the limit
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	close(release)
+	wg.Wait()
+
+	// Capacity is available again once the blocked requests finish
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	cfg := &config.Config{TrustedProxies: []string{"10.0.0.0/8"}}
+	handler := CSRFMiddleware(cfg)(okHandler)
+	token := strings.Repeat("a", 64)
+
+	t.Run("safe request issues token cookie", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		cookies := rec.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, csrfCookieName, cookies[0].Name)
+		assert.Len(t, cookies[0].Value, 64)
+		assert.False(t, cookies[0].Secure)
+	})
+
+	t.Run("cookie is secure behind a TLS-terminating proxy", func(t *testing.T) {
+		secure := func(remoteAddr string) bool {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = remoteAddr
+			req.Header.Set("X-Forwarded-Proto", "https")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			cookies := rec.Result().Cookies()
+			require.Len(t, cookies, 1)
+			return cookies[0].Secure
+		}
+
+		assert.True(t, secure("10.0.0.5:1234"))
+		// Anyone else could claim HTTPS
+		assert.False(t, secure("203.0.113.9:1234"))
+	})
+
+	t.Run("matching header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+		req.Header.Set(csrfHeaderName, token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("matching form field", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(csrfFormField+"="+token))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/api/posts/1", nil)
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("mismatched token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/api/posts/1", nil)
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+		req.Header.Set(csrfHeaderName, strings.Repeat("b", 64))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("no cookie", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+		req.Header.Set(csrfHeaderName, token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("bearer request is exempt", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/posts/reset-views", nil)
+		req.Header.Set("Authorization", "Bearer some-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestPoolBackpressureMiddleware(t *testing.T) {
+	db := newPooledDB(t, 2)
+	handler := PoolBackpressureMiddleware(db, 20*time.Millisecond)(okHandler)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// Spare connections let requests straight through
+	assert.Equal(t, http.StatusOK, serve("/api/posts").Code)
+
+	// Two slow queries hold every connection in the pool
+	var held []*sql.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := db.Conn(context.Background())
+		require.NoError(t, err)
+		held = append(held, conn)
+	}
+
+	start := time.Now()
+	rec := serve("/api/posts")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Less(t, time.Since(start), time.Second, "busy response should not wait for the request timeout")
+
+	// Health checks bypass the check
+	assert.Equal(t, http.StatusOK, serve("/health").Code)
+
+	// A connection freed while waiting is taken instead of failing
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		held[0].Close()
+	}()
+	handler = PoolBackpressureMiddleware(db, time.Second)(okHandler)
+	assert.Equal(t, http.StatusOK, serve("/api/posts").Code)
+
+	held[1].Close()
+}
+
+func TestPoolBackpressureMiddlewareDisabled(t *testing.T) {
+	// With no timeout the database is never consulted
+	handler := PoolBackpressureMiddleware(nil, 0)(okHandler)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	original := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = original })
+
+	var seen string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = requestIDFromContext(r)
+		loggerFromContext(r).Info().Msg("handled")
+	}))
+
+	loggedID := func(t *testing.T) string {
+		t.Helper()
+		var entry struct {
+			RequestID string `json:"request_id"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		buf.Reset()
+		return entry.RequestID
+	}
+
+	t.Run("incoming ID is preserved", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+		req.Header.Set("X-Request-ID", "abc-123")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, "abc-123", rec.Header().Get("X-Request-ID"))
+		assert.Equal(t, "abc-123", seen)
+		assert.Equal(t, "abc-123", loggedID(t))
+	})
+
+	t.Run("missing ID is generated", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+
+		id := rec.Header().Get("X-Request-ID")
+		assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+		assert.Equal(t, id, seen)
+		assert.Equal(t, id, loggedID(t))
+	})
+
+	t.Run("unusable ID is replaced", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+		req.Header.Set("X-Request-ID", strings.Repeat("x", 200))
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Len(t, rec.Header().Get("X-Request-ID"), 36)
+		buf.Reset()
+	})
+}
+
+func TestLoggerFromContextWithoutRequestID(t *testing.T) {
+	assert.Same(t, &log.Logger, loggerFromContext(httptest.NewRequest(http.MethodGet, "/", nil)))
+}
+
+func TestLoggingMiddlewareAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	original := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = original })
+
+	type accessLog struct {
+		Level      string   `json:"level"`
+		Method     string   `json:"method"`
+		Path       string   `json:"path"`
+		Status     int      `json:"status"`
+		Bytes      int64    `json:"bytes"`
+		DurationMS *float64 `json:"duration_ms"`
+		RemoteIP   string   `json:"remote_ip"`
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		handler http.HandlerFunc
+		want    accessLog
+	}{
+		{
+			name: "ok",
+			path: "/api/posts?tag=go",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("[]"))
+			},
+			want: accessLog{Level: "info", Method: http.MethodGet, Path: "/api/posts", Status: http.StatusOK, Bytes: 2, RemoteIP: "192.0.2.1"},
+		},
+		{
+			name: "not found",
+			path: "/api/nowhere",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeError(w, http.StatusNotFound, "Resource not found")
+			},
+			want: accessLog{Level: "info", Method: http.MethodGet, Path: "/api/nowhere", Status: http.StatusNotFound, RemoteIP: "192.0.2.1"},
+		},
+		{
+			name: "server error",
+			path: "/api/posts",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			want: accessLog{Level: "error", Method: http.MethodGet, Path: "/api/posts", Status: http.StatusInternalServerError, RemoteIP: "192.0.2.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			rec := httptest.NewRecorder()
+
+			LoggingMiddleware(tt.handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			var got accessLog
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+			require.NotNil(t, got.DurationMS)
+			assert.GreaterOrEqual(t, *got.DurationMS, 0.0)
+			got.DurationMS = nil
+			if tt.want.Bytes == 0 {
+				tt.want.Bytes = int64(rec.Body.Len())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoggingMiddlewareRouteTemplate(t *testing.T) {
+	var buf bytes.Buffer
+	original := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = original })
+
+	loggedRoute := func(t *testing.T) string {
+		t.Helper()
+		var entry struct {
+			Route string `json:"route"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		buf.Reset()
+		return entry.Route
+	}
+
+	router := mux.NewRouter()
+	router.Use(LoggingMiddleware)
+	router.Handle("/api/posts/{id:[0-9]+}", okHandler)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/posts/42", nil))
+	assert.Equal(t, "/api/posts/{id:[0-9]+}", loggedRoute(t))
+
+	// Requests that never matched a route are grouped together
+	LoggingMiddleware(okHandler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+	assert.Equal(t, "unmatched", loggedRoute(t))
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	handler := NewPostHandler(newPooledDB(t, 1), nil, newTestConfig())
+	router := mux.NewRouter()
+	router.Use(TracingMiddleware)
+	router.HandleFunc("/api/posts/{id:[0-9]+}", handler.GetPost)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/posts/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var server, query sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.SpanKind() {
+		case trace.SpanKindServer:
+			server = span
+		case trace.SpanKindClient:
+			query = span
+		}
+	}
+	require.NotNil(t, server, "no request span")
+	require.NotNil(t, query, "no database span")
+
+	assert.Equal(t, "GET /api/posts/{id:[0-9]+}", server.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+
+	assert.Equal(t, "SELECT", query.Name())
+	assert.Equal(t, server.SpanContext().SpanID(), query.Parent().SpanID())
+	assert.Equal(t, server.SpanContext().TraceID(), query.SpanContext().TraceID())
+}
+
+func TestPanicRecoveryMiddleware(t *testing.T) {
+	handler := RequestIDMiddleware(PanicRecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("secret: connection string postgres://admin:hunter2@db")
+	})))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+
+	var body models.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, http.StatusInternalServerError, body.Code)
+
+	// The body names the request, so the logged panic can be found
+	require.NotEmpty(t, rec.Header().Get("X-Request-ID"))
+	assert.Equal(t, rec.Header().Get("X-Request-ID"), body.RequestID)
+
+	// Neither the panic value nor the stack reaches the client
+	assert.NotContains(t, rec.Body.String(), "hunter2")
+	assert.NotContains(t, rec.Body.String(), "goroutine")
+	assert.NotContains(t, rec.Body.String(), ".go:")
+}
+
+func TestPanicRecoveryMiddlewareAbortHandler(t *testing.T) {
+	handler := PanicRecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+	})
+}
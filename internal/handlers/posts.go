@@ -2,35 +2,84 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"blog-api/internal/clock"
+	"blog-api/internal/config"
 	"blog-api/internal/database"
+	"blog-api/internal/events"
 	"blog-api/internal/models"
+	"blog-api/internal/moderation"
+)
 
-	"github.com/rs/zerolog/log"
+// Page sizes for GET /api/posts/search
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
 )
 
 // PostHandler handles post-related HTTP requests
 type PostHandler struct {
-	db *database.DB
+	db        *database.DB
+	events    *events.Bus
+	cfg       *config.Config
+	moderator moderation.Moderator
+	clock     clock.Clock
+	// autosaves limits autosaves per post; nil means unlimited
+	autosaves *rateLimiter
+}
+
+// NewPostHandler creates a new post handler that publishes post changes to bus.
+// New posts are checked against the configured denylist, if any.
+func NewPostHandler(db *database.DB, bus *events.Bus, cfg *config.Config) *PostHandler {
+	var moderator moderation.Moderator = moderation.AllowAll{}
+	if len(cfg.ModerationDenylist) > 0 {
+		moderator = moderation.NewDenylist(cfg.ModerationDenylist)
+	}
+	h := &PostHandler{db: db, events: bus, cfg: cfg, moderator: moderator, clock: clock.Real{}}
+	if cfg.AutosaveRPS > 0 {
+		h.autosaves = newRateLimiter(cfg.AutosaveRPS, cfg.AutosaveBurst, h.clock)
+	}
+	return h
+}
+
+// SetModerator replaces the moderator new posts are checked with, e.g. with
+// a client for an external spam filtering service
+func (h *PostHandler) SetModerator(m moderation.Moderator) {
+	h.moderator = m
 }
 
-// NewPostHandler creates a new post handler
-func NewPostHandler(db *database.DB) *PostHandler {
-	return &PostHandler{db: db}
+// applyDefaults fills in optional fields the client left out of a new post
+func (h *PostHandler) applyDefaults(req *models.PostRequest) {
+	if req.ContentFormat == "" {
+		req.ContentFormat = h.cfg.DefaultContentFormat
+	}
 }
 
-// CreatePost handles POST /posts
+// CreatePost handles POST /posts, as the authenticated user or, with the
+// admin token, as any existing user named by user_id
 func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	var req models.PostRequest
-	if err := parseJSON(r, &req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid JSON payload")
+	if err := parseJSON(r, &req, h.cfg); err != nil {
+		writeInvalidJSON(w, err)
 		return
 	}
+	h.applyDefaults(&req)
+
+	// The author is the authenticated user, whatever user_id the body names.
+	// Admins have no user of their own and post as the user_id they name.
+	if userID, ok := userIDFromContext(r); ok && !isAdmin(r) {
+		req.UserID = userID
+	}
 
-	// Validate the request
-	if err := ValidatePostRequest(&req); err != nil {
+	// Validate the request body and query together
+	query := newQueryParams(r)
+	loc := query.timezone()
+	if err := mergeValidationErrors(ValidatePostRequest(&req), ValidatePublishAt(&req, h.clock.Now()), query.err()); err != nil {
 		writeValidationError(w, err)
 		return
 	}
@@ -39,9 +88,19 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
+	// Moderate before touching the database, so rejected posts cost no queries
+	timeout := time.Duration(h.cfg.ModerationTimeoutMS) * time.Millisecond
+	if allowed, reason := moderation.Check(ctx, h.moderator, &req, timeout); !allowed {
+		writeError(w, http.StatusUnprocessableEntity, reason)
+		return
+	}
+
 	// Verify that the user exists before creating the post
 	_, err := h.db.GetUserByID(ctx, req.UserID)
 	if err != nil {
+		if isClientCanceled(r, err) {
+			return
+		}
 		writeError(w, http.StatusBadRequest, "Invalid user ID: user does not exist")
 		return
 	}
@@ -49,30 +108,148 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	// Create the post
 	post, err := h.db.CreatePost(ctx, &req)
 	if err != nil {
-		handleDatabaseError(w, err, "create post")
+		if errors.Is(err, database.ErrDuplicateTitle) {
+			writeError(w, http.StatusConflict, duplicateTitleMessage(req.Title))
+			return
+		}
+		handleDatabaseError(w, r, err, "create post")
 		return
 	}
 
-	log.Info().Int("post_id", post.ID).Str("title", post.Title).Int("user_id", post.UserID).Msg("Post created successfully")
+	loggerFromContext(r).Info().Int("post_id", post.ID).Str("title", post.Title).Int("user_id", post.UserID).Msg("Post created successfully")
+	h.events.Publish(events.PostCreated{Post: *post})
+	post.CreatedAt = post.CreatedAt.In(loc)
+	w.Header().Set("Location", fmt.Sprintf("%s/api/posts/%d", publicBaseURL(r, h.cfg), post.ID))
 	writeJSON(w, http.StatusCreated, post)
 }
 
-// GetAllPosts handles GET /posts
+// GetAllPosts handles GET /posts. Clients polling the list can send
+// If-Modified-Since to get a 304 while nothing has changed; view counts in a
+// cached list may lag behind. Only the newest MaxListRows posts are sent,
+// with X-Result-Truncated: true when there were more. ?tag= lists only the
+// posts with that tag. Drafts are only listed for their author, and deleted
+// posts only for admins asking with ?include_deleted=true.
 func (h *PostHandler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
+	query := newQueryParams(r)
+	tag := query.tag()
+	includeDeleted := query.boolean("include_deleted")
+	loc := query.timezone()
+	fields := query.fields(postFields, h.cfg.SparseFieldsLimit)
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	if includeDeleted && !isAdmin(r) {
+		writeError(w, http.StatusForbidden, "Only admins can list deleted posts")
+		return
+	}
+
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	posts, err := h.db.GetAllPosts(ctx)
+	opts := models.PostListOptions{Tag: tag, IncludeDeleted: includeDeleted}
+	h.writePostList(ctx, w, r, opts, loc, fields, "get all posts")
+}
+
+// GetPostsByUser handles GET /api/users/{id}/posts, listing one author's
+// posts like GetAllPosts does. An author without posts gets an empty list;
+// only an unknown user is a 404.
+func (h *PostHandler) GetPostsByUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromURL(r, "id")
 	if err != nil {
-		handleDatabaseError(w, err, "get all posts")
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	query := newQueryParams(r)
+	tag := query.tag()
+	loc := query.timezone()
+	fields := query.fields(postFields, h.cfg.SparseFieldsLimit)
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, posts)
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if _, err := h.db.GetUserByID(ctx, id); err != nil {
+		handleDatabaseError(w, r, err, "get posts by user")
+		return
+	}
+
+	opts := models.PostListOptions{Tag: tag, AuthorID: id}
+	h.writePostList(ctx, w, r, opts, loc, fields, "get posts by user")
 }
 
-// GetPost handles GET /posts/{id}
+// writePostList writes the posts selected by opts as seen by the requesting
+// user, for GetAllPosts and GetPostsByUser. It answers If-Modified-Since,
+// and caps the list at MaxListRows.
+func (h *PostHandler) writePostList(ctx context.Context, w http.ResponseWriter, r *http.Request, opts models.PostListOptions, loc *time.Location, fields []string, operation string) {
+	// The list depends on who is asking, since authors see their drafts
+	w.Header().Add("Vary", "Authorization")
+
+	lastModified, settled, err := h.db.PostsLastModified(ctx, opts.AuthorID)
+	if err != nil {
+		handleDatabaseError(w, r, err, operation)
+		return
+	}
+	if settled {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+		if notModifiedSince(r, lastModified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	// Anonymous callers get 0, which matches no author's drafts
+	opts.ViewerID, _ = userIDFromContext(r)
+
+	posts, truncated, err := h.db.GetRecentPosts(ctx, opts, h.cfg.MaxListRows)
+	if err != nil {
+		handleDatabaseError(w, r, err, operation)
+		return
+	}
+	if truncated {
+		w.Header().Set("X-Result-Truncated", "true")
+	}
+
+	postsInLocation(posts, loc)
+	writeList(w, posts, len(posts), fields, h.cfg.EmptyListNoContent)
+}
+
+// SearchPosts handles GET /api/posts/search?q=, listing the posts that
+// contain every word of q, best matches first. Pages are chosen with ?limit=
+// (default 20, at most 100) and ?offset=.
+func (h *PostHandler) SearchPosts(w http.ResponseWriter, r *http.Request) {
+	query := newQueryParams(r)
+	q := query.required("q")
+	limit, offset := query.page(defaultSearchLimit, maxSearchLimit)
+	loc := query.timezone()
+	fields := query.fields(postFields, h.cfg.SparseFieldsLimit)
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	posts, err := h.db.SearchPosts(ctx, q, limit, offset)
+	if err != nil {
+		handleDatabaseError(w, r, err, "search posts")
+		return
+	}
+
+	postsInLocation(posts, loc)
+	writeList(w, posts, len(posts), fields, h.cfg.EmptyListNoContent)
+}
+
+// GetPost handles GET and HEAD /posts/{id}
 func (h *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDFromURL(r, "id")
 	if err != nil {
@@ -80,17 +257,42 @@ func (h *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query := newQueryParams(r)
+	loc := query.timezone()
+	fields := query.fields(postFields, h.cfg.SparseFieldsLimit)
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
 	post, err := h.db.GetPostByID(ctx, id)
 	if err != nil {
-		handleDatabaseError(w, err, "get post")
+		handleDatabaseError(w, r, err, "get post")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, post)
+	// Drafts don't exist for anyone but their author
+	if viewerID, _ := userIDFromContext(r); post.Status == models.PostStatusDraft && viewerID != post.UserID {
+		writeError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+
+	// A HEAD only checks that the post exists, so it isn't counted as a view.
+	// A failed view count update shouldn't prevent reading the post.
+	if r.Method != http.MethodHead {
+		if err := h.db.IncrementPostViews(ctx, id); err != nil {
+			loggerFromContext(r).Warn().Err(err).Int("post_id", id).Msg("Failed to record post view")
+		} else {
+			post.ViewCount++
+		}
+	}
+
+	post.CreatedAt = post.CreatedAt.In(loc)
+	writeResource(w, r, post, fields)
 }
 
 // UpdatePost handles PUT /posts/{id}
@@ -102,19 +304,21 @@ func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req models.PostRequest
-	if err := parseJSON(r, &req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid JSON payload")
+	if err := parseJSON(r, &req, h.cfg); err != nil {
+		writeInvalidJSON(w, err)
 		return
 	}
 
-	// Validate the request (for updates, fields are optional)
-	if err := ValidatePostUpdateRequest(&req); err != nil {
+	// Validate the request body (for updates, fields are optional) and query together
+	query := newQueryParams(r)
+	loc := query.timezone()
+	if err := mergeValidationErrors(ValidatePostUpdateRequest(&req), ValidatePublishAt(&req, h.clock.Now()), query.err()); err != nil {
 		writeValidationError(w, err)
 		return
 	}
 
 	// Check if at least one field is provided for update
-	if req.Title == "" && req.Content == "" && req.UserID == 0 {
+	if req.Title == "" && req.Content == "" && req.ContentFormat == "" && req.Status == "" && req.Tags == nil && req.PublishAt == nil {
 		writeError(w, http.StatusBadRequest, "At least one field must be provided for update")
 		return
 	}
@@ -123,22 +327,161 @@ func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	// If user_id is provided, verify that the user exists
-	if req.UserID != 0 {
-		_, err := h.db.GetUserByID(ctx, req.UserID)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid user ID: user does not exist")
+	existing, err := h.db.GetPostByID(ctx, id)
+	if err != nil {
+		handleDatabaseError(w, r, err, "get post")
+		return
+	}
+	if !canChangePost(r, existing) {
+		writePostForbidden(w, existing, "Only the author can update a post")
+		return
+	}
+	if req.PublishAt != nil && req.Status == "" && existing.Status == models.PostStatusPublished {
+		writeError(w, http.StatusConflict, "Only drafts can be scheduled for publishing")
+		return
+	}
+
+	post, err := h.db.UpdatePost(ctx, id, &req)
+	if err != nil {
+		if errors.Is(err, database.ErrDuplicateTitle) {
+			writeError(w, http.StatusConflict, duplicateTitleMessage(req.Title))
 			return
 		}
+		handleDatabaseError(w, r, err, "update post")
+		return
 	}
 
-	post, err := h.db.UpdatePost(ctx, id, &req)
+	loggerFromContext(r).Info().Int("post_id", post.ID).Str("title", post.Title).Msg("Post updated successfully")
+	h.events.Publish(events.PostUpdated{Post: *post})
+	post.CreatedAt = post.CreatedAt.In(loc)
+	writeJSON(w, http.StatusOK, post)
+}
+
+// PublishPost handles POST /api/posts/{id}/publish. Only the author may
+// publish a draft; publishing a post twice is a 409.
+func (h *PostHandler) PublishPost(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	query := newQueryParams(r)
+	loc := query.timezone()
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	existing, err := h.db.GetPostByID(ctx, id)
+	if err != nil {
+		handleDatabaseError(w, r, err, "get post")
+		return
+	}
+	if existing.UserID != userID {
+		// Someone else's draft is hidden, as in GetPost
+		if existing.Status == models.PostStatusDraft {
+			writeError(w, http.StatusNotFound, "Resource not found")
+		} else {
+			writeError(w, http.StatusForbidden, "Only the author can publish a post")
+		}
+		return
+	}
+
+	post, err := h.db.PublishPost(ctx, id)
+	if err != nil {
+		if errors.Is(err, database.ErrPostAlreadyPublished) {
+			writeError(w, http.StatusConflict, "Post is already published")
+			return
+		}
+		handleDatabaseError(w, r, err, "publish post")
+		return
+	}
+
+	loggerFromContext(r).Info().Int("post_id", post.ID).Int("user_id", userID).Msg("Post published")
+	h.events.Publish(events.PostUpdated{Post: *post})
+	post.Username = existing.Username
+	post.CreatedAt = post.CreatedAt.In(loc)
+	writeJSON(w, http.StatusOK, post)
+}
+
+// AutosaveDraft handles PUT /api/posts/{id}/autosave, saving the title and
+// content of a draft as its author writes it. Content may be empty, the
+// draft stays a draft, and a published post is a 409. Each post has its own
+// rate limit, so a chatty editor can't flood the database.
+func (h *PostHandler) AutosaveDraft(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	userID, ok := userIDFromContext(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req models.AutosaveRequest
+	if err := parseJSON(r, &req, h.cfg); err != nil {
+		writeInvalidJSON(w, err)
+		return
+	}
+
+	if err := ValidateAutosaveRequest(&req); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	if req.Title == nil && req.Content == nil {
+		writeError(w, http.StatusBadRequest, "At least one of title and content must be provided")
+		return
+	}
+
+	if h.autosaves != nil && !h.autosaves.writeResult(w, h.autosaves.allow(strconv.Itoa(id))) {
+		loggerFromContext(r).Warn().Int("post_id", id).Msg("Autosave rate limit exceeded")
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	existing, err := h.db.GetPostByID(ctx, id)
 	if err != nil {
-		handleDatabaseError(w, err, "update post")
+		handleDatabaseError(w, r, err, "get post")
+		return
+	}
+	if existing.UserID != userID {
+		writePostForbidden(w, existing, "Only the author can autosave a draft")
 		return
 	}
 
-	log.Info().Int("post_id", post.ID).Str("title", post.Title).Msg("Post updated successfully")
+	post, err := h.db.AutosaveDraft(ctx, id, req.Title, req.Content)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrPostAlreadyPublished):
+			writeError(w, http.StatusConflict, "Only drafts can be autosaved")
+		case errors.Is(err, database.ErrDuplicateTitle):
+			writeError(w, http.StatusConflict, duplicateTitleMessage(*req.Title))
+		default:
+			handleDatabaseError(w, r, err, "autosave draft")
+		}
+		return
+	}
+
+	loggerFromContext(r).Debug().Int("post_id", post.ID).Msg("Draft autosaved")
+	h.events.Publish(events.PostUpdated{Post: *post})
+	post.Username = existing.Username
 	writeJSON(w, http.StatusOK, post)
 }
 
@@ -154,12 +497,400 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	err = h.db.DeletePost(ctx, id)
+	existing, err := h.db.GetPostByID(ctx, id)
+	if err == nil {
+		if !canChangePost(r, existing) {
+			writePostForbidden(w, existing, "Only the author can delete a post")
+			return
+		}
+
+		// With If-Match, only delete the post as the client last saw it: its
+		// full representation in UTC, as GET sends it without ?fields= or ?tz=
+		if r.Header.Get("If-Match") != "" {
+			err = h.db.DeletePostIf(ctx, id, func(post *models.Post) bool {
+				post.CreatedAt = post.CreatedAt.In(time.UTC)
+				return ifMatch(r, resourceETag(post))
+			})
+		} else {
+			err = h.db.DeletePost(ctx, id)
+		}
+	}
+	if errors.Is(err, database.ErrPreconditionFailed) {
+		writeError(w, http.StatusPreconditionFailed, "Post has changed since it was last read")
+		return
+	}
+	if errors.Is(err, database.ErrPostNotFound) && !h.cfg.DeleteMissingNotFound {
+		// Already gone, most likely a retry of a delete that succeeded
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		handleDatabaseError(w, r, err, "delete post")
+		return
+	}
+
+	loggerFromContext(r).Info().Int("post_id", id).Msg("Post deleted successfully")
+	h.events.Publish(events.PostDeleted{PostID: id})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// canChangePost reports whether the caller may edit or delete post: its
+// author or an admin
+func canChangePost(r *http.Request, post *models.Post) bool {
+	if isAdmin(r) {
+		return true
+	}
+	userID, ok := userIDFromContext(r)
+	return ok && userID == post.UserID
+}
+
+// writePostForbidden rejects a change to someone else's post. Their drafts
+// are hidden, as in GetPost.
+func writePostForbidden(w http.ResponseWriter, post *models.Post, message string) {
+	if post.Status == models.PostStatusDraft {
+		writeError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	writeError(w, http.StatusForbidden, message)
+}
+
+// RestorePost handles POST /api/posts/{id}/restore, bringing back a deleted post
+func (h *PostHandler) RestorePost(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	query := newQueryParams(r)
+	loc := query.timezone()
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	post, err := h.db.RestorePost(ctx, id)
 	if err != nil {
-		handleDatabaseError(w, err, "delete post")
+		if errors.Is(err, database.ErrDuplicateTitle) {
+			writeError(w, http.StatusConflict, "The author has since used this post's title for another post")
+			return
+		}
+		handleDatabaseError(w, r, err, "restore post")
 		return
 	}
 
-	log.Info().Int("post_id", id).Msg("Post deleted successfully")
-	writeSuccess(w, "Post deleted successfully", nil)
+	loggerFromContext(r).Info().Int("post_id", id).Msg("Post restored")
+	h.events.Publish(events.PostUpdated{Post: *post})
+	post.CreatedAt = post.CreatedAt.In(loc)
+	writeJSON(w, http.StatusOK, post)
+}
+
+// duplicateTitleMessage builds the conflict message for a title the author already uses
+func duplicateTitleMessage(title string) string {
+	return fmt.Sprintf("A post titled %q already exists for this author", title)
+}
+
+// resetViewsRequest is the body of a view count reset. The time bounds are
+// strings so they accept every format parseTimeParam does.
+type resetViewsRequest struct {
+	UserID        int    `json:"user_id"`
+	CreatedAfter  string `json:"created_after"`
+	CreatedBefore string `json:"created_before"`
+}
+
+// filter converts the request to a PostFilter, reporting every time bound
+// that fails to parse
+func (req resetViewsRequest) filter() (models.PostFilter, error) {
+	filter := models.PostFilter{UserID: req.UserID}
+	var errors []ValidationError
+
+	bounds := []struct {
+		field string
+		value string
+		dst   **time.Time
+	}{
+		{"created_after", req.CreatedAfter, &filter.CreatedAfter},
+		{"created_before", req.CreatedBefore, &filter.CreatedBefore},
+	}
+	for _, bound := range bounds {
+		if bound.value == "" {
+			continue
+		}
+		t, err := parseTimeParam(bound.value)
+		if err != nil {
+			errors = append(errors, ValidationError{Field: bound.field, Message: err.Error()})
+			continue
+		}
+		*bound.dst = &t
+	}
+
+	if len(errors) > 0 {
+		return filter, ValidationErrors{Errors: errors}
+	}
+	return filter, nil
+}
+
+// ResetViewCounts handles POST /api/admin/posts/reset-views
+func (h *PostHandler) ResetViewCounts(w http.ResponseWriter, r *http.Request) {
+	var req resetViewsRequest
+	if r.ContentLength != 0 {
+		if err := parseJSON(r, &req, h.cfg); err != nil {
+			writeInvalidJSON(w, err)
+			return
+		}
+	}
+
+	if req.UserID < 0 {
+		writeError(w, http.StatusBadRequest, "user_id must be a positive integer")
+		return
+	}
+
+	filter, err := req.filter()
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	affected, err := h.db.ResetViewCounts(ctx, filter)
+	if err != nil {
+		handleDatabaseError(w, r, err, "reset view counts")
+		return
+	}
+
+	loggerFromContext(r).Info().Int64("posts_affected", affected).Int("user_id", filter.UserID).Msg("Post view counts reset")
+	writeSuccess(w, "View counts reset successfully", map[string]int64{"posts_affected": affected})
+}
+
+// MovePost handles POST /api/posts/{id}/move
+func (h *PostHandler) MovePost(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	var req models.TransferRequest
+	if err := parseJSON(r, &req, h.cfg); err != nil {
+		writeInvalidJSON(w, err)
+		return
+	}
+
+	if req.NewUserID <= 0 {
+		writeError(w, http.StatusBadRequest, "new_user_id must be a positive integer")
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	post, err := h.db.TransferPostOwnership(ctx, id, req.NewUserID)
+	if err != nil {
+		if errors.Is(err, database.ErrTargetUserNotFound) {
+			writeError(w, http.StatusBadRequest, "Invalid user ID: user does not exist")
+			return
+		}
+		if errors.Is(err, database.ErrDuplicateTitle) {
+			// The move was rolled back, so the post is still there to name
+			if unmoved, err := h.db.GetPostByID(ctx, id); err == nil {
+				writeError(w, http.StatusConflict, duplicateTitleMessage(unmoved.Title))
+				return
+			}
+			writeError(w, http.StatusConflict, "The new author already has a post with this title")
+			return
+		}
+		handleDatabaseError(w, r, err, "move post")
+		return
+	}
+
+	loggerFromContext(r).Info().Int("post_id", post.ID).Int("user_id", post.UserID).Msg("Post ownership transferred")
+	h.events.Publish(events.PostUpdated{Post: *post})
+	writeJSON(w, http.StatusOK, post)
+}
+
+// TransferUserPosts handles POST /api/admin/users/{id}/transfer-posts
+func (h *PostHandler) TransferUserPosts(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req models.TransferRequest
+	if err := parseJSON(r, &req, h.cfg); err != nil {
+		writeInvalidJSON(w, err)
+		return
+	}
+
+	if req.NewUserID <= 0 {
+		writeError(w, http.StatusBadRequest, "new_user_id must be a positive integer")
+		return
+	}
+
+	if req.NewUserID == id {
+		writeError(w, http.StatusBadRequest, "new_user_id must differ from the current owner")
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	posts, err := h.db.TransferUserPosts(ctx, id, req.NewUserID)
+	if err != nil {
+		if errors.Is(err, database.ErrTargetUserNotFound) {
+			writeError(w, http.StatusBadRequest, "Invalid user ID: user does not exist")
+			return
+		}
+		if errors.Is(err, database.ErrDuplicateTitle) {
+			writeError(w, http.StatusConflict, "The new author already has a post with the title of one being transferred")
+			return
+		}
+		handleDatabaseError(w, r, err, "transfer user posts")
+		return
+	}
+
+	loggerFromContext(r).Info().Int("from_user_id", id).Int("to_user_id", req.NewUserID).Int("posts_moved", len(posts)).Msg("User posts transferred")
+	for _, post := range posts {
+		h.events.Publish(events.PostUpdated{Post: post})
+	}
+	writeSuccess(w, "Posts transferred successfully", map[string]int{"posts_moved": len(posts)})
+}
+
+// GetPostArchive handles GET /api/posts/archive, counting posts per month.
+// ?author= limits the count to one user's posts.
+func (h *PostHandler) GetPostArchive(w http.ResponseWriter, r *http.Request) {
+	query := newQueryParams(r)
+	loc := query.timezone()
+	filter := models.PostFilter{UserID: query.positiveInt("author")}
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	archive, err := h.db.GetPostArchive(ctx, filter, loc)
+	if err != nil {
+		handleDatabaseError(w, r, err, "get post archive")
+		return
+	}
+
+	writeList(w, archive, len(archive), nil, h.cfg.EmptyListNoContent)
+}
+
+// GetPostSiblings handles GET /api/posts/{id}/siblings, returning the posts
+// before and after it for prev/next links. ?scope=author only links posts by
+// the same author.
+func (h *PostHandler) GetPostSiblings(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	query := newQueryParams(r)
+	loc := query.timezone()
+	scope := query.siblingScope()
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	siblings, err := h.db.GetPostSiblings(ctx, id, scope)
+	if err != nil {
+		handleDatabaseError(w, r, err, "get post siblings")
+		return
+	}
+
+	for _, post := range []*models.Post{siblings.Prev, siblings.Next} {
+		if post != nil {
+			post.CreatedAt = post.CreatedAt.In(loc)
+		}
+	}
+	writeJSON(w, http.StatusOK, siblings)
+}
+
+// GetFeaturedPosts handles GET /api/posts/featured
+func (h *PostHandler) GetFeaturedPosts(w http.ResponseWriter, r *http.Request) {
+	query := newQueryParams(r)
+	loc := query.timezone()
+	fields := query.fields(postFields, h.cfg.SparseFieldsLimit)
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	posts, err := h.db.GetFeaturedPosts(ctx)
+	if err != nil {
+		handleDatabaseError(w, r, err, "get featured posts")
+		return
+	}
+
+	postsInLocation(posts, loc)
+	writeList(w, posts, len(posts), fields, h.cfg.EmptyListNoContent)
+}
+
+// FeaturePost handles POST /api/posts/{id}/feature
+func (h *PostHandler) FeaturePost(w http.ResponseWriter, r *http.Request) {
+	h.setFeatured(w, r, true)
+}
+
+// UnfeaturePost handles POST /api/posts/{id}/unfeature
+func (h *PostHandler) UnfeaturePost(w http.ResponseWriter, r *http.Request) {
+	h.setFeatured(w, r, false)
+}
+
+// setFeatured toggles the featured flag of the post in the URL
+func (h *PostHandler) setFeatured(w http.ResponseWriter, r *http.Request, featured bool) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	// The body is optional and only carries the featured order
+	var req models.FeatureRequest
+	if featured && r.ContentLength != 0 {
+		if err := parseJSON(r, &req, h.cfg); err != nil {
+			writeInvalidJSON(w, err)
+			return
+		}
+	}
+
+	if req.Order != nil && *req.Order < 0 {
+		writeError(w, http.StatusBadRequest, "order must be a non-negative integer")
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	post, err := h.db.SetFeatured(ctx, id, featured, req.Order)
+	if err != nil {
+		handleDatabaseError(w, r, err, "set featured")
+		return
+	}
+
+	loggerFromContext(r).Info().Int("post_id", post.ID).Bool("featured", post.Featured).Msg("Post featured flag updated")
+	writeJSON(w, http.StatusOK, post)
 }
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"blog-api/internal/database"
+	"blog-api/internal/models"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAllPostsClientCanceled(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/posts", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	handler.GetAllPosts(rec, req)
+
+	// Nothing should be written back to a client that has already gone away
+	assert.Empty(t, rec.Body.String())
+	assert.Empty(t, rec.Header().Get("Content-Type"))
+}
+
+func TestCreatePostModerationRejects(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.ModerationDenylist = []string{"casino"}
+	handler := NewPostHandler(newUnreachableDB(t), nil, cfg)
+
+	body := `{"title":"Big wins","content":"Play at our casino","user_id":1}`
+	rec := httptest.NewRecorder()
+	handler.CreatePost(rec, httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(body)))
+
+	// Rejected before the (unreachable) database is consulted
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "banned word")
+}
+
+// authorModerator records the author of the post it checks and rejects it,
+// so CreatePost stops before the database
+type authorModerator struct {
+	userID int
+}
+
+func (m *authorModerator) Check(_ context.Context, post *models.PostRequest) (bool, string) {
+	m.userID = post.UserID
+	return false, "stopped"
+}
+
+func TestCreatePostAuthorFromToken(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JWTSecret = "secret"
+	token, _, err := issueToken(cfg, &models.User{ID: 7, Username: "alice"}, time.Now())
+	require.NoError(t, err)
+
+	for _, body := range []string{
+		`{"title":"Mine","content":"Content"}`,
+		`{"title":"Mine","content":"Content","user_id":8}`,
+	} {
+		moderator := &authorModerator{}
+		handler := NewPostHandler(newUnreachableDB(t), nil, cfg)
+		handler.SetModerator(moderator)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		AuthMiddleware(cfg.JWTSecret, nil)(http.HandlerFunc(handler.CreatePost)).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, body)
+		assert.Equal(t, 7, moderator.userID, body)
+	}
+}
+
+func TestCreatePostAsAdmin(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JWTSecret = "secret"
+	cfg.AdminToken = "admin-token"
+	token, _, err := issueToken(cfg, &models.User{ID: 7, Username: "alice"}, time.Now())
+	require.NoError(t, err)
+
+	send := func(body, bearer string) (*httptest.ResponseRecorder, *authorModerator) {
+		moderator := &authorModerator{}
+		handler := NewPostHandler(newUnreachableDB(t), nil, cfg)
+		handler.SetModerator(moderator)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		rec := httptest.NewRecorder()
+		AuthOrAdminMiddleware(cfg.AdminToken, cfg.JWTSecret, nil)(http.HandlerFunc(handler.CreatePost)).ServeHTTP(rec, req)
+		return rec, moderator
+	}
+
+	// The admin posts as the user named
+	rec, moderator := send(`{"title":"On Behalf","content":"Content","user_id":8}`, cfg.AdminToken)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, 8, moderator.userID)
+
+	// and must name one
+	rec, _ = send(`{"title":"On Behalf","content":"Content"}`, cfg.AdminToken)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, validationDetails(t, rec), "user_id")
+
+	// A user's user_id is still ignored
+	rec, moderator = send(`{"title":"Mine","content":"Content","user_id":8}`, token)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, 7, moderator.userID)
+}
+
+func TestAutosaveDraftRejectedBeforeDatabase(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JWTSecret = "secret"
+	cfg.AutosaveRPS = 0.001
+	cfg.AutosaveBurst = 1
+	token, _, err := issueToken(cfg, &models.User{ID: 7, Username: "alice"}, time.Now())
+	require.NoError(t, err)
+
+	handler := NewPostHandler(newUnreachableDB(t), nil, cfg)
+	autosave := AuthMiddleware(cfg.JWTSecret, nil)(http.HandlerFunc(handler.AutosaveDraft))
+	send := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/posts/"+id+"/autosave", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rec := httptest.NewRecorder()
+		autosave.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusBadRequest, send("1", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("1", `{"title":" "}`).Code)
+
+	// The first autosave of a post reaches the database; the next one is
+	// limited, while other posts have their own budget
+	assert.Equal(t, http.StatusInternalServerError, send("1", `{"content":""}`).Code)
+	rec := send("1", `{"content":"more"}`)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusInternalServerError, send("2", `{"content":""}`).Code)
+}
+
+func TestHandleDatabaseErrorDeadlineExceeded(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+	rec := httptest.NewRecorder()
+
+	handleDatabaseError(rec, req, context.DeadlineExceeded, "get all posts")
+
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+}
+
+func TestHandleDatabaseErrorDuplicateUser(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "username", err: database.ErrDuplicateUsername, want: "Username is already taken"},
+		{name: "email", err: database.ErrDuplicateEmail, want: "Email is already registered"},
+		{name: "other", err: fmt.Errorf("failed to create user: %w", database.ErrDuplicate), want: "Resource already exists"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/users", nil)
+			rec := httptest.NewRecorder()
+
+			handleDatabaseError(rec, req, tt.err, "create user")
+
+			assert.Equal(t, http.StatusConflict, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.want)
+		})
+	}
+}
+
+func TestApplyDefaultsContentFormat(t *testing.T) {
+	handler := NewPostHandler(nil, nil, newTestConfig())
+
+	req := models.PostRequest{Title: "Title", Content: "Content", UserID: 1}
+	handler.applyDefaults(&req)
+	assert.Equal(t, models.ContentFormatMarkdown, req.ContentFormat)
+
+	// An explicit format from the client is kept
+	req = models.PostRequest{Title: "Title", Content: "<p>Content</p>", ContentFormat: models.ContentFormatHTML, UserID: 1}
+	handler.applyDefaults(&req)
+	assert.Equal(t, models.ContentFormatHTML, req.ContentFormat)
+}
+
+func TestCreatePostInvalidContentFormat(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	body := `{"title":"Title","content":"Content","content_format":"rtf","user_id":1}`
+	req := httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.CreatePost(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "content_format")
+}
+
+func TestHandleDatabaseErrorCheckViolation(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+	rec := httptest.NewRecorder()
+
+	err := fmt.Errorf("failed to create post: %w", &pq.Error{Code: "23514", Table: "posts", Constraint: "posts_title_check"})
+	handleDatabaseError(rec, req, err, "create post")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"field":"title"`)
+	assert.Contains(t, rec.Body.String(), "posts_title_check")
+}
+
+func TestGetPostArchiveInvalidAuthor(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	for _, author := range []string{"abc", "0", "-3"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/posts/archive?author="+author, nil)
+		rec := httptest.NewRecorder()
+
+		handler.GetPostArchive(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, author)
+		assert.Contains(t, rec.Body.String(), "author must be a positive integer", author)
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"blog-api/internal/database"
+	"blog-api/internal/models"
+)
+
+// queryParams validates a request's query parameters. Every problem is
+// collected rather than returned on the first failure, so a client sees all
+// of them in one 400, in the same shape as body validation errors.
+type queryParams struct {
+	r      *http.Request
+	errors []ValidationError
+}
+
+func newQueryParams(r *http.Request) *queryParams {
+	return &queryParams{r: r}
+}
+
+// timezone reads ?tz=, falling back to UTC when it is invalid
+func (q *queryParams) timezone() *time.Location {
+	loc, err := parseTimezone(q.r)
+	if err != nil {
+		q.add("tz", err.Error())
+		return time.UTC
+	}
+	return loc
+}
+
+// fields reads ?fields= against the allowed field names
+func (q *queryParams) fields(allowed map[string]bool, limit int) []string {
+	fields, err := parseFields(q.r, allowed, limit)
+	if err != nil {
+		q.add("fields", err.Error())
+		return nil
+	}
+	return fields
+}
+
+// positiveInt reads an optional positive integer parameter, returning 0 when
+// it is absent or invalid
+func (q *queryParams) positiveInt(param string) int {
+	raw := q.r.URL.Query().Get(param)
+	if raw == "" {
+		return 0
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		q.add(param, param+" must be a positive integer")
+		return 0
+	}
+	return n
+}
+
+// required reads a parameter that must be given and not blank
+func (q *queryParams) required(param string) string {
+	value := strings.TrimSpace(q.r.URL.Query().Get(param))
+	if value == "" {
+		q.add(param, param+" is required")
+	}
+	return value
+}
+
+// tag reads the optional ?tag= filter in its canonical form, see
+// database.NormalizeTag. It must not be blank when given.
+func (q *queryParams) tag() string {
+	values, ok := q.r.URL.Query()["tag"]
+	if !ok {
+		return ""
+	}
+
+	tag := database.NormalizeTag(values[0])
+	if tag == "" {
+		q.add("tag", "tag must not be blank")
+	}
+	return tag
+}
+
+// page reads ?limit= and ?offset=. limit defaults to def and may not exceed
+// max; offset defaults to 0.
+func (q *queryParams) page(def, max int) (limit, offset int) {
+	limit = def
+	if raw := q.r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > max {
+			q.add("limit", fmt.Sprintf("limit must be an integer from 1 to %d", max))
+		} else {
+			limit = n
+		}
+	}
+
+	if raw := q.r.URL.Query().Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			q.add("offset", "offset must be a non-negative integer")
+		} else {
+			offset = n
+		}
+	}
+
+	return limit, offset
+}
+
+// boolean reads an optional true/false parameter, returning false when it is
+// absent or invalid
+func (q *queryParams) boolean(param string) bool {
+	raw := q.r.URL.Query().Get(param)
+	if raw == "" {
+		return false
+	}
+
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		q.add(param, param+" must be true or false")
+		return false
+	}
+	return b
+}
+
+// siblingScope reads ?scope= for post siblings, which is empty or "author"
+func (q *queryParams) siblingScope() models.SiblingScope {
+	switch scope := models.SiblingScope(q.r.URL.Query().Get("scope")); scope {
+	case models.SiblingScopeAll, models.SiblingScopeAuthor:
+		return scope
+	default:
+		q.add("scope", `scope must be "author" when given`)
+		return models.SiblingScopeAll
+	}
+}
+
+func (q *queryParams) add(param, message string) {
+	q.errors = append(q.errors, ValidationError{Field: param, Message: message})
+}
+
+// err returns the collected problems as ValidationErrors, or nil
+func (q *queryParams) err() error {
+	if len(q.errors) == 0 {
+		return nil
+	}
+	return ValidationErrors{Errors: q.errors}
+}
+
+// mergeValidationErrors combines the ValidationErrors from body and query
+// validation into one, returning nil when there are none
+func mergeValidationErrors(errs ...error) error {
+	var merged []ValidationError
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if validationErr, ok := err.(ValidationErrors); ok {
+			merged = append(merged, validationErr.Errors...)
+		} else {
+			merged = append(merged, ValidationError{Message: err.Error()})
+		}
+	}
+
+	if len(merged) == 0 {
+		return nil
+	}
+	return ValidationErrors{Errors: merged}
+}
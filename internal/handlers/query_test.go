@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validationDetails decodes the field errors from a validation failure response
+func validationDetails(t *testing.T, rec *httptest.ResponseRecorder) map[string]string {
+	t.Helper()
+
+	var body struct {
+		Details []ValidationError `json:"details"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+
+	details := make(map[string]string)
+	for _, detail := range body.Details {
+		details[detail.Field] = detail.Message
+	}
+	return details
+}
+
+func TestGetAllPostsReportsAllQueryErrors(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/posts?tz=Not/AZone&fields=title,bogus", nil)
+	rec := httptest.NewRecorder()
+
+	handler.GetAllPosts(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	details := validationDetails(t, rec)
+	assert.Len(t, details, 2)
+	assert.Contains(t, details["tz"], "Not/AZone")
+	assert.Contains(t, details["fields"], "bogus")
+}
+
+func TestGetAllPostsBlankTag(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	rec := httptest.NewRecorder()
+	handler.GetAllPosts(rec, httptest.NewRequest(http.MethodGet, "/api/posts?tag=%20", nil))
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, validationDetails(t, rec), "tag")
+}
+
+func TestQueryTagIsNormalized(t *testing.T) {
+	query := newQueryParams(httptest.NewRequest(http.MethodGet, "/api/posts?tag=%20Web%20%20Dev", nil))
+	assert.Equal(t, "web-dev", query.tag())
+	assert.NoError(t, query.err())
+
+	query = newQueryParams(httptest.NewRequest(http.MethodGet, "/api/posts?tag=Golang", nil))
+	assert.Equal(t, "golang", query.tag())
+}
+
+func TestGetAllPostsIncludeDeletedRequiresAdmin(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	rec := httptest.NewRecorder()
+	handler.GetAllPosts(rec, httptest.NewRequest(http.MethodGet, "/api/posts?include_deleted=true", nil))
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestGetPostsByUserInvalidID(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/abc/posts", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "abc"})
+	rec := httptest.NewRecorder()
+	handler.GetPostsByUser(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCreatePostReportsBodyAndQueryErrors(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	body := `{"title":"","content":"Content","user_id":1}`
+	req := httptest.NewRequest(http.MethodPost, "/api/posts?tz=Not/AZone", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.CreatePost(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	details := validationDetails(t, rec)
+	assert.Len(t, details, 2)
+	assert.Contains(t, details, "title")
+	assert.Contains(t, details, "tz")
+}
+
+func TestMergeValidationErrors(t *testing.T) {
+	assert.NoError(t, mergeValidationErrors(nil, nil))
+
+	merged := mergeValidationErrors(
+		ValidationErrors{Errors: []ValidationError{{Field: "title", Message: "title is required"}}},
+		nil,
+		ValidationErrors{Errors: []ValidationError{{Field: "tz", Message: "unknown time zone"}}},
+	)
+	require.IsType(t, ValidationErrors{}, merged)
+	assert.Len(t, merged.(ValidationErrors).Errors, 2)
+}
+
+func TestGetPostSiblingsInvalidScope(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/posts/1/siblings?scope=tag", nil), map[string]string{"id": "1"})
+	rec := httptest.NewRecorder()
+
+	handler.GetPostSiblings(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, validationDetails(t, rec), "scope")
+}
+
+func TestBooleanParam(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    bool
+		wantErr bool
+	}{
+		{query: "", want: false},
+		{query: "?all=true", want: true},
+		{query: "?all=1", want: true},
+		{query: "?all=false", want: false},
+		{query: "?all=yes", want: false, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query := newQueryParams(httptest.NewRequest(http.MethodPost, "/api/auth/logout"+tt.query, nil))
+
+			assert.Equal(t, tt.want, query.boolean("all"))
+			assert.Equal(t, tt.wantErr, query.err() != nil)
+		})
+	}
+}
+
+func TestSearchPostsRequiresQuery(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	for _, target := range []string{"/api/posts/search", "/api/posts/search?q=", "/api/posts/search?q=%20%20"} {
+		t.Run(target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.SearchPosts(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, validationDetails(t, rec), "q")
+		})
+	}
+}
+
+func TestPageParams(t *testing.T) {
+	tests := []struct {
+		query      string
+		wantLimit  int
+		wantOffset int
+		wantErrs   []string
+	}{
+		{query: "", wantLimit: 20, wantOffset: 0},
+		{query: "?limit=5&offset=10", wantLimit: 5, wantOffset: 10},
+		{query: "?limit=100", wantLimit: 100},
+		{query: "?limit=101&offset=-1", wantLimit: 20, wantErrs: []string{"limit", "offset"}},
+		{query: "?limit=0", wantLimit: 20, wantErrs: []string{"limit"}},
+		{query: "?offset=abc", wantLimit: 20, wantErrs: []string{"offset"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query := newQueryParams(httptest.NewRequest(http.MethodGet, "/api/posts/search"+tt.query, nil))
+
+			limit, offset := query.page(20, 100)
+			assert.Equal(t, tt.wantLimit, limit)
+			assert.Equal(t, tt.wantOffset, offset)
+
+			var fields []string
+			for _, e := range query.errors {
+				fields = append(fields, e.Field)
+			}
+			assert.Equal(t, tt.wantErrs, fields)
+		})
+	}
+}
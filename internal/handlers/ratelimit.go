@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"blog-api/internal/clock"
+	"blog-api/internal/config"
+)
+
+// rateLimitSweepInterval is how often buckets that have refilled are dropped,
+// so clients that went away don't keep using memory
+const rateLimitSweepInterval = time.Minute
+
+// RateLimitMiddleware limits each client IP to cfg.RateLimitRPS requests per
+// second on average, with bursts of up to cfg.RateLimitBurst, and answers
+// 429 with Retry-After beyond that. All routes it wraps share one budget.
+// Every response carries X-RateLimit-Limit (the burst),
+// X-RateLimit-Remaining (requests left in it) and X-RateLimit-Reset (seconds
+// until the burst is available again), so clients can slow down in time.
+func RateLimitMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	if cfg.RateLimitRPS <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return newRateLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst, clock.Real{}).middleware(cfg)
+}
+
+// rateLimiter keeps a token bucket per key. Each bucket holds up to burst
+// tokens, refills at rate tokens per second, and a request takes one.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	clock clock.Clock
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket is the state of one key's bucket as of last
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimitResult is the outcome of a request against its bucket
+type rateLimitResult struct {
+	allowed bool
+	// remaining is how many whole tokens are left
+	remaining int
+	// retryAfter is how long until the next token, when not allowed
+	retryAfter time.Duration
+	// reset is how long until the bucket is full again
+	reset time.Duration
+}
+
+// newRateLimiter creates a rate limiter whose buckets start out full
+func newRateLimiter(rate float64, burst int, clk clock.Clock) *rateLimiter {
+	return &rateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		clock:     clk,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: clk.Now(),
+	}
+}
+
+// middleware rejects requests from client IPs whose bucket is empty
+func (l *rateLimiter) middleware(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r, cfg)
+			if !l.writeResult(w, l.allow(ip)) {
+				loggerFromContext(r).Warn().Str("client_ip", ip).Str("path", r.URL.Path).Msg("Rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeResult sets the X-RateLimit-* headers for result and, when it isn't
+// allowed, answers 429 with Retry-After. It reports whether the request may
+// go on.
+func (l *rateLimiter) writeResult(w http.ResponseWriter, result rateLimitResult) bool {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(int(l.burst)))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.reset)))
+	if !result.allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.retryAfter)))
+		writeError(w, http.StatusTooManyRequests, "Too many requests, please retry later")
+	}
+	return result.allowed
+}
+
+// allow takes a token from key's bucket. When the bucket is empty it reports
+// how long until the next token is added instead.
+func (l *rateLimiter) allow(key string) rateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = l.refill(bucket, now)
+	bucket.last = now
+
+	result := rateLimitResult{allowed: bucket.tokens >= 1}
+	if result.allowed {
+		bucket.tokens--
+	} else {
+		result.retryAfter = l.untilTokens(bucket, 1)
+	}
+	result.remaining = int(bucket.tokens)
+	result.reset = l.untilTokens(bucket, l.burst)
+	return result
+}
+
+// untilTokens returns how long until bucket holds n tokens
+func (l *rateLimiter) untilTokens(bucket *tokenBucket, n float64) time.Duration {
+	if bucket.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - bucket.tokens) / l.rate * float64(time.Second))
+}
+
+// ceilSeconds rounds d up to whole seconds, for headers that count seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// refill returns how many tokens bucket holds at now
+func (l *rateLimiter) refill(bucket *tokenBucket, now time.Time) float64 {
+	return math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+}
+
+// sweep drops the buckets that are full again. A full bucket behaves just
+// like the new one a returning client gets, so nothing is lost.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if l.refill(bucket, now) >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
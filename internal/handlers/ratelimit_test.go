@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"blog-api/internal/clock"
+	"blog-api/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	cfg := &config.Config{}
+	handler := newRateLimiter(0.5, 3, clk).middleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The burst goes through, the request after it doesn't
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusNoContent, send("203.0.113.9:1234").Code, "request %d", i+1)
+	}
+	rec := send("203.0.113.9:1234")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+
+	// Other clients have their own bucket
+	assert.Equal(t, http.StatusNoContent, send("198.51.100.4:1234").Code)
+
+	// One token is added every two seconds
+	clk.Advance(time.Second)
+	rec = send("203.0.113.9:5678")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	clk.Advance(time.Second)
+	assert.Equal(t, http.StatusNoContent, send("203.0.113.9:5678").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("203.0.113.9:5678").Code)
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	handler := newRateLimiter(0.5, 3, clk).middleware(&config.Config{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+		req.RemoteAddr = "203.0.113.9:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The remaining count goes down with each request, and the bucket needs
+	// two seconds per request taken to fill up again
+	for i, want := range []struct{ remaining, reset string }{{"2", "2"}, {"1", "4"}, {"0", "6"}} {
+		rec := send()
+		assert.Equal(t, http.StatusNoContent, rec.Code, "request %d", i+1)
+		assert.Equal(t, "3", rec.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, want.remaining, rec.Header().Get("X-RateLimit-Remaining"), "request %d", i+1)
+		assert.Equal(t, want.reset, rec.Header().Get("X-RateLimit-Reset"), "request %d", i+1)
+	}
+
+	// Rejected requests carry them too
+	rec := send()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "6", rec.Header().Get("X-RateLimit-Reset"))
+
+	// Refilling counts back up
+	clk.Advance(3 * time.Second)
+	rec = send()
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "5", rec.Header().Get("X-RateLimit-Reset"))
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	limiter := newRateLimiter(1, 2, clk)
+
+	limiter.allow("idle")
+	clk.Advance(rateLimitSweepInterval - time.Second)
+	for i := 0; i < 3; i++ {
+		limiter.allow("busy")
+	}
+
+	clk.Advance(time.Second)
+	limiter.allow("new")
+
+	assert.NotContains(t, limiter.buckets, "idle", "full buckets should be dropped")
+	assert.Contains(t, limiter.buckets, "busy")
+	assert.Contains(t, limiter.buckets, "new")
+}
+
+func TestRateLimitMiddlewareDisabled(t *testing.T) {
+	handler := RateLimitMiddleware(&config.Config{RateLimitRPS: 0, RateLimitBurst: 0})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/users", nil))
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	}
+}
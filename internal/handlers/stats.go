@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"blog-api/internal/models"
+)
+
+// statsCacheTTL is how long user stats are served from memory. Profiles are
+// read far more often than their numbers change.
+const statsCacheTTL = 30 * time.Second
+
+// statsCache holds recently computed user stats
+type statsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int]statsEntry
+}
+
+type statsEntry struct {
+	stats   models.UserStats
+	expires time.Time
+}
+
+func newStatsCache(ttl time.Duration) *statsCache {
+	return &statsCache{ttl: ttl, entries: make(map[int]statsEntry)}
+}
+
+// get returns cached stats for userID if they haven't expired
+func (c *statsCache) get(userID int, now time.Time) (models.UserStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[userID]
+	if !ok {
+		return models.UserStats{}, false
+	}
+	if now.After(entry.expires) {
+		delete(c.entries, userID)
+		return models.UserStats{}, false
+	}
+	return entry.stats, true
+}
+
+// put caches stats until the TTL elapses
+func (c *statsCache) put(stats models.UserStats, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[stats.UserID] = statsEntry{stats: stats, expires: now.Add(c.ttl)}
+}
+
+// GetUserStats handles GET /api/users/{id}/stats. The draft-inclusive
+// post_count is only sent to the user themselves and admins.
+func (h *UserHandler) GetUserStats(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	query := newQueryParams(r)
+	loc := query.timezone()
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	stats, ok := h.stats.get(id, h.clock.Now())
+	if !ok {
+		// Create context with timeout
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		fresh, err := h.db.GetUserStats(ctx, id)
+		if err != nil {
+			handleDatabaseError(w, r, err, "get user stats")
+			return
+		}
+		stats = *fresh
+		h.stats.put(stats, h.clock.Now())
+	}
+
+	viewerID, _ := userIDFromContext(r)
+	ownStats := viewerID == id || isAdmin(r)
+	if !ownStats {
+		stats.PostCount = nil
+	}
+
+	// Shared caches may only keep responses anyone could have been sent
+	visibility := "public"
+	if ownStats || h.cfg.RequireAuthForReads {
+		visibility = "private"
+	}
+
+	stats.JoinedAt = stats.JoinedAt.In(loc)
+	w.Header().Add("Vary", "Authorization")
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, int(statsCacheTTL.Seconds())))
+	writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"blog-api/internal/clock"
+	"blog-api/internal/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsCacheExpiry(t *testing.T) {
+	cache := newStatsCache(time.Minute)
+	now := time.Now()
+
+	cache.put(models.UserStats{UserID: 1, PublishedCount: 3}, now)
+
+	stats, ok := cache.get(1, now.Add(30*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 3, stats.PublishedCount)
+
+	_, ok = cache.get(1, now.Add(2*time.Minute))
+	assert.False(t, ok)
+
+	_, ok = cache.get(2, now)
+	assert.False(t, ok)
+}
+
+func TestGetUserStatsServedFromCache(t *testing.T) {
+	handler := NewUserHandler(newUnreachableDB(t), nil, newTestConfig())
+	handler.stats.put(models.UserStats{UserID: 5, PublishedCount: 2, TotalViews: 40}, time.Now())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/5/stats", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	rec := httptest.NewRecorder()
+
+	handler.GetUserStats(rec, req)
+
+	// The unreachable database is never queried for cached stats
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"total_views":40`)
+	assert.Equal(t, "public, max-age=30", rec.Header().Get("Cache-Control"))
+}
+
+func TestGetUserStatsDraftCount(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JWTSecret = "secret"
+	cfg.AdminToken = "admin-token"
+	handler := NewUserHandler(newUnreachableDB(t), nil, cfg)
+	postCount := 5
+	handler.stats.put(models.UserStats{UserID: 7, PostCount: &postCount, PublishedCount: 3}, time.Now())
+
+	token, _, err := issueToken(cfg, &models.User{ID: 7, Username: "alice"}, time.Now())
+	require.NoError(t, err)
+	other, _, err := issueToken(cfg, &models.User{ID: 8, Username: "bob"}, time.Now())
+	require.NoError(t, err)
+
+	get := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/users/7/stats", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "7"})
+		if authorization != "" {
+			req.Header.Set("Authorization", "Bearer "+authorization)
+		}
+		rec := httptest.NewRecorder()
+		OptionalAuthMiddleware(cfg.AdminToken, cfg.JWTSecret, nil)(http.HandlerFunc(handler.GetUserStats)).ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec
+	}
+
+	// Everyone else only learns the published count, and may share it
+	for _, authorization := range []string{"", other} {
+		rec := get(authorization)
+		assert.NotContains(t, rec.Body.String(), "post_count")
+		assert.Contains(t, rec.Body.String(), `"published_count":3`)
+		assert.Equal(t, "public, max-age=30", rec.Header().Get("Cache-Control"))
+	}
+
+	// The author and admins also see drafts, privately
+	for _, authorization := range []string{token, cfg.AdminToken} {
+		rec := get(authorization)
+		assert.Contains(t, rec.Body.String(), `"post_count":5`)
+		assert.Equal(t, "private, max-age=30", rec.Header().Get("Cache-Control"))
+	}
+
+	// Responses that needed a token never go to shared caches
+	cfg.RequireAuthForReads = true
+	rec := get(other)
+	assert.NotContains(t, rec.Body.String(), "post_count")
+	assert.Equal(t, "private, max-age=30", rec.Header().Get("Cache-Control"))
+}
+
+func TestGetUserStatsRefetchesAfterTTL(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC))
+	handler := NewUserHandler(newUnreachableDB(t), nil, newTestConfig())
+	handler.clock = fake
+	handler.stats.put(models.UserStats{UserID: 5, PublishedCount: 2}, fake.Now())
+
+	get := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/users/5/stats", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "5"})
+		rec := httptest.NewRecorder()
+		handler.GetUserStats(rec, req)
+		return rec.Code
+	}
+
+	fake.Advance(statsCacheTTL - time.Second)
+	assert.Equal(t, http.StatusOK, get())
+
+	// Once the entry expires the unreachable database is queried and fails
+	fake.Advance(2 * time.Second)
+	assert.NotEqual(t, http.StatusOK, get())
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"blog-api/internal/database"
+	"blog-api/internal/events"
+	"blog-api/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// SetPostTags handles PUT /api/posts/{id}/tags, replacing all of a post's
+// tags with {"tags": [...]} at once
+func (h *PostHandler) SetPostTags(w http.ResponseWriter, r *http.Request) {
+	var req models.TagsRequest
+	if err := parseJSON(r, &req, h.cfg); err != nil {
+		writeInvalidJSON(w, err)
+		return
+	}
+	if err := ValidateTagsRequest(&req); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	h.changePostTags(w, r, func(ctx context.Context, id int) ([]string, error) {
+		return h.db.SetPostTags(ctx, id, req.Tags)
+	})
+}
+
+// AddPostTag handles POST /api/posts/{id}/tags/{tag}. Adding a tag the post
+// already has changes nothing.
+func (h *PostHandler) AddPostTag(w http.ResponseWriter, r *http.Request) {
+	tags := []string{mux.Vars(r)["tag"]}
+	if errs := validateTags(tags); len(errs) > 0 {
+		writeValidationError(w, ValidationErrors{Errors: errs})
+		return
+	}
+
+	h.changePostTags(w, r, func(ctx context.Context, id int) ([]string, error) {
+		return h.db.AddPostTag(ctx, id, tags[0])
+	})
+}
+
+// RemovePostTag handles DELETE /api/posts/{id}/tags/{tag}. Removing a tag
+// the post doesn't have changes nothing.
+func (h *PostHandler) RemovePostTag(w http.ResponseWriter, r *http.Request) {
+	tag := mux.Vars(r)["tag"]
+	h.changePostTags(w, r, func(ctx context.Context, id int) ([]string, error) {
+		return h.db.RemovePostTag(ctx, id, tag)
+	})
+}
+
+// changePostTags applies change to the tags of the post in the URL once the
+// caller is known to be its author or an admin, and responds with the post
+func (h *PostHandler) changePostTags(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, id int) ([]string, error)) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	post, err := h.db.GetPostByID(ctx, id)
+	if err != nil {
+		handleDatabaseError(w, r, err, "get post")
+		return
+	}
+	if !canChangePost(r, post) {
+		writePostForbidden(w, post, "Only the author can change a post's tags")
+		return
+	}
+
+	tags, err := change(ctx, id)
+	if err != nil {
+		if errors.Is(err, database.ErrTooManyTags) {
+			writeError(w, http.StatusConflict, fmt.Sprintf("A post can have no more than %d tags", models.MaxPostTags))
+			return
+		}
+		handleDatabaseError(w, r, err, "change post tags")
+		return
+	}
+
+	loggerFromContext(r).Info().Int("post_id", post.ID).Strs("tags", tags).Msg("Post tags changed")
+	post.Tags = tags
+	h.events.Publish(events.PostUpdated{Post: *post})
+	writeJSON(w, http.StatusOK, post)
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeParamFormats lists the formats parseTimeParam accepts, for error messages
+const timeParamFormats = "RFC3339 (2006-01-02T15:04:05Z), YYYY-MM-DD, or unix seconds"
+
+// parseTimeParam parses a timestamp given by a client in a filter or
+// schedule. Dates without a time are midnight UTC, and unix seconds are
+// whole seconds since the epoch.
+func parseTimeParam(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected %s", value, timeParamFormats)
+}
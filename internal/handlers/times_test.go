@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeParam(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Time
+	}{
+		{"RFC3339", "2024-03-10T15:04:05Z", time.Date(2024, 3, 10, 15, 4, 5, 0, time.UTC)},
+		{"RFC3339 with offset", "2024-03-10T10:04:05-05:00", time.Date(2024, 3, 10, 15, 4, 5, 0, time.UTC)},
+		{"date", "2024-03-10", time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{"unix seconds", "1710083045", time.Date(2024, 3, 10, 15, 4, 5, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTimeParam(tt.value)
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "got %s", got)
+		})
+	}
+
+	t.Run("rejects other formats", func(t *testing.T) {
+		for _, value := range []string{"", "yesterday", "10/03/2024", "2024-03-10 15:04:05"} {
+			_, err := parseTimeParam(value)
+			require.Error(t, err, value)
+			assert.Contains(t, err.Error(), timeParamFormats)
+		}
+	})
+}
+
+func TestResetViewCountsInvalidTimes(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	body := `{"created_after":"last week","created_before":"2024-13-01"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/posts/reset-views", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handler.ResetViewCounts(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	details := validationDetails(t, rec)
+	assert.Len(t, details, 2)
+	assert.Contains(t, details["created_after"], timeParamFormats)
+	assert.Contains(t, details["created_before"], timeParamFormats)
+}
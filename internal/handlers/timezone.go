@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	// Embedded zone database so ?tz= works on hosts without /usr/share/zoneinfo
+	_ "time/tzdata"
+
+	"blog-api/internal/models"
+)
+
+// parseTimezone reads the optional ?tz= query parameter. Timestamps default to UTC.
+func parseTimezone(r *http.Request) (*time.Location, error) {
+	name := r.URL.Query().Get("tz")
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	// "Local" would depend on the server's own zone, so it isn't accepted
+	if name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+
+	return loc, nil
+}
+
+// postsInLocation converts the timestamps of posts to the given location
+func postsInLocation(posts []models.Post, loc *time.Location) {
+	for i := range posts {
+		posts[i].CreatedAt = posts[i].CreatedAt.In(loc)
+	}
+}
+
+// usersInLocation converts the timestamps of users to the given location
+func usersInLocation(users []models.User, loc *time.Location) {
+	for i := range users {
+		users[i].CreatedAt = users[i].CreatedAt.In(loc)
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"blog-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimezone(t *testing.T) {
+	t.Run("defaults to UTC", func(t *testing.T) {
+		loc, err := parseTimezone(httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+		require.NoError(t, err)
+		assert.Equal(t, time.UTC, loc)
+	})
+
+	t.Run("valid zone converts timestamps", func(t *testing.T) {
+		loc, err := parseTimezone(httptest.NewRequest(http.MethodGet, "/api/posts?tz=America/New_York", nil))
+		require.NoError(t, err)
+
+		posts := []models.Post{{ID: 1, CreatedAt: time.Date(2024, 1, 15, 17, 0, 0, 0, time.UTC)}}
+		postsInLocation(posts, loc)
+
+		encoded, err := json.Marshal(posts[0].CreatedAt)
+		require.NoError(t, err)
+		assert.Equal(t, `"2024-01-15T12:00:00-05:00"`, string(encoded))
+	})
+
+	t.Run("rejects unknown zones", func(t *testing.T) {
+		for _, tz := range []string{"Mars/Olympus_Mons", "Local"} {
+			_, err := parseTimezone(httptest.NewRequest(http.MethodGet, "/api/posts?tz="+tz, nil))
+			assert.Error(t, err, tz)
+		}
+	})
+}
+
+func TestGetAllPostsInvalidTimezone(t *testing.T) {
+	handler := NewPostHandler(newUnreachableDB(t), nil, newTestConfig())
+
+	rec := httptest.NewRecorder()
+	handler.GetAllPosts(rec, httptest.NewRequest(http.MethodGet, "/api/posts?tz=Not/AZone", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Not/AZone")
+}
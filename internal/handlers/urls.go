@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"blog-api/internal/config"
+)
+
+// requestScheme reports whether r arrived over http or https. Behind a
+// TLS-terminating proxy r.TLS is nil, so X-Forwarded-Proto is honored, but
+// only when the request came directly from a trusted proxy.
+func requestScheme(r *http.Request, cfg *config.Config) string {
+	if fromTrustedProxy(r, cfg) {
+		proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+		switch proto = strings.ToLower(strings.TrimSpace(proto)); proto {
+		case "http", "https":
+			return proto
+		}
+	}
+
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// publicBaseURL returns the scheme and host to use in generated links,
+// preferring the configured PublicBaseURL
+func publicBaseURL(r *http.Request, cfg *config.Config) string {
+	if cfg.PublicBaseURL != "" {
+		return strings.TrimSuffix(cfg.PublicBaseURL, "/")
+	}
+	return requestScheme(r, cfg) + "://" + r.Host
+}
+
+// clientIP returns the address of the client that sent r. Behind trusted
+// proxies that is the last X-Forwarded-For entry not added by one of them;
+// earlier entries were sent by the client and can't be trusted.
+func clientIP(r *http.Request, cfg *config.Config) string {
+	ip := remoteIP(r)
+	if !fromTrustedProxy(r, cfg) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		ip = addr.Unmap().String()
+		if !cfg.TrustsProxy(addr) {
+			break
+		}
+	}
+	return ip
+}
+
+// fromTrustedProxy reports whether the peer that sent r is a trusted proxy
+func fromTrustedProxy(r *http.Request, cfg *config.Config) bool {
+	if len(cfg.TrustedProxies) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	return cfg.TrustsProxy(addr)
+}
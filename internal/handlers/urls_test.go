@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"blog-api/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestScheme(t *testing.T) {
+	trusting := &config.Config{TrustedProxies: []string{"10.0.0.0/8"}}
+
+	newRequest := func(remoteAddr, proto string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+		req.RemoteAddr = remoteAddr
+		if proto != "" {
+			req.Header.Set("X-Forwarded-Proto", proto)
+		}
+		return req
+	}
+
+	t.Run("forwarded proto from trusted proxy", func(t *testing.T) {
+		assert.Equal(t, "https", requestScheme(newRequest("10.0.0.7:4321", "https"), trusting))
+		assert.Equal(t, "https", requestScheme(newRequest("10.0.0.7:4321", "HTTPS, http"), trusting))
+	})
+
+	t.Run("forwarded proto from untrusted peer is ignored", func(t *testing.T) {
+		assert.Equal(t, "http", requestScheme(newRequest("203.0.113.9:4321", "https"), trusting))
+		assert.Equal(t, "http", requestScheme(newRequest("10.0.0.7:4321", "https"), &config.Config{}))
+	})
+
+	t.Run("direct TLS", func(t *testing.T) {
+		req := newRequest("203.0.113.9:4321", "")
+		req.TLS = &tls.ConnectionState{}
+		assert.Equal(t, "https", requestScheme(req, trusting))
+	})
+
+	t.Run("unknown forwarded proto falls back", func(t *testing.T) {
+		assert.Equal(t, "http", requestScheme(newRequest("10.0.0.7:4321", "gopher"), trusting))
+	})
+}
+
+func TestPublicBaseURL(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://blog.internal:8080/api/posts", nil)
+
+	assert.Equal(t, "http://blog.internal:8080", publicBaseURL(req, &config.Config{}))
+
+	// An explicit override wins over anything derived from the request
+	override := &config.Config{PublicBaseURL: "https://blog.example.com/", TrustedProxies: []string{"0.0.0.0/0"}}
+	req.Header.Set("X-Forwarded-Proto", "http")
+	assert.Equal(t, "https://blog.example.com", publicBaseURL(req, override))
+}
+
+func TestClientIP(t *testing.T) {
+	trusting := &config.Config{TrustedProxies: []string{"10.0.0.0/8"}}
+
+	newRequest := func(remoteAddr string, forwardedFor ...string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+		req.RemoteAddr = remoteAddr
+		for _, value := range forwardedFor {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		cfg  *config.Config
+		want string
+	}{
+		{name: "direct", req: newRequest("203.0.113.9:4321"), cfg: trusting, want: "203.0.113.9"},
+		{name: "untrusted peer's header is ignored", req: newRequest("203.0.113.9:4321", "198.51.100.4"), cfg: trusting, want: "203.0.113.9"},
+		{name: "no trusted proxies", req: newRequest("10.0.0.7:4321", "198.51.100.4"), cfg: &config.Config{}, want: "10.0.0.7"},
+		{name: "trusted proxy", req: newRequest("10.0.0.7:4321", "198.51.100.4"), cfg: trusting, want: "198.51.100.4"},
+		{name: "spoofed entries before the client", req: newRequest("10.0.0.7:4321", "1.2.3.4, 198.51.100.4, 10.0.0.3"), cfg: trusting, want: "198.51.100.4"},
+		{name: "several headers", req: newRequest("10.0.0.7:4321", "1.2.3.4", "198.51.100.4"), cfg: trusting, want: "198.51.100.4"},
+		{name: "garbage stops the walk", req: newRequest("10.0.0.7:4321", "198.51.100.4, unknown"), cfg: trusting, want: "10.0.0.7"},
+		{name: "trusted proxy without header", req: newRequest("10.0.0.7:4321"), cfg: trusting, want: "10.0.0.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, clientIP(tt.req, tt.cfg))
+		})
+	}
+}
@@ -2,35 +2,44 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
+	"blog-api/internal/clock"
+	"blog-api/internal/config"
 	"blog-api/internal/database"
+	"blog-api/internal/events"
 	"blog-api/internal/models"
-
-	"github.com/rs/zerolog/log"
 )
 
 // UserHandler handles user-related HTTP requests
 type UserHandler struct {
-	db *database.DB
+	db     *database.DB
+	events *events.Bus
+	cfg    *config.Config
+	stats  *statsCache
+	clock  clock.Clock
 }
 
-// NewUserHandler creates a new user handler
-func NewUserHandler(db *database.DB) *UserHandler {
-	return &UserHandler{db: db}
+// NewUserHandler creates a new user handler that publishes user changes to bus
+func NewUserHandler(db *database.DB, bus *events.Bus, cfg *config.Config) *UserHandler {
+	return &UserHandler{db: db, events: bus, cfg: cfg, stats: newStatsCache(statsCacheTTL), clock: clock.Real{}}
 }
 
 // CreateUser handles POST /users
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var req models.UserRequest
-	if err := parseJSON(r, &req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid JSON payload")
+	if err := parseJSON(r, &req, h.cfg); err != nil {
+		writeInvalidJSON(w, err)
 		return
 	}
 
-	// Validate the request
-	if err := ValidateUserRequest(&req); err != nil {
+	// Validate the request body and query together
+	query := newQueryParams(r)
+	loc := query.timezone()
+	if err := mergeValidationErrors(ValidateUserRequest(&req), query.err()); err != nil {
 		writeValidationError(w, err)
 		return
 	}
@@ -42,30 +51,42 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	// Create the user
 	user, err := h.db.CreateUser(ctx, &req)
 	if err != nil {
-		handleDatabaseError(w, err, "create user")
+		handleDatabaseError(w, r, err, "create user")
 		return
 	}
 
-	log.Info().Int("user_id", user.ID).Str("username", user.Username).Msg("User created successfully")
+	loggerFromContext(r).Info().Int("user_id", user.ID).Str("username", user.Username).Msg("User created successfully")
+	h.events.Publish(events.UserCreated{User: *user})
+	user.CreatedAt = user.CreatedAt.In(loc)
+	w.Header().Set("Location", fmt.Sprintf("%s/api/users/%d", publicBaseURL(r, h.cfg), user.ID))
 	writeJSON(w, http.StatusCreated, user)
 }
 
 // GetAllUsers handles GET /users
 func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
+	query := newQueryParams(r)
+	loc := query.timezone()
+	fields := query.fields(userFields, h.cfg.SparseFieldsLimit)
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	users, err := h.db.GetAllUsers(ctx)
 	if err != nil {
-		handleDatabaseError(w, err, "get all users")
+		handleDatabaseError(w, r, err, "get all users")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, users)
+	usersInLocation(users, loc)
+	writeList(w, users, len(users), fields, h.cfg.EmptyListNoContent)
 }
 
-// GetUser handles GET /users/{id}
+// GetUser handles GET and HEAD /users/{id}
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDFromURL(r, "id")
 	if err != nil {
@@ -73,20 +94,30 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query := newQueryParams(r)
+	loc := query.timezone()
+	fields := query.fields(userFields, h.cfg.SparseFieldsLimit)
+	if err := query.err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
 	user, err := h.db.GetUserByID(ctx, id)
 	if err != nil {
-		handleDatabaseError(w, err, "get user")
+		handleDatabaseError(w, r, err, "get user")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, user)
+	user.CreatedAt = user.CreatedAt.In(loc)
+	writeResource(w, r, user, fields)
 }
 
-// UpdateUser handles PUT /users/{id}
+// UpdateUser handles PUT /users/{id}. Only the account's owner or an admin
+// may call it.
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDFromURL(r, "id")
 	if err != nil {
@@ -94,20 +125,27 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !canChangeUser(r, id) {
+		writeError(w, http.StatusForbidden, "Only the account owner can update it")
+		return
+	}
+
 	var req models.UserRequest
-	if err := parseJSON(r, &req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid JSON payload")
+	if err := parseJSON(r, &req, h.cfg); err != nil {
+		writeInvalidJSON(w, err)
 		return
 	}
 
-	// Validate the request (for updates, fields are optional)
-	if err := ValidateUserUpdateRequest(&req); err != nil {
+	// Validate the request body (for updates, fields are optional) and query together
+	query := newQueryParams(r)
+	loc := query.timezone()
+	if err := mergeValidationErrors(ValidateUserUpdateRequest(&req), query.err()); err != nil {
 		writeValidationError(w, err)
 		return
 	}
 
 	// Check if at least one field is provided for update
-	if req.Username == "" && req.Email == "" && req.Password == "" {
+	if req.Username == "" && req.Email == "" {
 		writeError(w, http.StatusBadRequest, "At least one field must be provided for update")
 		return
 	}
@@ -118,15 +156,83 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.db.UpdateUser(ctx, id, &req)
 	if err != nil {
-		handleDatabaseError(w, err, "update user")
+		handleDatabaseError(w, r, err, "update user")
 		return
 	}
 
-	log.Info().Int("user_id", user.ID).Str("username", user.Username).Msg("User updated successfully")
+	loggerFromContext(r).Info().Int("user_id", user.ID).Str("username", user.Username).Msg("User updated successfully")
+	user.CreatedAt = user.CreatedAt.In(loc)
 	writeJSON(w, http.StatusOK, user)
 }
 
-// DeleteUser handles DELETE /users/{id}
+// ChangePassword handles POST /users/{id}/password. Users must prove they know
+// their current password; admins can set a new one without it.
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	admin := isAdmin(r)
+	if !canChangeUser(r, id) {
+		writeError(w, http.StatusForbidden, "Only the account owner can change its password")
+		return
+	}
+
+	var req models.PasswordChangeRequest
+	if err := parseJSON(r, &req, h.cfg); err != nil {
+		writeInvalidJSON(w, err)
+		return
+	}
+
+	if err := ValidatePasswordChangeRequest(&req, !admin); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.db.GetUserByID(ctx, id)
+	if err != nil {
+		handleDatabaseError(w, r, err, "get user")
+		return
+	}
+
+	if !admin {
+		if _, err := h.db.VerifyPassword(ctx, user.Username, req.CurrentPassword); err != nil {
+			switch {
+			case errors.Is(err, database.ErrInvalidPassword):
+				writeError(w, http.StatusUnauthorized, "Current password is incorrect")
+			case errors.Is(err, database.ErrUserBanned):
+				writeError(w, http.StatusForbidden, "User is banned")
+			default:
+				handleDatabaseError(w, r, err, "verify password")
+			}
+			return
+		}
+	}
+
+	hash, err := database.HashPassword(req.NewPassword)
+	if err != nil {
+		loggerFromContext(r).Error().Err(err).Int("user_id", id).Msg("Failed to hash password")
+		writeError(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	if err := h.db.UpdatePassword(ctx, id, hash); err != nil {
+		handleDatabaseError(w, r, err, "update password")
+		return
+	}
+
+	loggerFromContext(r).Info().Int("user_id", id).Bool("admin", admin).Msg("User password changed")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteUser handles DELETE /users/{id}. Only the account's owner or an admin
+// may call it.
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDFromURL(r, "id")
 	if err != nil {
@@ -134,16 +240,82 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !canChangeUser(r, id) {
+		writeError(w, http.StatusForbidden, "Only the account owner can delete it")
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// With If-Match, only delete the user as the client last saw it: its full
+	// representation in UTC, as GET sends it without ?fields= or ?tz=
+	if r.Header.Get("If-Match") != "" {
+		err = h.db.DeleteUserIf(ctx, id, func(user *models.User) bool {
+			user.CreatedAt = user.CreatedAt.In(time.UTC)
+			return ifMatch(r, resourceETag(user))
+		})
+	} else {
+		err = h.db.DeleteUser(ctx, id)
+	}
+	if errors.Is(err, database.ErrPreconditionFailed) {
+		writeError(w, http.StatusPreconditionFailed, "User has changed since it was last read")
+		return
+	}
+	if errors.Is(err, database.ErrUserNotFound) && !h.cfg.DeleteMissingNotFound {
+		// Already gone, most likely a retry of a delete that succeeded
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		handleDatabaseError(w, r, err, "delete user")
+		return
+	}
+
+	loggerFromContext(r).Info().Int("user_id", id).Msg("User deleted successfully")
+	h.events.Publish(events.UserDeleted{UserID: id})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BanUser handles POST /api/users/{id}/ban
+func (h *UserHandler) BanUser(w http.ResponseWriter, r *http.Request) {
+	h.setBanned(w, r, true)
+}
+
+// UnbanUser handles POST /api/users/{id}/unban
+func (h *UserHandler) UnbanUser(w http.ResponseWriter, r *http.Request) {
+	h.setBanned(w, r, false)
+}
+
+// setBanned updates the ban status of the user in the URL
+func (h *UserHandler) setBanned(w http.ResponseWriter, r *http.Request, banned bool) {
+	id, err := parseIDFromURL(r, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	err = h.db.DeleteUser(ctx, id)
+	user, err := h.db.SetUserBanned(ctx, id, banned)
 	if err != nil {
-		handleDatabaseError(w, err, "delete user")
+		handleDatabaseError(w, r, err, "set user banned")
 		return
 	}
 
-	log.Info().Int("user_id", id).Msg("User deleted successfully")
-	writeSuccess(w, "User deleted successfully", nil)
+	loggerFromContext(r).Info().Int("user_id", user.ID).Bool("banned", user.Banned).Msg("User ban status updated")
+	writeJSON(w, http.StatusOK, user)
+}
+
+// canChangeUser reports whether the caller may change the account with the
+// given id: its owner or an admin
+func canChangeUser(r *http.Request, id int) bool {
+	if isAdmin(r) {
+		return true
+	}
+	userID, ok := userIDFromContext(r)
+	return ok && userID == id
 }
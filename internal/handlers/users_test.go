@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"blog-api/internal/models"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateAndDeleteUserOnlyOwner(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JWTSecret = "secret"
+	cfg.AdminToken = "admin"
+	token, _, err := issueToken(cfg, &models.User{ID: 7, Username: "alice"}, time.Now())
+	require.NoError(t, err)
+
+	handler := NewUserHandler(newUnreachableDB(t), nil, cfg)
+	tests := []struct {
+		name          string
+		method        string
+		userID        string
+		authorization string
+		body          string
+		want          int
+	}{
+		{name: "update someone else's account", method: http.MethodPut, userID: "8", authorization: "Bearer " + token, body: `{"username":"mallory"}`, want: http.StatusForbidden},
+		{name: "delete someone else's account", method: http.MethodDelete, userID: "8", authorization: "Bearer " + token, want: http.StatusForbidden},
+		{name: "owner update reaches the database", method: http.MethodPut, userID: "7", authorization: "Bearer " + token, body: `{"username":"alice2"}`, want: http.StatusInternalServerError},
+		{name: "owner delete reaches the database", method: http.MethodDelete, userID: "7", authorization: "Bearer " + token, want: http.StatusInternalServerError},
+		{name: "admin update reaches the database", method: http.MethodPut, userID: "8", authorization: "Bearer admin", body: `{"username":"renamed"}`, want: http.StatusInternalServerError},
+		{name: "admin delete reaches the database", method: http.MethodDelete, userID: "8", authorization: "Bearer admin", want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/users/"+tt.userID, strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.userID})
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", tt.authorization)
+			rec := httptest.NewRecorder()
+
+			next := handler.UpdateUser
+			if tt.method == http.MethodDelete {
+				next = handler.DeleteUser
+			}
+			AuthOrAdminMiddleware(cfg.AdminToken, cfg.JWTSecret, nil)(http.HandlerFunc(next)).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestChangePasswordOnlyOwner(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.JWTSecret = "secret"
+	cfg.AdminToken = "admin"
+	token, _, err := issueToken(cfg, &models.User{ID: 7, Username: "alice"}, time.Now())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		userID        string
+		authorization string
+		body          string
+		want          int
+	}{
+		{name: "someone else's account", userID: "8", authorization: "Bearer " + token, body: `{"current_password":"old-secret","new_password":"new-secret"}`, want: http.StatusForbidden},
+		{name: "owner without current password", userID: "7", authorization: "Bearer " + token, body: `{"new_password":"new-secret"}`, want: http.StatusBadRequest},
+		{name: "owner with short password", userID: "7", authorization: "Bearer " + token, body: `{"current_password":"old-secret","new_password":"123"}`, want: http.StatusBadRequest},
+		{name: "admin with short password", userID: "8", authorization: "Bearer admin", body: `{"new_password":"123"}`, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(newUnreachableDB(t), nil, cfg)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/users/"+tt.userID+"/password", strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": tt.userID})
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", tt.authorization)
+			rec := httptest.NewRecorder()
+
+			AuthOrAdminMiddleware(cfg.AdminToken, cfg.JWTSecret, nil)(http.HandlerFunc(handler.ChangePassword)).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
@@ -1,10 +1,21 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"blog-api/internal/config"
+	"blog-api/internal/database"
 	"blog-api/internal/models"
 
 	"github.com/gorilla/mux"
@@ -15,12 +26,97 @@ import (
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	
+
 	if err := json.NewEncoder(w).Encode(data); err != nil {
 		log.Error().Err(err).Msg("Failed to encode JSON response")
 	}
 }
 
+// writeResource writes a single resource as JSON, keeping only the given
+// fields when fields is non-nil. The body is encoded up front so the response
+// carries an ETag and Content-Length; HEAD requests get those headers without
+// the body.
+func writeResource(w http.ResponseWriter, r *http.Request, data interface{}, fields []string) {
+	if fields != nil {
+		projected, err := selectFields(data, fields)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to encode response")
+			return
+		}
+		data = projected
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		loggerFromContext(r).Error().Err(err).Msg("Failed to encode JSON response")
+		writeError(w, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+	body = append(body, '\n')
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("ETag", bodyETag(body))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(body)
+}
+
+// bodyETag returns the strong ETag for a response body
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// resourceETag returns the ETag writeResource sends for data in full, or ""
+// if data can't be encoded
+func resourceETag(data interface{}) string {
+	body, err := json.Marshal(data)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode resource for ETag")
+		return ""
+	}
+	return bodyETag(append(body, '\n'))
+}
+
+// ifMatch reports whether etag satisfies r's If-Match header, which lists
+// the ETags the client is willing to act on or is "*" for any. Weak ETags
+// never match, as If-Match uses strong comparison.
+func ifMatch(r *http.Request, etag string) bool {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "*" {
+		return true
+	}
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimSpace(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModifiedSince reports whether r carries an If-Modified-Since no older
+// than lastModified. HTTP dates have whole-second precision, so lastModified
+// is truncated before comparing.
+func notModifiedSince(r *http.Request, lastModified time.Time) bool {
+	header := r.Header.Get("If-Modified-Since")
+	if header == "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+
+	since, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
 // writeError writes an error response
 func writeError(w http.ResponseWriter, status int, message string) {
 	response := models.ErrorResponse{
@@ -54,6 +150,16 @@ func writeSuccess(w http.ResponseWriter, message string, data interface{}) {
 	writeJSON(w, http.StatusOK, response)
 }
 
+// OptionsHandler answers OPTIONS requests with 204 and an Allow header
+// listing the methods supported by the route
+func OptionsHandler(methods ...string) http.HandlerFunc {
+	allow := strings.Join(append(methods, http.MethodOptions), ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
 // parseIDFromURL extracts and validates an ID from the URL path
 func parseIDFromURL(r *http.Request, paramName string) (int, error) {
 	vars := mux.Vars(r)
@@ -74,24 +180,174 @@ func parseIDFromURL(r *http.Request, paramName string) (int, error) {
 	return id, nil
 }
 
-// parseJSON parses JSON from request body
-func parseJSON(r *http.Request, dst interface{}) error {
+// errEmptyJSON reports a request without a body, or with only whitespace
+var errEmptyJSON = errors.New("request body is empty")
+
+// errTrailingJSON reports a request body with more after its JSON value
+var errTrailingJSON = errors.New("JSON payload must contain a single value")
+
+// jsonLimitError reports a request body that exceeds the configured JSON
+// nesting depth or element count
+type jsonLimitError struct {
+	message string
+}
+
+func (e *jsonLimitError) Error() string {
+	return e.message
+}
+
+// parseJSON parses JSON from request body, rejecting bodies larger than
+// cfg.MaxRequestBytes, payloads nested deeper or holding more elements than
+// cfg allows, keys dst has no field for, and unless cfg allows it, bodies
+// with content after the JSON value
+func parseJSON(r *http.Request, dst interface{}, cfg *config.Config) error {
 	if r.Body == nil {
-		return http.ErrMissingFile
+		return errEmptyJSON
 	}
-	
+
 	defer r.Body.Close()
-	return json.NewDecoder(r.Body).Decode(dst)
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, cfg.MaxRequestBytes))
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return errEmptyJSON
+	}
+
+	if err := checkJSONLimits(body, cfg.MaxJSONDepth, cfg.MaxJSONElements); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return err
+	}
+
+	// Anything but whitespace after the value could be a second payload
+	// hidden from whatever inspected only the first one
+	if !cfg.AllowTrailingJSON {
+		if _, err := dec.Token(); err != io.EOF {
+			return errTrailingJSON
+		}
+	}
+	return nil
+}
+
+// checkJSONLimits scans the first JSON value in body token by token, so
+// pathological nesting is caught without the recursion of a full decode.
+// Object keys, values and array items each count as an element. Syntax
+// errors are left for the decode that follows to report.
+func checkJSONLimits(body []byte, maxDepth, maxElements int) error {
+	if maxDepth <= 0 && maxElements <= 0 {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	depth, elements := 0, 0
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+
+		switch token {
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		case json.Delim('{'), json.Delim('['):
+			depth++
+			if maxDepth > 0 && depth > maxDepth {
+				return &jsonLimitError{fmt.Sprintf("JSON payload is nested more than %d levels deep", maxDepth)}
+			}
+			fallthrough
+		default:
+			elements++
+			if maxElements > 0 && elements > maxElements {
+				return &jsonLimitError{fmt.Sprintf("JSON payload has more than %d elements", maxElements)}
+			}
+		}
+
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// writeInvalidJSON writes the 400, or 413 for an oversized body, for a
+// request body parseJSON rejected
+func writeInvalidJSON(w http.ResponseWriter, err error) {
+	var sizeErr *http.MaxBytesError
+	if errors.As(err, &sizeErr) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not be larger than %d bytes", sizeErr.Limit))
+		return
+	}
+	// The decoder has no error type for these, only its message
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		writeError(w, http.StatusBadRequest, "Unknown field "+field)
+		return
+	}
+	var limitErr *jsonLimitError
+	if errors.As(err, &limitErr) {
+		writeError(w, http.StatusBadRequest, limitErr.Error())
+		return
+	}
+	if errors.Is(err, errEmptyJSON) {
+		writeError(w, http.StatusBadRequest, "Request body is empty")
+		return
+	}
+	if errors.Is(err, errTrailingJSON) {
+		writeError(w, http.StatusBadRequest, errTrailingJSON.Error())
+		return
+	}
+	writeError(w, http.StatusBadRequest, "Invalid JSON payload")
 }
 
 // handleDatabaseError converts database errors to appropriate HTTP responses
-func handleDatabaseError(w http.ResponseWriter, err error, operation string) {
-	log.Error().Err(err).Str("operation", operation).Msg("Database operation failed")
-	
+func handleDatabaseError(w http.ResponseWriter, r *http.Request, err error, operation string) {
+	// The client went away before we could answer; there is nobody to write to
+	if isClientCanceled(r, err) {
+		loggerFromContext(r).Debug().Err(err).Str("operation", operation).Msg("Request canceled by client")
+		return
+	}
+
+	// Our own deadline expired, which is a server-side problem worth surfacing
+	if errors.Is(err, context.DeadlineExceeded) {
+		loggerFromContext(r).Warn().Err(err).Str("operation", operation).Msg("Database operation timed out")
+		writeError(w, http.StatusGatewayTimeout, "Request timed out")
+		return
+	}
+
+	// Constraint violations are bad input that slipped past validation
+	if violation, ok := database.AsConstraintViolation(err); ok {
+		loggerFromContext(r).Warn().Err(err).Str("operation", operation).Msg("Database constraint violated")
+		field := violation.Column
+		if field == "" {
+			field = violation.Constraint
+		}
+		writeValidationError(w, ValidationErrors{Errors: []ValidationError{{
+			Field:   field,
+			Message: violation.Error(),
+		}}})
+		return
+	}
+
+	// A username or email someone else has is for the client to change
+	if message, ok := duplicateUserMessage(err); ok {
+		loggerFromContext(r).Info().Err(err).Str("operation", operation).Msg("Duplicate user field")
+		writeError(w, http.StatusConflict, message)
+		return
+	}
+
+	loggerFromContext(r).Error().Err(err).Str("operation", operation).Msg("Database operation failed")
+
 	errMsg := err.Error()
-	
+
 	// Check for common error patterns
 	switch {
+	case errors.Is(err, database.ErrDuplicate):
+		writeError(w, http.StatusConflict, "Resource already exists")
+	case errors.Is(err, database.ErrForeignKey):
+		writeError(w, http.StatusBadRequest, "Invalid reference to related resource")
 	case contains(errMsg, "not found"):
 		writeError(w, http.StatusNotFound, "Resource not found")
 	case contains(errMsg, "duplicate") || contains(errMsg, "unique"):
@@ -105,9 +361,29 @@ func handleDatabaseError(w http.ResponseWriter, err error, operation string) {
 	}
 }
 
+// duplicateUserMessage returns the 409 message naming the user field err
+// clashed on, if it is a duplicate username or email
+func duplicateUserMessage(err error) (string, bool) {
+	switch {
+	case errors.Is(err, database.ErrDuplicateUsername):
+		return "Username is already taken", true
+	case errors.Is(err, database.ErrDuplicateEmail):
+		return "Email is already registered", true
+	}
+	return "", false
+}
+
+// isClientCanceled reports whether err was caused by the client disconnecting
+func isClientCanceled(r *http.Request, err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return true
+	}
+	return errors.Is(r.Context().Err(), context.Canceled)
+}
+
 // contains checks if a string contains a substring (case-insensitive)
 func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || 
+	return len(s) >= len(substr) && (s == substr ||
 		(len(s) > len(substr) && containsHelper(s, substr)))
 }
 
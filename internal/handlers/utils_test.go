@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"blog-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteResourceHead(t *testing.T) {
+	post := models.Post{ID: 1, Title: "Hello", Content: "World"}
+
+	getRec := httptest.NewRecorder()
+	writeResource(getRec, httptest.NewRequest(http.MethodGet, "/api/posts/1", nil), post, nil)
+
+	headRec := httptest.NewRecorder()
+	writeResource(headRec, httptest.NewRequest(http.MethodHead, "/api/posts/1", nil), post, nil)
+
+	assert.Equal(t, http.StatusOK, headRec.Code)
+	assert.Empty(t, headRec.Body.String())
+
+	// HEAD carries exactly the headers GET would have sent
+	assert.NotEmpty(t, getRec.Header().Get("ETag"))
+	assert.Equal(t, getRec.Header().Get("ETag"), headRec.Header().Get("ETag"))
+	assert.Equal(t, strconv.Itoa(getRec.Body.Len()), getRec.Header().Get("Content-Length"))
+	assert.Equal(t, getRec.Header().Get("Content-Length"), headRec.Header().Get("Content-Length"))
+	assert.Equal(t, "application/json", headRec.Header().Get("Content-Type"))
+}
+
+func TestWriteResourceETagChangesWithContent(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/posts/1", nil)
+
+	first := httptest.NewRecorder()
+	writeResource(first, req, models.Post{ID: 1, Title: "Before"}, nil)
+
+	second := httptest.NewRecorder()
+	writeResource(second, req, models.Post{ID: 1, Title: "After"}, nil)
+
+	assert.NotEqual(t, first.Header().Get("ETag"), second.Header().Get("ETag"))
+}
+
+func TestIfMatch(t *testing.T) {
+	post := models.Post{ID: 1, Title: "Hello", Content: "World"}
+
+	rec := httptest.NewRecorder()
+	writeResource(rec, httptest.NewRequest(http.MethodGet, "/api/posts/1", nil), post, nil)
+	etag := rec.Header().Get("ETag")
+	require.Equal(t, etag, resourceETag(post), "resourceETag must agree with what GET sends")
+
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: etag, want: true},
+		{header: `"other", ` + etag, want: true},
+		{header: "*", want: true},
+		{header: `"other"`, want: false},
+		{header: "W/" + etag, want: false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodDelete, "/api/posts/1", nil)
+		req.Header.Set("If-Match", tt.header)
+		assert.Equal(t, tt.want, ifMatch(req, etag), "If-Match: %s", tt.header)
+	}
+}
+
+func TestParseJSONTrailingData(t *testing.T) {
+	cfg := newTestConfig()
+
+	parse := func(body string) error {
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(body))
+		var dst models.PostRequest
+		return parseJSON(req, &dst, cfg)
+	}
+
+	assert.NoError(t, parse("{\"title\":\"x\"}\n \t\r\n"), "trailing whitespace is fine")
+	assert.ErrorIs(t, parse(`{"title":"x"}{"evil":true}`), errTrailingJSON)
+	assert.ErrorIs(t, parse(`{"title":"x"}}`), errTrailingJSON)
+	assert.ErrorIs(t, parse(`{"title":"x"} garbage`), errTrailingJSON)
+
+	rec := httptest.NewRecorder()
+	writeInvalidJSON(rec, parse(`{"title":"x"}{"evil":true}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "single value")
+
+	cfg.AllowTrailingJSON = true
+	assert.NoError(t, parse(`{"title":"x"}{"evil":true}`))
+}
+
+func TestParseJSONEmptyBody(t *testing.T) {
+	cfg := newTestConfig()
+
+	for _, body := range []string{"", " \n\t"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(body))
+		var dst models.PostRequest
+		err := parseJSON(req, &dst, cfg)
+		assert.ErrorIs(t, err, errEmptyJSON, "body %q", body)
+
+		rec := httptest.NewRecorder()
+		writeInvalidJSON(rec, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Request body is empty")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+	req.Body = nil
+	var dst models.PostRequest
+	assert.ErrorIs(t, parseJSON(req, &dst, cfg), errEmptyJSON)
+
+	// A single object is decoded as usual
+	req = httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(`{"title":"Hello"}`))
+	require.NoError(t, parseJSON(req, &dst, cfg))
+	assert.Equal(t, "Hello", dst.Title)
+}
+
+func TestParseJSONLimits(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxJSONDepth = 8
+	cfg.MaxJSONElements = 100
+
+	parse := func(body string) error {
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(body))
+		var dst interface{}
+		return parseJSON(req, &dst, cfg)
+	}
+
+	t.Run("accepts payloads within the limits", func(t *testing.T) {
+		assert.NoError(t, parse(`{"title":"Hello","content":"World","user_id":1}`))
+		assert.NoError(t, parse(strings.Repeat("[", 8)+strings.Repeat("]", 8)))
+	})
+
+	t.Run("rejects deeply nested payloads", func(t *testing.T) {
+		body := strings.Repeat(`{"a":`, 10000) + "1" + strings.Repeat("}", 10000)
+		err := parse(body)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "nested more than 8 levels")
+
+		rec := httptest.NewRecorder()
+		writeInvalidJSON(rec, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "nested more than 8 levels")
+	})
+
+	t.Run("rejects extremely wide payloads", func(t *testing.T) {
+		body := "[" + strings.Repeat("0,", 100000) + "0]"
+		err := parse(body)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "more than 100 elements")
+	})
+
+	t.Run("malformed JSON is still reported as invalid", func(t *testing.T) {
+		err := parse(`{"title":`)
+		require.Error(t, err)
+
+		rec := httptest.NewRecorder()
+		writeInvalidJSON(rec, err)
+		assert.Contains(t, rec.Body.String(), "Invalid JSON payload")
+	})
+}
+
+func TestParseJSONBodySize(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.MaxRequestBytes = 64
+
+	parse := func(body string) error {
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(body))
+		var dst models.PostRequest
+		return parseJSON(req, &dst, cfg)
+	}
+
+	assert.NoError(t, parse(`{"title":"`+strings.Repeat("a", 40)+`"}`))
+
+	err := parse(`{"title":"` + strings.Repeat("a", 100) + `"}`)
+	require.Error(t, err)
+	rec := httptest.NewRecorder()
+	writeInvalidJSON(rec, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Contains(t, rec.Body.String(), "64 bytes")
+}
+
+func TestParseJSONUnknownFields(t *testing.T) {
+	cfg := newTestConfig()
+
+	parse := func(body string) error {
+		req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body))
+		var dst models.UserRequest
+		return parseJSON(req, &dst, cfg)
+	}
+
+	assert.NoError(t, parse(`{"username":"alice","email":"alice@example.com","password":"secret"}`))
+
+	err := parse(`{"username":"alice","emial":"alice@example.com"}`)
+	require.Error(t, err)
+	rec := httptest.NewRecorder()
+	writeInvalidJSON(rec, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `Unknown field \"emial\"`)
+}
+
+func TestNotModifiedSince(t *testing.T) {
+	lastModified := time.Date(2024, 3, 10, 15, 4, 5, 600_000_000, time.UTC)
+
+	request := func(method, since string) *http.Request {
+		req := httptest.NewRequest(method, "/api/posts", nil)
+		if since != "" {
+			req.Header.Set("If-Modified-Since", since)
+		}
+		return req
+	}
+
+	assert.True(t, notModifiedSince(request(http.MethodGet, "Sun, 10 Mar 2024 15:04:05 GMT"), lastModified))
+	assert.True(t, notModifiedSince(request(http.MethodHead, "Sun, 10 Mar 2024 16:00:00 GMT"), lastModified))
+
+	assert.False(t, notModifiedSince(request(http.MethodGet, "Sun, 10 Mar 2024 15:04:04 GMT"), lastModified))
+	assert.False(t, notModifiedSince(request(http.MethodGet, ""), lastModified))
+	assert.False(t, notModifiedSince(request(http.MethodGet, "last tuesday"), lastModified))
+	assert.False(t, notModifiedSince(request(http.MethodPost, "Sun, 10 Mar 2024 16:00:00 GMT"), lastModified))
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"net/mail"
 	"strings"
+	"time"
+	"unicode"
 
+	"blog-api/internal/database"
 	"blog-api/internal/models"
 )
 
@@ -47,6 +50,11 @@ func ValidateUserRequest(req *models.UserRequest) error {
 			Field:   "username",
 			Message: "username must be no more than 50 characters long",
 		})
+	} else if hasDisallowedControlChars(req.Username, false) {
+		errors = append(errors, ValidationError{
+			Field:   "username",
+			Message: "username must not contain control characters",
+		})
 	}
 
 	// Validate email
@@ -55,6 +63,11 @@ func ValidateUserRequest(req *models.UserRequest) error {
 			Field:   "email",
 			Message: "email is required",
 		})
+	} else if hasDisallowedControlChars(req.Email, false) {
+		errors = append(errors, ValidationError{
+			Field:   "email",
+			Message: "email must not contain control characters",
+		})
 	} else if !isValidEmail(req.Email) {
 		errors = append(errors, ValidationError{
 			Field:   "email",
@@ -98,20 +111,93 @@ func ValidateUserUpdateRequest(req *models.UserRequest) error {
 				Field:   "username",
 				Message: "username must be no more than 50 characters long",
 			})
+		} else if hasDisallowedControlChars(req.Username, false) {
+			errors = append(errors, ValidationError{
+				Field:   "username",
+				Message: "username must not contain control characters",
+			})
 		}
 	}
 
-	if req.Email != "" && !isValidEmail(req.Email) {
+	if req.Email != "" {
+		if hasDisallowedControlChars(req.Email, false) {
+			errors = append(errors, ValidationError{
+				Field:   "email",
+				Message: "email must not contain control characters",
+			})
+		} else if !isValidEmail(req.Email) {
+			errors = append(errors, ValidationError{
+				Field:   "email",
+				Message: "email format is invalid",
+			})
+		}
+	}
+
+	if req.Password != "" {
 		errors = append(errors, ValidationError{
-			Field:   "email",
-			Message: "email format is invalid",
+			Field:   "password",
+			Message: "password can only be changed through POST /api/users/{id}/password",
 		})
 	}
 
-	if req.Password != "" && len(req.Password) < 6 {
+	if len(errors) > 0 {
+		return ValidationErrors{Errors: errors}
+	}
+
+	return nil
+}
+
+// ValidatePasswordChangeRequest validates a password change request. The
+// current password may only be left out when requireCurrent is false.
+func ValidatePasswordChangeRequest(req *models.PasswordChangeRequest, requireCurrent bool) error {
+	var errors []ValidationError
+
+	if requireCurrent && req.CurrentPassword == "" {
 		errors = append(errors, ValidationError{
-			Field:   "password",
-			Message: "password must be at least 6 characters long",
+			Field:   "current_password",
+			Message: "current_password is required",
+		})
+	}
+
+	if req.NewPassword == "" {
+		errors = append(errors, ValidationError{
+			Field:   "new_password",
+			Message: "new_password is required",
+		})
+	} else if len(req.NewPassword) < 6 {
+		errors = append(errors, ValidationError{
+			Field:   "new_password",
+			Message: "new_password must be at least 6 characters long",
+		})
+	}
+
+	if len(errors) > 0 {
+		return ValidationErrors{Errors: errors}
+	}
+
+	return nil
+}
+
+// ValidatePasswordResetRequest validates a password reset request
+func ValidatePasswordResetRequest(req *models.PasswordResetRequest) error {
+	var errors []ValidationError
+
+	if req.Token == "" {
+		errors = append(errors, ValidationError{
+			Field:   "token",
+			Message: "token is required",
+		})
+	}
+
+	if req.NewPassword == "" {
+		errors = append(errors, ValidationError{
+			Field:   "new_password",
+			Message: "new_password is required",
+		})
+	} else if len(req.NewPassword) < 6 {
+		errors = append(errors, ValidationError{
+			Field:   "new_password",
+			Message: "new_password must be at least 6 characters long",
 		})
 	}
 
@@ -137,6 +223,11 @@ func ValidatePostRequest(req *models.PostRequest) error {
 			Field:   "title",
 			Message: "title must be no more than 255 characters long",
 		})
+	} else if hasDisallowedControlChars(req.Title, false) {
+		errors = append(errors, ValidationError{
+			Field:   "title",
+			Message: "title must not contain control characters",
+		})
 	}
 
 	// Validate content
@@ -145,6 +236,25 @@ func ValidatePostRequest(req *models.PostRequest) error {
 			Field:   "content",
 			Message: "content is required",
 		})
+	} else if hasDisallowedControlChars(req.Content, true) {
+		errors = append(errors, ValidationError{
+			Field:   "content",
+			Message: "content must not contain control characters other than newlines and tabs",
+		})
+	}
+
+	if req.ContentFormat != "" && !isValidContentFormat(req.ContentFormat) {
+		errors = append(errors, ValidationError{
+			Field:   "content_format",
+			Message: contentFormatMessage,
+		})
+	}
+
+	if req.Status != "" && !isValidPostStatus(req.Status) {
+		errors = append(errors, ValidationError{
+			Field:   "status",
+			Message: postStatusMessage,
+		})
 	}
 
 	// Validate user_id
@@ -155,6 +265,15 @@ func ValidatePostRequest(req *models.PostRequest) error {
 		})
 	}
 
+	if req.PublishAt != nil && req.Status == models.PostStatusPublished {
+		errors = append(errors, ValidationError{
+			Field:   "publish_at",
+			Message: publishAtStatusMessage,
+		})
+	}
+
+	errors = append(errors, validateTags(req.Tags)...)
+
 	if len(errors) > 0 {
 		return ValidationErrors{Errors: errors}
 	}
@@ -167,22 +286,60 @@ func ValidatePostUpdateRequest(req *models.PostRequest) error {
 	var errors []ValidationError
 
 	// For updates, fields are optional, but if provided, they must be valid
-	if req.Title != "" && len(req.Title) > 255 {
+	if req.Title != "" {
+		if len(req.Title) > 255 {
+			errors = append(errors, ValidationError{
+				Field:   "title",
+				Message: "title must be no more than 255 characters long",
+			})
+		} else if hasDisallowedControlChars(req.Title, false) {
+			errors = append(errors, ValidationError{
+				Field:   "title",
+				Message: "title must not contain control characters",
+			})
+		}
+	}
+
+	// Content can be empty in updates, but if provided it must be clean
+	if hasDisallowedControlChars(req.Content, true) {
 		errors = append(errors, ValidationError{
-			Field:   "title",
-			Message: "title must be no more than 255 characters long",
+			Field:   "content",
+			Message: "content must not contain control characters other than newlines and tabs",
 		})
 	}
 
-	// Content can be empty in updates, so no validation needed
+	if req.ContentFormat != "" && !isValidContentFormat(req.ContentFormat) {
+		errors = append(errors, ValidationError{
+			Field:   "content_format",
+			Message: contentFormatMessage,
+		})
+	}
 
-	if req.UserID < 0 {
+	if req.Status != "" && !isValidPostStatus(req.Status) {
+		errors = append(errors, ValidationError{
+			Field:   "status",
+			Message: postStatusMessage,
+		})
+	}
+
+	// Moving a post to another author is up to admins, through
+	// POST /api/posts/{id}/move
+	if req.UserID != 0 {
 		errors = append(errors, ValidationError{
 			Field:   "user_id",
-			Message: "user_id must be a non-negative integer",
+			Message: "user_id cannot be changed by an update",
 		})
 	}
 
+	if req.PublishAt != nil && req.Status == models.PostStatusPublished {
+		errors = append(errors, ValidationError{
+			Field:   "publish_at",
+			Message: publishAtStatusMessage,
+		})
+	}
+
+	errors = append(errors, validateTags(req.Tags)...)
+
 	if len(errors) > 0 {
 		return ValidationErrors{Errors: errors}
 	}
@@ -190,8 +347,129 @@ func ValidatePostUpdateRequest(req *models.PostRequest) error {
 	return nil
 }
 
+// ValidateAutosaveRequest validates an autosave of a draft. Unlike a post
+// update, content may be saved empty while the author is still writing.
+func ValidateAutosaveRequest(req *models.AutosaveRequest) error {
+	var errors []ValidationError
+
+	if req.Title != nil {
+		if strings.TrimSpace(*req.Title) == "" {
+			errors = append(errors, ValidationError{
+				Field:   "title",
+				Message: "title must not be blank",
+			})
+		} else if len(*req.Title) > 255 {
+			errors = append(errors, ValidationError{
+				Field:   "title",
+				Message: "title must be no more than 255 characters long",
+			})
+		} else if hasDisallowedControlChars(*req.Title, false) {
+			errors = append(errors, ValidationError{
+				Field:   "title",
+				Message: "title must not contain control characters",
+			})
+		}
+	}
+
+	if req.Content != nil && hasDisallowedControlChars(*req.Content, true) {
+		errors = append(errors, ValidationError{
+			Field:   "content",
+			Message: "content must not contain control characters other than newlines and tabs",
+		})
+	}
+
+	if len(errors) > 0 {
+		return ValidationErrors{Errors: errors}
+	}
+
+	return nil
+}
+
+// publishAtStatusMessage rejects a schedule for a post published right away
+const publishAtStatusMessage = "publish_at schedules a draft and can't be combined with status published"
+
+// ValidatePublishAt checks that a post is scheduled for after now
+func ValidatePublishAt(req *models.PostRequest, now time.Time) error {
+	if req.PublishAt == nil || req.PublishAt.After(now) {
+		return nil
+	}
+	return ValidationErrors{Errors: []ValidationError{{
+		Field:   "publish_at",
+		Message: "publish_at must be in the future",
+	}}}
+}
+
+// maxTagLength is the longest tag the tags table can hold
+const maxTagLength = 50
+
+// validateTags normalizes each tag of a post request in place with
+// database.NormalizeTag, then checks it and that there are no more than
+// models.MaxPostTags distinct tags. Duplicates are left to the database.
+func validateTags(tags []string) []ValidationError {
+	distinct := make(map[string]bool, len(tags))
+	for i, raw := range tags {
+		tag := database.NormalizeTag(raw)
+		tags[i] = tag
+		switch {
+		case tag == "":
+			return []ValidationError{{Field: "tags", Message: "tags must not be blank"}}
+		case len(tag) > maxTagLength:
+			return []ValidationError{{Field: "tags", Message: fmt.Sprintf("tags must be no more than %d characters long", maxTagLength)}}
+		case hasDisallowedControlChars(raw, false):
+			return []ValidationError{{Field: "tags", Message: "tags must not contain control characters"}}
+		}
+		distinct[tag] = true
+	}
+	if len(distinct) > models.MaxPostTags {
+		return []ValidationError{{Field: "tags", Message: fmt.Sprintf("a post can have no more than %d tags", models.MaxPostTags)}}
+	}
+	return nil
+}
+
+// ValidateTagsRequest validates the tags that replace a post's tags
+func ValidateTagsRequest(req *models.TagsRequest) error {
+	if req.Tags == nil {
+		return ValidationErrors{Errors: []ValidationError{{Field: "tags", Message: "tags is required"}}}
+	}
+	if errors := validateTags(req.Tags); len(errors) > 0 {
+		return ValidationErrors{Errors: errors}
+	}
+	return nil
+}
+
+// contentFormatMessage is the validation message for an unknown content format
+const contentFormatMessage = "content_format must be one of: " + models.ContentFormatMarkdown + ", " + models.ContentFormatHTML
+
+// isValidContentFormat checks if format is a supported content format
+func isValidContentFormat(format string) bool {
+	return format == models.ContentFormatMarkdown || format == models.ContentFormatHTML
+}
+
+// postStatusMessage is the validation message for an unknown post status
+const postStatusMessage = "status must be one of: " + models.PostStatusDraft + ", " + models.PostStatusPublished
+
+// isValidPostStatus checks if status is a supported post status
+func isValidPostStatus(status string) bool {
+	return status == models.PostStatusDraft || status == models.PostStatusPublished
+}
+
 // isValidEmail checks if the email format is valid
 func isValidEmail(email string) bool {
 	_, err := mail.ParseAddress(email)
 	return err == nil
 }
+
+// hasDisallowedControlChars reports whether s contains NUL or any other
+// control character. When allowWhitespace is set, newlines and tabs are
+// permitted so multi-line content still passes.
+func hasDisallowedControlChars(s string, allowWhitespace bool) bool {
+	for _, r := range s {
+		if allowWhitespace && (r == '\n' || r == '\t') {
+			continue
+		}
+		if unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
+}
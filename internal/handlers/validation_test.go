@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"blog-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasDisallowedControlChars(t *testing.T) {
+	tests := []struct {
+		name            string
+		input           string
+		allowWhitespace bool
+		want            bool
+	}{
+		{"plain text", "Hello, world", false, false},
+		{"unicode text", "Grüße 👋", false, false},
+		{"null byte", "bad\x00title", false, true},
+		{"null byte in content", "bad\x00content", true, true},
+		{"escape character", "bad\x1btitle", false, true},
+		{"delete character", "bad\x7ftitle", false, true},
+		{"newline in title", "line one\nline two", false, true},
+		{"newline in content", "line one\nline two", true, false},
+		{"tab in content", "col one\tcol two", true, false},
+		{"carriage return in content", "line one\r\nline two", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, hasDisallowedControlChars(tt.input, tt.allowWhitespace))
+		})
+	}
+}
+
+func TestValidatePostRequestControlChars(t *testing.T) {
+	err := ValidatePostRequest(&models.PostRequest{
+		Title:   "Null\x00Title",
+		Content: "First line\nSecond line",
+		UserID:  1,
+	})
+	require.Error(t, err)
+
+	validationErr, ok := err.(ValidationErrors)
+	require.True(t, ok)
+	require.Len(t, validationErr.Errors, 1)
+	assert.Equal(t, "title", validationErr.Errors[0].Field)
+
+	// A newline in content on its own is fine
+	err = ValidatePostRequest(&models.PostRequest{
+		Title:   "Clean Title",
+		Content: "First line\nSecond line",
+		UserID:  1,
+	})
+	assert.NoError(t, err)
+}
+
+func TestValidatePostUpdateRejectsUserID(t *testing.T) {
+	err := ValidatePostUpdateRequest(&models.PostRequest{Title: "Title", UserID: 2})
+	require.Error(t, err)
+	assert.Equal(t, "user_id", err.(ValidationErrors).Errors[0].Field)
+}
+
+func TestValidatePublishAt(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	future := now.Add(time.Minute)
+	past := now.Add(-time.Minute)
+
+	assert.NoError(t, ValidatePublishAt(&models.PostRequest{}, now))
+	assert.NoError(t, ValidatePublishAt(&models.PostRequest{PublishAt: &future}, now))
+	for _, at := range []*time.Time{&now, &past} {
+		err := ValidatePublishAt(&models.PostRequest{PublishAt: at}, now)
+		require.Error(t, err)
+		assert.Equal(t, "publish_at", err.(ValidationErrors).Errors[0].Field)
+	}
+
+	// Only drafts are scheduled
+	for _, err := range []error{
+		ValidatePostRequest(&models.PostRequest{Title: "Title", Content: "Content", Status: models.PostStatusPublished, UserID: 1, PublishAt: &future}),
+		ValidatePostUpdateRequest(&models.PostRequest{Status: models.PostStatusPublished, PublishAt: &future}),
+	} {
+		require.Error(t, err)
+		assert.Equal(t, "publish_at", err.(ValidationErrors).Errors[0].Field)
+	}
+	assert.NoError(t, ValidatePostRequest(&models.PostRequest{Title: "Title", Content: "Content", Status: models.PostStatusDraft, UserID: 1, PublishAt: &future}))
+}
+
+func TestValidateAutosaveRequest(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name    string
+		req     models.AutosaveRequest
+		wantErr string
+	}{
+		{name: "title and content", req: models.AutosaveRequest{Title: str("Title"), Content: str("Content")}},
+		{name: "empty content", req: models.AutosaveRequest{Content: str("")}},
+		{name: "blank title", req: models.AutosaveRequest{Title: str("  ")}, wantErr: "title"},
+		{name: "long title", req: models.AutosaveRequest{Title: str(strings.Repeat("a", 256))}, wantErr: "title"},
+		{name: "control characters in content", req: models.AutosaveRequest{Content: str("a\x00b")}, wantErr: "content"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAutosaveRequest(&tt.req)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tt.wantErr, err.(ValidationErrors).Errors[0].Field)
+		})
+	}
+}
+
+func TestValidatePostStatus(t *testing.T) {
+	for _, status := range []string{"", models.PostStatusDraft, models.PostStatusPublished} {
+		assert.NoError(t, ValidatePostRequest(&models.PostRequest{Title: "Title", Content: "Content", Status: status, UserID: 1}))
+		assert.NoError(t, ValidatePostUpdateRequest(&models.PostRequest{Status: status}))
+	}
+
+	for _, err := range []error{
+		ValidatePostRequest(&models.PostRequest{Title: "Title", Content: "Content", Status: "archived", UserID: 1}),
+		ValidatePostUpdateRequest(&models.PostRequest{Status: "Published"}),
+	} {
+		require.Error(t, err)
+		assert.Equal(t, "status", err.(ValidationErrors).Errors[0].Field)
+	}
+}
+
+func TestValidatePostTags(t *testing.T) {
+	tooMany := make([]string, models.MaxPostTags+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("tag%d", i)
+	}
+
+	tests := []struct {
+		name    string
+		tags    []string
+		wantErr bool
+	}{
+		{name: "none", tags: nil},
+		{name: "valid", tags: []string{"Go", "web-dev"}},
+		{name: "blank", tags: []string{"go", "  "}, wantErr: true},
+		{name: "too long", tags: []string{strings.Repeat("a", 51)}, wantErr: true},
+		{name: "control character", tags: []string{"go\tlang"}, wantErr: true},
+		{name: "too many", tags: tooMany, wantErr: true},
+		{name: "duplicates count once", tags: append(tooMany[:models.MaxPostTags:models.MaxPostTags], "TAG0")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Validation normalizes the tags in place, so each call gets a copy
+			tags := func() []string { return append([]string(nil), tt.tags...) }
+			create := ValidatePostRequest(&models.PostRequest{Title: "Title", Content: "Content", UserID: 1, Tags: tags()})
+			update := ValidatePostUpdateRequest(&models.PostRequest{Tags: tags()})
+			replace := ValidateTagsRequest(&models.TagsRequest{Tags: tags()})
+
+			if tt.wantErr {
+				for _, err := range []error{create, update, replace} {
+					require.Error(t, err)
+					assert.Equal(t, "tags", err.(ValidationErrors).Errors[0].Field)
+				}
+			} else {
+				assert.NoError(t, create)
+				assert.NoError(t, update)
+			}
+		})
+	}
+}
+
+func TestValidatePostTagsNormalizes(t *testing.T) {
+	create := &models.PostRequest{Title: "Title", Content: "Content", UserID: 1, Tags: []string{" Web  Dev ", "Go--Lang"}}
+	require.NoError(t, ValidatePostRequest(create))
+	assert.Equal(t, []string{"web-dev", "go-lang"}, create.Tags)
+
+	update := &models.PostRequest{Tags: []string{"Golang"}}
+	require.NoError(t, ValidatePostUpdateRequest(update))
+	assert.Equal(t, []string{"golang"}, update.Tags)
+
+	replace := &models.TagsRequest{Tags: []string{"web dev", "web-dev"}}
+	require.NoError(t, ValidateTagsRequest(replace))
+	assert.Equal(t, []string{"web-dev", "web-dev"}, replace.Tags)
+}
+
+func TestValidateUserRequestControlChars(t *testing.T) {
+	err := ValidateUserRequest(&models.UserRequest{
+		Username: "bad\x00user",
+		Email:    "user@example.com",
+		Password: "password123",
+	})
+	require.Error(t, err)
+
+	validationErr, ok := err.(ValidationErrors)
+	require.True(t, ok)
+	require.Len(t, validationErr.Errors, 1)
+	assert.Equal(t, "username", validationErr.Errors[0].Field)
+}
+
+func TestValidateUserUpdateRequestRejectsPassword(t *testing.T) {
+	err := ValidateUserUpdateRequest(&models.UserRequest{Password: "password123"})
+	require.Error(t, err)
+
+	validationErr, ok := err.(ValidationErrors)
+	require.True(t, ok)
+	require.Len(t, validationErr.Errors, 1)
+	assert.Equal(t, "password", validationErr.Errors[0].Field)
+}
+
+func TestValidatePasswordChangeRequest(t *testing.T) {
+	tests := []struct {
+		name           string
+		req            models.PasswordChangeRequest
+		requireCurrent bool
+		wantFields     []string
+	}{
+		{name: "valid", req: models.PasswordChangeRequest{CurrentPassword: "old-secret", NewPassword: "new-secret"}, requireCurrent: true},
+		{name: "missing current", req: models.PasswordChangeRequest{NewPassword: "new-secret"}, requireCurrent: true, wantFields: []string{"current_password"}},
+		{name: "current not required", req: models.PasswordChangeRequest{NewPassword: "new-secret"}},
+		{name: "new too short", req: models.PasswordChangeRequest{CurrentPassword: "old-secret", NewPassword: "123"}, requireCurrent: true, wantFields: []string{"new_password"}},
+		{name: "both missing", requireCurrent: true, wantFields: []string{"current_password", "new_password"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePasswordChangeRequest(&tt.req, tt.requireCurrent)
+			if tt.wantFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			validationErr, ok := err.(ValidationErrors)
+			require.True(t, ok)
+			var fields []string
+			for _, e := range validationErr.Errors {
+				fields = append(fields, e.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// BuildInfo describes the build of the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// VersionHandler handles build information requests
+type VersionHandler struct {
+	info BuildInfo
+}
+
+// NewVersionHandler creates a new version handler
+func NewVersionHandler(info BuildInfo) *VersionHandler {
+	if info.GoVersion == "" {
+		info.GoVersion = runtime.Version()
+	}
+	return &VersionHandler{info: info}
+}
+
+// Version handles GET /api/version
+func (h *VersionHandler) Version(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.info)
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersion(t *testing.T) {
+	handler := NewVersionHandler(BuildInfo{
+		Version:   "1.4.0",
+		Commit:    "abc1234",
+		BuildTime: "2024-01-02T03:04:05Z",
+	})
+
+	rec := httptest.NewRecorder()
+	handler.Version(rec, httptest.NewRequest(http.MethodGet, "/api/version", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var info BuildInfo
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&info))
+	assert.Equal(t, "1.4.0", info.Version)
+	assert.Equal(t, "abc1234", info.Commit)
+	assert.Equal(t, "2024-01-02T03:04:05Z", info.BuildTime)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+}
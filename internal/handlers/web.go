@@ -1,44 +1,200 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"html/template"
 	"net/http"
+	"os"
 	"path/filepath"
+	"time"
+
+	"blog-api/internal/clock"
+	"blog-api/internal/config"
+	"blog-api/internal/database"
+	"blog-api/internal/events"
 
 	"github.com/rs/zerolog/log"
 )
 
 // WebHandler handles web interface requests
 type WebHandler struct {
+	db        *database.DB
+	events    *events.Bus
+	cfg       *config.Config
+	clock     clock.Clock
 	templates *template.Template
+
+	// renderSlots bounds concurrent page renders so a traffic spike on the
+	// web pages can't take every database connection from the API.
+	// A nil channel means renders are unlimited.
+	renderSlots chan struct{}
+
+	// rootRedirect, when set, is where GET / redirects instead of serving
+	// the landing page
+	rootRedirect string
+}
+
+// indexData is passed to the landing page template
+type indexData struct {
+	// DatabaseDown shows a banner while the database can't be reached
+	DatabaseDown bool
 }
 
-// NewWebHandler creates a new web handler
-func NewWebHandler() *WebHandler {
-	// Parse templates
-	templatePath := filepath.Join("web", "templates", "*.html")
-	templates, err := template.ParseGlob(templatePath)
+var (
+	// errTemplateDirMissing means the template directory doesn't exist
+	errTemplateDirMissing = errors.New("template directory not found")
+
+	// errNoTemplates means the template directory has no *.html files
+	errNoTemplates = errors.New("no templates found")
+
+	// errNoIndexTemplate means the templates parsed but none is index.html
+	errNoIndexTemplate = errors.New("templates do not define index.html")
+)
+
+// loadTemplates parses the *.html templates in dir. Each way of ending up
+// without a usable index.html gets its own error, so the startup log says
+// which one happened.
+func loadTemplates(dir string) (*template.Template, error) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, errTemplateDirMissing
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
 	if err != nil {
-		log.Warn().Err(err).Msg("Failed to parse templates, serving without templates")
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errNoTemplates
+	}
+
+	templates, err := template.ParseFiles(files...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+	if templates.Lookup("index.html") == nil {
+		return nil, errNoIndexTemplate
+	}
+
+	return templates, nil
+}
+
+// NewWebHandler creates a new web handler that renders at most
+// cfg.MaxConcurrentRenders pages at once; 0 means unlimited. Changes made on
+// the admin page are published to bus.
+func NewWebHandler(db *database.DB, bus *events.Bus, cfg *config.Config) *WebHandler {
+	dir := filepath.Join("web", "templates")
+	templates, err := loadTemplates(dir)
+	if err != nil {
+		if errors.Is(err, errTemplateDirMissing) || errors.Is(err, errNoTemplates) || errors.Is(err, errNoIndexTemplate) {
+			log.Warn().Str("dir", dir).Msgf("Web templates unavailable (%v), serving the fallback page", err)
+		} else {
+			log.Error().Err(err).Str("dir", dir).Msg("Failed to parse web templates, serving the fallback page")
+		}
+	}
+
+	var renderSlots chan struct{}
+	if cfg.MaxConcurrentRenders > 0 {
+		renderSlots = make(chan struct{}, cfg.MaxConcurrentRenders)
 	}
 
 	return &WebHandler{
-		templates: templates,
+		db:           db,
+		events:       bus,
+		cfg:          cfg,
+		clock:        clock.Real{},
+		templates:    templates,
+		renderSlots:  renderSlots,
+		rootRedirect: cfg.RootRedirect,
 	}
 }
 
-// Index serves the main application page
+// Index serves the main application page, or redirects to the configured
+// root path. A database outage still renders the page with a banner; only a
+// broken template results in a 500.
 func (h *WebHandler) Index(w http.ResponseWriter, r *http.Request) {
+	if h.rootRedirect != "" {
+		http.Redirect(w, r, h.rootRedirect, http.StatusFound)
+		return
+	}
+
+	if !h.acquireRender() {
+		writeBusyPage(w)
+		return
+	}
+	defer h.releaseRender()
+
+	data := indexData{DatabaseDown: !h.databaseAvailable(r.Context())}
+
+	tmpl, name := fallbackTemplate, "fallback"
 	if h.templates != nil {
-		err := h.templates.ExecuteTemplate(w, "index.html", nil)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to execute template")
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
-	} else {
-		// Fallback HTML if templates fail to load
-		fallbackHTML := `
+		tmpl, name = h.templates, "index.html"
+	}
+
+	// Render into a buffer so a failing template can't send a partial page
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		loggerFromContext(r).Error().Err(err).Str("template", name).Msg("Failed to execute template")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// acquireRender takes a render slot without waiting, reporting false when
+// all slots are in use
+func (h *WebHandler) acquireRender() bool {
+	if h.renderSlots == nil {
+		return true
+	}
+	select {
+	case h.renderSlots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseRender returns a slot taken by acquireRender
+func (h *WebHandler) releaseRender() {
+	if h.renderSlots != nil {
+		<-h.renderSlots
+	}
+}
+
+// writeBusyPage tells the browser to come back shortly
+func writeBusyPage(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write([]byte(busyPage))
+}
+
+// busyPage is served when every render slot is taken
+const busyPage = `<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="UTF-8"><title>BlogWriter</title></head>
+<body><p>We're handling a lot of visitors right now. Please try again in a moment.</p></body>
+</html>`
+
+// databaseAvailable pings the database with a short timeout
+func (h *WebHandler) databaseAvailable(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	if err := h.db.Ping(ctx); err != nil {
+		log.Warn().Err(err).Msg("Database unavailable while rendering landing page")
+		return false
+	}
+	return true
+}
+
+// fallbackTemplate is served when the templates in web/templates fail to load
+var fallbackTemplate = template.Must(template.New("fallback").Parse(`
 <!DOCTYPE html>
 <html lang="en">
 <head>
@@ -79,10 +235,21 @@ func (h *WebHandler) Index(w http.ResponseWriter, r *http.Request) {
         .api-links a:hover {
             background: rgba(240, 147, 251, 0.1);
         }
+        .status-banner {
+            background: rgba(220, 38, 38, 0.85);
+            padding: 0.75rem 1rem;
+            border-radius: 8px;
+            margin-bottom: 1.5rem;
+        }
     </style>
 </head>
 <body>
     <div class="container">
+        {{if .DatabaseDown}}
+        <div class="status-banner" role="alert">
+            The database is currently unavailable. Posts can't be loaded or saved right now.
+        </div>
+        {{end}}
         <h1>🚀 BlogWriter API</h1>
         <p>Your production-ready blog API is running successfully!</p>
         <div class="api-links">
@@ -95,8 +262,4 @@ func (h *WebHandler) Index(w http.ResponseWriter, r *http.Request) {
         </p>
     </div>
 </body>
-</html>`
-		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(fallbackHTML))
-	}
-}
+</html>`))
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexDatabaseDown(t *testing.T) {
+	handler := &WebHandler{db: newUnreachableDB(t)}
+
+	rec := httptest.NewRecorder()
+	handler.Index(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// The landing page still renders, but warns about the outage
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), "database is currently unavailable")
+}
+
+func TestIndexRootRedirect(t *testing.T) {
+	handler := &WebHandler{db: newUnreachableDB(t), rootRedirect: "/api/posts"}
+
+	rec := httptest.NewRecorder()
+	handler.Index(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "/api/posts", rec.Header().Get("Location"))
+	assert.NotContains(t, rec.Body.String(), "database is currently unavailable")
+}
+
+func TestIndexTemplateError(t *testing.T) {
+	broken := template.Must(template.New("index.html").Parse(`{{.Missing.Field}}`))
+	handler := &WebHandler{db: newUnreachableDB(t), templates: broken}
+
+	rec := httptest.NewRecorder()
+	handler.Index(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "database is currently unavailable")
+}
+
+func TestIndexRenderLimit(t *testing.T) {
+	handler := &WebHandler{db: newUnreachableDB(t), renderSlots: make(chan struct{}, 2)}
+
+	// Saturate the guard as if two slow renders were in flight
+	handler.renderSlots <- struct{}{}
+	handler.renderSlots <- struct{}{}
+
+	rec := httptest.NewRecorder()
+	handler.Index(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+
+	// Once a render finishes the page is served again, and its slot is returned
+	<-handler.renderSlots
+
+	rec = httptest.NewRecorder()
+	handler.Index(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, handler.renderSlots, 1)
+}
+
+func TestLoadTemplates(t *testing.T) {
+	writeTemplate := func(t *testing.T, dir, name, content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	t.Run("missing directory", func(t *testing.T) {
+		_, err := loadTemplates(filepath.Join(t.TempDir(), "nope"))
+		assert.ErrorIs(t, err, errTemplateDirMissing)
+	})
+
+	t.Run("empty directory", func(t *testing.T) {
+		_, err := loadTemplates(t.TempDir())
+		assert.ErrorIs(t, err, errNoTemplates)
+	})
+
+	t.Run("malformed template", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTemplate(t, dir, "index.html", `<h1>{{ .Title </h1>`)
+
+		templates, err := loadTemplates(dir)
+		assert.Nil(t, templates)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to parse templates")
+	})
+
+	t.Run("no index template", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTemplate(t, dir, "about.html", `<h1>About</h1>`)
+
+		_, err := loadTemplates(dir)
+		assert.ErrorIs(t, err, errNoIndexTemplate)
+	})
+
+	t.Run("valid templates", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTemplate(t, dir, "index.html", `<h1>Blog</h1>`)
+
+		templates, err := loadTemplates(dir)
+		require.NoError(t, err)
+		assert.NotNil(t, templates.Lookup("index.html"))
+	})
+}
+
+func TestIndexFallbackWithoutTemplates(t *testing.T) {
+	// What NewWebHandler ends up with after any loadTemplates failure
+	templates, err := loadTemplates(t.TempDir())
+	require.Error(t, err)
+	handler := &WebHandler{db: newUnreachableDB(t), templates: templates}
+
+	rec := httptest.NewRecorder()
+	handler.Index(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+}
@@ -10,32 +10,237 @@ type User struct {
 	Username     string    `json:"username" db:"username"`
 	Email        string    `json:"email" db:"email"`
 	PasswordHash string    `json:"-" db:"password_hash"` // Never expose password hash in JSON
+	Banned       bool      `json:"banned" db:"banned"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 }
 
-// UserRequest represents the request payload for creating/updating users
+// UserStats holds aggregate figures for an author's profile. PostCount
+// includes drafts, so it is only shown to the author and admins;
+// PublishedCount and TotalViews only cover published posts.
+type UserStats struct {
+	UserID         int       `json:"user_id"`
+	PostCount      *int      `json:"post_count,omitempty"`
+	PublishedCount int       `json:"published_count"`
+	TotalViews     int64     `json:"total_views"`
+	JoinedAt       time.Time `json:"joined_at"`
+}
+
+// ArchiveMonth is the number of posts created in one calendar month
+type ArchiveMonth struct {
+	Year  int `json:"year"`
+	Month int `json:"month"`
+	Count int `json:"count"`
+}
+
+// DatabaseStatus describes the database for operators
+type DatabaseStatus struct {
+	Tables []TableEstimate `json:"tables"`
+	Pool   PoolStats       `json:"pool"`
+	// Migrations lists the versions of the applied migrations, oldest first
+	Migrations []string `json:"migrations"`
+}
+
+// TableEstimate is the planner's row count estimate for a table.
+// EstimatedRows is nil until the table has been analyzed.
+type TableEstimate struct {
+	Name          string `json:"name"`
+	EstimatedRows *int64 `json:"estimated_rows"`
+}
+
+// PoolStats summarizes the connection pool
+type PoolStats struct {
+	MaxOpen        int   `json:"max_open"`
+	Open           int   `json:"open"`
+	InUse          int   `json:"in_use"`
+	Idle           int   `json:"idle"`
+	WaitCount      int64 `json:"wait_count"`
+	WaitDurationMS int64 `json:"wait_duration_ms"`
+}
+
+// UserRequest represents the request payload for creating/updating users.
+// Its fields are the only ones a client can set; bodies with other keys,
+// such as id or banned, are rejected. Bans go through the admin endpoints, and
+// the password can only be set on create; later changes go through
+// PasswordChangeRequest.
 type UserRequest struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
-// Post represents a blog post
-type Post struct {
+// PasswordChangeRequest represents the request payload for changing a password
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ForgotPasswordRequest represents the request payload for requesting a
+// password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// PasswordResetRequest represents the request payload for setting a new
+// password with a reset token
+type PasswordResetRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// LoginRequest represents the request payload for logging in
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// TokenInfo describes a valid access token, as returned by GET
+// /api/auth/validate. ExpiresIn is the token's remaining lifetime in seconds.
+type TokenInfo struct {
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+	ExpiresIn int       `json:"expires_in"`
+}
+
+// RoleUser is the role of every access token; admins authenticate with the
+// admin token instead
+const RoleUser = "user"
+
+// RefreshRequest represents the request payload for refreshing an access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenResponse carries the tokens issued on login and refresh. The access
+// token authenticates requests; the refresh token gets a new pair once it expires.
+type TokenResponse struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// RefreshToken is a stored refresh token. Tokens rotated from the same login
+// share a Family.
+type RefreshToken struct {
 	ID        int       `json:"id" db:"id"`
-	Title     string    `json:"title" db:"title"`
-	Content   string    `json:"content" db:"content"`
 	UserID    int       `json:"user_id" db:"user_id"`
+	Family    string    `json:"family" db:"family"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	Revoked   bool      `json:"revoked" db:"revoked"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	// Optional: include user information in post responses
+}
+
+// Post represents a blog post
+type Post struct {
+	ID            int        `json:"id" db:"id"`
+	Title         string     `json:"title" db:"title"`
+	Content       string     `json:"content" db:"content"`
+	ContentFormat string     `json:"content_format" db:"content_format"`
+	UserID        int        `json:"user_id" db:"user_id"`
+	ViewCount     int        `json:"view_count" db:"view_count"`
+	Featured      bool       `json:"featured" db:"featured"`
+	FeaturedOrder *int       `json:"featured_order,omitempty" db:"featured_order"`
+	Status        string     `json:"status" db:"status"`
+	PublishedAt   *time.Time `json:"published_at,omitempty" db:"published_at"`
+	PublishAt     *time.Time `json:"publish_at,omitempty" db:"publish_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	Tags          []string   `json:"tags"`
+	// Optional: include user information in post responses. It is joined
+	// from users on every read, so a renamed author shows up at once.
 	Username string `json:"username,omitempty" db:"username"`
 }
 
-// PostRequest represents the request payload for creating/updating posts
+// PostListOptions selects the posts of a list
+type PostListOptions struct {
+	// Tag limits the list to posts with this tag, ignoring case
+	Tag string
+	// ViewerID's drafts are listed along with published posts; 0 is anonymous
+	ViewerID int
+	// IncludeDrafts lists every author's drafts, for admins
+	IncludeDrafts bool
+	// IncludeDeleted lists soft-deleted posts as well
+	IncludeDeleted bool
+	// AuthorID limits the list to one author's posts; 0 lists everyone's
+	AuthorID int
+}
+
+// PostSiblings are the posts just before and after a post, by creation time.
+// Either is nil at the ends of the list.
+type PostSiblings struct {
+	Prev *Post `json:"prev"` // the next older post
+	Next *Post `json:"next"` // the next newer post
+}
+
+// SiblingScope limits which posts count as a post's siblings
+type SiblingScope string
+
+const (
+	// SiblingScopeAll considers every listed post
+	SiblingScopeAll SiblingScope = ""
+	// SiblingScopeAuthor considers only posts by the same author
+	SiblingScopeAuthor SiblingScope = "author"
+)
+
+// PostRequest represents the request payload for creating/updating posts.
+// On create, UserID is replaced by the authenticated user and an empty
+// Status saves a draft. On update, UserID is rejected, and Tags replaces the
+// post's tags when present, so [] removes them all. PublishAt schedules a
+// draft to be published at that time.
 type PostRequest struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
-	UserID  int    `json:"user_id"`
+	Title         string     `json:"title"`
+	Content       string     `json:"content"`
+	ContentFormat string     `json:"content_format,omitempty"`
+	Status        string     `json:"status,omitempty"`
+	UserID        int        `json:"user_id"`
+	Tags          []string   `json:"tags,omitempty"`
+	PublishAt     *time.Time `json:"publish_at,omitempty"`
+}
+
+// AutosaveRequest represents the request payload for autosaving a draft.
+// A field left out keeps its saved value; Content may be empty.
+type AutosaveRequest struct {
+	Title   *string `json:"title"`
+	Content *string `json:"content"`
+}
+
+// MaxPostTags is the most tags a post can carry
+const MaxPostTags = 10
+
+// TagsRequest represents the request payload for replacing a post's tags
+type TagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// Supported values for Post.ContentFormat
+const (
+	ContentFormatMarkdown = "markdown"
+	ContentFormatHTML     = "html"
+)
+
+// Supported values for Post.Status. Drafts are only shown to their author.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusPublished = "published"
+)
+
+// FeatureRequest represents the optional request payload for featuring a post
+type FeatureRequest struct {
+	Order *int `json:"order"`
+}
+
+// TransferRequest represents the request payload for moving posts to another user
+type TransferRequest struct {
+	NewUserID int `json:"new_user_id"`
+}
+
+// PostFilter narrows bulk post operations to a subset of posts
+type PostFilter struct {
+	UserID        int        `json:"user_id,omitempty"`
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
 }
 
 // ErrorResponse represents an error response
@@ -43,6 +248,8 @@ type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message,omitempty"`
 	Code    int    `json:"code"`
+	// RequestID is sent with unexpected errors so they can be found in the logs
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // SuccessResponse represents a success response
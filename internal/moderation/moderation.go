@@ -0,0 +1,91 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"blog-api/internal/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Moderator decides whether a new post may be published. When it rejects a
+// post, reason is shown to the author.
+type Moderator interface {
+	Check(ctx context.Context, post *models.PostRequest) (allowed bool, reason string)
+}
+
+// AllowAll is the default moderator, which accepts every post
+type AllowAll struct{}
+
+// Check accepts the post
+func (AllowAll) Check(context.Context, *models.PostRequest) (bool, string) {
+	return true, ""
+}
+
+// Denylist rejects posts whose title or content contains one of its words,
+// ignoring case. Only whole words match, so "ass" doesn't reject "class".
+type Denylist struct {
+	words map[string]bool
+}
+
+// NewDenylist creates a denylist of the given words
+func NewDenylist(words []string) *Denylist {
+	d := &Denylist{words: make(map[string]bool, len(words))}
+	for _, word := range words {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			d.words[word] = true
+		}
+	}
+	return d
+}
+
+// Check rejects the post if it uses a denied word
+func (d *Denylist) Check(_ context.Context, post *models.PostRequest) (bool, string) {
+	for _, text := range []string{post.Title, post.Content} {
+		for _, word := range strings.FieldsFunc(strings.ToLower(text), isWordSeparator) {
+			if d.words[word] {
+				return false, fmt.Sprintf("Post contains a banned word: %q", word)
+			}
+		}
+	}
+	return true, ""
+}
+
+// isWordSeparator splits text into words of letters and digits
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// Check runs m on post, giving up after timeout; 0 means no limit. A
+// moderator that doesn't answer in time lets the post through, so a slow
+// external service can't hold up posting.
+func Check(ctx context.Context, m Moderator, post *models.PostRequest, timeout time.Duration) (bool, string) {
+	if timeout <= 0 {
+		return m.Check(ctx, post)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type verdict struct {
+		allowed bool
+		reason  string
+	}
+	done := make(chan verdict, 1)
+	go func() {
+		allowed, reason := m.Check(ctx, post)
+		done <- verdict{allowed, reason}
+	}()
+
+	select {
+	case v := <-done:
+		return v.allowed, v.reason
+	case <-ctx.Done():
+		log.Warn().Dur("timeout", timeout).Msg("Post moderation timed out, allowing the post")
+		return true, ""
+	}
+}
@@ -0,0 +1,50 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"blog-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDenylist(t *testing.T) {
+	d := NewDenylist([]string{"Casino", " spam "})
+
+	allowed, reason := d.Check(context.Background(), &models.PostRequest{Title: "Hello", Content: "Visit our CASINO today!"})
+	assert.False(t, allowed)
+	assert.Contains(t, reason, "casino")
+
+	allowed, _ = d.Check(context.Background(), &models.PostRequest{Title: "Spam, spam, spam", Content: "Eggs"})
+	assert.False(t, allowed)
+
+	// Only whole words are denied
+	allowed, reason = d.Check(context.Background(), &models.PostRequest{Title: "Spammers", Content: "Casinos of Monaco"})
+	assert.True(t, allowed)
+	assert.Empty(t, reason)
+}
+
+// slowModerator rejects everything, but only after its context ends
+type slowModerator struct{}
+
+func (slowModerator) Check(ctx context.Context, _ *models.PostRequest) (bool, string) {
+	<-ctx.Done()
+	time.Sleep(10 * time.Millisecond)
+	return false, "too late"
+}
+
+func TestCheckTimeout(t *testing.T) {
+	start := time.Now()
+	allowed, _ := Check(context.Background(), slowModerator{}, &models.PostRequest{}, 20*time.Millisecond)
+
+	assert.True(t, allowed, "a moderator that times out lets the post through")
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestCheckNoTimeout(t *testing.T) {
+	allowed, reason := Check(context.Background(), NewDenylist([]string{"spam"}), &models.PostRequest{Content: "spam"}, 0)
+	assert.False(t, allowed)
+	assert.NotEmpty(t, reason)
+}
@@ -0,0 +1,149 @@
+// Package notify sends email to users, such as password reset links.
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"blog-api/internal/clock"
+	"blog-api/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Notifier sends a plain-text email
+type Notifier interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// New returns the SMTP notifier configured in cfg, or Noop when no SMTP
+// server is configured
+func New(cfg *config.Config) Notifier {
+	if cfg.SMTPHost == "" {
+		log.Warn().Msg("SMTP_HOST is not set, emails will not be sent")
+		return Noop{}
+	}
+	return NewSMTP(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPass, cfg.SMTPFrom)
+}
+
+// Noop drops every email. It is used when no SMTP server is configured.
+type Noop struct{}
+
+// Send logs that the email was dropped. The body is left out of the log,
+// since it may hold a secret such as a reset token.
+func (Noop) Send(_ context.Context, _, subject, _ string) error {
+	log.Debug().Str("subject", subject).Msg("Email dropped, SMTP is not configured")
+	return nil
+}
+
+// smtpTimeout bounds a delivery when the caller's context has no deadline
+const smtpTimeout = 30 * time.Second
+
+// SMTP delivers email through an SMTP server, upgrading the connection with
+// STARTTLS when the server offers it
+type SMTP struct {
+	host     string
+	addr     string
+	username string
+	password string
+	from     string
+	clock    clock.Clock
+}
+
+// NewSMTP creates a notifier sending from from through host:port. Without a
+// username it doesn't authenticate.
+func NewSMTP(host string, port int, username, password, from string) *SMTP {
+	return &SMTP{
+		host:     host,
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		username: username,
+		password: password,
+		from:     from,
+		clock:    clock.Real{},
+	}
+}
+
+// Send delivers one email to to
+func (s *SMTP) Send(ctx context.Context, to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return errors.New("email recipient and subject must not contain line breaks")
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, smtpTimeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to greet SMTP server: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("failed to authenticate with SMTP server: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.from); err != nil {
+		return fmt.Errorf("SMTP server rejected sender: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("SMTP server rejected recipient: %w", err)
+	}
+
+	data, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start email data: %w", err)
+	}
+	if _, err := data.Write(s.message(to, subject, body)); err != nil {
+		data.Close()
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	if err := data.Close(); err != nil {
+		return fmt.Errorf("SMTP server rejected email: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// message formats an email with its headers and CRLF line endings
+func (s *SMTP) message(to, subject, body string) []byte {
+	var msg strings.Builder
+	msg.WriteString("From: " + s.from + "\r\n")
+	msg.WriteString("To: " + to + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	msg.WriteString("Date: " + s.clock.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return []byte(msg.String())
+}
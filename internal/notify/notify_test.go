@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"blog-api/internal/clock"
+	"blog-api/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receivedEmail is what fakeSMTPServer recorded of one delivery
+type receivedEmail struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTPServer accepts a single SMTP session on a local port and sends what
+// it received on the returned channel once the client quits
+func fakeSMTPServer(t *testing.T) (host string, port int, received <-chan receivedEmail) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan receivedEmail, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		var email receivedEmail
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch {
+			case verb == "EHLO" || verb == "HELO":
+				reply("250 localhost")
+			case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+				email.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+				email.to = append(email.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				reply("250 OK")
+			case verb == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				email.data = data.String()
+				reply("250 OK")
+			case verb == "QUIT":
+				reply("221 Bye")
+				ch <- email
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, ch
+}
+
+func TestSMTPSend(t *testing.T) {
+	host, port, received := fakeSMTPServer(t)
+	notifier := NewSMTP(host, port, "", "", "blog@example.com")
+	notifier.clock = clock.NewFake(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC))
+
+	err := notifier.Send(context.Background(), "alice@example.com", "Reset your password", "Hello Alice,\nfollow the link.")
+	require.NoError(t, err)
+
+	email := <-received
+	assert.Equal(t, "blog@example.com", email.from)
+	assert.Equal(t, []string{"alice@example.com"}, email.to)
+	assert.Contains(t, email.data, "To: alice@example.com\r\n")
+	assert.Contains(t, email.data, "Subject: Reset your password\r\n")
+	assert.Contains(t, email.data, "Date: Wed, 01 May 2024 12:30:00 +0000\r\n")
+	assert.Contains(t, email.data, "\r\n\r\nHello Alice,\r\nfollow the link.\r\n")
+}
+
+func TestSMTPSendRejectsHeaderInjection(t *testing.T) {
+	notifier := NewSMTP("127.0.0.1", 1, "", "", "blog@example.com")
+
+	for _, tc := range []struct{ to, subject string }{
+		{"alice@example.com\r\nBcc: mallory@example.com", "Hello"},
+		{"alice@example.com", "Hello\nBcc: mallory@example.com"},
+	} {
+		err := notifier.Send(context.Background(), tc.to, tc.subject, "body")
+		assert.Error(t, err, "to=%q subject=%q", tc.to, tc.subject)
+	}
+}
+
+func TestSMTPSendUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	notifier := NewSMTP("127.0.0.1", port, "", "", "blog@example.com")
+	err = notifier.Send(context.Background(), "alice@example.com", "Hello", "body")
+	assert.ErrorContains(t, err, "failed to connect to SMTP server")
+}
+
+func TestNew(t *testing.T) {
+	assert.Equal(t, Noop{}, New(&config.Config{}), "without SMTP_HOST emails should be dropped")
+	assert.NoError(t, Noop{}.Send(context.Background(), "alice@example.com", "Hello", "body"))
+
+	notifier := New(&config.Config{SMTPHost: "smtp.example.com", SMTPPort: 2525, SMTPFrom: "blog@example.com"})
+	require.IsType(t, &SMTP{}, notifier)
+	assert.Equal(t, "smtp.example.com:2525", notifier.(*SMTP).addr)
+}
@@ -0,0 +1,21 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupWithoutEndpoint(t *testing.T) {
+	shutdown, err := Setup(context.Background(), "", "blog-api", "dev")
+	require.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "noop")
+	assert.False(t, span.SpanContext().IsValid(), "spans should not be recorded without an endpoint")
+	span.End()
+
+	assert.NoError(t, shutdown(context.Background()))
+}
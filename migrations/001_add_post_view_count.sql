@@ -0,0 +1,2 @@
+-- Track how often each post has been viewed
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS view_count INTEGER NOT NULL DEFAULT 0;
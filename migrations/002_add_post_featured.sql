@@ -0,0 +1,4 @@
+-- Let editors pin posts to the homepage
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS featured BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS featured_order INTEGER;
+CREATE INDEX IF NOT EXISTS idx_posts_featured ON posts(featured_order, created_at) WHERE featured;
@@ -0,0 +1,2 @@
+-- Let moderators ban users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned BOOLEAN NOT NULL DEFAULT FALSE;
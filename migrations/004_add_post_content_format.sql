@@ -0,0 +1,4 @@
+-- Record how each post's content is written. Posts created before this
+-- column existed were stored as raw HTML, so they are backfilled as html.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_format VARCHAR(16) NOT NULL DEFAULT 'html'
+    CHECK (content_format IN ('markdown', 'html'));
@@ -0,0 +1,8 @@
+-- Reject blank post titles at the database level. NOT VALID skips checking
+-- existing rows so the migration can't fail on old data.
+DO $$
+BEGIN
+    ALTER TABLE posts ADD CONSTRAINT posts_title_check CHECK (btrim(title) <> '') NOT VALID;
+EXCEPTION
+    WHEN duplicate_object THEN NULL;
+END $$;
@@ -0,0 +1,6 @@
+-- Allow post content to be stored gzip-compressed. Existing posts keep their
+-- text in content and are marked plain; compressed posts leave content empty
+-- and hold their text in content_compressed.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_compressed BYTEA;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_encoding VARCHAR(16) NOT NULL DEFAULT 'plain'
+    CHECK (content_encoding IN ('plain', 'gzip'));
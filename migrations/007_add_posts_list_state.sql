@@ -0,0 +1,25 @@
+-- Track when the public posts list last changed, for Last-Modified on
+-- GET /api/posts. See schema.sql for why view counts are left out.
+CREATE TABLE IF NOT EXISTS posts_list_state (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO posts_list_state DEFAULT VALUES ON CONFLICT (id) DO NOTHING;
+
+CREATE OR REPLACE FUNCTION touch_posts_list_state() RETURNS trigger AS $$
+BEGIN
+    UPDATE posts_list_state SET changed_at = clock_timestamp();
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_list_posts_changed ON posts;
+CREATE TRIGGER posts_list_posts_changed
+    AFTER INSERT OR DELETE OR UPDATE OF title, content, content_compressed, content_encoding,
+        content_format, user_id, featured, featured_order, created_at ON posts
+    FOR EACH STATEMENT EXECUTE FUNCTION touch_posts_list_state();
+
+DROP TRIGGER IF EXISTS posts_list_users_changed ON users;
+CREATE TRIGGER posts_list_users_changed
+    AFTER DELETE OR UPDATE OF username, banned ON users
+    FOR EACH STATEMENT EXECUTE FUNCTION touch_posts_list_state();
@@ -0,0 +1,5 @@
+-- Denylist access tokens revoked by logout, when JWT_DENYLIST=true
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+    token_id VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
@@ -0,0 +1,20 @@
+-- Tag posts, for ?tag= on GET /api/posts. See schema.sql.
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (post_id, tag_id),
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_tags_tag_id ON post_tags(tag_id);
+
+DROP TRIGGER IF EXISTS posts_list_tags_changed ON post_tags;
+CREATE TRIGGER posts_list_tags_changed
+    AFTER INSERT OR DELETE OR UPDATE ON post_tags
+    FOR EACH STATEMENT EXECUTE FUNCTION touch_posts_list_state();
@@ -0,0 +1,14 @@
+-- Draft and published posts. See schema.sql.
+-- Posts that existed before drafts stay visible: they are added as published,
+-- as of their creation, and only new posts default to draft.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'published'));
+ALTER TABLE posts ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS published_at TIMESTAMP WITH TIME ZONE;
+UPDATE posts SET published_at = created_at WHERE status = 'published' AND published_at IS NULL;
+
+DROP TRIGGER IF EXISTS posts_list_posts_changed ON posts;
+CREATE TRIGGER posts_list_posts_changed
+    AFTER INSERT OR DELETE OR UPDATE OF title, content, content_compressed, content_encoding,
+        content_format, user_id, featured, featured_order, status, published_at, created_at ON posts
+    FOR EACH STATEMENT EXECUTE FUNCTION touch_posts_list_state();
@@ -0,0 +1,9 @@
+-- Soft-deleted posts, restorable by admins. See schema.sql.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+DROP TRIGGER IF EXISTS posts_list_posts_changed ON posts;
+CREATE TRIGGER posts_list_posts_changed
+    AFTER INSERT OR DELETE OR UPDATE OF title, content, content_compressed, content_encoding,
+        content_format, user_id, featured, featured_order, status, published_at, created_at,
+        deleted_at ON posts
+    FOR EACH STATEMENT EXECUTE FUNCTION touch_posts_list_state();
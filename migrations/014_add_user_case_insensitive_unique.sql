@@ -0,0 +1,10 @@
+-- Usernames and emails unique ignoring case. See schema.sql. Creating the
+-- indexes fails while users differ only in case; rename them first.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users (lower(username));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
+
+-- The case-sensitive constraints and lookup indexes are covered by the above
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+DROP INDEX IF EXISTS idx_users_username;
+DROP INDEX IF EXISTS idx_users_email;
@@ -0,0 +1,4 @@
+-- Schedule drafts for publishing. See schema.sql.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts(publish_at) WHERE publish_at IS NOT NULL;
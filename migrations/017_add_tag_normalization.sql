@@ -0,0 +1,24 @@
+-- Tags unique in their canonical form. See schema.sql.
+CREATE OR REPLACE FUNCTION normalize_tag(name TEXT) RETURNS TEXT AS $$
+    SELECT regexp_replace(regexp_replace(btrim(lower(name), E' \t\n\r\f\x0b'), '\s+', '-', 'g'), '-{2,}', '-', 'g')
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- Tags that only differ in form are merged into the oldest of them
+CREATE TEMPORARY TABLE tag_merges AS
+    SELECT id, min(id) OVER (PARTITION BY normalize_tag(name)) AS keep_id
+    FROM tags;
+
+INSERT INTO post_tags (post_id, tag_id)
+    SELECT pt.post_id, m.keep_id
+    FROM post_tags pt
+    JOIN tag_merges m ON m.id = pt.tag_id
+    WHERE m.id <> m.keep_id
+    ON CONFLICT DO NOTHING;
+
+DELETE FROM tags t USING tag_merges m WHERE t.id = m.id AND m.id <> m.keep_id;
+
+DROP TABLE tag_merges;
+
+UPDATE tags SET name = normalize_tag(name) WHERE name <> normalize_tag(name);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name_normalized ON tags (normalize_tag(name));
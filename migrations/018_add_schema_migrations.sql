@@ -0,0 +1,11 @@
+-- Record which migrations have been applied. See schema.sql.
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version VARCHAR(16) PRIMARY KEY,
+    applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The files before this one have been applied by now
+INSERT INTO schema_migrations (version) VALUES
+    ('001'), ('002'), ('003'), ('004'), ('005'), ('006'), ('007'), ('008'), ('009'),
+    ('010'), ('011'), ('012'), ('013'), ('014'), ('015'), ('016'), ('017'), ('018')
+ON CONFLICT DO NOTHING;
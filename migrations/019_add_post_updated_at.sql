@@ -0,0 +1,62 @@
+-- Compute Last-Modified on post lists from posts.updated_at rather than a
+-- single row every write updates. See schema.sql.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT clock_timestamp();
+
+CREATE INDEX IF NOT EXISTS idx_posts_updated_at ON posts(updated_at);
+CREATE INDEX IF NOT EXISTS idx_posts_user_id_updated_at ON posts(user_id, updated_at);
+
+CREATE OR REPLACE FUNCTION touch_post_updated_at() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at := clock_timestamp();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION touch_tagged_post() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'DELETE' THEN
+        UPDATE posts SET updated_at = clock_timestamp() WHERE id = NEW.post_id;
+    END IF;
+    IF TG_OP <> 'INSERT' THEN
+        UPDATE posts SET updated_at = clock_timestamp() WHERE id = OLD.post_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION touch_user_posts() RETURNS trigger AS $$
+BEGIN
+    UPDATE posts SET updated_at = clock_timestamp() WHERE user_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_list_posts_changed ON posts;
+DROP TRIGGER IF EXISTS posts_list_users_changed ON users;
+DROP TRIGGER IF EXISTS posts_list_tags_changed ON post_tags;
+
+DROP TRIGGER IF EXISTS posts_updated ON posts;
+CREATE TRIGGER posts_updated
+    BEFORE UPDATE OF title, content, content_compressed, content_encoding, content_format,
+        user_id, featured, featured_order, status, published_at, publish_at, created_at,
+        deleted_at ON posts
+    FOR EACH ROW EXECUTE FUNCTION touch_post_updated_at();
+
+DROP TRIGGER IF EXISTS post_tags_changed ON post_tags;
+CREATE TRIGGER post_tags_changed
+    AFTER INSERT OR DELETE OR UPDATE ON post_tags
+    FOR EACH ROW EXECUTE FUNCTION touch_tagged_post();
+
+DROP TRIGGER IF EXISTS users_shown_on_posts_changed ON users;
+CREATE TRIGGER users_shown_on_posts_changed
+    AFTER UPDATE OF username, banned ON users
+    FOR EACH ROW
+    WHEN (OLD.username IS DISTINCT FROM NEW.username OR OLD.banned IS DISTINCT FROM NEW.banned)
+    EXECUTE FUNCTION touch_user_posts();
+
+DROP TRIGGER IF EXISTS posts_list_posts_removed ON posts;
+CREATE TRIGGER posts_list_posts_removed
+    AFTER DELETE OR UPDATE OF user_id ON posts
+    FOR EACH STATEMENT EXECUTE FUNCTION touch_posts_list_state();
+
+INSERT INTO schema_migrations (version) VALUES ('019') ON CONFLICT DO NOTHING;
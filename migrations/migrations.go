@@ -0,0 +1,28 @@
+// Package migrations embeds the SQL files that bring an existing database up
+// to date with schema.sql, so the server knows which ones it expects
+package migrations
+
+import (
+	"embed"
+	"strings"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Versions returns the version of each migration, the number its file name
+// starts with, oldest first
+func Versions() []string {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		// The embedded directory always exists
+		panic(err)
+	}
+
+	versions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		version, _, _ := strings.Cut(entry.Name(), "_")
+		versions = append(versions, version)
+	}
+	return versions
+}
@@ -3,23 +3,205 @@
 -- Create users table
 CREATE TABLE users (
     id SERIAL PRIMARY KEY,
-    username VARCHAR(50) UNIQUE NOT NULL,
-    email VARCHAR(255) UNIQUE NOT NULL,
+    username VARCHAR(50) NOT NULL,
+    email VARCHAR(255) NOT NULL,
     password_hash VARCHAR(255) NOT NULL,
+    banned BOOLEAN NOT NULL DEFAULT FALSE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
 -- Create posts table
 CREATE TABLE posts (
     id SERIAL PRIMARY KEY,
-    title VARCHAR(255) NOT NULL,
+    title VARCHAR(255) NOT NULL CHECK (btrim(title) <> ''),
     content TEXT NOT NULL,
+    content_compressed BYTEA,
+    content_encoding VARCHAR(16) NOT NULL DEFAULT 'plain' CHECK (content_encoding IN ('plain', 'gzip')),
+    content_format VARCHAR(16) NOT NULL DEFAULT 'html' CHECK (content_format IN ('markdown', 'html')),
     user_id INTEGER NOT NULL,
+    view_count INTEGER NOT NULL DEFAULT 0,
+    featured BOOLEAN NOT NULL DEFAULT FALSE,
+    featured_order INTEGER,
+    -- Drafts are only shown to their author; published_at is set on publishing
+    status VARCHAR(16) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'published')),
+    published_at TIMESTAMP WITH TIME ZONE,
+    -- A draft with publish_at is published by the scheduler once it is due
+    publish_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    -- Set by DELETE /api/posts/{id}; deleted posts are hidden until restored
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    -- When anything a post list shows about the post last changed, kept
+    -- current by triggers, for Last-Modified on the lists
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT clock_timestamp(),
+    -- Full-text search over the title, weighted above the plain-text content.
+    -- Compressed content can't be indexed, so those posts match on title only.
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', title), 'A') ||
+        setweight(to_tsvector('english', content), 'B')
+    ) STORED,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Tags, stored lowercase. A tag left without posts is kept for reuse.
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) UNIQUE NOT NULL
+);
+
+CREATE TABLE post_tags (
+    post_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (post_id, tag_id),
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
+
+-- Refresh tokens issued at login. Only a SHA-256 hash of each token is kept.
+-- Tokens rotated from the same login share a family, so reuse of a rotated
+-- token can revoke the whole chain.
+CREATE TABLE refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL,
+    family VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Access tokens revoked by logout before their expiry, when JWT_DENYLIST=true.
+-- Rows are only needed until the token would have expired anyway.
+CREATE TABLE revoked_access_tokens (
+    token_id VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Tokens emailed by POST /api/auth/forgot-password. Like refresh tokens only
+-- their hash is stored, and each can be used once.
+CREATE TABLE password_resets (
+    id SERIAL PRIMARY KEY,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
 );
 
 -- Create indexes for better performance
 CREATE INDEX idx_posts_user_id ON posts(user_id);
-CREATE INDEX idx_users_username ON users(username);
-CREATE INDEX idx_users_email ON users(email);
+CREATE INDEX idx_posts_featured ON posts(featured_order, created_at) WHERE featured;
+CREATE INDEX idx_posts_search ON posts USING GIN (search_vector);
+CREATE INDEX idx_posts_publish_at ON posts(publish_at) WHERE publish_at IS NOT NULL;
+CREATE INDEX idx_post_tags_tag_id ON post_tags(tag_id);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family);
+CREATE INDEX idx_password_resets_user_id ON password_resets(user_id);
+
+-- Usernames and emails are unique ignoring case, so Alice can't also sign up
+-- as alice. Lookups by either go through lower() to use these.
+CREATE UNIQUE INDEX idx_users_username_lower ON users (lower(username));
+CREATE UNIQUE INDEX idx_users_email_lower ON users (lower(email));
+
+-- The canonical form of a tag, as NormalizeTag in internal/database/tags.go:
+-- lowercase and trimmed, internal whitespace turned into hyphens and repeated
+-- hyphens collapsed. Tags are unique in this form, so "Web Dev" and
+-- "web--dev" can't both exist.
+CREATE FUNCTION normalize_tag(name TEXT) RETURNS TEXT AS $$
+    SELECT regexp_replace(regexp_replace(btrim(lower(name), E' \t\n\r\f\x0b'), '\s+', '-', 'g'), '-{2,}', '-', 'g')
+$$ LANGUAGE SQL IMMUTABLE;
+
+CREATE UNIQUE INDEX idx_tags_name_normalized ON tags (normalize_tag(name));
+
+-- Post lists are Last-Modified at the newest updated_at among the posts they
+-- could show, or posts_list_state.changed_at if that is newer. Triggers keep
+-- updated_at current on every write path; view count increments deliberately
+-- don't count as a change, so polling clients aren't sent the whole list for
+-- every page view.
+CREATE INDEX idx_posts_updated_at ON posts(updated_at);
+CREATE INDEX idx_posts_user_id_updated_at ON posts(user_id, updated_at);
+
+CREATE FUNCTION touch_post_updated_at() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at := clock_timestamp();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Every posts column the lists show except view_count
+CREATE TRIGGER posts_updated
+    BEFORE UPDATE OF title, content, content_compressed, content_encoding, content_format,
+        user_id, featured, featured_order, status, published_at, publish_at, created_at,
+        deleted_at ON posts
+    FOR EACH ROW EXECUTE FUNCTION touch_post_updated_at();
+
+-- The lists show each post's tags
+CREATE FUNCTION touch_tagged_post() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'DELETE' THEN
+        UPDATE posts SET updated_at = clock_timestamp() WHERE id = NEW.post_id;
+    END IF;
+    IF TG_OP <> 'INSERT' THEN
+        UPDATE posts SET updated_at = clock_timestamp() WHERE id = OLD.post_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER post_tags_changed
+    AFTER INSERT OR DELETE OR UPDATE ON post_tags
+    FOR EACH ROW EXECUTE FUNCTION touch_tagged_post();
+
+-- The lists show author usernames and hide posts by banned users
+CREATE FUNCTION touch_user_posts() RETURNS trigger AS $$
+BEGIN
+    UPDATE posts SET updated_at = clock_timestamp() WHERE user_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_shown_on_posts_changed
+    AFTER UPDATE OF username, banned ON users
+    FOR EACH ROW
+    WHEN (OLD.username IS DISTINCT FROM NEW.username OR OLD.banned IS DISTINCT FROM NEW.banned)
+    EXECUTE FUNCTION touch_user_posts();
+
+-- A post that is removed, including by a cascading user delete, or moved to
+-- another author leaves no updated_at behind in the list it left, so these
+-- rare changes are recorded here instead
+CREATE TABLE posts_list_state (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO posts_list_state DEFAULT VALUES;
+
+CREATE FUNCTION touch_posts_list_state() RETURNS trigger AS $$
+BEGIN
+    UPDATE posts_list_state SET changed_at = clock_timestamp();
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER posts_list_posts_removed
+    AFTER DELETE OR UPDATE OF user_id ON posts
+    FOR EACH STATEMENT EXECUTE FUNCTION touch_posts_list_state();
+
+-- The files in migrations/ applied to this database. A fresh database is
+-- current, so it records them all; each new migration inserts its own version.
+CREATE TABLE schema_migrations (
+    version VARCHAR(16) PRIMARY KEY,
+    applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO schema_migrations (version) VALUES
+    ('001'), ('002'), ('003'), ('004'), ('005'), ('006'), ('007'), ('008'), ('009'),
+    ('010'), ('011'), ('012'), ('013'), ('014'), ('015'), ('016'), ('017'), ('018'),
+    ('019');
+
+-- Optional: unique post titles per author (case-insensitive), among posts
+-- that aren't deleted, so a deleted post's title can be reused.
+-- Applied automatically at startup when UNIQUE_TITLE_PER_USER=true; left out
+-- by default because existing data may already contain duplicate titles. An
+-- older index that also covered deleted posts is dropped and recreated.
+-- CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_user_title_unique ON posts (user_id, lower(title)) WHERE deleted_at IS NULL;